@@ -0,0 +1,97 @@
+// Command configdiff compares the OTG config a test pushed with the one the
+// OTG service returns from GetConfig, and lists the fields the service
+// dropped, reset to their default or changed.
+//
+//	configdiff pushed.json fetched.json
+//	configdiff -keys ../../SupportedAPIsList.txt pushed.json fetched.json
+//
+// With -keys it also prints the round-trip status of every Set/Get Config key
+// the pushed config sets. It exits with status 1 if the configs differ.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/SpirentOrion/stc-otg/example/gosnappi/configdiff"
+	"github.com/open-traffic-generator/snappi/gosnappi/otg"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+func main() {
+	keysPath := flag.String("keys", "", "SupportedAPIsList.txt to report the status of each config key from")
+	all := flag.Bool("all", false, "with -keys, also print the keys the pushed config does not set")
+	flag.Usage = func() {
+		fmt.Fprintln(flag.CommandLine.Output(), "usage: configdiff [-keys SupportedAPIsList.txt] [-all] pushed.json fetched.json")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 2 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	log.SetFlags(0)
+
+	want, err := readConfig(flag.Arg(0))
+	if err != nil {
+		log.Fatal(err)
+	}
+	got, err := readConfig(flag.Arg(1))
+	if err != nil {
+		log.Fatal(err)
+	}
+	changes, err := configdiff.DiffProto(want, got)
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, c := range changes {
+		fmt.Println(c)
+	}
+
+	if *keysPath != "" {
+		f, err := os.Open(*keysPath)
+		if err != nil {
+			log.Fatal(err)
+		}
+		keys, err := configdiff.ReadKeys(f)
+		f.Close()
+		if err != nil {
+			log.Fatal(err)
+		}
+		results, err := configdiff.Fidelity(keys, want, changes)
+		if err != nil {
+			log.Fatal(err)
+		}
+		counts := map[string]int{}
+		fmt.Println()
+		for _, r := range results {
+			status := r.Status()
+			counts[status]++
+			if *all || status != "not set" {
+				fmt.Printf("%-10s %s\n", status, r.Key)
+			}
+		}
+		fmt.Printf("\n%d keys: %d ok, %d dropped, %d defaulted, %d changed, %d not set\n",
+			len(results), counts["ok"], counts["dropped"], counts["defaulted"], counts["changed"], counts["not set"])
+	}
+
+	if len(changes) > 0 {
+		os.Exit(1)
+	}
+}
+
+// readConfig reads a config as JSON, as is, without validating it or filling
+// in defaults.
+func readConfig(path string) (*otg.Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	config := &otg.Config{}
+	if err := (protojson.UnmarshalOptions{DiscardUnknown: true}).Unmarshal(data, config); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	return config, nil
+}
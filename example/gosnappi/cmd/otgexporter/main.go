@@ -0,0 +1,39 @@
+// Command otgexporter serves the metrics of an OTG service to Prometheus.
+//
+//	otgexporter -otg localhost:50051 -listen :9464 -interval 10s
+//
+// Point a Prometheus scrape job at http://<host>:9464/metrics.
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/SpirentOrion/stc-otg/example/gosnappi/exporter"
+	"github.com/open-traffic-generator/snappi/gosnappi"
+)
+
+func main() {
+	location := flag.String("otg", "localhost:50051", "gRPC address of the OTG service")
+	listen := flag.String("listen", ":9464", "address to serve /metrics on")
+	interval := flag.Duration("interval", 10*time.Second, "how often to collect metrics")
+	flag.Parse()
+
+	api := gosnappi.NewApi()
+	api.NewGrpcTransport().
+		SetLocation(*location).
+		SetDialTimeout(time.Minute).
+		SetRequestTimeout(time.Minute)
+
+	e := exporter.New(api)
+	e.Interval = *interval
+	e.Logf = log.Printf
+	go e.Run(context.Background())
+
+	http.Handle("/metrics", e)
+	log.Printf("serving metrics of %s on %s/metrics", *location, *listen)
+	log.Fatal(http.ListenAndServe(*listen, nil))
+}
@@ -0,0 +1,49 @@
+// Command otgsim runs the simulated OTG service, e.g. as the otg target of an
+// Ondatra binding when no chassis is available.
+//
+//	otgsim -listen :50051 -maxrate 80 -latency 10us
+//
+// With -testbed, the links of an Ondatra testbed cable the ports named by its
+// port IDs back-to-back, e.g. for LAG tests:
+//
+//	otgsim -testbed b2b_1ate_4links.testbed
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+
+	"github.com/SpirentOrion/stc-otg/example/gosnappi/otgsim"
+)
+
+func main() {
+	listen := flag.String("listen", ":50051", "address to serve the OTG gRPC API on")
+	timeScale := flag.Float64("timescale", 1, "how many times faster than wall clock time simulated time runs")
+	maxRate := flag.Float64("maxrate", 100, "load in percent of line rate the DUT forwards without loss")
+	latency := flag.Duration("latency", 0, "forwarding latency of the idle DUT")
+	jitter := flag.Duration("jitter", 0, "extra queueing latency of the DUT at full load")
+	buffer := flag.Float64("buffer", 0, "frames of a flow the DUT buffers above maxrate")
+	testbed := flag.String("testbed", "", "Ondatra testbed file whose links cable ports back-to-back")
+	flag.Parse()
+
+	sim := otgsim.New()
+	sim.TimeScale = *timeScale
+	sim.Dut = otgsim.Dut{MaxRate: *maxRate, Latency: *latency, Jitter: *jitter, Buffer: *buffer}
+	if *testbed != "" {
+		f, err := os.Open(*testbed)
+		if err != nil {
+			log.Fatal(err)
+		}
+		sim.Links, err = otgsim.ReadTestbedLinks(f)
+		f.Close()
+		if err != nil {
+			log.Fatalf("%s: %v", *testbed, err)
+		}
+	}
+	if err := sim.Start(*listen); err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("simulated OTG service listening on %s", sim.Location())
+	select {}
+}
@@ -0,0 +1,277 @@
+// Package configdiff compares OTG configs field by field, matching ports,
+// devices, flows, LAGs and other named objects by name rather than list
+// index. Comparing the config a test pushed with the one GetConfig returns
+// shows the fields the OTG service dropped, reset to their default or
+// changed:
+//
+//	flows[f1].rate.pps: 2000 -> 1000 (defaulted)
+//	devices[d1].isis.interfaces[i1].l2_settings.hello_interval: 3 -> (none) (dropped)
+package configdiff
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/open-traffic-generator/snappi/gosnappi"
+	"github.com/open-traffic-generator/snappi/gosnappi/otg"
+)
+
+// Kind is the kind of a difference.
+type Kind int
+
+const (
+	// Changed is a field with a different value.
+	Changed Kind = iota
+	// Added is a field or object only the second config has.
+	Added
+	// Removed is a list item, e.g. a flow, only the first config has.
+	Removed
+	// Dropped is a field the first config sets to a non-default value and
+	// the second one lacks.
+	Dropped
+	// Defaulted is a field the second config has at its default value
+	// instead of the value of the first.
+	Defaulted
+)
+
+func (k Kind) String() string {
+	switch k {
+	case Added:
+		return "added"
+	case Removed:
+		return "removed"
+	case Dropped:
+		return "dropped"
+	case Defaulted:
+		return "defaulted"
+	}
+	return "changed"
+}
+
+// Change is a difference between two configs.
+type Change struct {
+	// Path is the field, with named list items by name and others by index,
+	// e.g. flows[f1].packet[1].ipv4.src.value.
+	Path string
+	// Key is the field as keyed in SupportedAPIsList.txt, e.g.
+	// flows.{i}.packet.{i}.ipv4.src.value.
+	Key  string
+	Kind Kind
+	// Want and Got are the values in the first and second config, as bare
+	// strings and numbers or compact JSON, "(none)" if missing.
+	Want string
+	Got  string
+}
+
+func (c Change) String() string {
+	s := fmt.Sprintf("%s: %s -> %s", c.Path, c.Want, c.Got)
+	if c.Kind == Dropped || c.Kind == Defaulted {
+		s += " (" + c.Kind.String() + ")"
+	}
+	return s
+}
+
+// Diff returns the differences between two configs, with the defaults of
+// both filled in, in path order.
+func Diff(want, got gosnappi.Config) ([]Change, error) {
+	w, err := want.Marshal().ToProto()
+	if err != nil {
+		return nil, err
+	}
+	g, err := got.Marshal().ToProto()
+	if err != nil {
+		return nil, err
+	}
+	return DiffProto(w, g)
+}
+
+// DiffProto returns the differences between the config pushed, want, with
+// its defaults filled in, and got as it is, e.g. as read back from an OTG
+// service, in path order. Fields of want at their default value that got
+// lacks are not differences.
+func DiffProto(want, got *otg.Config) ([]Change, error) {
+	if c, err := gosnappi.NewConfig().Unmarshal().FromProto(want); err == nil {
+		if full, err := c.Marshal().ToProto(); err == nil {
+			want = full
+		}
+	}
+	wt, err := toTree(want)
+	if err != nil {
+		return nil, err
+	}
+	gt, err := toTree(got)
+	if err != nil {
+		return nil, err
+	}
+	var dt any
+	if d, _, err := defaults(want); err == nil {
+		if dt, err = toTree(d); err != nil {
+			return nil, err
+		}
+	}
+	changes := []Change{}
+	walk("", "", wt, gt, dt, &changes)
+	return changes, nil
+}
+
+// walk adds the differences between want and got at path to out. def is the
+// default value at path, nil if unknown.
+func walk(path, key string, want, got, def any, out *[]Change) {
+	add := func(kind Kind) {
+		*out = append(*out, Change{Path: path, Key: key, Kind: kind, Want: format(want), Got: format(got)})
+	}
+	switch w := want.(type) {
+	case map[string]any:
+		g, ok := got.(map[string]any)
+		if got == nil {
+			// Report the fields of a missing object one by one.
+			g, ok = map[string]any{}, true
+		}
+		if !ok {
+			break
+		}
+		d, _ := def.(map[string]any)
+		keys := []string{}
+		for k := range w {
+			keys = append(keys, k)
+		}
+		for k := range g {
+			if _, ok := w[k]; !ok {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			walk(join(path, k), join(key, k), w[k], g[k], d[k], out)
+		}
+		return
+	case []any:
+		if got == nil {
+			if _, objects := first(w).(map[string]any); !objects {
+				// SupportedAPIsList.txt keys lists of values by item.
+				key += ".{i}"
+			}
+			add(Dropped)
+			return
+		}
+		g, ok := got.([]any)
+		if !ok {
+			break
+		}
+		d, _ := def.([]any)
+		kw, kg, kd := byName(w), byName(g), byName(d)
+		if kw == nil || kg == nil {
+			kw, kg, kd = byIndex(w), byIndex(g), byIndex(d)
+		}
+		keys := []string{}
+		seen := map[string]bool{}
+		for _, k := range append(kw.order, kg.order...) {
+			if !seen[k] {
+				seen[k] = true
+				keys = append(keys, k)
+			}
+		}
+		for _, k := range keys {
+			p := fmt.Sprintf("%s[%s]", path, k)
+			switch wi, gi := kw.items[k], kg.items[k]; {
+			case gi == nil:
+				*out = append(*out, Change{Path: p, Key: key + ".{i}", Kind: Removed, Want: format(wi), Got: format(nil)})
+			case wi == nil:
+				*out = append(*out, Change{Path: p, Key: key + ".{i}", Kind: Added, Want: format(nil), Got: format(gi)})
+			default:
+				var di any
+				if kd != nil {
+					di = kd.items[k]
+				}
+				walk(p, key+".{i}", wi, gi, di, out)
+			}
+		}
+		return
+	case nil:
+		if got != nil {
+			add(Added)
+		}
+		return
+	}
+	switch {
+	case got == nil && (def == nil || !equal(want, def)):
+		add(Dropped)
+	case got == nil || equal(want, got):
+	case def != nil && equal(got, def):
+		add(Defaulted)
+	default:
+		add(Changed)
+	}
+}
+
+func first(list []any) any {
+	if len(list) == 0 {
+		return nil
+	}
+	return list[0]
+}
+
+func join(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+// keyed is a list by key, in list order.
+type keyed struct {
+	order []string
+	items map[string]any
+}
+
+// byName returns the items of a list of objects with unique names by name,
+// nil if any item has no name.
+func byName(list []any) *keyed {
+	k := &keyed{items: map[string]any{}}
+	for _, item := range list {
+		obj, ok := item.(map[string]any)
+		if !ok {
+			return nil
+		}
+		name, ok := obj["name"].(string)
+		if _, dup := k.items[name]; !ok || dup {
+			return nil
+		}
+		k.order = append(k.order, name)
+		k.items[name] = item
+	}
+	return k
+}
+
+func byIndex(list []any) *keyed {
+	k := &keyed{items: map[string]any{}}
+	for i, item := range list {
+		key := fmt.Sprint(i)
+		k.order = append(k.order, key)
+		k.items[key] = item
+	}
+	return k
+}
+
+func equal(a, b any) bool {
+	x, _ := json.Marshal(a)
+	y, _ := json.Marshal(b)
+	return bytes.Equal(x, y)
+}
+
+// format returns a JSON value for a diff line: strings and numbers bare,
+// objects and lists as compact JSON, missing values as (none).
+func format(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return "(none)"
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	}
+	data, _ := json.Marshal(v)
+	return string(data)
+}
@@ -0,0 +1,174 @@
+package configdiff
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/open-traffic-generator/snappi/gosnappi"
+	"github.com/open-traffic-generator/snappi/gosnappi/otg"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// flowConfig returns two ports and a flow between them at 2000 pps of 512
+// byte frames.
+func flowConfig() gosnappi.Config {
+	config := gosnappi.NewConfig()
+	config.Ports().Add().SetName("p1").SetLocation("10.1.1.1/1/1")
+	config.Ports().Add().SetName("p2").SetLocation("10.1.1.1/1/2")
+	flow := config.Flows().Add().SetName("f1")
+	flow.TxRx().Port().SetTxName("p1").SetRxNames([]string{"p2"})
+	flow.Rate().SetPps(2000)
+	flow.Size().SetFixed(512)
+	flow.Packet().Add().Ethernet().Src().SetValue("02:00:01:01:01:01")
+	return config
+}
+
+func TestNormalize(t *testing.T) {
+	data, err := Normalize(flowConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := string(data)
+	for _, want := range []string{`"name": "f1"`, `"pps": "2000"`, `"fixed": 512`} {
+		if !strings.Contains(got, want) {
+			t.Errorf("Normalize() got no %s in:\n%s", want, got)
+		}
+	}
+	for _, defaulted := range []string{`"priority"`, `"packets"`} {
+		if strings.Contains(got, defaulted) {
+			t.Errorf("Normalize() kept the default %s in:\n%s", defaulted, got)
+		}
+	}
+
+	// The stripped config reads back as the same config.
+	back := gosnappi.NewConfig()
+	if err := back.Unmarshal().FromJson(got); err != nil {
+		t.Fatal(err)
+	}
+	if changes, err := Diff(flowConfig(), back); err != nil || len(changes) > 0 {
+		t.Errorf("Diff() of the normalized config got %v, %v, want no differences", changes, err)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	got := flowConfig()
+	got.Flows().Items()[0].Rate().SetPps(3000)
+	// A new flow ahead of the existing one is matched by name, not index.
+	flows := got.Flows().Items()
+	got.Flows().Clear()
+	got.Flows().Add().SetName("extra").TxRx().Port().SetTxName("p1")
+	got.Flows().Append(flows...)
+
+	changes, err := Diff(flowConfig(), got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 2 {
+		t.Fatalf("Diff() got %v, want 2 changes", changes)
+	}
+	if c := changes[0]; c.String() != "flows[f1].rate.pps: 2000 -> 3000" || c.Key != "flows.{i}.rate.pps" || c.Kind != Changed {
+		t.Errorf("Diff() got %q (%s, %s), want the changed rate", c, c.Key, c.Kind)
+	}
+	if c := changes[1]; c.Path != "flows[extra]" || c.Kind != Added || !strings.HasPrefix(c.Got, "{") {
+		t.Errorf("Diff() got %q (%s), want the extra flow", c, c.Kind)
+	}
+}
+
+func TestDiffProto(t *testing.T) {
+	msg, err := flowConfig().Marshal().ToProto()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The service reset the rate to its default, lost the frame size and
+	// the receive ports.
+	got := proto.Clone(msg).(*otg.Config)
+	pps := uint64(1000)
+	got.Flows[0].Rate.Pps = &pps
+	got.Flows[0].Size.Fixed = nil
+	got.Flows[0].TxRx.Port.RxNames = nil
+
+	changes, err := DiffProto(msg, got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"flows[f1].rate.pps: 2000 -> 1000 (defaulted)",
+		"flows[f1].size.fixed: 512 -> (none) (dropped)",
+		`flows[f1].tx_rx.port.rx_names: ["p2"] -> (none) (dropped)`,
+	}
+	if len(changes) == 3 && changes[2].Key != "flows.{i}.tx_rx.port.rx_names.{i}" {
+		t.Errorf("DiffProto() got key %s for the dropped names", changes[2].Key)
+	}
+	lines := []string{}
+	for _, c := range changes {
+		lines = append(lines, c.String())
+	}
+	if strings.Join(lines, "\n") != strings.Join(want, "\n") {
+		t.Errorf("DiffProto() got\n%s\nwant\n%s", strings.Join(lines, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestFidelity(t *testing.T) {
+	list := strings.Join([]string{
+		"Set/Get Config\tRequest\tflows.{i}.rate.pps",
+		"Set/Get Config\tRequest\tflows.{i}.size.fixed",
+		"Set/Get Config\tRequest\tflows.{i}.metrics.enable",
+		"Set/Get Config\tRequest\tports.{i}.location",
+		"Set/Get Config\tRequest\tdevices.ospfv2\t\tdevices{i}.ospfv2.name",
+		"Get Metrics\tResponse\tport_metrics.{i}.frames_tx",
+		"",
+	}, "\n")
+	keys, err := ReadKeys(strings.NewReader(list))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 5 || keys[4] != "devices.{i}.ospfv2.name" {
+		t.Fatalf("ReadKeys() got %v, want the 5 config keys", keys)
+	}
+
+	msg, err := flowConfig().Marshal().ToProto()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := proto.Clone(msg).(*otg.Config)
+	got.Flows[0].Size.Fixed = nil
+	changes, err := DiffProto(msg, got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	results, err := Fidelity(keys, msg, changes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"flows.{i}.rate.pps":       "ok",
+		"flows.{i}.size.fixed":     "dropped",
+		"flows.{i}.metrics.enable": "not set",
+		"ports.{i}.location":       "ok",
+		"devices.{i}.ospfv2.name":  "not set",
+	}
+	for _, r := range results {
+		if r.Status() != want[r.Key] {
+			t.Errorf("Fidelity() got %s for %s, want %s", r.Status(), r.Key, want[r.Key])
+		}
+	}
+}
+
+func TestMissingFields(t *testing.T) {
+	// A flow without a name and a port without a name miss required fields.
+	msg := &otg.Config{Ports: []*otg.Port{{}}, Flows: []*otg.Flow{{}}}
+	_, err := gosnappi.NewConfig().Unmarshal().FromProto(msg)
+	if err == nil {
+		t.Fatal("validating a config without names should fail")
+	}
+	missing := MissingFields(err)
+	for _, fd := range []protoreflect.FieldDescriptor{
+		msg.Ports[0].ProtoReflect().Descriptor().Fields().ByName("name"),
+		msg.Flows[0].ProtoReflect().Descriptor().Fields().ByName("name"),
+	} {
+		if !missing[FieldKey(fd)] {
+			t.Errorf("MissingFields() got %v, want %s", missing, FieldKey(fd))
+		}
+	}
+}
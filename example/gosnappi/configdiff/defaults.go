@@ -0,0 +1,156 @@
+package configdiff
+
+import (
+	"bytes"
+	"encoding/json"
+	"regexp"
+	"strings"
+
+	"github.com/open-traffic-generator/snappi/gosnappi"
+	"github.com/open-traffic-generator/snappi/gosnappi/otg"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// Normalize returns config as indented JSON with sorted keys and without the
+// optional fields that are at their default value.
+func Normalize(config gosnappi.Config) ([]byte, error) {
+	tree, err := stripped(config)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(tree); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// stripped returns config as decoded JSON without the optional fields that are
+// at their default value.
+func stripped(config gosnappi.Config) (any, error) {
+	msg, err := config.Marshal().ToProto()
+	if err != nil {
+		return nil, err
+	}
+	msg = proto.Clone(msg).(*otg.Config)
+	if defaults, required, err := defaults(msg); err == nil {
+		strip(msg.ProtoReflect(), defaults.ProtoReflect(), required)
+	}
+	return toTree(msg)
+}
+
+// requiredField matches the gosnappi validation error of a missing required
+// field, e.g. "Name is required field on interface Port".
+var requiredField = regexp.MustCompile(`(\w+) is required field on interface (\w+)`)
+
+// MissingFields returns the keys of the required fields a gosnappi validation
+// error reports missing, as FieldKey returns them, e.g. Port.name.
+func MissingFields(err error) map[string]bool {
+	missing := map[string]bool{}
+	for _, m := range requiredField.FindAllStringSubmatch(err.Error(), -1) {
+		missing[m[2]+"."+strings.ToLower(m[1])] = true
+	}
+	return missing
+}
+
+// defaults returns the config with the structure and required fields of msg
+// and every optional field at its default value, as gosnappi fills them in
+// when validating, and the keys of the required fields. OTG protos mark
+// required fields optional too, so they are found from the validation errors
+// of missing fields.
+func defaults(msg *otg.Config) (*otg.Config, map[string]bool, error) {
+	required := map[string]bool{}
+	for {
+		skel := proto.Clone(msg).(*otg.Config)
+		clearOptional(skel.ProtoReflect(), required)
+		config, err := gosnappi.NewConfig().Unmarshal().FromProto(skel)
+		if err == nil {
+			d, err := config.Marshal().ToProto()
+			return d, required, err
+		}
+		found := false
+		for key := range MissingFields(err) {
+			if !required[key] {
+				required[key] = true
+				found = true
+			}
+		}
+		if !found {
+			return nil, nil, err
+		}
+	}
+}
+
+// FieldKey returns the key of a field as in MissingFields, e.g. Port.name.
+func FieldKey(fd protoreflect.FieldDescriptor) string {
+	return string(fd.ContainingMessage().Name()) + "." + strings.ReplaceAll(string(fd.Name()), "_", "")
+}
+
+// clearOptional clears the scalar fields of m and the messages in it, other
+// than the required ones.
+func clearOptional(m protoreflect.Message, required map[string]bool) {
+	unset := []protoreflect.FieldDescriptor{}
+	m.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		switch {
+		case fd.IsList() && fd.Message() != nil:
+			for i := 0; i < v.List().Len(); i++ {
+				clearOptional(v.List().Get(i).Message(), required)
+			}
+		case fd.Message() != nil && !fd.IsMap():
+			clearOptional(v.Message(), required)
+		case fd.HasPresence() && !required[FieldKey(fd)]:
+			unset = append(unset, fd)
+		}
+		return true
+	})
+	for _, fd := range unset {
+		m.Clear(fd)
+	}
+}
+
+// strip clears the optional scalar fields of m that have the value they have
+// in defaults, the same message with defaults filled in.
+func strip(m, defaults protoreflect.Message, required map[string]bool) {
+	unset := []protoreflect.FieldDescriptor{}
+	m.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		if !defaults.Has(fd) {
+			return true
+		}
+		d := defaults.Get(fd)
+		switch {
+		case fd.IsList() && fd.Message() != nil:
+			if v.List().Len() == d.List().Len() {
+				for i := 0; i < v.List().Len(); i++ {
+					strip(v.List().Get(i).Message(), d.List().Get(i).Message(), required)
+				}
+			}
+		case fd.Message() != nil && !fd.IsMap():
+			strip(v.Message(), d.Message(), required)
+		case fd.HasPresence() && !required[FieldKey(fd)] && v.Equal(d):
+			unset = append(unset, fd)
+		}
+		return true
+	})
+	for _, fd := range unset {
+		m.Clear(fd)
+	}
+}
+
+// toTree returns msg as decoded JSON, with numbers kept as json.Number.
+func toTree(msg proto.Message) (any, error) {
+	data, err := protojson.MarshalOptions{UseProtoNames: true}.Marshal(msg)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var tree any
+	if err := dec.Decode(&tree); err != nil {
+		return nil, err
+	}
+	return tree, nil
+}
@@ -0,0 +1,106 @@
+package configdiff
+
+import (
+	"bufio"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/open-traffic-generator/snappi/gosnappi"
+	"github.com/open-traffic-generator/snappi/gosnappi/otg"
+)
+
+// configMethod is the method of the config keys in SupportedAPIsList.txt.
+const configMethod = "Set/Get Config"
+
+// gluedIndex matches a list index without the dot before it, as in the
+// devices{i}.ospfv2 keys.
+var gluedIndex = regexp.MustCompile(`(\w)\{i\}`)
+
+// ReadKeys returns the Set/Get Config keys of a SupportedAPIsList.txt, e.g.
+// flows.{i}.rate.pps, in file order. The key is the last field of a line;
+// lines that group keys by object, like
+// "Set/Get Config\tRequest\tdevices.ospfv2\t\tdevices{i}.ospfv2.name", get
+// the missing dot before the index.
+func ReadKeys(rd io.Reader) ([]string, error) {
+	keys := []string{}
+	sc := bufio.NewScanner(rd)
+	for sc.Scan() {
+		fields := strings.Split(strings.TrimSpace(sc.Text()), "\t")
+		if len(fields) >= 3 && fields[0] == configMethod {
+			key := strings.TrimSpace(fields[len(fields)-1])
+			keys = append(keys, gluedIndex.ReplaceAllString(key, "$1.{i}"))
+		}
+	}
+	return keys, sc.Err()
+}
+
+// Result is the round-trip fidelity of a config key.
+type Result struct {
+	Key string
+	// Set is whether the pushed config sets the key to a non-default value.
+	Set bool
+	// Changes are the differences at the key.
+	Changes []Change
+}
+
+// Status returns "not set" for keys the pushed config leaves at their
+// default and that did not change, "ok" for other keys without changes, and
+// the kind of the first change otherwise.
+func (r Result) Status() string {
+	switch {
+	case len(r.Changes) > 0:
+		return r.Changes[0].Kind.String()
+	case !r.Set:
+		return "not set"
+	}
+	return "ok"
+}
+
+// Fidelity returns the result of each key for pushing want and reading back a
+// config with the given changes, e.g. from DiffProto.
+func Fidelity(keys []string, want *otg.Config, changes []Change) ([]Result, error) {
+	c, err := gosnappi.NewConfig().Unmarshal().FromProto(want)
+	if err != nil {
+		return nil, err
+	}
+	set := map[string]bool{}
+	if err := SetKeys(c, set); err != nil {
+		return nil, err
+	}
+	byKey := map[string][]Change{}
+	for _, ch := range changes {
+		byKey[ch.Key] = append(byKey[ch.Key], ch)
+	}
+	results := []Result{}
+	for _, key := range keys {
+		results = append(results, Result{Key: key, Set: set[key], Changes: byKey[key]})
+	}
+	return results, nil
+}
+
+// SetKeys adds the keys of the fields config sets to non-default values to
+// keys.
+func SetKeys(config gosnappi.Config, keys map[string]bool) error {
+	tree, err := stripped(config)
+	if err != nil {
+		return err
+	}
+	leafKeys("", tree, keys)
+	return nil
+}
+
+func leafKeys(key string, v any, keys map[string]bool) {
+	switch v := v.(type) {
+	case map[string]any:
+		for k, child := range v {
+			leafKeys(join(key, k), child, keys)
+		}
+	case []any:
+		for _, item := range v {
+			leafKeys(key+".{i}", item, keys)
+		}
+	default:
+		keys[key] = true
+	}
+}
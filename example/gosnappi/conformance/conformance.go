@@ -0,0 +1,128 @@
+// Package conformance checks that every Set/Get Config key of
+// SupportedAPIsList.txt survives SetConfig and GetConfig on an OTG service.
+// For each key it pushes a minimal config setting the field to a non-default
+// value, reads the config back and compares the field with configdiff. The
+// results form a coverage matrix per OTG service build, one tab-separated
+// line per key, so matrices of two releases can be compared with diff:
+//
+//	# otgservice.V1.3.53
+//	flows.{i}.rate.pps	ok
+//	flows.{i}.size.fixed	dropped	flows[flow1].size.fixed: 512 -> (none)
+package conformance
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/SpirentOrion/stc-otg/example/gosnappi/configdiff"
+	"github.com/open-traffic-generator/snappi/gosnappi"
+)
+
+// Statuses of a key besides the configdiff change kinds.
+const (
+	// OK is a key whose field reads back as pushed.
+	OK = "ok"
+	// Unsupported is a key no valid config could be generated for.
+	Unsupported = "unsupported"
+	// Rejected is a key whose config SetConfig failed with.
+	Rejected = "rejected"
+	// Failed is a key whose config could not be read back.
+	Failed = "failed"
+)
+
+// Result is the round-trip status of a config key.
+type Result struct {
+	Key string
+	// Status is OK, Unsupported, Rejected, Failed or the kind of the first
+	// change to the field, e.g. "dropped".
+	Status string
+	// Detail is the error or the change, "" for OK.
+	Detail string
+}
+
+// Suite pushes the configs of config keys to an OTG service.
+type Suite struct {
+	Api gosnappi.Api
+	// Locations of the two test ports of the configs.
+	Locations []string
+}
+
+// Check returns the round-trip status of a Set/Get Config key.
+func (s *Suite) Check(key string) Result {
+	config, err := Generate(key, s.Locations)
+	if err != nil {
+		return Result{Key: key, Status: Unsupported, Detail: oneLine(err)}
+	}
+	want, err := config.Marshal().ToProto()
+	if err != nil {
+		return Result{Key: key, Status: Unsupported, Detail: oneLine(err)}
+	}
+	if _, err := s.Api.SetConfig(config); err != nil {
+		return Result{Key: key, Status: Rejected, Detail: oneLine(err)}
+	}
+	got, err := s.Api.GetConfig()
+	if err != nil {
+		return Result{Key: key, Status: Failed, Detail: oneLine(err)}
+	}
+	gotMsg, err := got.Marshal().ToProto()
+	if err != nil {
+		return Result{Key: key, Status: Failed, Detail: oneLine(err)}
+	}
+	changes, err := configdiff.DiffProto(want, gotMsg)
+	if err != nil {
+		return Result{Key: key, Status: Failed, Detail: oneLine(err)}
+	}
+	for _, c := range changes {
+		// A change to the field or an object it is in.
+		if c.Key == key || strings.HasPrefix(key, c.Key+".") {
+			return Result{Key: key, Status: c.Kind.String(), Detail: fmt.Sprintf("%s: %s -> %s", c.Path, c.Want, c.Got)}
+		}
+	}
+	return Result{Key: key, Status: OK}
+}
+
+// Build returns the application version of the OTG service, e.g.
+// otgservice.V1.3.53, or "unknown".
+func Build(api gosnappi.Api) string {
+	v, err := api.GetVersion()
+	if err != nil || v.AppVersion() == "" {
+		return "unknown"
+	}
+	return v.AppVersion()
+}
+
+// WriteMatrix writes the coverage matrix of a build: a "# build" line, a line
+// per result and a "# " line with the number of keys per status.
+func WriteMatrix(w io.Writer, build string, results []Result) error {
+	if _, err := fmt.Fprintf(w, "# %s\n", build); err != nil {
+		return err
+	}
+	counts := map[string]int{}
+	for _, r := range results {
+		counts[r.Status]++
+		line := r.Key + "\t" + r.Status
+		if r.Detail != "" {
+			line += "\t" + r.Detail
+		}
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+	statuses := []string{}
+	for status := range counts {
+		statuses = append(statuses, status)
+	}
+	sort.Strings(statuses)
+	summary := []string{}
+	for _, status := range statuses {
+		summary = append(summary, fmt.Sprintf("%d %s", counts[status], status))
+	}
+	_, err := fmt.Fprintf(w, "# %d keys: %s\n", len(results), strings.Join(summary, ", "))
+	return err
+}
+
+func oneLine(err error) string {
+	return strings.Join(strings.Fields(err.Error()), " ")
+}
@@ -0,0 +1,142 @@
+package conformance
+
+import (
+	"context"
+	"net"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/SpirentOrion/stc-otg/example/gosnappi/configdiff"
+	"github.com/SpirentOrion/stc-otg/example/gosnappi/otgsim"
+	"github.com/SpirentOrion/stc-otg/example/gosnappi/otgsimtest"
+	"github.com/open-traffic-generator/snappi/gosnappi"
+	"github.com/open-traffic-generator/snappi/gosnappi/otg"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+var locations = []string{"10.1.1.1/1/1", "10.1.1.1/1/2"}
+
+func TestGenerate(t *testing.T) {
+	for _, tc := range []struct {
+		key  string
+		want []string
+	}{
+		// One more than the default.
+		{"flows.{i}.rate.pps", []string{`"pps": "1001"`, `"tx_name": "p1"`}},
+		{"flows.{i}.packet.{i}.ipv4.src.value", []string{`"ethernet": {}`, `"value": "0.0.0.1"`}},
+		{"devices.{i}.bgp.ipv4_interfaces.{i}.peers.{i}.advanced.hold_time_interval", []string{`"hold_time_interval": 91`, `"ipv4_name": "d1Ipv4"`}},
+		// No default, so any value.
+		{"ports.{i}.location", []string{`"location": "10.1.1.1/1/1"`}},
+		{"layer1.{i}.port_names.{i}", []string{`"port_names": [`, `"p1"`}},
+	} {
+		config, err := Generate(tc.key, locations)
+		if err != nil {
+			t.Errorf("Generate(%s) failed: %v", tc.key, err)
+			continue
+		}
+		data, err := configdiff.Normalize(config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, want := range tc.want {
+			if !strings.Contains(string(data), want) {
+				t.Errorf("Generate(%s) got no %s in:\n%s", tc.key, want, data)
+			}
+		}
+	}
+	if _, err := Generate("flows.{i}.rate.nonsense", locations); err == nil {
+		t.Error("Generate() of an unknown field succeeded")
+	}
+}
+
+func readKeys(t *testing.T) []string {
+	f, err := os.Open("../../../SupportedAPIsList.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	keys, err := configdiff.ReadKeys(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return keys
+}
+
+func TestCheck(t *testing.T) {
+	_, api := otgsimtest.Start(t)
+
+	s := &Suite{Api: api, Locations: locations}
+	counts := map[string]int{}
+	for _, key := range readKeys(t) {
+		r := s.Check(key)
+		counts[r.Status]++
+		switch {
+		case r.Status == OK:
+		case r.Status == Unsupported && strings.Contains(r.Detail, "has no field"):
+			// Keys of fields newer than gosnappi, like devices.{i}.ospfv2.
+		default:
+			t.Errorf("Check(%s) got %s: %s", key, r.Status, r.Detail)
+		}
+	}
+	t.Logf("statuses: %v", counts)
+}
+
+// lossy is an OTG service that drops the frame size of flows from the config
+// it returns.
+type lossy struct {
+	*otgsim.Server
+}
+
+func (l lossy) GetConfig(ctx context.Context, e *emptypb.Empty) (*otg.GetConfigResponse, error) {
+	res, err := l.Server.GetConfig(ctx, e)
+	if err == nil {
+		for _, f := range res.Config.Flows {
+			f.Size = nil
+		}
+	}
+	return res, err
+}
+
+func TestCheckLossy(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := grpc.NewServer()
+	otg.RegisterOpenapiServer(srv, lossy{otgsim.New()})
+	go srv.Serve(lis)
+	defer srv.Stop()
+	api := gosnappi.NewApi()
+	api.NewGrpcTransport().SetLocation(lis.Addr().String()).SetDialTimeout(10 * time.Second)
+
+	s := &Suite{Api: api, Locations: locations}
+	if r := s.Check("flows.{i}.size.fixed"); r.Status != "dropped" || r.Detail != "flows[flow1].size.fixed: 65 -> (none)" {
+		t.Errorf("Check() of the dropped size got %+v", r)
+	}
+	if r := s.Check("flows.{i}.rate.pps"); r.Status != OK {
+		t.Errorf("Check() of the kept rate got %+v", r)
+	}
+}
+
+func TestWriteMatrix(t *testing.T) {
+	var b strings.Builder
+	err := WriteMatrix(&b, "otgservice.V1.3.53", []Result{
+		{Key: "flows.{i}.rate.pps", Status: OK},
+		{Key: "flows.{i}.size.fixed", Status: "dropped", Detail: "flows[flow1].size.fixed: 65 -> (none)"},
+		{Key: "ports.{i}.location", Status: OK},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "# otgservice.V1.3.53\n" +
+		"flows.{i}.rate.pps\tok\n" +
+		"flows.{i}.size.fixed\tdropped\tflows[flow1].size.fixed: 65 -> (none)\n" +
+		"ports.{i}.location\tok\n" +
+		"# 3 keys: 1 dropped, 2 ok\n"
+	if b.String() != want {
+		t.Errorf("WriteMatrix() got\n%s\nwant\n%s", b.String(), want)
+	}
+}
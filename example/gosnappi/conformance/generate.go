@@ -0,0 +1,445 @@
+package conformance
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/SpirentOrion/stc-otg/example/gosnappi/configdiff"
+	"github.com/open-traffic-generator/snappi/gosnappi"
+	"github.com/open-traffic-generator/snappi/gosnappi/otg"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// maxFills bounds the rounds of filling in required fields, each of which
+// may add objects with required fields of their own.
+const maxFills = 20
+
+// Generate returns a minimal config that sets the field of a Set/Get Config
+// key of SupportedAPIsList.txt, e.g. flows.{i}.rate.pps, to a valid
+// non-default value. Beyond the objects on the path to the field, it holds
+// two ports at the given locations with a device each, and the required
+// fields of every object, referring to those ports and devices.
+func Generate(key string, locations []string) (gosnappi.Config, error) {
+	g := &generator{names: map[string]int{}}
+	msg, err := base(locations)
+	if err != nil {
+		return nil, err
+	}
+	path := strings.Split(key, ".")
+	if _, _, err := locate(msg, path, true); err != nil {
+		return nil, err
+	}
+	if err := g.complete(msg); err != nil {
+		return nil, err
+	}
+	if _, fd, _ := locate(msg, path, false); fd != nil {
+		if msg, err = g.nonDefault(msg, path, fd); err != nil {
+			return nil, err
+		}
+	}
+	withEthernet(msg)
+	return gosnappi.NewConfig().Unmarshal().FromProto(msg)
+}
+
+// base returns ports p1 and p2 at locations with devices d1 and d2 on them,
+// each with an Ethernet, IPv4 and IPv6 interface.
+func base(locations []string) (*otg.Config, error) {
+	config := gosnappi.NewConfig()
+	for i := 1; i <= 2; i++ {
+		port := config.Ports().Add().SetName(fmt.Sprintf("p%d", i))
+		if i <= len(locations) {
+			port.SetLocation(locations[i-1])
+		}
+		name := fmt.Sprintf("d%d", i)
+		eth := config.Devices().Add().SetName(name).Ethernets().Add().
+			SetName(name + "Eth").SetMac(fmt.Sprintf("00:00:01:01:01:%02d", i))
+		eth.Connection().SetPortName(port.Name())
+		eth.Ipv4Addresses().Add().SetName(name + "Ipv4").
+			SetAddress(fmt.Sprintf("1.1.1.%d", i)).SetGateway(fmt.Sprintf("1.1.1.%d", 3-i)).SetPrefix(24)
+		eth.Ipv6Addresses().Add().SetName(name + "Ipv6").
+			SetAddress(fmt.Sprintf("2001:db8::%d", i)).SetGateway(fmt.Sprintf("2001:db8::%d", 3-i)).SetPrefix(64)
+	}
+	msg, err := config.Marshal().ToProto()
+	if err != nil {
+		return nil, err
+	}
+	return proto.Clone(msg).(*otg.Config), nil
+}
+
+// locate returns the message and field of the leaf of path in msg, adding the
+// messages and list items on the way and selecting them in the choice field
+// of their parent if create is set. The field is nil if path ends at a
+// message.
+func locate(msg *otg.Config, path []string, create bool) (protoreflect.Message, protoreflect.FieldDescriptor, error) {
+	m := msg.ProtoReflect()
+	for i := 0; i < len(path); i++ {
+		fd := m.Descriptor().Fields().ByName(protoreflect.Name(path[i]))
+		if fd == nil {
+			return nil, nil, fmt.Errorf("%s has no field %s", m.Descriptor().Name(), path[i])
+		}
+		if create {
+			selectChoice(m, path[i])
+		}
+		if fd.Message() == nil {
+			return m, fd, nil
+		}
+		switch {
+		case fd.IsList():
+			if i+1 < len(path) && path[i+1] == "{i}" {
+				i++
+			}
+			if !create && m.Get(fd).List().Len() == 0 {
+				return nil, nil, fmt.Errorf("%s has no %s", m.Descriptor().Name(), path[i])
+			}
+			l := m.Mutable(fd).List()
+			if l.Len() == 0 {
+				l.Append(l.NewElement())
+			}
+			m = l.Get(0).Message()
+		case create:
+			m = m.Mutable(fd).Message()
+		case m.Has(fd):
+			m = m.Get(fd).Message()
+		default:
+			return nil, nil, fmt.Errorf("%s has no %s", m.Descriptor().Name(), path[i])
+		}
+	}
+	return m, nil, nil
+}
+
+// selectChoice sets the choice field of m to name if it is one of its values.
+func selectChoice(m protoreflect.Message, name string) {
+	choice := m.Descriptor().Fields().ByName("choice")
+	if choice == nil || choice.Enum() == nil {
+		return
+	}
+	if v := choice.Enum().Values().ByName(protoreflect.Name(name)); v != nil {
+		m.Set(choice, protoreflect.ValueOfEnum(v.Number()))
+	}
+}
+
+// withEthernet adds an Ethernet header in front of flow packets that start
+// with another header.
+func withEthernet(msg *otg.Config) {
+	for _, f := range msg.Flows {
+		if len(f.Packet) > 0 && f.Packet[0].GetChoice() != otg.FlowHeader_Choice_ethernet {
+			eth := otg.FlowHeader_Choice_ethernet
+			f.Packet = append([]*otg.FlowHeader{{Choice: &eth, Ethernet: &otg.FlowEthernet{}}}, f.Packet...)
+		}
+	}
+}
+
+// generator fills in configs, naming the objects it adds uniquely.
+type generator struct {
+	names map[string]int
+}
+
+// complete fills in the required fields of msg until it validates.
+func (g *generator) complete(msg *otg.Config) error {
+	for i := 0; i < maxFills; i++ {
+		_, err := gosnappi.NewConfig().Unmarshal().FromProto(msg)
+		if err == nil {
+			return nil
+		}
+		missing := configdiff.MissingFields(err)
+		if len(missing) == 0 {
+			return err
+		}
+		if !g.fill(msg.ProtoReflect(), missing) {
+			return err
+		}
+	}
+	return fmt.Errorf("config still misses required fields after %d rounds", maxFills)
+}
+
+// fill sets the missing fields of m and the messages in it, and reports
+// whether it set any.
+func (g *generator) fill(m protoreflect.Message, missing map[string]bool) bool {
+	filled := false
+	fields := m.Descriptor().Fields()
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		if fd.IsList() && m.Get(fd).List().Len() > 0 || !fd.IsList() && m.Has(fd) {
+			continue
+		}
+		if !missing[configdiff.FieldKey(fd)] {
+			if fd.Message() != nil && !fd.IsList() && lacks(missing, fd.Message()) && unchosen(m, fd) {
+				// The missing fields are in the object gosnappi chooses by
+				// default, e.g. the port of a flow without tx_rx.
+				selectChoice(m, string(fd.Name()))
+				m.Mutable(fd)
+				filled = true
+			}
+			continue
+		}
+		switch {
+		case fd.Message() != nil && fd.IsList():
+			l := m.Mutable(fd).List()
+			l.Append(l.NewElement())
+		case fd.Message() != nil:
+			m.Mutable(fd)
+		case fd.IsList():
+			m.Mutable(fd).List().Append(g.value(m, fd))
+		default:
+			m.Set(fd, g.value(m, fd))
+		}
+		filled = true
+	}
+	m.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		switch {
+		case fd.Message() == nil || fd.IsMap():
+		case fd.IsList():
+			for i := 0; i < v.List().Len(); i++ {
+				filled = g.fill(v.List().Get(i).Message(), missing) || filled
+			}
+		default:
+			filled = g.fill(v.Message(), missing) || filled
+		}
+		return true
+	})
+	return filled
+}
+
+// lacks reports whether missing holds fields of messages of type md.
+func lacks(missing map[string]bool, md protoreflect.MessageDescriptor) bool {
+	for key := range missing {
+		if strings.HasPrefix(key, string(md.Name())+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// unchosen reports whether the choice field of m is unset and has a value for
+// field fd.
+func unchosen(m protoreflect.Message, fd protoreflect.FieldDescriptor) bool {
+	choice := m.Descriptor().Fields().ByName("choice")
+	return choice != nil && choice.Enum() != nil && !m.Has(choice) &&
+		choice.Enum().Values().ByName(fd.Name()) != nil
+}
+
+// value returns the value of a required field of m: a new name, a reference
+// to the ports and devices of the base config, or a plausible value of the
+// field type.
+func (g *generator) value(m protoreflect.Message, fd protoreflect.FieldDescriptor) protoreflect.Value {
+	msgName := string(m.Descriptor().Name())
+	switch fd.Kind() {
+	case protoreflect.EnumKind:
+		if values := fd.Enum().Values(); values.Len() > 1 {
+			return protoreflect.ValueOfEnum(values.Get(1).Number())
+		}
+		return protoreflect.ValueOfEnum(0)
+	case protoreflect.BoolKind:
+		return protoreflect.ValueOfBool(true)
+	case protoreflect.StringKind:
+	default:
+		return number(fd, 1)
+	}
+	name := string(fd.Name())
+	s := "conformance"
+	switch {
+	case name == "name":
+		base := strings.ToLower(msgName)
+		g.names[base]++
+		s = fmt.Sprintf("%s%d", base, g.names[base])
+	case strings.HasPrefix(msgName, "FlowRouter") && name == "tx_names":
+		s = "d1Ipv4"
+	case strings.HasPrefix(msgName, "FlowRouter") && name == "rx_names":
+		s = "d2Ipv4"
+	case name == "rx_names" || name == "rx_name":
+		s = "p2"
+	case strings.HasPrefix(name, "port_name") || name == "tx_name":
+		s = "p1"
+	case name == "eth_name" || name == "eth_names":
+		s = "d1Eth"
+	case name == "ipv4_name" || name == "ipv4_names":
+		s = "d1Ipv4"
+	case name == "ipv6_name" || name == "ipv6_names":
+		s = "d1Ipv6"
+	case strings.Contains(name, "mac"):
+		s = "00:00:01:01:01:09"
+	case strings.Contains(name, "system_id"):
+		s = "640000000001"
+	case strings.Contains(name, "address") || strings.Contains(name, "gateway") || strings.Contains(name, "router_id"):
+		if strings.Contains(msgName, "V6") || strings.Contains(msgName, "v6") {
+			s = "2001:db8::2"
+		} else {
+			s = "1.1.1.2"
+		}
+	}
+	return protoreflect.ValueOfString(s)
+}
+
+// nonDefault returns msg if it sets the leaf of path, field fd, and the field
+// has no default, like names and references, or else a copy of msg with the
+// leaf set to the first candidate value that differs from its default and
+// validates.
+func (g *generator) nonDefault(msg *otg.Config, path []string, fd protoreflect.FieldDescriptor) (*otg.Config, error) {
+	var def protoreflect.Value
+	hasDefault := false
+	// gosnappi only fills in the default of a chosen field when it makes the
+	// choice itself.
+	bare := proto.Clone(msg).(*otg.Config)
+	if m, _, err := locate(bare, path, false); err == nil {
+		m.Clear(fd)
+		if choice := m.Descriptor().Fields().ByName("choice"); choice != nil {
+			m.Clear(choice)
+		}
+	}
+	if c, err := gosnappi.NewConfig().Unmarshal().FromProto(bare); err == nil {
+		if full, err := c.Marshal().ToProto(); err == nil {
+			if m, _, err := locate(full, path, false); err == nil && !fd.IsList() && m.Has(fd) {
+				def, hasDefault = m.Get(fd), true
+			}
+		}
+	}
+	if m, _, err := locate(msg, path, false); err == nil && !hasDefault {
+		if (fd.IsList() && m.Get(fd).List().Len() > 0) || (!fd.IsList() && m.Has(fd)) {
+			return msg, nil
+		}
+	}
+	var lastErr error
+	for _, v := range candidates(fd, def, hasDefault) {
+		try := proto.Clone(msg).(*otg.Config)
+		m, _, err := locate(try, path, true)
+		if err != nil {
+			return nil, err
+		}
+		if fd.IsList() {
+			l := m.Mutable(fd).List()
+			l.Truncate(0)
+			l.Append(v)
+		} else {
+			m.Set(fd, v)
+		}
+		if fd.Name() == "choice" {
+			// The chosen object may have required fields of its own.
+			if chosen := m.Descriptor().Fields().ByName(fd.Enum().Values().ByNumber(v.Enum()).Name()); chosen != nil && chosen.Message() != nil && !chosen.IsList() {
+				m.Mutable(chosen)
+			}
+		}
+		if lastErr = g.complete(try); lastErr == nil {
+			return try, nil
+		}
+	}
+	if lastErr == nil {
+		lastErr = fmt.Errorf("no candidate value for %s", strings.Join(path, "."))
+	}
+	return nil, lastErr
+}
+
+// candidates returns the values to try for field fd, other than its default
+// def.
+func candidates(fd protoreflect.FieldDescriptor, def protoreflect.Value, hasDefault bool) []protoreflect.Value {
+	values := []protoreflect.Value{}
+	add := func(v protoreflect.Value) {
+		if hasDefault && v.Equal(def) {
+			return
+		}
+		for _, prev := range values {
+			if prev.Equal(v) {
+				return
+			}
+		}
+		values = append(values, v)
+	}
+	switch fd.Kind() {
+	case protoreflect.EnumKind:
+		enum := fd.Enum().Values()
+		for i := 0; i < enum.Len(); i++ {
+			if enum.Get(i).Number() != 0 {
+				add(protoreflect.ValueOfEnum(enum.Get(i).Number()))
+			}
+		}
+	case protoreflect.BoolKind:
+		add(protoreflect.ValueOfBool(!hasDefault || !def.Bool()))
+	case protoreflect.StringKind:
+		if hasDefault {
+			if s := nextString(def.String()); s != "" {
+				add(protoreflect.ValueOfString(s))
+			}
+		}
+		for _, s := range []string{"1.1.1.9", "2001:db8::9", "00:00:01:01:01:09", "640000000009", "9", "0a", "conformance"} {
+			add(protoreflect.ValueOfString(s))
+		}
+	case protoreflect.BytesKind:
+		add(protoreflect.ValueOfBytes([]byte{9}))
+	default:
+		var d float64
+		if hasDefault {
+			d = float(def)
+			add(number(fd, d+1))
+			if d >= 1 {
+				add(number(fd, d-1))
+			}
+		}
+		for _, n := range []float64{1, 2, 10, 100, 1000, 1500, 65535} {
+			add(number(fd, n))
+		}
+		if fd.Kind() == protoreflect.FloatKind || fd.Kind() == protoreflect.DoubleKind {
+			add(number(fd, d+0.5))
+		}
+	}
+	if len(values) == 0 && hasDefault {
+		// A field with a single valid value, like the choice of an ICMP
+		// header, is checked with it set explicitly.
+		values = append(values, def)
+	}
+	return values
+}
+
+// float returns a numeric value as float64.
+func float(v protoreflect.Value) float64 {
+	switch n := v.Interface().(type) {
+	case int32:
+		return float64(n)
+	case int64:
+		return float64(n)
+	case uint32:
+		return float64(n)
+	case uint64:
+		return float64(n)
+	case float32:
+		return float64(n)
+	case float64:
+		return n
+	}
+	return 0
+}
+
+// number returns n as a value of the numeric kind of fd.
+func number(fd protoreflect.FieldDescriptor, n float64) protoreflect.Value {
+	switch fd.Kind() {
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		return protoreflect.ValueOfInt32(int32(n))
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		return protoreflect.ValueOfInt64(int64(n))
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		return protoreflect.ValueOfUint32(uint32(n))
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		return protoreflect.ValueOfUint64(uint64(n))
+	case protoreflect.FloatKind:
+		return protoreflect.ValueOfFloat32(float32(n))
+	}
+	return protoreflect.ValueOfFloat64(n)
+}
+
+// nextString returns the address after an IPv4, IPv6 or MAC address default,
+// e.g. 0.0.0.1 for 0.0.0.0, and "" for other strings.
+func nextString(s string) string {
+	if mac, err := net.ParseMAC(s); err == nil && len(mac) == 6 {
+		mac[5]++
+		return mac.String()
+	}
+	ip := net.ParseIP(s)
+	if ip == nil {
+		return ""
+	}
+	if v4 := ip.To4(); v4 != nil && !strings.Contains(s, ":") {
+		ip = v4
+	}
+	ip[len(ip)-1]++
+	return ip.String()
+}
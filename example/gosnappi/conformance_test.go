@@ -0,0 +1,68 @@
+package gosnappi_examples
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/SpirentOrion/stc-otg/example/gosnappi/configdiff"
+	"github.com/SpirentOrion/stc-otg/example/gosnappi/conformance"
+	"github.com/open-traffic-generator/snappi/gosnappi"
+)
+
+// PORT1=10.109.121.181/1/1 PORT2=10.109.123.254/1/1 OTGSERVER=localhost:50051 go test -v -test.run TestConformance
+//
+// Writes the coverage matrix to conformance-<build>.tsv, e.g.
+// conformance-otgservice.V1.3.53.tsv, in the directory CONFORMANCE_DIR names,
+// or in a temporary directory removed after the test when it is unset; diff
+// it with the one of another build.
+func TestConformance(t *testing.T) {
+	// Create a new API handle to make API calls against OTG
+	api := gosnappi.NewApi()
+
+	// Set the transport protocol to gRPC
+	api.NewGrpcTransport().
+		SetLocation(OTGSERVER).
+		SetDialTimeout(3 * time.Minute).
+		SetRequestTimeout(10 * time.Minute)
+
+	f, err := os.Open("../../SupportedAPIsList.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys, err := configdiff.ReadKeys(f)
+	f.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	suite := &conformance.Suite{Api: api, Locations: []string{PORT1, PORT2}}
+	results := []conformance.Result{}
+	for _, key := range keys {
+		t.Run(key, func(t *testing.T) {
+			r := suite.Check(key)
+			results = append(results, r)
+			if r.Status != conformance.OK {
+				t.Errorf("%s: %s", r.Status, r.Detail)
+			}
+		})
+	}
+
+	build := conformance.Build(api)
+	dir := os.Getenv("CONFORMANCE_DIR")
+	if dir == "" {
+		dir = t.TempDir()
+	}
+	path := filepath.Join(dir, "conformance-"+strings.ReplaceAll(build, "/", "_")+".tsv")
+	out, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer out.Close()
+	if err := conformance.WriteMatrix(out, build, results); err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("wrote the coverage matrix of %s to %s", build, path)
+}
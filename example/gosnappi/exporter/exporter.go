@@ -0,0 +1,246 @@
+// Package exporter polls the metrics of an OTG service and serves them to
+// Prometheus, in the Prometheus text format or in OpenMetrics when the
+// scraper asks for it. Flow, port, LAG, LACP, ISIS and BGP metrics are
+// exported with flow, port, LAG and device labels, so dashboards can show
+// live rates and loss of a running test.
+package exporter
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/open-traffic-generator/snappi/gosnappi"
+)
+
+const (
+	textContentType        = "text/plain; version=0.0.4; charset=utf-8"
+	openMetricsContentType = "application/openmetrics-text; version=1.0.0; charset=utf-8"
+)
+
+// Exporter collects OTG metrics and serves the latest collection over HTTP.
+type Exporter struct {
+	Api gosnappi.Api
+	// Interval between collections in Run, 10s when zero.
+	Interval time.Duration
+	// Logf, if set, receives collection errors, e.g. log.Printf.
+	Logf func(format string, args ...any)
+
+	mu   sync.Mutex
+	last *registry
+	// errors counts failed GetMetrics calls by metric kind, created by the
+	// first collection that fails.
+	errors map[string]float64
+}
+
+// New returns an exporter for the OTG service behind api. It is the same as
+// &Exporter{Api: api}.
+func New(api gosnappi.Api) *Exporter {
+	return &Exporter{Api: api}
+}
+
+func (e *Exporter) logf(format string, args ...any) {
+	if e.Logf != nil {
+		e.Logf(format, args...)
+	}
+}
+
+// Collect fetches the config and then the metrics of every kind the config
+// uses. A kind that fails is left out and counted in
+// otg_scrape_errors_total; only failing to get the config is returned.
+func (e *Exporter) Collect() error {
+	start := time.Now()
+	reg := newRegistry()
+
+	config, err := e.Api.GetConfig()
+	if err != nil {
+		reg.add("otg_up", gauge, "Whether the OTG service answered the last collection.", 0)
+		e.publish(reg, start)
+		return fmt.Errorf("get config: %v", err)
+	}
+	reg.add("otg_up", gauge, "Whether the OTG service answered the last collection.", 1)
+
+	devices := deviceNames(config)
+	failed := []string{}
+	for _, k := range kinds {
+		if !k.used(config) {
+			continue
+		}
+		req := gosnappi.NewMetricsRequest()
+		k.request(req)
+		resp, err := e.Api.GetMetrics(req)
+		if err != nil {
+			e.logf("exporter: get %s metrics: %v", k.name, err)
+			failed = append(failed, k.name)
+			continue
+		}
+		msg, err := resp.Marshal().ToProto()
+		if err != nil {
+			e.logf("exporter: %s metrics: %v", k.name, err)
+			failed = append(failed, k.name)
+			continue
+		}
+		k.export(reg, msg, devices)
+	}
+	e.mu.Lock()
+	if e.errors == nil && len(failed) > 0 {
+		e.errors = map[string]float64{}
+	}
+	for _, name := range failed {
+		e.errors[name]++
+	}
+	e.mu.Unlock()
+	e.publish(reg, start)
+	return nil
+}
+
+// publish adds the exporter's own metrics to reg and makes it the collection
+// ServeHTTP serves.
+func (e *Exporter) publish(reg *registry, start time.Time) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, name := range sortedKeys(e.errors) {
+		reg.add("otg_scrape_errors_total", counter, "GetMetrics calls that failed, by metric kind.",
+			e.errors[name], "kind", name)
+	}
+	reg.add("otg_scrape_duration_seconds", gauge, "How long the last collection took.",
+		time.Since(start).Seconds())
+	reg.add("otg_scrape_timestamp_seconds", gauge, "Unix time of the last collection.",
+		float64(start.UnixNano())/1e9)
+	e.last = reg
+}
+
+// Run collects every Interval until ctx is done.
+func (e *Exporter) Run(ctx context.Context) error {
+	interval := e.Interval
+	if interval == 0 {
+		interval = 10 * time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := e.Collect(); err != nil {
+			e.logf("exporter: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// ServeHTTP writes the latest collection, in OpenMetrics if the request
+// accepts it and in the Prometheus text format otherwise.
+func (e *Exporter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	e.mu.Lock()
+	reg := e.last
+	e.mu.Unlock()
+	if reg == nil {
+		http.Error(w, "no metrics collected yet", http.StatusServiceUnavailable)
+		return
+	}
+	openMetrics := strings.Contains(r.Header.Get("Accept"), "application/openmetrics-text")
+	if openMetrics {
+		w.Header().Set("Content-Type", openMetricsContentType)
+	} else {
+		w.Header().Set("Content-Type", textContentType)
+	}
+	reg.write(w, openMetrics)
+}
+
+type metricType string
+
+const (
+	counter metricType = "counter"
+	gauge   metricType = "gauge"
+)
+
+type sample struct {
+	labels []string
+	value  float64
+}
+
+type family struct {
+	name    string
+	typ     metricType
+	help    string
+	samples []sample
+}
+
+// registry holds the metric families of one collection.
+type registry struct {
+	families map[string]*family
+}
+
+func newRegistry() *registry {
+	return &registry{families: map[string]*family{}}
+}
+
+// add adds a sample to the named family. labels are name, value pairs.
+// Counter names end in _total.
+func (r *registry) add(name string, typ metricType, help string, value float64, labels ...string) {
+	f, ok := r.families[name]
+	if !ok {
+		f = &family{name: name, typ: typ, help: help}
+		r.families[name] = f
+	}
+	f.samples = append(f.samples, sample{labels: labels, value: value})
+}
+
+// write writes the families sorted by name. OpenMetrics names counter families
+// without their _total suffix and ends with an EOF marker.
+func (r *registry) write(w io.Writer, openMetrics bool) error {
+	b := &strings.Builder{}
+	for _, name := range sortedKeys(r.families) {
+		f := r.families[name]
+		familyName := f.name
+		if openMetrics && f.typ == counter {
+			familyName = strings.TrimSuffix(familyName, "_total")
+		}
+		fmt.Fprintf(b, "# HELP %s %s\n", familyName, f.help)
+		fmt.Fprintf(b, "# TYPE %s %s\n", familyName, f.typ)
+		for _, s := range f.samples {
+			b.WriteString(f.name)
+			if len(s.labels) > 0 {
+				b.WriteByte('{')
+				for i := 0; i+1 < len(s.labels); i += 2 {
+					if i > 0 {
+						b.WriteByte(',')
+					}
+					fmt.Fprintf(b, "%s=\"%s\"", s.labels[i], escape(s.labels[i+1]))
+				}
+				b.WriteByte('}')
+			}
+			b.WriteByte(' ')
+			b.WriteString(strconv.FormatFloat(s.value, 'g', -1, 64))
+			b.WriteByte('\n')
+		}
+	}
+	if openMetrics {
+		b.WriteString("# EOF\n")
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+var escaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escape(v string) string {
+	return escaper.Replace(v)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,167 @@
+package exporter
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/SpirentOrion/stc-otg/example/gosnappi/otgsimtest"
+	"github.com/open-traffic-generator/snappi/gosnappi"
+)
+
+func TestRegistryWrite(t *testing.T) {
+	reg := newRegistry()
+	reg.add("otg_flow_frames_tx_total", counter, "Frames transmitted by the flow.", 100, "flow", `f"1`)
+	reg.add("otg_up", gauge, "Whether the OTG service answered the last collection.", 1)
+
+	for _, tc := range []struct {
+		openMetrics bool
+		want        string
+	}{{
+		want: `# HELP otg_flow_frames_tx_total Frames transmitted by the flow.
+# TYPE otg_flow_frames_tx_total counter
+otg_flow_frames_tx_total{flow="f\"1"} 100
+# HELP otg_up Whether the OTG service answered the last collection.
+# TYPE otg_up gauge
+otg_up 1
+`,
+	}, {
+		openMetrics: true,
+		want: `# HELP otg_flow_frames_tx Frames transmitted by the flow.
+# TYPE otg_flow_frames_tx counter
+otg_flow_frames_tx_total{flow="f\"1"} 100
+# HELP otg_up Whether the OTG service answered the last collection.
+# TYPE otg_up gauge
+otg_up 1
+# EOF
+`,
+	}} {
+		b := &strings.Builder{}
+		if err := reg.write(b, tc.openMetrics); err != nil {
+			t.Fatal(err)
+		}
+		if b.String() != tc.want {
+			t.Errorf("write(openMetrics=%v) got:\n%s\nwant:\n%s", tc.openMetrics, b.String(), tc.want)
+		}
+	}
+}
+
+func scrape(t *testing.T, url, accept string) (string, string) {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Accept", accept)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp.Header.Get("Content-Type"), string(body)
+}
+
+func TestExporter(t *testing.T) {
+	sim, api := otgsimtest.Start(t)
+
+	config := gosnappi.NewConfig()
+	config.Ports().Add().SetName("port1").SetLocation("//sim/1/1")
+	config.Ports().Add().SetName("port2").SetLocation("//sim/1/2")
+	lag := config.Lags().Add().SetName("lag1")
+	lag.Ports().Add().SetPortName("port1").Ethernet().SetName("lag1.eth1").SetMac("00:00:01:01:01:01")
+	flow := config.Flows().Add().SetName("flow1")
+	flow.TxRx().Port().SetTxName("port1").SetRxNames([]string{"port2"})
+	flow.Metrics().SetEnable(true)
+	flow.Rate().SetPps(1000)
+	flow.Duration().FixedPackets().SetPackets(1000)
+	if _, err := api.SetConfig(config); err != nil {
+		t.Fatal(err)
+	}
+	cs := gosnappi.NewControlState()
+	cs.Traffic().FlowTransmit().SetState(gosnappi.StateTrafficFlowTransmitState.START)
+	if _, err := api.SetControlState(cs); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(10 * time.Millisecond)
+
+	e := New(api)
+	srv := httptest.NewServer(e)
+	defer srv.Close()
+	if _, body := scrape(t, srv.URL, ""); !strings.Contains(body, "no metrics collected yet") {
+		t.Errorf("scrape before Collect() got %q, want an error", body)
+	}
+
+	if err := e.Collect(); err != nil {
+		t.Fatal(err)
+	}
+	contentType, body := scrape(t, srv.URL, "text/plain")
+	if contentType != textContentType {
+		t.Errorf("Content-Type got %q, want %q", contentType, textContentType)
+	}
+	for _, want := range []string{
+		"otg_up 1\n",
+		`otg_flow_frames_tx_total{flow="flow1",tx_port="port1",rx_port="port2"} 1000` + "\n",
+		`otg_flow_frames_rx_total{flow="flow1",tx_port="port1",rx_port="port2"} 1000` + "\n",
+		`otg_flow_transmitting{flow="flow1",tx_port="port1",rx_port="port2"} 0` + "\n",
+		`otg_port_link_up{port="port2",location="//sim/1/2"} 1` + "\n",
+		`otg_port_frames_rx_total{port="port2",location="//sim/1/2"} 1000` + "\n",
+		`otg_lag_up{lag="lag1"} 1` + "\n",
+		`otg_lag_member_ports_up{lag="lag1"} 1` + "\n",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("scrape got:\n%s\nmissing %q", body, want)
+		}
+	}
+
+	contentType, body = scrape(t, srv.URL, "application/openmetrics-text; version=1.0.0")
+	if contentType != openMetricsContentType || !strings.HasSuffix(body, "# EOF\n") {
+		t.Errorf("OpenMetrics scrape got Content-Type %q and body:\n%s", contentType, body)
+	}
+
+	sim.Stop()
+	if err := e.Collect(); err == nil {
+		t.Error("Collect() with the service stopped succeeded, want error")
+	}
+	if _, body := scrape(t, srv.URL, ""); !strings.Contains(body, "otg_up 0\n") {
+		t.Errorf("scrape with the service stopped got:\n%s\nwant otg_up 0", body)
+	}
+}
+
+// failingMetrics is an Api whose GetMetrics calls all fail.
+type failingMetrics struct {
+	gosnappi.Api
+}
+
+func (failingMetrics) GetMetrics(gosnappi.MetricsRequest) (gosnappi.MetricsResponse, error) {
+	return nil, errors.New("unavailable")
+}
+
+func TestExporterLiteral(t *testing.T) {
+	_, api := otgsimtest.Start(t)
+	config := gosnappi.NewConfig()
+	config.Ports().Add().SetName("port1")
+	if _, err := api.SetConfig(config); err != nil {
+		t.Fatal(err)
+	}
+
+	// An exporter made without New counts failed metric kinds too.
+	e := &Exporter{Api: failingMetrics{api}}
+	srv := httptest.NewServer(e)
+	defer srv.Close()
+	for i := 0; i < 2; i++ {
+		if err := e.Collect(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, body := scrape(t, srv.URL, ""); !strings.Contains(body, `otg_scrape_errors_total{kind="port"} 2`+"\n") {
+		t.Errorf("scrape got:\n%s\nwant 2 port scrape errors", body)
+	}
+}
@@ -0,0 +1,227 @@
+package exporter
+
+import (
+	"github.com/open-traffic-generator/snappi/gosnappi"
+	"github.com/open-traffic-generator/snappi/gosnappi/otg"
+)
+
+// kind is one choice of metrics request. Metrics are exported from the proto
+// form of the response, whose getters return zero for fields the service
+// left out.
+type kind struct {
+	name string
+	// used reports whether the config has anything to get metrics for.
+	used    func(config gosnappi.Config) bool
+	request func(req gosnappi.MetricsRequest)
+	export  func(reg *registry, resp *otg.MetricsResponse, devices map[string]string)
+}
+
+var kinds = []kind{
+	{"flow", hasFlows, func(req gosnappi.MetricsRequest) { req.Flow() }, exportFlows},
+	{"port", hasPorts, func(req gosnappi.MetricsRequest) { req.Port() }, exportPorts},
+	{"lag", hasLags, func(req gosnappi.MetricsRequest) { req.Lag() }, exportLags},
+	{"lacp", hasLacp, func(req gosnappi.MetricsRequest) { req.Lacp() }, exportLacp},
+	{"isis", hasIsis, func(req gosnappi.MetricsRequest) { req.Isis() }, exportIsis},
+	{"bgpv4", hasBgpv4, func(req gosnappi.MetricsRequest) { req.Bgpv4() }, exportBgpv4},
+	{"bgpv6", hasBgpv6, func(req gosnappi.MetricsRequest) { req.Bgpv6() }, exportBgpv6},
+}
+
+func hasFlows(c gosnappi.Config) bool { return len(c.Flows().Items()) > 0 }
+func hasPorts(c gosnappi.Config) bool { return len(c.Ports().Items()) > 0 }
+func hasLags(c gosnappi.Config) bool  { return len(c.Lags().Items()) > 0 }
+
+func hasLacp(c gosnappi.Config) bool {
+	for _, lag := range c.Lags().Items() {
+		if lag.Protocol().Choice() == gosnappi.LagProtocolChoice.LACP {
+			return true
+		}
+	}
+	return false
+}
+
+func hasIsis(c gosnappi.Config) bool {
+	for _, d := range c.Devices().Items() {
+		if d.HasIsis() {
+			return true
+		}
+	}
+	return false
+}
+
+func hasBgpv4(c gosnappi.Config) bool {
+	for _, d := range c.Devices().Items() {
+		if d.HasBgp() && len(d.Bgp().Ipv4Interfaces().Items()) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func hasBgpv6(c gosnappi.Config) bool {
+	for _, d := range c.Devices().Items() {
+		if d.HasBgp() && len(d.Bgp().Ipv6Interfaces().Items()) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// deviceNames maps ISIS router and BGP peer names to the name of the device
+// they are configured on.
+func deviceNames(c gosnappi.Config) map[string]string {
+	devices := map[string]string{}
+	for _, d := range c.Devices().Items() {
+		if d.HasIsis() {
+			devices[d.Isis().Name()] = d.Name()
+		}
+		if d.HasBgp() {
+			for _, intf := range d.Bgp().Ipv4Interfaces().Items() {
+				for _, peer := range intf.Peers().Items() {
+					devices[peer.Name()] = d.Name()
+				}
+			}
+			for _, intf := range d.Bgp().Ipv6Interfaces().Items() {
+				for _, peer := range intf.Peers().Items() {
+					devices[peer.Name()] = d.Name()
+				}
+			}
+		}
+	}
+	return devices
+}
+
+func bool01(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func exportFlows(reg *registry, resp *otg.MetricsResponse, _ map[string]string) {
+	for _, m := range resp.GetFlowMetrics() {
+		l := []string{"flow", m.GetName(), "tx_port", m.GetPortTx(), "rx_port", m.GetPortRx()}
+		reg.add("otg_flow_transmitting", gauge, "Whether the flow is transmitting.",
+			bool01(m.GetTransmit() == otg.FlowMetric_Transmit_started), l...)
+		reg.add("otg_flow_frames_tx_total", counter, "Frames transmitted by the flow.", float64(m.GetFramesTx()), l...)
+		reg.add("otg_flow_frames_rx_total", counter, "Frames of the flow received.", float64(m.GetFramesRx()), l...)
+		reg.add("otg_flow_bytes_tx_total", counter, "Bytes transmitted by the flow.", float64(m.GetBytesTx()), l...)
+		reg.add("otg_flow_bytes_rx_total", counter, "Bytes of the flow received.", float64(m.GetBytesRx()), l...)
+		reg.add("otg_flow_frames_tx_rate", gauge, "Frames per second transmitted by the flow.", float64(m.GetFramesTxRate()), l...)
+		reg.add("otg_flow_frames_rx_rate", gauge, "Frames per second of the flow received.", float64(m.GetFramesRxRate()), l...)
+		reg.add("otg_flow_loss_percent", gauge, "Percentage of the transmitted frames of the flow not received.", float64(m.GetLoss()), l...)
+		if m.GetLatency() != nil {
+			reg.add("otg_flow_latency_minimum_seconds", gauge, "Minimum latency of the received frames of the flow.", m.GetLatency().GetMinimumNs()/1e9, l...)
+			reg.add("otg_flow_latency_average_seconds", gauge, "Average latency of the received frames of the flow.", m.GetLatency().GetAverageNs()/1e9, l...)
+			reg.add("otg_flow_latency_maximum_seconds", gauge, "Maximum latency of the received frames of the flow.", m.GetLatency().GetMaximumNs()/1e9, l...)
+		}
+	}
+}
+
+func exportPorts(reg *registry, resp *otg.MetricsResponse, _ map[string]string) {
+	for _, m := range resp.GetPortMetrics() {
+		l := []string{"port", m.GetName(), "location", m.GetLocation()}
+		reg.add("otg_port_link_up", gauge, "Whether the port link is up.", bool01(m.GetLink() == otg.PortMetric_Link_up), l...)
+		reg.add("otg_port_transmitting", gauge, "Whether the port is transmitting.",
+			bool01(m.GetTransmit() == otg.PortMetric_Transmit_started), l...)
+		reg.add("otg_port_frames_tx_total", counter, "Frames transmitted on the port.", float64(m.GetFramesTx()), l...)
+		reg.add("otg_port_frames_rx_total", counter, "Frames received on the port.", float64(m.GetFramesRx()), l...)
+		reg.add("otg_port_bytes_tx_total", counter, "Bytes transmitted on the port.", float64(m.GetBytesTx()), l...)
+		reg.add("otg_port_bytes_rx_total", counter, "Bytes received on the port.", float64(m.GetBytesRx()), l...)
+		reg.add("otg_port_frames_tx_rate", gauge, "Frames per second transmitted on the port.", float64(m.GetFramesTxRate()), l...)
+		reg.add("otg_port_frames_rx_rate", gauge, "Frames per second received on the port.", float64(m.GetFramesRxRate()), l...)
+		reg.add("otg_port_bytes_tx_rate", gauge, "Bytes per second transmitted on the port.", float64(m.GetBytesTxRate()), l...)
+		reg.add("otg_port_bytes_rx_rate", gauge, "Bytes per second received on the port.", float64(m.GetBytesRxRate()), l...)
+	}
+}
+
+func exportLags(reg *registry, resp *otg.MetricsResponse, _ map[string]string) {
+	for _, m := range resp.GetLagMetrics() {
+		l := []string{"lag", m.GetName()}
+		reg.add("otg_lag_up", gauge, "Whether the LAG is operationally up.", bool01(m.GetOperStatus() == otg.LagMetric_OperStatus_up), l...)
+		reg.add("otg_lag_member_ports_up", gauge, "LAG member ports that are up.", float64(m.GetMemberPortsUp()), l...)
+		reg.add("otg_lag_frames_tx_total", counter, "Frames transmitted on the LAG.", float64(m.GetFramesTx()), l...)
+		reg.add("otg_lag_frames_rx_total", counter, "Frames received on the LAG.", float64(m.GetFramesRx()), l...)
+		reg.add("otg_lag_bytes_tx_total", counter, "Bytes transmitted on the LAG.", float64(m.GetBytesTx()), l...)
+		reg.add("otg_lag_bytes_rx_total", counter, "Bytes received on the LAG.", float64(m.GetBytesRx()), l...)
+		reg.add("otg_lag_frames_tx_rate", gauge, "Frames per second transmitted on the LAG.", float64(m.GetFramesTxRate()), l...)
+		reg.add("otg_lag_frames_rx_rate", gauge, "Frames per second received on the LAG.", float64(m.GetFramesRxRate()), l...)
+	}
+}
+
+func exportLacp(reg *registry, resp *otg.MetricsResponse, _ map[string]string) {
+	for _, m := range resp.GetLacpMetrics() {
+		l := []string{"lag", m.GetLagName(), "port", m.GetLagMemberPortName()}
+		reg.add("otg_lacp_synchronized", gauge, "Whether the LAG member is in sync.",
+			bool01(m.GetSynchronization() == otg.LacpMetric_Synchronization_in_sync), l...)
+		reg.add("otg_lacp_collecting", gauge, "Whether the LAG member is collecting.", bool01(m.GetCollecting()), l...)
+		reg.add("otg_lacp_distributing", gauge, "Whether the LAG member is distributing.", bool01(m.GetDistributing()), l...)
+		reg.add("otg_lacp_packets_tx_total", counter, "LACPDUs transmitted on the LAG member.", float64(m.GetLacpPacketsTx()), l...)
+		reg.add("otg_lacp_packets_rx_total", counter, "LACPDUs received on the LAG member.", float64(m.GetLacpPacketsRx()), l...)
+		reg.add("otg_lacp_rx_errors_total", counter, "Invalid LACPDUs received on the LAG member.", float64(m.GetLacpRxErrors()), l...)
+	}
+}
+
+func exportIsis(reg *registry, resp *otg.MetricsResponse, devices map[string]string) {
+	for _, m := range resp.GetIsisMetrics() {
+		for _, level := range []struct {
+			name                    string
+			up                      uint32
+			flaps, db, lspTx, lspRx uint64
+			hellosTx, hellosRx      uint64
+		}{
+			{"1", m.GetL1SessionsUp(), m.GetL1SessionFlap(), m.GetL1DatabaseSize(), m.GetL1LspSent(), m.GetL1LspReceived(),
+				m.GetL1BroadcastHellosSent() + m.GetL1PointToPointHellosSent(), m.GetL1BroadcastHellosReceived() + m.GetL1PointToPointHellosReceived()},
+			{"2", m.GetL2SessionsUp(), m.GetL2SessionFlap(), m.GetL2DatabaseSize(), m.GetL2LspSent(), m.GetL2LspReceived(),
+				m.GetL2BroadcastHellosSent() + m.GetL2PointToPointHellosSent(), m.GetL2BroadcastHellosReceived() + m.GetL2PointToPointHellosReceived()},
+		} {
+			l := []string{"router", m.GetName(), "device", devices[m.GetName()], "level", level.name}
+			reg.add("otg_isis_sessions_up", gauge, "ISIS adjacencies that are up.", float64(level.up), l...)
+			reg.add("otg_isis_session_flaps_total", counter, "ISIS adjacency flaps.", float64(level.flaps), l...)
+			reg.add("otg_isis_database_size", gauge, "LSPs in the ISIS link state database.", float64(level.db), l...)
+			reg.add("otg_isis_lsps_sent_total", counter, "ISIS LSPs sent.", float64(level.lspTx), l...)
+			reg.add("otg_isis_lsps_received_total", counter, "ISIS LSPs received.", float64(level.lspRx), l...)
+			reg.add("otg_isis_hellos_sent_total", counter, "ISIS hellos sent.", float64(level.hellosTx), l...)
+			reg.add("otg_isis_hellos_received_total", counter, "ISIS hellos received.", float64(level.hellosRx), l...)
+		}
+	}
+}
+
+// bgpMetric is what Bgpv4Metric and Bgpv6Metric have in common.
+type bgpMetric interface {
+	GetName() string
+	GetSessionFlapCount() uint64
+	GetRoutesAdvertised() uint64
+	GetRoutesReceived() uint64
+	GetRouteWithdrawsSent() uint64
+	GetRouteWithdrawsReceived() uint64
+	GetUpdatesSent() uint64
+	GetUpdatesReceived() uint64
+	GetNotificationsSent() uint64
+	GetNotificationsReceived() uint64
+}
+
+func exportBgp(reg *registry, m bgpMetric, up bool, afi string, devices map[string]string) {
+	l := []string{"peer", m.GetName(), "device", devices[m.GetName()], "afi", afi}
+	reg.add("otg_bgp_session_up", gauge, "Whether the BGP session is established.", bool01(up), l...)
+	reg.add("otg_bgp_session_flaps_total", counter, "BGP session flaps.", float64(m.GetSessionFlapCount()), l...)
+	reg.add("otg_bgp_routes_advertised_total", counter, "Routes advertised to the BGP peer.", float64(m.GetRoutesAdvertised()), l...)
+	reg.add("otg_bgp_routes_received_total", counter, "Routes received from the BGP peer.", float64(m.GetRoutesReceived()), l...)
+	reg.add("otg_bgp_route_withdraws_sent_total", counter, "Route withdraws sent to the BGP peer.", float64(m.GetRouteWithdrawsSent()), l...)
+	reg.add("otg_bgp_route_withdraws_received_total", counter, "Route withdraws received from the BGP peer.", float64(m.GetRouteWithdrawsReceived()), l...)
+	reg.add("otg_bgp_updates_sent_total", counter, "BGP updates sent.", float64(m.GetUpdatesSent()), l...)
+	reg.add("otg_bgp_updates_received_total", counter, "BGP updates received.", float64(m.GetUpdatesReceived()), l...)
+	reg.add("otg_bgp_notifications_sent_total", counter, "BGP notifications sent.", float64(m.GetNotificationsSent()), l...)
+	reg.add("otg_bgp_notifications_received_total", counter, "BGP notifications received.", float64(m.GetNotificationsReceived()), l...)
+}
+
+func exportBgpv4(reg *registry, resp *otg.MetricsResponse, devices map[string]string) {
+	for _, m := range resp.GetBgpv4Metrics() {
+		exportBgp(reg, m, m.GetSessionState() == otg.Bgpv4Metric_SessionState_up, "ipv4", devices)
+	}
+}
+
+func exportBgpv6(reg *registry, resp *otg.MetricsResponse, devices map[string]string) {
+	for _, m := range resp.GetBgpv6Metrics() {
+		exportBgp(reg, m, m.GetSessionState() == otg.Bgpv6Metric_SessionState_up, "ipv6", devices)
+	}
+}
@@ -0,0 +1,200 @@
+// Package framesize offers the frame size profiles of flows: the standard
+// IMIX distributions, custom weighted mixes and increment or random size
+// ranges, on top of the gosnappi flow size choices. A profile knows the
+// average frame size of its mix and the octets a number of frames carries,
+// so tests can check the octet counters of a flow against the mix it sends;
+// linerate.NewLink takes a profile to the frame rate at a line rate.
+package framesize
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/open-traffic-generator/snappi/gosnappi"
+)
+
+// Weight is a frame size of a mix and how often the flow sends it, relative
+// to the other sizes of the mix.
+type Weight struct {
+	Size   uint32
+	Weight float64
+}
+
+// Profile is the frame sizes a flow sends.
+type Profile struct {
+	Name   string
+	Choice gosnappi.FlowSizeChoiceEnum
+	// Fixed is the size of FIXED profiles.
+	Fixed uint32
+	// Min, Max and Step are the range of INCREMENT profiles, which step from
+	// Min up to Max, and RANDOM ones, which ignore Step.
+	Min  uint32
+	Max  uint32
+	Step uint32
+	// Predefined names the distribution of WEIGHT_PAIRS profiles the service
+	// knows, empty for custom ones; Weights holds the distribution either way.
+	Predefined gosnappi.FlowSizeWeightPairsPredefinedEnum
+	Weights    []Weight
+}
+
+// The predefined IMIX distributions of OTG.
+var (
+	// SimpleImix is the classic 7:4:1 mix of 64, 570 and 1518 byte frames.
+	SimpleImix = predefined("imix", gosnappi.FlowSizeWeightPairsPredefined.IMIX,
+		Weight{64, 7}, Weight{570, 4}, Weight{1518, 1})
+	// InternetImix is the mix of Internet traffic OTG calls standard IMIX.
+	InternetImix = predefined("internet imix", gosnappi.FlowSizeWeightPairsPredefined.STANDARD_IMIX,
+		Weight{58, 58.67}, Weight{62, 2}, Weight{594, 23.66}, Weight{1518, 15.67})
+	IpsecImix = predefined("ipsec imix", gosnappi.FlowSizeWeightPairsPredefined.IPSEC_IMIX,
+		Weight{90, 58.67}, Weight{92, 2}, Weight{594, 23.66}, Weight{1418, 15.67})
+	Ipv6Imix = predefined("ipv6 imix", gosnappi.FlowSizeWeightPairsPredefined.IPV6_IMIX,
+		Weight{60, 58.67}, Weight{496, 2}, Weight{594, 23.66}, Weight{1518, 15.67})
+	TcpImix = predefined("tcp imix", gosnappi.FlowSizeWeightPairsPredefined.TCP_IMIX,
+		Weight{90, 58.67}, Weight{92, 2}, Weight{594, 23.66}, Weight{1518, 15.67})
+)
+
+var predefinedProfiles = []Profile{SimpleImix, InternetImix, IpsecImix, Ipv6Imix, TcpImix}
+
+func predefined(name string, p gosnappi.FlowSizeWeightPairsPredefinedEnum, weights ...Weight) Profile {
+	return Profile{Name: name, Choice: gosnappi.FlowSizeChoice.WEIGHT_PAIRS, Predefined: p, Weights: weights}
+}
+
+// Fixed returns the profile of frames of a single size.
+func Fixed(size uint32) Profile {
+	return Profile{Name: fmt.Sprintf("fixed %d", size), Choice: gosnappi.FlowSizeChoice.FIXED, Fixed: size}
+}
+
+// Increment returns the profile of frame sizes stepping from min up to max.
+func Increment(min, max, step uint32) Profile {
+	return Profile{
+		Name:   fmt.Sprintf("increment %d-%d/%d", min, max, step),
+		Choice: gosnappi.FlowSizeChoice.INCREMENT,
+		Min:    min, Max: max, Step: step,
+	}
+}
+
+// Random returns the profile of frame sizes spread uniformly between min and
+// max.
+func Random(min, max uint32) Profile {
+	return Profile{
+		Name:   fmt.Sprintf("random %d-%d", min, max),
+		Choice: gosnappi.FlowSizeChoice.RANDOM,
+		Min:    min, Max: max,
+	}
+}
+
+// Custom returns a weighted mix of frame sizes, e.g.
+// Custom("voice", Weight{128, 9}, Weight{1518, 1}).
+func Custom(name string, weights ...Weight) Profile {
+	return Profile{Name: name, Choice: gosnappi.FlowSizeChoice.WEIGHT_PAIRS, Weights: weights}
+}
+
+// FromFlowSize returns the profile of a gosnappi flow size, with the defaults
+// of the fields it leaves unset.
+func FromFlowSize(size gosnappi.FlowSize) (Profile, error) {
+	switch size.Choice() {
+	case gosnappi.FlowSizeChoice.INCREMENT:
+		inc := size.Increment()
+		return Increment(inc.Start(), inc.End(), inc.Step()), nil
+	case gosnappi.FlowSizeChoice.RANDOM:
+		return Random(size.Random().Min(), size.Random().Max()), nil
+	case gosnappi.FlowSizeChoice.WEIGHT_PAIRS:
+		pairs := size.WeightPairs()
+		if pairs.Choice() == gosnappi.FlowSizeWeightPairsChoice.CUSTOM {
+			p := Custom("custom")
+			for _, w := range pairs.Custom().Items() {
+				p.Weights = append(p.Weights, Weight{w.Size(), float64(w.Weight())})
+			}
+			return p, nil
+		}
+		for _, p := range predefinedProfiles {
+			if p.Predefined == pairs.Predefined() {
+				return p, nil
+			}
+		}
+		return Profile{}, fmt.Errorf("unknown predefined frame size distribution %s", pairs.Predefined())
+	}
+	return Fixed(size.Fixed()), nil
+}
+
+// Apply sets size to send the frame sizes of the profile, e.g.
+// framesize.SimpleImix.Apply(flow.Size()).
+func (p Profile) Apply(size gosnappi.FlowSize) {
+	switch p.Choice {
+	case gosnappi.FlowSizeChoice.INCREMENT:
+		size.Increment().SetStart(p.Min).SetEnd(p.Max).SetStep(max(p.Step, 1))
+	case gosnappi.FlowSizeChoice.RANDOM:
+		size.Random().SetMin(p.Min).SetMax(p.Max)
+	case gosnappi.FlowSizeChoice.WEIGHT_PAIRS:
+		if p.Predefined != "" {
+			size.WeightPairs().SetPredefined(p.Predefined)
+			return
+		}
+		custom := size.WeightPairs().Custom()
+		custom.Clear()
+		for _, w := range p.Weights {
+			custom.Add().SetSize(w.Size).SetWeight(float32(w.Weight))
+		}
+	default:
+		size.SetFixed(p.Fixed)
+	}
+}
+
+// Average returns the average size of the frames of the profile, in bytes.
+// Increments average over the sizes they step through, random sizes over
+// their range.
+func (p Profile) Average() float64 {
+	switch p.Choice {
+	case gosnappi.FlowSizeChoice.INCREMENT:
+		if p.Max < p.Min {
+			return float64(p.Min)
+		}
+		step := max(p.Step, 1)
+		last := p.Min + (p.Max-p.Min)/step*step
+		return float64(p.Min+last) / 2
+	case gosnappi.FlowSizeChoice.RANDOM:
+		return float64(p.Min+p.Max) / 2
+	case gosnappi.FlowSizeChoice.WEIGHT_PAIRS:
+		var sum, weights float64
+		for _, w := range p.Weights {
+			sum += float64(w.Size) * w.Weight
+			weights += w.Weight
+		}
+		if weights == 0 {
+			return 0
+		}
+		return sum / weights
+	}
+	return float64(p.Fixed)
+}
+
+// Octets returns the octets frames of the profile are expected to carry.
+func (p Profile) Octets(frames uint64) float64 {
+	return float64(frames) * p.Average()
+}
+
+// Check returns an error if the octet counters of the flow metrics m are off
+// the frame counters times the average frame size of the profile by more than
+// tolerance, e.g. 0.01 for one percent.
+func (p Profile) Check(m gosnappi.FlowMetric, tolerance float64) error {
+	if m.FramesTx() == 0 {
+		return fmt.Errorf("flow %s sent no frames", m.Name())
+	}
+	for _, c := range []struct {
+		dir    string
+		frames uint64
+		bytes  uint64
+	}{{"tx", m.FramesTx(), m.BytesTx()}, {"rx", m.FramesRx(), m.BytesRx()}} {
+		want := p.Octets(c.frames)
+		if math.Abs(float64(c.bytes)-want) > want*tolerance {
+			return fmt.Errorf("flow %s bytes %s got %d, want %.0f +- %.1f%% for %d frames of %s averaging %.2f bytes",
+				m.Name(), c.dir, c.bytes, want, tolerance*100, c.frames, p.Name, p.Average())
+		}
+	}
+	return nil
+}
+
+// String returns the name of the profile and its average frame size.
+func (p Profile) String() string {
+	return fmt.Sprintf("%s (%.2f bytes average)", p.Name, p.Average())
+}
@@ -0,0 +1,61 @@
+package framesize
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/open-traffic-generator/snappi/gosnappi"
+)
+
+func TestAverage(t *testing.T) {
+	for _, tc := range []struct {
+		p    Profile
+		want float64
+	}{
+		{Fixed(128), 128},
+		{SimpleImix, (64*7 + 570*4 + 1518) / 12.0},
+		{InternetImix, (58*58.67 + 62*2 + 594*23.66 + 1518*15.67) / 100},
+		{Increment(64, 1518, 1), 791},
+		// 64, 164, ..., 1464: the last step stops short of 1518.
+		{Increment(64, 1518, 100), 764},
+		{Random(64, 1518), 791},
+		{Custom("voice", Weight{128, 9}, Weight{1518, 1}), 267},
+	} {
+		if got := tc.p.Average(); math.Abs(got-tc.want) > 1e-9 {
+			t.Errorf("%s Average() got %f, want %f", tc.p.Name, got, tc.want)
+		}
+	}
+}
+
+func TestApply(t *testing.T) {
+	for _, p := range []Profile{
+		Fixed(512), SimpleImix, TcpImix, Increment(64, 1518, 2), Random(100, 200),
+		Custom("custom", Weight{64, 1}, Weight{1500, 3}),
+	} {
+		size := gosnappi.NewFlow().Size()
+		p.Apply(size)
+		got, err := FromFlowSize(size)
+		if err != nil {
+			t.Errorf("FromFlowSize() of %s failed: %v", p.Name, err)
+			continue
+		}
+		if got.Choice != p.Choice || got.Predefined != p.Predefined || got.Average() != p.Average() {
+			t.Errorf("FromFlowSize() after %s Apply() got %+v", p.Name, got)
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+	m := gosnappi.NewFlowMetric().SetName("flow1").
+		SetFramesTx(1200).SetFramesRx(1200).
+		SetBytesTx(424600).SetBytesRx(424600)
+	if err := SimpleImix.Check(m, 0.001); err != nil {
+		t.Errorf("Check() failed: %v", err)
+	}
+	m.SetBytesRx(1200 * 128)
+	err := SimpleImix.Check(m, 0.001)
+	if err == nil || !strings.Contains(err.Error(), "bytes rx got 153600, want 424600") {
+		t.Errorf("Check() of 128 byte frames got %v", err)
+	}
+}
@@ -2,7 +2,11 @@ module github.com/SpirentOrion/stc-otg/example/gosnappi
 
 go 1.21
 
-require github.com/open-traffic-generator/snappi/gosnappi v1.5.1
+require (
+	github.com/open-traffic-generator/snappi/gosnappi v1.5.1
+	google.golang.org/grpc v1.64.0
+	google.golang.org/protobuf v1.34.1
+)
 
 require (
 	github.com/Masterminds/semver/v3 v3.2.1 // indirect
@@ -11,7 +15,5 @@ require (
 	golang.org/x/sys v0.18.0 // indirect
 	golang.org/x/text v0.14.0 // indirect
 	google.golang.org/genproto/googleapis/rpc v0.0.0-20240318140521-94a12d6c2237 // indirect
-	google.golang.org/grpc v1.64.0 // indirect
-	google.golang.org/protobuf v1.34.1 // indirect
 	gopkg.in/yaml.v2 v2.2.3 // indirect
 )
@@ -0,0 +1,86 @@
+// Package golden compares OTG configs with golden files checked in next to
+// the tests that build them, so a refactor that changes the config a test
+// pushes fails the test with a configdiff of the changed fields, e.g.
+//
+//	flows[ISISv4Flow].rate.pps: 1000 -> 2000
+//
+// Run the tests with -update, or UPDATE_GOLDEN=1 in the environment, to
+// rewrite the golden files after an intended change.
+package golden
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/SpirentOrion/stc-otg/example/gosnappi/configdiff"
+	"github.com/open-traffic-generator/snappi/gosnappi"
+)
+
+// Update makes Check rewrite the golden files instead of comparing with them.
+// Test packages set it from an -update flag:
+//
+//	flag.BoolVar(&golden.Update, "update", false, "rewrite the golden files")
+var Update bool
+
+// UpdateEnv is the environment variable that, when set to anything but empty,
+// does the same as Update.
+const UpdateEnv = "UPDATE_GOLDEN"
+
+// Check compares config with the golden file at path and fails t with the
+// differences, or rewrites the file if Update or UpdateEnv is set.
+func Check(t testing.TB, path string, config gosnappi.Config) {
+	t.Helper()
+	got, err := Normalize(config)
+	if err != nil {
+		t.Fatalf("normalizing config: %v", err)
+	}
+	if Update || os.Getenv(UpdateEnv) != "" {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		t.Logf("updated %s", path)
+		return
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("reading golden config: %v (run with -update to create it)", err)
+	}
+	want := gosnappi.NewConfig()
+	if err := want.Unmarshal().FromJson(string(data)); err != nil {
+		t.Fatalf("parsing golden config %s: %v", path, err)
+	}
+	diffs, err := Diff(want, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diffs) > 0 {
+		t.Errorf("config differs from %s (run with -update if intended):\n%s", path, strings.Join(diffs, "\n"))
+	}
+}
+
+// Normalize returns config as indented JSON with sorted keys and without the
+// optional fields that are at their default value, as golden files hold it.
+// It is configdiff.Normalize.
+func Normalize(config gosnappi.Config) ([]byte, error) {
+	return configdiff.Normalize(config)
+}
+
+// Diff returns the differences between two configs with all defaults filled
+// in, one per line as "path: want -> got", as Check reports them. Named
+// objects are matched by name, see configdiff.Diff.
+func Diff(want, got gosnappi.Config) ([]string, error) {
+	changes, err := configdiff.Diff(want, got)
+	if err != nil {
+		return nil, err
+	}
+	diffs := []string{}
+	for _, c := range changes {
+		diffs = append(diffs, c.String())
+	}
+	return diffs, nil
+}
@@ -0,0 +1,98 @@
+package golden
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/SpirentOrion/stc-otg/example/gosnappi/configdiff"
+	"github.com/open-traffic-generator/snappi/gosnappi"
+)
+
+func init() {
+	flag.BoolVar(&Update, "update", false, "rewrite the golden files")
+}
+
+// isisConfig returns the ports, devices and flows of the isis_basic test.
+func isisConfig() gosnappi.Config {
+	config := gosnappi.NewConfig()
+	for i, name := range []string{"dtx", "drx"} {
+		port := config.Ports().Add().SetName(fmt.Sprintf("port%d", i+1))
+		d := config.Devices().Add().SetName(name)
+		eth := d.Ethernets().Add().SetName(name + "Eth").SetMac(fmt.Sprintf("02:00:01:01:01:%02d", i+1))
+		eth.Connection().SetPortName(port.Name())
+		eth.Ipv4Addresses().Add().SetName(name + "Ipv4").
+			SetAddress(fmt.Sprintf("1.1.1.%d", i+1)).SetGateway(fmt.Sprintf("1.1.1.%d", 2-i)).SetPrefix(24)
+		isis := d.Isis().SetName(name + "Isis").SetSystemId(fmt.Sprintf("%d50000000001", i+6))
+		isis.Interfaces().Add().SetName(name + "IsisInt").SetEthName(eth.Name()).
+			SetNetworkType(gosnappi.IsisInterfaceNetworkType.POINT_TO_POINT).
+			SetLevelType(gosnappi.IsisInterfaceLevelType.LEVEL_2)
+		isis.V4Routes().Add().SetName(name + "IsisRr4").Addresses().Add().
+			SetAddress(fmt.Sprintf("%d.1.1.1", (i+1)*100)).SetPrefix(32).SetCount(5)
+	}
+	flow := config.Flows().Add().SetName("ISISv4Flow")
+	flow.TxRx().Device().SetTxNames([]string{"dtxIsisRr4"}).SetRxNames([]string{"drxIsisRr4"})
+	flow.Metrics().SetEnable(true)
+	flow.Rate().SetPps(1000)
+	flow.Size().SetFixed(512)
+	flow.Duration().FixedPackets().SetPackets(1000)
+	flow.Packet().Add().Ethernet().Src().SetValue("02:00:01:01:01:01")
+	v4 := flow.Packet().Add().Ipv4()
+	v4.Src().SetValue("100.1.1.1")
+	v4.Dst().Increment().SetStart("200.1.1.1").SetCount(5)
+	return config
+}
+
+func TestGolden(t *testing.T) {
+	Check(t, "testdata/isis.json", isisConfig())
+}
+
+// TestWrappers checks that Normalize and Diff are configdiff's; configdiff
+// tests them.
+func TestWrappers(t *testing.T) {
+	config := isisConfig()
+	got, err := Normalize(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, _ := configdiff.Normalize(config); !bytes.Equal(got, want) {
+		t.Errorf("Normalize() got\n%s\nwant configdiff.Normalize()\n%s", got, want)
+	}
+
+	changed := isisConfig()
+	changed.Flows().Items()[0].Rate().SetPps(2000)
+	diffs, err := Diff(config, changed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	changes, _ := configdiff.Diff(config, changed)
+	if len(diffs) != 1 || len(changes) != 1 || diffs[0] != changes[0].String() {
+		t.Errorf("Diff() got %v, want configdiff.Diff() %v", diffs, changes)
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		set  func()
+	}{
+		{"flag", func() { Update = true }},
+		{"env", func() { t.Setenv(UpdateEnv, "1") }},
+	} {
+		path := filepath.Join(t.TempDir(), "testdata", "isis.json")
+		update := Update
+		tc.set()
+		Check(t, path, isisConfig())
+		if _, err := os.Stat(path); err != nil {
+			t.Fatalf("%s: Check did not write the golden file: %v", tc.name, err)
+		}
+
+		// The written file is what Check compares with afterwards.
+		Update = update
+		t.Setenv(UpdateEnv, "")
+		Check(t, path, isisConfig())
+	}
+}
@@ -0,0 +1,203 @@
+// Package gre builds flows of GRE tunneled frames, ethernet, an outer IPv4
+// or IPv6 header, GRE with an optional checksum and an inner IPv4 or IPv6
+// packet, and checks their delivery against the MTU of the ports. A Tunnel
+// takes the size of the inner packet and sizes the frames for the
+// encapsulation around it. Frames whose outer packet fits the MTU must
+// arrive whole, neither lost nor fragmented; larger ones must not arrive at
+// all, as in the large_ip_packet_transmission test.
+package gre
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/SpirentOrion/stc-otg/example/gosnappi/headerstack"
+	"github.com/SpirentOrion/stc-otg/example/gosnappi/linerate"
+	"github.com/open-traffic-generator/snappi/gosnappi"
+)
+
+// Ether types of the inner packet in the GRE protocol field.
+const (
+	protocolIpv4 = 0x0800
+	protocolIpv6 = 0x86dd
+)
+
+// DefaultMtu is the MTU of ethernets that do not set one.
+const DefaultMtu = 1500
+
+// Tunnel is a flow of GRE tunneled packets from one port to another.
+type Tunnel struct {
+	Name string
+	// Tx and Rx are the names of the ports the flow is sent from and to.
+	Tx string
+	Rx string
+	// OuterSrc and OuterDst are the tunnel endpoints, InnerSrc and InnerDst
+	// the addresses of the tunneled packets; each pair is IPv4 or IPv6.
+	OuterSrc string
+	OuterDst string
+	InnerSrc string
+	InnerDst string
+	// Checksum adds the GRE checksum and its 4 bytes.
+	Checksum bool
+	// InnerSize is the size of the inner packet, 1000 if zero.
+	InnerSize uint32
+	// Pps is the rate of the flow, 1000 if zero.
+	Pps uint64
+	// Frames is the number of frames to send, continuous if zero.
+	Frames uint32
+}
+
+// Frames is the flow of a tunnel with the sizes of its frames.
+type Frames struct {
+	Flow gosnappi.Flow
+	// Size is the frame size, the inner packet plus Encapsulation.
+	Size uint32
+	// Encapsulation is the bytes the tunnel adds to the inner packet:
+	// ethernet, the outer IP header, GRE and the FCS.
+	Encapsulation uint32
+	// Packet is the size of the outer IP packet, which the MTU applies to.
+	Packet uint32
+}
+
+// pair returns whether a and b are IPv6 addresses, and an error unless both
+// are addresses of the same family.
+func pair(a, b string) (bool, error) {
+	ipa, ipb := net.ParseIP(a), net.ParseIP(b)
+	if ipa == nil || ipb == nil {
+		return false, fmt.Errorf("%q and %q are not IP addresses", a, b)
+	}
+	if (ipa.To4() == nil) != (ipb.To4() == nil) {
+		return false, fmt.Errorf("%s and %s are of different families", a, b)
+	}
+	return ipa.To4() == nil, nil
+}
+
+// Add adds the flow of the tunnel to config.
+func (t Tunnel) Add(config gosnappi.Config) (*Frames, error) {
+	outerV6, err := pair(t.OuterSrc, t.OuterDst)
+	if err != nil {
+		return nil, fmt.Errorf("tunnel %s: outer addresses %v", t.Name, err)
+	}
+	innerV6, err := pair(t.InnerSrc, t.InnerDst)
+	if err != nil {
+		return nil, fmt.Errorf("tunnel %s: inner addresses %v", t.Name, err)
+	}
+	inner, pps := t.InnerSize, t.Pps
+	if inner == 0 {
+		inner = 1000
+	}
+	if pps == 0 {
+		pps = 1000
+	}
+	innerHeader := uint32(20)
+	if innerV6 {
+		innerHeader = 40
+	}
+	if inner < innerHeader {
+		return nil, fmt.Errorf("tunnel %s: inner packet size %d below its %d byte header", t.Name, inner, innerHeader)
+	}
+
+	flow := gosnappi.NewFlow().SetName(t.Name)
+	flow.TxRx().Port().SetTxName(t.Tx).SetRxNames([]string{t.Rx})
+	flow.Metrics().SetEnable(true)
+	flow.Rate().SetPps(pps)
+	if t.Frames > 0 {
+		flow.Duration().FixedPackets().SetPackets(t.Frames)
+	} else {
+		flow.Duration().Continuous()
+	}
+	eth := flow.Packet().Add().Ethernet()
+	eth.Src().SetValue("00:11:22:33:44:66")
+	eth.Dst().SetValue("00:11:22:33:44:55")
+	if outerV6 {
+		ip := flow.Packet().Add().Ipv6()
+		ip.Src().SetValue(t.OuterSrc)
+		ip.Dst().SetValue(t.OuterDst)
+	} else {
+		ip := flow.Packet().Add().Ipv4()
+		ip.Src().SetValue(t.OuterSrc)
+		ip.Dst().SetValue(t.OuterDst)
+	}
+	gre := flow.Packet().Add().Gre()
+	if t.Checksum {
+		gre.ChecksumPresent().SetValue(1)
+	}
+	if innerV6 {
+		gre.Protocol().SetValue(protocolIpv6)
+		ip := flow.Packet().Add().Ipv6()
+		ip.Src().SetValue(t.InnerSrc)
+		ip.Dst().SetValue(t.InnerDst)
+	} else {
+		gre.Protocol().SetValue(protocolIpv4)
+		ip := flow.Packet().Add().Ipv4()
+		ip.Src().SetValue(t.InnerSrc)
+		ip.Dst().SetValue(t.InnerDst)
+	}
+
+	headers, err := headerstack.HeaderLen(flow)
+	if err != nil {
+		return nil, err
+	}
+	f := &Frames{Flow: flow, Encapsulation: uint32(headers) - innerHeader + 4}
+	f.Size = max(inner+f.Encapsulation, 64)
+	l2, _ := linerate.L2Overhead(flow.Packet())
+	f.Packet = f.Size - l2
+	flow.Size().SetFixed(f.Size)
+	config.Flows().Append(flow)
+	return f, nil
+}
+
+// Fits reports whether the outer packets fit mtu.
+func (f *Frames) Fits(mtu uint32) bool {
+	return f.Packet <= mtu
+}
+
+// MaxInnerSize returns the largest inner packet of the tunnel whose outer
+// packet fits mtu.
+func (t Tunnel) MaxInnerSize(mtu uint32) (uint32, error) {
+	f, err := t.Add(gosnappi.NewConfig())
+	if err != nil {
+		return 0, err
+	}
+	return mtu + f.Size - f.Packet - f.Encapsulation, nil
+}
+
+// Mtu returns the smallest MTU of the device ethernets connected to the named
+// ports, DefaultMtu for ports without any.
+func Mtu(config gosnappi.Config, ports ...string) uint32 {
+	if mtu := linerate.Mtu(config, ports...); mtu > 0 {
+		return mtu
+	}
+	return DefaultMtu
+}
+
+// Check returns an error if the frames of the flow metrics m were not
+// delivered as the MTU demands: all of them but a loss of up to
+// lossTolerance, e.g. 0.005 for half a percent, and each at its full size
+// when their outer packets fit mtu, none otherwise. Frames received at
+// another size, or more frames than sent, were fragmented.
+func (f *Frames) Check(m gosnappi.FlowMetric, mtu uint32, lossTolerance float64) error {
+	tx, rx := m.FramesTx(), m.FramesRx()
+	if tx == 0 {
+		return fmt.Errorf("flow %s sent no frames", f.Flow.Name())
+	}
+	var errs []string
+	if rx > 0 && (rx > tx || m.BytesRx() != rx*uint64(f.Size)) {
+		errs = append(errs, fmt.Sprintf("received %d bytes in %d of %d frames, want %d bytes each: fragmented",
+			m.BytesRx(), rx, tx, f.Size))
+	}
+	if f.Fits(mtu) {
+		if loss := float64(tx-min(rx, tx)) / float64(tx); loss > lossTolerance {
+			errs = append(errs, fmt.Sprintf("received %d of %d frames, a loss of %.2f%% above %.2f%%",
+				rx, tx, loss*100, lossTolerance*100))
+		}
+	} else if rx > 0 {
+		errs = append(errs, fmt.Sprintf("received %d frames with %d byte outer packets above the MTU of %d bytes",
+			rx, f.Packet, mtu))
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("flow %s:\n%s", f.Flow.Name(), strings.Join(errs, "\n"))
+	}
+	return nil
+}
@@ -0,0 +1,160 @@
+package gre
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/SpirentOrion/stc-otg/example/gosnappi/otgsimtest"
+	"github.com/open-traffic-generator/snappi/gosnappi"
+)
+
+func TestAdd(t *testing.T) {
+	config := gosnappi.NewConfig()
+	for _, tc := range []struct {
+		tunnel Tunnel
+		encap  uint32
+	}{
+		// 14 + 20 + 4 bytes of headers and the FCS.
+		{Tunnel{Name: "v4", OuterSrc: "10.0.0.1", OuterDst: "10.0.0.2", InnerSrc: "172.16.0.1", InnerDst: "172.16.0.2"}, 42},
+		// 14 + 40 + 8 bytes of headers and the FCS.
+		{Tunnel{Name: "v6", OuterSrc: "2001:db8::1", OuterDst: "2001:db8::2", InnerSrc: "2001:db8:1::1", InnerDst: "2001:db8:1::2", Checksum: true}, 66},
+	} {
+		f, err := tc.tunnel.Add(config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if f.Encapsulation != tc.encap || f.Size != 1000+tc.encap || f.Flow.Size().Fixed() != f.Size {
+			t.Errorf("%s: Add() got encapsulation %d and size %d, want %d and %d", tc.tunnel.Name, f.Encapsulation, f.Size, tc.encap, 1000+tc.encap)
+		}
+		if f.Packet != f.Size-18 {
+			t.Errorf("%s: Add() got outer packet %d, want %d", tc.tunnel.Name, f.Packet, f.Size-18)
+		}
+		if inner, err := tc.tunnel.MaxInnerSize(1500); err != nil || inner+tc.encap-18 != 1500 {
+			t.Errorf("%s: MaxInnerSize(1500) got %d, %v", tc.tunnel.Name, inner, err)
+		}
+	}
+	gre := config.Flows().Items()[1].Packet().Items()[2].Gre()
+	if gre.ChecksumPresent().Value() != 1 || gre.Protocol().Value() != protocolIpv6 {
+		t.Errorf("Add() got checksum present %d and protocol 0x%x", gre.ChecksumPresent().Value(), gre.Protocol().Value())
+	}
+
+	for _, tc := range []struct {
+		tunnel Tunnel
+		want   string
+	}{
+		{Tunnel{Name: "f", OuterSrc: "10.0.0.1", OuterDst: "2001:db8::2", InnerSrc: "172.16.0.1", InnerDst: "172.16.0.2"}, "outer addresses 10.0.0.1 and 2001:db8::2 are of different families"},
+		{Tunnel{Name: "f", OuterSrc: "10.0.0.1", OuterDst: "10.0.0.2", InnerSrc: "host", InnerDst: "172.16.0.2"}, "inner addresses \"host\""},
+		{Tunnel{Name: "f", OuterSrc: "10.0.0.1", OuterDst: "10.0.0.2", InnerSrc: "2001:db8::1", InnerDst: "2001:db8::2", InnerSize: 20}, "inner packet size 20 below its 40 byte header"},
+	} {
+		if _, err := tc.tunnel.Add(config); err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("Add() of %+v got %v, want %q", tc.tunnel, err, tc.want)
+		}
+	}
+}
+
+// mtuConfig returns two ports with a device ethernet of the given MTU each.
+func mtuConfig(mtu1, mtu2 uint32) gosnappi.Config {
+	config := gosnappi.NewConfig()
+	for i, mtu := range []uint32{mtu1, mtu2} {
+		port := config.Ports().Add().SetName([]string{"p1", "p2"}[i])
+		eth := config.Devices().Add().SetName(port.Name()).Ethernets().Add().SetName(port.Name() + ".eth").
+			SetMac([]string{"02:00:00:00:00:01", "02:00:00:00:00:02"}[i])
+		if mtu > 0 {
+			eth.SetMtu(mtu)
+		}
+		eth.Connection().SetPortName(port.Name())
+	}
+	return config
+}
+
+func TestMtu(t *testing.T) {
+	if got := Mtu(mtuConfig(9000, 1600), "p1", "p2"); got != 1600 {
+		t.Errorf("Mtu() got %d, want the smaller 1600", got)
+	}
+	if got := Mtu(mtuConfig(9000, 0), "p1"); got != 9000 {
+		t.Errorf("Mtu() of p1 got %d, want 9000", got)
+	}
+	if got := Mtu(mtuConfig(9000, 0), "p2"); got != DefaultMtu {
+		t.Errorf("Mtu() of an ethernet without MTU got %d, want %d", got, DefaultMtu)
+	}
+	if got := Mtu(gosnappi.NewConfig(), "p1"); got != DefaultMtu {
+		t.Errorf("Mtu() of a port without devices got %d, want %d", got, DefaultMtu)
+	}
+}
+
+func TestRun(t *testing.T) {
+	_, api := otgsimtest.Start(t)
+
+	config := mtuConfig(1500, 1500)
+	mtu := Mtu(config, "p1", "p2")
+	tunnel := Tunnel{Name: "fits", Tx: "p1", Rx: "p2", OuterSrc: "10.0.0.1", OuterDst: "10.0.0.2",
+		InnerSrc: "172.16.0.1", InnerDst: "172.16.0.2", Checksum: true, Frames: 100}
+	var err error
+	// The largest inner packet that fits and one byte more.
+	if tunnel.InnerSize, err = tunnel.MaxInnerSize(mtu); err != nil {
+		t.Fatal(err)
+	}
+	if tunnel.InnerSize != 1472 {
+		t.Errorf("MaxInnerSize(%d) got %d, want 1472 within 46 bytes of encapsulation", mtu, tunnel.InnerSize)
+	}
+	fits, err := tunnel.Add(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tunnel.Name, tunnel.InnerSize = "oversize", tunnel.InnerSize+1
+	oversize, err := tunnel.Add(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !fits.Fits(mtu) || oversize.Fits(mtu) {
+		t.Fatalf("Fits(%d) got %t and %t for outer packets of %d and %d bytes", mtu, fits.Fits(mtu), oversize.Fits(mtu), fits.Packet, oversize.Packet)
+	}
+
+	if _, err := api.SetConfig(config); err != nil {
+		t.Fatal(err)
+	}
+	cs := gosnappi.NewControlState()
+	cs.Traffic().FlowTransmit().SetState(gosnappi.StateTrafficFlowTransmitState.START)
+	if _, err := api.SetControlState(cs); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(20 * time.Millisecond)
+
+	req := gosnappi.NewMetricsRequest()
+	req.Flow()
+	res, err := api.GetMetrics(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, f := range []*Frames{fits, oversize} {
+		m := res.FlowMetrics().Items()[i]
+		if err := f.Check(m, mtu, 0); err != nil {
+			t.Errorf("Check() failed: %v", err)
+		}
+	}
+	if m := res.FlowMetrics().Items()[1]; m.FramesRx() != 0 {
+		t.Errorf("oversize received %d frames, want none", m.FramesRx())
+	}
+}
+
+func TestCheck(t *testing.T) {
+	f := &Frames{Flow: gosnappi.NewFlow().SetName("gre"), Size: 1000, Encapsulation: 42, Packet: 982}
+	for _, tc := range []struct {
+		desc string
+		m    gosnappi.FlowMetric
+		mtu  uint32
+		want string
+	}{
+		{"fragmented", gosnappi.NewFlowMetric().SetFramesTx(100).SetFramesRx(200).SetBytesRx(100000), 1500, "fragmented"},
+		{"lost", gosnappi.NewFlowMetric().SetFramesTx(100).SetFramesRx(90).SetBytesRx(90000), 1500, "received 90 of 100 frames, a loss of 10.00%"},
+		{"above the MTU", gosnappi.NewFlowMetric().SetFramesTx(100).SetFramesRx(100).SetBytesRx(100000), 900, "received 100 frames with 982 byte outer packets above the MTU of 900 bytes"},
+		{"delivered", gosnappi.NewFlowMetric().SetFramesTx(100).SetFramesRx(100).SetBytesRx(100000), 1500, ""},
+		{"dropped", gosnappi.NewFlowMetric().SetFramesTx(100).SetFramesRx(0).SetBytesRx(0), 900, ""},
+	} {
+		err := f.Check(tc.m, tc.mtu, 0.005)
+		if tc.want == "" && err != nil || tc.want != "" && (err == nil || !strings.Contains(err.Error(), tc.want)) {
+			t.Errorf("%s: Check() got %v, want %q", tc.desc, err, tc.want)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package gosnappi_examples
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/SpirentOrion/stc-otg/example/gosnappi/gre"
+	"github.com/SpirentOrion/stc-otg/example/gosnappi/trial"
+	"github.com/open-traffic-generator/snappi/gosnappi"
+)
+
+// PORT1=10.109.121.181/1/1 PORT2=10.109.123.254/1/1 OTGSERVER=localhost:50051 go test -v -test.run TestGreTunnel
+func TestGreTunnel(t *testing.T) {
+	// Create a new API handle to make API calls against OTG
+	api := gosnappi.NewApi()
+
+	// Set the transport protocol to gRPC
+	api.NewGrpcTransport().
+		SetLocation(OTGSERVER).
+		SetDialTimeout(3 * time.Minute).
+		SetRequestTimeout(10 * time.Minute)
+
+	// Ports with a device ethernet of a 9216 byte MTU each, like the
+	// large_ip_packet_transmission test
+	config := gosnappi.NewConfig()
+	for i, location := range []string{PORT1, PORT2} {
+		port := config.Ports().Add().SetName(fmt.Sprintf("port%d", i+1)).SetLocation(location)
+		eth := config.Devices().Add().SetName(port.Name()).Ethernets().Add().SetName(port.Name() + "_ETH").
+			SetMac(fmt.Sprintf("02:00:0%d:01:01:01", i+1)).SetMtu(9216)
+		eth.Connection().SetPortName(port.Name())
+	}
+	mtu := gre.Mtu(config, "port1", "port2")
+
+	// For IPv4 in IPv4 and, with the GRE checksum, IPv6 in IPv6 tunnels,
+	// send the largest inner packets that fit the MTU once encapsulated and
+	// packets one byte larger
+	tunnels := []gre.Tunnel{
+		{OuterSrc: "10.1.1.1", OuterDst: "20.1.1.1", InnerSrc: "172.16.0.1", InnerDst: "172.17.0.1"},
+		{OuterSrc: "2001:db8::1", OuterDst: "2001:db8::2", InnerSrc: "2001:db8:1::1", InnerDst: "2001:db8:2::1", Checksum: true},
+	}
+	frames := []*gre.Frames{}
+	for i, tunnel := range tunnels {
+		tunnel.Tx, tunnel.Rx, tunnel.Pps, tunnel.Frames = "port1", "port2", 1000, 10000
+		inner, err := tunnel.MaxInnerSize(mtu)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, size := range []uint32{inner, inner + 1} {
+			tunnel.Name, tunnel.InnerSize = fmt.Sprintf("gre%d_%d", i+1, size), size
+			f, err := tunnel.Add(config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Logf("flow %s: %d byte frames, %d byte outer packets, fits MTU %d: %t",
+				f.Flow.Name(), f.Size, f.Packet, mtu, f.Fits(mtu))
+			frames = append(frames, f)
+		}
+	}
+
+	// Run the flows until they stop on their own and let the last frames
+	// arrive before reading the metrics
+	runner := trial.Runner{Api: api, Settle: 2 * time.Second}
+	metrics, err := runner.Run(config, nil, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Frames that fit arrive whole, larger ones not at all
+	for _, f := range frames {
+		m, ok := metrics[f.Flow.Name()]
+		if !ok {
+			t.Errorf("no metrics for flow %s", f.Flow.Name())
+			continue
+		}
+		t.Logf("flow %s: tx %d rx %d bytes rx %d", m.Name(), m.FramesTx(), m.FramesRx(), m.BytesRx())
+		if err := f.Check(m, mtu, 0.005); err != nil {
+			t.Error(err)
+		}
+	}
+}
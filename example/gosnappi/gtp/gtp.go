@@ -0,0 +1,255 @@
+// Package gtp generates mobile-core traffic: flows of GTPv1-U tunneled
+// frames, outer IPv4/UDP/GTPv1 and inner IPv4/UDP, for a number of
+// subscribers. Each subscriber has its own TEID and inner address; both
+// increment from frame to frame in step, and both carry a metric tag so the
+// tagged metrics break the flow down by subscriber. Analyze checks from them
+// that every TEID got its share of the frames and delivered all of them.
+package gtp
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"net"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/SpirentOrion/stc-otg/example/gosnappi/headerstack"
+	"github.com/SpirentOrion/stc-otg/example/gosnappi/metrictags"
+	"github.com/open-traffic-generator/snappi/gosnappi"
+)
+
+// Port is the UDP port of GTP-U.
+const Port = 2152
+
+// Profile is the traffic of a set of subscribers in one direction.
+type Profile struct {
+	Name string
+	// Tx and Rx are the names of the ports the flow is sent from and to.
+	Tx string
+	Rx string
+	// Subscribers is the number of subscribers, 1 if zero.
+	Subscribers uint32
+	// Teid is the TEID of the first subscriber, 1 if zero; TeidStep the
+	// increment to the next, 1 if zero.
+	Teid     uint32
+	TeidStep uint32
+	// OuterSrc and OuterDst are the addresses of the tunnel endpoints, e.g.
+	// the eNodeB and the serving gateway.
+	OuterSrc string
+	OuterDst string
+	// Subscriber is the inner address of the first subscriber, SubscriberStep
+	// the increment to the next, 0.0.0.1 if empty.
+	Subscriber     string
+	SubscriberStep string
+	// Server is the inner address the subscribers talk to.
+	Server string
+	// Downlink sends the frames from the server to the subscribers: the
+	// subscriber addresses go to the inner destination instead of the source.
+	Downlink bool
+	// Pps is the rate of the flow, 1000 if zero.
+	Pps uint64
+	// FrameSize is the size of the frames, 128 if zero.
+	FrameSize uint32
+	// Frames is the number of frames to send, continuous if zero.
+	Frames uint32
+}
+
+// Tunnels is the flow of a profile with the metric tags of its subscribers.
+type Tunnels struct {
+	Flow gosnappi.Flow
+	// Teids and Addresses are the TEID and inner address of each subscriber.
+	Teids     []uint32
+	Addresses []string
+	// Teid and Subscriber tag the TEID and the inner subscriber address.
+	Teid       metrictags.Tag
+	Subscriber metrictags.Tag
+}
+
+// Add adds the flow of the profile to config. It fails, leaving config as it
+// is, if an address is not IPv4, the TEIDs or subscriber addresses run past
+// their 32 bits, or the frame size is too small for the headers.
+func (p Profile) Add(config gosnappi.Config) (*Tunnels, error) {
+	n, teid, teidStep := p.Subscribers, p.Teid, p.TeidStep
+	if n == 0 {
+		n = 1
+	}
+	if teid == 0 {
+		teid = 1
+	}
+	if teidStep == 0 {
+		teidStep = 1
+	}
+	step := p.SubscriberStep
+	if step == "" {
+		step = "0.0.0.1"
+	}
+	for _, a := range []string{p.OuterSrc, p.OuterDst, p.Subscriber, step, p.Server} {
+		if ip := net.ParseIP(a); ip == nil || ip.To4() == nil {
+			return nil, fmt.Errorf("profile %s: %q is not an IPv4 address", p.Name, a)
+		}
+	}
+	if last := uint64(teid) + uint64(n-1)*uint64(teidStep); last > math.MaxUint32 {
+		return nil, fmt.Errorf("profile %s: %d TEIDs from %d by %d run past 0x%x", p.Name, n, teid, teidStep, uint32(math.MaxUint32))
+	}
+	start := new(big.Int).SetBytes(net.ParseIP(p.Subscriber).To4())
+	inc := new(big.Int).SetBytes(net.ParseIP(step).To4())
+	if last := new(big.Int).Mul(inc, big.NewInt(int64(n-1))); last.Add(last, start).BitLen() > 32 {
+		return nil, fmt.Errorf("profile %s: %d subscribers from %s by %s run past 255.255.255.255", p.Name, n, p.Subscriber, step)
+	}
+	pps, size := p.Pps, p.FrameSize
+	if pps == 0 {
+		pps = 1000
+	}
+	if size == 0 {
+		size = 128
+	}
+
+	flow := gosnappi.NewFlow().SetName(p.Name)
+	flow.TxRx().Port().SetTxName(p.Tx).SetRxNames([]string{p.Rx})
+	flow.Metrics().SetEnable(true)
+	flow.Rate().SetPps(pps)
+	flow.Size().SetFixed(size)
+	if p.Frames > 0 {
+		flow.Duration().FixedPackets().SetPackets(p.Frames)
+	} else {
+		flow.Duration().Continuous()
+	}
+	eth := flow.Packet().Add().Ethernet()
+	eth.Src().SetValue("00:11:22:33:44:66")
+	eth.Dst().SetValue("00:11:22:33:44:55")
+	outer := flow.Packet().Add().Ipv4()
+	outer.Src().SetValue(p.OuterSrc)
+	outer.Dst().SetValue(p.OuterDst)
+	udp := flow.Packet().Add().Udp()
+	udp.SrcPort().SetValue(Port)
+	udp.DstPort().SetValue(Port)
+	gtp := flow.Packet().Add().Gtpv1()
+	gtp.Teid().Increment().SetStart(teid).SetStep(teidStep).SetCount(n)
+	inner := flow.Packet().Add().Ipv4()
+	if p.Downlink {
+		inner.Src().SetValue(p.Server)
+		inner.Dst().Increment().SetStart(p.Subscriber).SetStep(step).SetCount(n)
+	} else {
+		inner.Src().Increment().SetStart(p.Subscriber).SetStep(step).SetCount(n)
+		inner.Dst().SetValue(p.Server)
+	}
+	flow.Packet().Add().Udp()
+
+	if min, err := headerstack.MinSize(flow); err != nil {
+		return nil, err
+	} else if size < min {
+		return nil, fmt.Errorf("profile %s: frame size %d below the %d bytes of its headers", p.Name, size, min)
+	}
+
+	t := &Tunnels{Flow: flow}
+	var err error
+	if t.Teid, err = metrictags.Add(flow, gtp.Teid(), "teid"); err != nil {
+		return nil, err
+	}
+	if p.Downlink {
+		t.Subscriber, err = metrictags.Add(flow, inner.Dst(), "subscriber")
+	} else {
+		t.Subscriber, err = metrictags.Add(flow, inner.Src(), "subscriber")
+	}
+	if err != nil {
+		return nil, err
+	}
+	for i := uint32(0); i < n; i++ {
+		t.Teids = append(t.Teids, teid+i*teidStep)
+		a := new(big.Int).Mul(inc, big.NewInt(int64(i)))
+		a.Add(a, start)
+		b := make([]byte, 4)
+		t.Addresses = append(t.Addresses, net.IP(a.FillBytes(b)).String())
+	}
+	config.Flows().Append(flow)
+	return t, nil
+}
+
+// Delivery is the traffic of one subscriber.
+type Delivery struct {
+	Teid     uint32
+	Address  string
+	FramesTx uint64
+	FramesRx uint64
+	// Share is the fraction of the frames of the flow sent to the
+	// subscriber, Want the expected fraction.
+	Share float64
+	Want  float64
+	// Mismatch marks subscribers whose frames by inner address differ from
+	// their frames by TEID, i.e. whose frames went into another tunnel.
+	Mismatch bool
+}
+
+// Result is the per-subscriber breakdown of the flow metrics of Tunnels.
+type Result struct {
+	Flow        string
+	Subscribers []Delivery
+	// Teid and Subscriber are the breakdowns by each tag.
+	Teid       *metrictags.Result
+	Subscriber *metrictags.Result
+}
+
+// Analyze breaks the flow metrics m, fetched with tagged metrics as by
+// metrictags.Metrics, down by subscriber. Subscribers are expected to get an
+// equal share of the frames within tolerance, e.g. 0.01 for one percentage
+// point.
+func (t *Tunnels) Analyze(m gosnappi.FlowMetric, tolerance float64) *Result {
+	r := &Result{
+		Flow:       t.Teid.Flow,
+		Teid:       t.Teid.Analyze(m, tolerance),
+		Subscriber: t.Subscriber.Analyze(m, tolerance),
+	}
+	for i := range t.Teids {
+		d := Delivery{Teid: t.Teids[i], Address: t.Addresses[i]}
+		if i < len(r.Teid.Values) {
+			v := r.Teid.Values[i]
+			d.FramesTx, d.FramesRx, d.Share, d.Want = v.FramesTx, v.FramesRx, v.Share, v.Want
+		}
+		if i < len(r.Subscriber.Values) {
+			v := r.Subscriber.Values[i]
+			d.Mismatch = v.FramesTx != d.FramesTx || v.FramesRx != d.FramesRx
+		}
+		r.Subscribers = append(r.Subscribers, d)
+	}
+	return r
+}
+
+// Check returns an error listing the TEIDs that lost frames or whose frames
+// do not match those of their subscriber address, along with the failures
+// of the tag breakdowns.
+func (r *Result) Check() error {
+	var errs []string
+	for _, tr := range []*metrictags.Result{r.Teid, r.Subscriber} {
+		if err := tr.Check(); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	for _, d := range r.Subscribers {
+		if d.FramesRx != d.FramesTx {
+			errs = append(errs, fmt.Sprintf("teid %d delivered %d of %d frames", d.Teid, d.FramesRx, d.FramesTx))
+		}
+		if d.Mismatch {
+			errs = append(errs, fmt.Sprintf("teid %d frames differ from those of subscriber %s", d.Teid, d.Address))
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("flow %s:\n%s", r.Flow, strings.Join(errs, "\n"))
+	}
+	return nil
+}
+
+// String renders the per-subscriber table.
+func (r *Result) String() string {
+	b := &strings.Builder{}
+	w := tabwriter.NewWriter(b, 0, 0, 1, ' ', 0)
+
+	fmt.Fprintf(w, "Flow %s by subscriber\n\n", r.Flow)
+	fmt.Fprint(w, "TEID\tSubscriber\tFramesTx\tFramesRx\tShare\tWant\n")
+	for _, d := range r.Subscribers {
+		fmt.Fprintf(w, "%d\t%s\t%d\t%d\t%.4f\t%.4f\n", d.Teid, d.Address, d.FramesTx, d.FramesRx, d.Share, d.Want)
+	}
+	fmt.Fprintf(w, "Flow\t\t%d\t%d\t\t\n", r.Teid.FramesTx, r.Teid.FramesRx)
+	w.Flush()
+	return b.String()
+}
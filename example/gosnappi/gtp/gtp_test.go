@@ -0,0 +1,147 @@
+package gtp
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/SpirentOrion/stc-otg/example/gosnappi/metrictags"
+	"github.com/SpirentOrion/stc-otg/example/gosnappi/otgsimtest"
+	"github.com/open-traffic-generator/snappi/gosnappi"
+)
+
+func profile() Profile {
+	return Profile{
+		Name: "uplink", Tx: "p1", Rx: "p2",
+		Subscribers: 4, Teid: 100, TeidStep: 2,
+		OuterSrc: "10.0.0.1", OuterDst: "10.0.0.2",
+		Subscriber: "172.16.0.254", SubscriberStep: "0.0.0.1",
+		Server: "192.0.2.1",
+		Frames: 1000,
+	}
+}
+
+func TestAdd(t *testing.T) {
+	config := gosnappi.NewConfig()
+	tun, err := profile().Add(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := tun.Addresses; strings.Join(got, " ") != "172.16.0.254 172.16.0.255 172.16.1.0 172.16.1.1" {
+		t.Errorf("Add() got addresses %v", got)
+	}
+	if got := tun.Teids; len(got) != 4 || got[3] != 106 {
+		t.Errorf("Add() got TEIDs %v, want 100 to 106", got)
+	}
+	if got := strings.Join(tun.Teid.Values, " "); got != "0x64 0x66 0x68 0x6a" {
+		t.Errorf("Add() got TEID tag values %s", got)
+	}
+	packet := tun.Flow.Packet().Items()
+	if got := packet[2].Udp().DstPort().Value(); got != Port {
+		t.Errorf("Add() got outer UDP port %d, want %d", got, Port)
+	}
+	if len(packet[4].Ipv4().Src().MetricTags().Items()) != 1 {
+		t.Error("Add() did not tag the inner source of an uplink")
+	}
+
+	p := profile()
+	p.Name, p.Downlink = "downlink", true
+	if tun, err = p.Add(config); err != nil {
+		t.Fatal(err)
+	}
+	inner := tun.Flow.Packet().Items()[4].Ipv4()
+	if inner.Src().Value() != "192.0.2.1" || len(inner.Dst().MetricTags().Items()) != 1 {
+		t.Error("Add() of a downlink did not send from the server to the tagged subscribers")
+	}
+
+	p.Name, p.FrameSize = "small", 64
+	if _, err := p.Add(config); err == nil || !strings.Contains(err.Error(), "frame size 64 below the 82 bytes") {
+		t.Errorf("Add() of a frame too small got %v", err)
+	}
+	p.FrameSize, p.Server = 0, "2001:db8::1"
+	if _, err := p.Add(config); err == nil {
+		t.Error("Add() of an IPv6 server succeeded")
+	}
+	p.Server, p.Subscriber = "192.0.2.1", "255.255.255.254"
+	if _, err := p.Add(config); err == nil || !strings.Contains(err.Error(), "run past 255.255.255.255") {
+		t.Errorf("Add() of subscribers past the last address got %v", err)
+	}
+	p.Subscriber, p.Teid = "172.16.0.254", 0xfffffffe
+	if _, err := p.Add(config); err == nil || !strings.Contains(err.Error(), "TEIDs") {
+		t.Errorf("Add() of TEIDs past 32 bits got %v", err)
+	}
+	if n := len(config.Flows().Items()); n != 2 {
+		t.Errorf("failed Add() left %d flows in the config, want the 2 added before", n)
+	}
+}
+
+func TestAnalyze(t *testing.T) {
+	_, api := otgsimtest.Start(t)
+
+	config := gosnappi.NewConfig()
+	config.Ports().Add().SetName("p1")
+	config.Ports().Add().SetName("p2")
+	tun, err := profile().Add(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := api.SetConfig(config); err != nil {
+		t.Fatal(err)
+	}
+	cs := gosnappi.NewControlState()
+	cs.Traffic().FlowTransmit().SetState(gosnappi.StateTrafficFlowTransmitState.START)
+	if _, err := api.SetControlState(cs); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(20 * time.Millisecond)
+
+	metrics, err := metrictags.Metrics(api, tun.Flow.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := tun.Analyze(metrics[0], 0.001)
+	if err := r.Check(); err != nil {
+		t.Errorf("Check() failed: %v\n%s", err, r)
+	}
+	for _, d := range r.Subscribers {
+		if d.FramesRx != 250 {
+			t.Errorf("teid %d got %d frames, want 250", d.Teid, d.FramesRx)
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+	config := gosnappi.NewConfig()
+	p := profile()
+	p.Subscribers = 2
+	tun, err := p.Add(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := gosnappi.NewFlowMetric().SetName("uplink").SetFramesTx(100).SetFramesRx(90)
+	row := func(teid, subscriber string, tx, rx uint64) {
+		tm := m.TaggedMetrics().Add().SetFramesTx(tx).SetFramesRx(rx)
+		tm.Tags().Add().SetName("teid").Value().SetHex(teid)
+		tm.Tags().Add().SetName("subscriber").Value().SetHex(subscriber)
+	}
+	row("0x64", "0xac1000fe", 50, 50)
+	row("0x66", "0xac1000ff", 40, 40)
+	// Frames of the second subscriber in the tunnel of the first.
+	row("0x64", "0xac1000ff", 10, 0)
+
+	err = tun.Analyze(m, 0.001).Check()
+	if err == nil {
+		t.Fatal("Check() passed")
+	}
+	for _, want := range []string{
+		"teid 100 delivered 50 of 60 frames",
+		"teid 100 frames differ from those of subscriber 172.16.0.254",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Check() got %v, want %q", err, want)
+		}
+	}
+	if strings.Contains(err.Error(), "teid 102 delivered") {
+		t.Errorf("Check() failed the TEID that delivered all frames: %v", err)
+	}
+}
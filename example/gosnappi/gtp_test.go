@@ -0,0 +1,64 @@
+package gosnappi_examples
+
+import (
+	"testing"
+	"time"
+
+	"github.com/SpirentOrion/stc-otg/example/gosnappi/gtp"
+	"github.com/SpirentOrion/stc-otg/example/gosnappi/trial"
+	"github.com/open-traffic-generator/snappi/gosnappi"
+)
+
+// PORT1=10.109.121.181/1/1 PORT2=10.109.123.254/1/1 OTGSERVER=localhost:50051 go test -v -test.run TestGtp
+func TestGtp(t *testing.T) {
+	// Create a new API handle to make API calls against OTG
+	api := gosnappi.NewApi()
+
+	// Set the transport protocol to gRPC
+	api.NewGrpcTransport().
+		SetLocation(OTGSERVER).
+		SetDialTimeout(3 * time.Minute).
+		SetRequestTimeout(10 * time.Minute)
+
+	config := gosnappi.NewConfig()
+	config.Ports().Add().SetName("port1").SetLocation(PORT1)
+	config.Ports().Add().SetName("port2").SetLocation(PORT2)
+
+	// Tunnel the uplink traffic of 100 subscribers from the eNodeB on port1
+	// to the gateway on port2, and their downlink traffic back
+	uplink := gtp.Profile{
+		Name: "uplink", Tx: "port1", Rx: "port2",
+		Subscribers: 100, Teid: 0x1000,
+		OuterSrc: "10.1.1.1", OuterDst: "10.1.1.2",
+		Subscriber: "172.16.0.1", Server: "192.0.2.1",
+		Pps: 10000, Frames: 100000,
+	}
+	downlink := uplink
+	downlink.Name, downlink.Tx, downlink.Rx, downlink.Downlink = "downlink", "port2", "port1", true
+	downlink.Teid, downlink.OuterSrc, downlink.OuterDst = 0x2000, "10.1.1.2", "10.1.1.1"
+	tunnels := []*gtp.Tunnels{}
+	for _, p := range []gtp.Profile{uplink, downlink} {
+		tun, err := p.Add(config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tunnels = append(tunnels, tun)
+	}
+
+	// Run the flows until they stop on their own and let the last frames
+	// arrive before reading the metrics of every TEID
+	runner := trial.Runner{Api: api, Settle: 2 * time.Second, TaggedMetrics: true}
+	metrics, err := runner.Run(config, []string{"uplink", "downlink"}, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Every TEID gets its share of the frames and delivers all of them
+	for _, tun := range tunnels {
+		res := tun.Analyze(metrics[tun.Flow.Name()], 0.001)
+		t.Log("\n" + res.String())
+		if err := res.Check(); err != nil {
+			t.Error(err)
+		}
+	}
+}
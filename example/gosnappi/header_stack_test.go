@@ -0,0 +1,32 @@
+package gosnappi_examples
+
+import (
+	"testing"
+	"time"
+
+	"github.com/SpirentOrion/stc-otg/example/gosnappi/headerstack"
+	"github.com/open-traffic-generator/snappi/gosnappi"
+)
+
+// PORT1=10.109.121.181/1/1 PORT2=10.109.123.254/1/1 OTGSERVER=localhost:50051 go test -v -test.run TestHeaderStacks
+func TestHeaderStacks(t *testing.T) {
+	// Create a new API handle to make API calls against OTG
+	api := gosnappi.NewApi()
+
+	// Set the transport protocol to gRPC
+	api.NewGrpcTransport().
+		SetLocation(OTGSERVER).
+		SetDialTimeout(3 * time.Minute).
+		SetRequestTimeout(10 * time.Minute)
+
+	// Send 1000 frames of every header stack, 128 bytes each
+	suite := &headerstack.Suite{Api: api, Locations: []string{PORT1, PORT2}}
+	results, err := suite.Run(headerstack.Stacks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Log("\n" + results.String())
+	if err := results.Err(); err != nil {
+		t.Error(err)
+	}
+}
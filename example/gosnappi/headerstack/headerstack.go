@@ -0,0 +1,235 @@
+// Package headerstack covers the flow packet header types STC supports with
+// one representative flow per header stack, from plain ethernet/ipv4 to
+// stacked ones like ethernet/vlan/vlan/mpls/ipv4/udp and
+// ethernet/ipv4/gre/ipv4. A Suite sends a fixed number of frames of each
+// stack and checks that every frame arrives and that the octet counters
+// match the frame size, which must hold the headers of the stack.
+package headerstack
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/open-traffic-generator/snappi/gosnappi"
+	"github.com/open-traffic-generator/snappi/gosnappi/otg"
+)
+
+// fcs is the frame check sequence every frame ends with, in bytes. Flow
+// frame sizes count it.
+const fcs = 4
+
+// minFrame is the minimum Ethernet frame size, in bytes.
+const minFrame = 64
+
+// Stack is a representative packet of a header stack.
+type Stack struct {
+	// Build adds the headers of the stack to the packet of a flow.
+	Build func(packet gosnappi.FlowFlowHeaderIter)
+	// Size is the frame size the stack is sent with, the size of the suite
+	// when zero.
+	Size uint32
+	// Consumed marks stacks the receiving port consumes instead of counting
+	// them as flow frames, like MAC control frames; only their tx side is
+	// checked.
+	Consumed bool
+}
+
+// Name returns the header types of the stack joined by slashes, e.g.
+// ethernet/ipv4/udp.
+func (s Stack) Name() string {
+	flow := gosnappi.NewFlow()
+	s.Build(flow.Packet())
+	names := []string{}
+	for _, h := range flow.Packet().Items() {
+		names = append(names, string(h.Choice()))
+	}
+	return strings.Join(names, "/")
+}
+
+// Stacks covers every flow header type of SupportedAPIsList.txt: arp,
+// custom, ethernet, ethernetpause, gre, gtpv1, icmp, icmpv6, igmpv1, ipv4,
+// ipv6, mpls, pfcpause, ppp, tcp, udp and vlan.
+var Stacks = []Stack{
+	{Build: func(p gosnappi.FlowFlowHeaderIter) {
+		ethernet(p)
+		p.Add().Ipv4()
+	}},
+	{Build: func(p gosnappi.FlowFlowHeaderIter) {
+		ethernet(p)
+		ipv4(p)
+		p.Add().Udp().DstPort().SetValue(7)
+	}},
+	{Build: func(p gosnappi.FlowFlowHeaderIter) {
+		ethernet(p)
+		ipv4(p)
+		p.Add().Tcp().DstPort().SetValue(80)
+	}},
+	{Build: func(p gosnappi.FlowFlowHeaderIter) {
+		ethernet(p)
+		p.Add().Ipv6().Dst().SetValue("2001:db8::2")
+		p.Add().Udp()
+	}},
+	{Build: func(p gosnappi.FlowFlowHeaderIter) {
+		ethernet(p)
+		ipv4(p)
+		p.Add().Icmp().Echo()
+	}},
+	{Build: func(p gosnappi.FlowFlowHeaderIter) {
+		ethernet(p)
+		p.Add().Ipv6().Dst().SetValue("2001:db8::2")
+		p.Add().Icmpv6().Echo()
+	}},
+	{Build: func(p gosnappi.FlowFlowHeaderIter) {
+		ethernet(p)
+		ipv4(p)
+		p.Add().Igmpv1().GroupAddress().SetValue("239.1.1.1")
+	}},
+	{Build: func(p gosnappi.FlowFlowHeaderIter) {
+		ethernet(p)
+		p.Add().Arp().TargetProtocolAddr().SetValue("1.1.1.2")
+	}},
+	{Build: func(p gosnappi.FlowFlowHeaderIter) {
+		ethernet(p)
+		p.Add().Vlan().Id().SetValue(10)
+		ipv4(p)
+		p.Add().Udp()
+	}},
+	{Build: func(p gosnappi.FlowFlowHeaderIter) {
+		ethernet(p)
+		p.Add().Vlan().Id().SetValue(10)
+		p.Add().Vlan().Id().SetValue(20)
+		p.Add().Mpls().Label().SetValue(16)
+		ipv4(p)
+		p.Add().Udp()
+	}},
+	{Build: func(p gosnappi.FlowFlowHeaderIter) {
+		ethernet(p)
+		p.Add().Mpls().Label().SetValue(16)
+		p.Add().Mpls().Label().SetValue(17)
+		p.Add().Ipv6().Dst().SetValue("2001:db8::2")
+	}},
+	{Build: func(p gosnappi.FlowFlowHeaderIter) {
+		ethernet(p)
+		ipv4(p)
+		p.Add().Gre()
+		p.Add().Ipv4().Dst().SetValue("10.2.2.2")
+	}},
+	{Build: func(p gosnappi.FlowFlowHeaderIter) {
+		ethernet(p)
+		ipv4(p)
+		p.Add().Udp().DstPort().SetValue(2152)
+		p.Add().Gtpv1().Teid().SetValue(1)
+		p.Add().Ipv4().Dst().SetValue("10.2.2.2")
+		p.Add().Udp()
+	}},
+	{Build: func(p gosnappi.FlowFlowHeaderIter) {
+		ethernet(p)
+		p.Add().Ppp()
+		ipv4(p)
+	}},
+	{Build: func(p gosnappi.FlowFlowHeaderIter) {
+		ethernet(p)
+		p.Add().Custom().SetBytes("88b5000102030405")
+	}},
+	{Build: func(p gosnappi.FlowFlowHeaderIter) {
+		p.Add().Ethernetpause().Time().SetValue(0xffff)
+	}, Consumed: true},
+	{Build: func(p gosnappi.FlowFlowHeaderIter) {
+		pfc := p.Add().Pfcpause()
+		pfc.ClassEnableVector().SetValue(0x08)
+		pfc.PauseClass3().SetValue(0xffff)
+	}, Consumed: true},
+}
+
+func ethernet(p gosnappi.FlowFlowHeaderIter) {
+	eth := p.Add().Ethernet()
+	eth.Src().SetValue("00:11:22:33:44:66")
+	eth.Dst().SetValue("00:11:22:33:44:55")
+}
+
+func ipv4(p gosnappi.FlowFlowHeaderIter) {
+	ip := p.Add().Ipv4()
+	ip.Src().SetValue("10.1.1.1")
+	ip.Dst().SetValue("20.1.1.1")
+}
+
+// HeaderLen returns the bytes the packet headers of flow take, the FCS not
+// included.
+func HeaderLen(flow gosnappi.Flow) (int, error) {
+	msg, err := flow.Marshal().ToProto()
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, h := range msg.GetPacket() {
+		l, err := headerLen(h)
+		if err != nil {
+			return 0, fmt.Errorf("flow %s: %v", flow.Name(), err)
+		}
+		n += l
+	}
+	return n, nil
+}
+
+// headerLen returns the bytes of a header.
+func headerLen(h *otg.FlowHeader) (int, error) {
+	switch h.GetChoice() {
+	case otg.FlowHeader_Choice_custom:
+		return len(h.GetCustom().GetBytes()) / 2, nil
+	case otg.FlowHeader_Choice_ethernet:
+		return 14, nil
+	case otg.FlowHeader_Choice_vlan, otg.FlowHeader_Choice_mpls, otg.FlowHeader_Choice_ppp:
+		return 4, nil
+	case otg.FlowHeader_Choice_vxlan, otg.FlowHeader_Choice_udp, otg.FlowHeader_Choice_icmp,
+		otg.FlowHeader_Choice_icmpv6, otg.FlowHeader_Choice_igmpv1:
+		return 8, nil
+	case otg.FlowHeader_Choice_ipv4:
+		if hl := h.GetIpv4().GetHeaderLength(); hl.GetChoice() == otg.PatternFlowIpv4HeaderLength_Choice_value {
+			return int(hl.GetValue()) * 4, nil
+		}
+		return 20, nil
+	case otg.FlowHeader_Choice_ipv6:
+		return 40, nil
+	case otg.FlowHeader_Choice_tcp:
+		if off := h.GetTcp().GetDataOffset(); off.GetChoice() == otg.PatternFlowTcpDataOffset_Choice_value && off.GetValue() > 0 {
+			return int(off.GetValue()) * 4, nil
+		}
+		return 20, nil
+	case otg.FlowHeader_Choice_gre:
+		// The checksum and reserved1 fields follow only with the checksum
+		// present bit set.
+		if h.GetGre().GetChecksumPresent().GetValue() == 1 {
+			return 8, nil
+		}
+		return 4, nil
+	case otg.FlowHeader_Choice_gtpv1:
+		gtp := h.GetGtpv1()
+		n := 8
+		if gtp.GetEFlag().GetValue() == 1 || gtp.GetSFlag().GetValue() == 1 || gtp.GetPnFlag().GetValue() == 1 {
+			n += 4
+		}
+		for _, ext := range gtp.GetExtensionHeaders() {
+			n += int(ext.GetExtensionLength().GetValue()) * 4
+		}
+		return n, nil
+	case otg.FlowHeader_Choice_arp:
+		return 28, nil
+	case otg.FlowHeader_Choice_ethernetpause:
+		// Destination, source, ether type, control op code and pause time.
+		return 18, nil
+	case otg.FlowHeader_Choice_pfcpause:
+		// As above with the class enable vector and 8 pause times.
+		return 34, nil
+	}
+	return 0, fmt.Errorf("unknown size of %s header", h.GetChoice())
+}
+
+// MinSize returns the smallest frame size that holds the packet headers of
+// flow and the FCS.
+func MinSize(flow gosnappi.Flow) (uint32, error) {
+	n, err := HeaderLen(flow)
+	if err != nil {
+		return 0, err
+	}
+	return max(uint32(n+fcs), minFrame), nil
+}
@@ -0,0 +1,107 @@
+package headerstack
+
+import (
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/SpirentOrion/stc-otg/example/gosnappi/otgsimtest"
+	"github.com/open-traffic-generator/snappi/gosnappi"
+)
+
+func TestStacks(t *testing.T) {
+	types := map[string]bool{}
+	for _, s := range Stacks {
+		for _, h := range strings.Split(s.Name(), "/") {
+			types[h] = true
+		}
+	}
+	got := []string{}
+	for h := range types {
+		got = append(got, h)
+	}
+	sort.Strings(got)
+	want := "arp custom ethernet ethernetpause gre gtpv1 icmp icmpv6 igmpv1 ipv4 ipv6 mpls pfcpause ppp tcp udp vlan"
+	if strings.Join(got, " ") != want {
+		t.Errorf("Stacks cover %s, want %s", strings.Join(got, " "), want)
+	}
+}
+
+func TestMinSize(t *testing.T) {
+	for _, tc := range []struct {
+		build func(gosnappi.FlowFlowHeaderIter)
+		want  uint32
+	}{
+		{func(p gosnappi.FlowFlowHeaderIter) { p.Add().Ethernet(); p.Add().Ipv4() }, 64},
+		// 14 + 20 + 8 + 8 + 20 + 8 bytes of headers and the FCS.
+		{func(p gosnappi.FlowFlowHeaderIter) {
+			p.Add().Ethernet()
+			p.Add().Ipv4()
+			p.Add().Udp()
+			p.Add().Gtpv1()
+			p.Add().Ipv4()
+			p.Add().Udp()
+		}, 82},
+		// The checksum adds 4 bytes to the GRE header.
+		{func(p gosnappi.FlowFlowHeaderIter) {
+			p.Add().Ethernet()
+			p.Add().Ipv6()
+			p.Add().Gre().ChecksumPresent().SetValue(1)
+			p.Add().Ipv6()
+		}, 106},
+		{func(p gosnappi.FlowFlowHeaderIter) {
+			p.Add().Ethernet()
+			p.Add().Custom().SetBytes(strings.Repeat("00", 100))
+		}, 118},
+	} {
+		flow := gosnappi.NewFlow().SetName("f1")
+		flow.TxRx().Port().SetTxName("p1")
+		tc.build(flow.Packet())
+		got, err := MinSize(flow)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tc.want {
+			t.Errorf("MinSize() of %s got %d, want %d", Stack{Build: tc.build}.Name(), got, tc.want)
+		}
+	}
+}
+
+func TestRun(t *testing.T) {
+	_, api := otgsimtest.Start(t)
+
+	stacks := append([]Stack{}, Stacks...)
+	stacks[0].Size = 1500
+	s := &Suite{Api: api, Frames: 500, Settle: time.Millisecond}
+	results, err := s.Run(stacks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != len(Stacks) {
+		t.Fatalf("Run() got %d results, want %d", len(results), len(Stacks))
+	}
+	if err := results.Err(); err != nil {
+		t.Errorf("Run() failed: %v\n%s", err, results)
+	}
+	if r := results[0]; r.BytesRx != 500*1500 {
+		t.Errorf("%s bytes rx got %d, want %d", r.Stack, r.BytesRx, 500*1500)
+	}
+
+	stacks[0].Size = 32
+	if _, err := s.Run(stacks); err == nil || !strings.Contains(err.Error(), "frame size 32 below the 64 bytes") {
+		t.Errorf("Run() of a frame too small got %v", err)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	r := Result{Stack: "ethernet/ipv4", Size: 128, Frames: 10, FramesTx: 10, FramesRx: 9, BytesTx: 1280, BytesRx: 1280}
+	err := r.Check()
+	if err == nil || !strings.Contains(err.Error(), "received 9 of 10 frames, bytes rx got 1280, want 9 frames of 128 bytes") {
+		t.Errorf("Check() got %v", err)
+	}
+	r.Consumed = true
+	if err := r.Check(); err != nil {
+		t.Errorf("Check() of a consumed stack got %v", err)
+	}
+}
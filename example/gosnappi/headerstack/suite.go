@@ -0,0 +1,196 @@
+package headerstack
+
+import (
+	"fmt"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/SpirentOrion/stc-otg/example/gosnappi/trial"
+	"github.com/open-traffic-generator/snappi/gosnappi"
+)
+
+// Suite sends the frames of header stacks from one port to another.
+type Suite struct {
+	Api gosnappi.Api
+	// Locations are the locations of the tx and rx port.
+	Locations []string
+	// Frames is the number of frames sent per stack, 1000 if zero.
+	Frames uint32
+	// Pps is the rate of every stack, 1000 if zero.
+	Pps uint64
+	// Size is the frame size of the stacks without their own, 128 if zero,
+	// which leaves room for the signature of the flow metrics. Stacks whose
+	// headers take more are sent at their MinSize.
+	Size uint32
+	// Timeout bounds the wait for the flows to stop, one minute if zero.
+	Timeout time.Duration
+	// Settle is how long to wait after the flows stopped transmitting before
+	// their final metrics are read, 2s when zero.
+	Settle time.Duration
+}
+
+// Result is the traffic of one header stack.
+type Result struct {
+	Stack    string
+	Consumed bool
+	// Size is the frame size sent, MinSize the smallest one holding the
+	// headers.
+	Size    uint32
+	MinSize uint32
+	Frames  uint64
+	// The flow metrics of the stack.
+	FramesTx uint64
+	FramesRx uint64
+	BytesTx  uint64
+	BytesRx  uint64
+}
+
+// Check returns an error if not all frames were sent, or received unless the
+// stack is consumed, or the octet counters are not the frames times the
+// frame size.
+func (r Result) Check() error {
+	var errs []string
+	if r.FramesTx != r.Frames {
+		errs = append(errs, fmt.Sprintf("sent %d of %d frames", r.FramesTx, r.Frames))
+	}
+	if r.BytesTx != r.FramesTx*uint64(r.Size) {
+		errs = append(errs, fmt.Sprintf("bytes tx got %d, want %d frames of %d bytes", r.BytesTx, r.FramesTx, r.Size))
+	}
+	if !r.Consumed {
+		if r.FramesRx != r.FramesTx {
+			errs = append(errs, fmt.Sprintf("received %d of %d frames", r.FramesRx, r.FramesTx))
+		}
+		if r.BytesRx != r.FramesRx*uint64(r.Size) {
+			errs = append(errs, fmt.Sprintf("bytes rx got %d, want %d frames of %d bytes", r.BytesRx, r.FramesRx, r.Size))
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("%s: %s", r.Stack, strings.Join(errs, ", "))
+	}
+	return nil
+}
+
+// Results are the results of a suite run, in the order of its stacks.
+type Results []Result
+
+// Err returns the errors of the results that fail their Check, nil if all
+// pass.
+func (rs Results) Err() error {
+	var errs []string
+	for _, r := range rs {
+		if err := r.Check(); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("%d of %d header stacks failed:\n%s", len(errs), len(rs), strings.Join(errs, "\n"))
+	}
+	return nil
+}
+
+// String renders the per-stack table.
+func (rs Results) String() string {
+	b := &strings.Builder{}
+	w := tabwriter.NewWriter(b, 0, 0, 1, ' ', 0)
+
+	fmt.Fprint(w, "Stack\tSize\tMinSize\tFramesTx\tFramesRx\tBytesTx\tBytesRx\tResult\n")
+	for _, r := range rs {
+		result := "ok"
+		if err := r.Check(); err != nil {
+			result = "FAIL"
+		} else if r.Consumed {
+			result = "ok (tx only)"
+		}
+		fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%d\t%d\t%d\t%s\n",
+			r.Stack, r.Size, r.MinSize, r.FramesTx, r.FramesRx, r.BytesTx, r.BytesRx, result)
+	}
+	w.Flush()
+	return b.String()
+}
+
+// flowName returns the flow name of a stack, its name with dashes.
+func flowName(stack string) string {
+	return strings.ReplaceAll(stack, "/", "-")
+}
+
+// Config returns the config sending the frames of stacks, one flow each,
+// with the results to fill in. It fails if a stack is too small for its
+// headers.
+func (s *Suite) Config(stacks []Stack) (gosnappi.Config, Results, error) {
+	frames, pps := s.Frames, s.Pps
+	if frames == 0 {
+		frames = 1000
+	}
+	if pps == 0 {
+		pps = 1000
+	}
+	config := gosnappi.NewConfig()
+	tx := config.Ports().Add().SetName("p1")
+	rx := config.Ports().Add().SetName("p2")
+	if len(s.Locations) == 2 {
+		tx.SetLocation(s.Locations[0])
+		rx.SetLocation(s.Locations[1])
+	}
+
+	results := Results{}
+	for _, st := range stacks {
+		name := st.Name()
+		flow := config.Flows().Add().SetName(flowName(name))
+		flow.TxRx().Port().SetTxName(tx.Name()).SetRxNames([]string{rx.Name()})
+		flow.Metrics().SetEnable(true)
+		flow.Rate().SetPps(pps)
+		flow.Duration().FixedPackets().SetPackets(frames)
+		st.Build(flow.Packet())
+
+		min, err := MinSize(flow)
+		if err != nil {
+			return nil, nil, err
+		}
+		size := st.Size
+		if size == 0 {
+			size = s.Size
+			if size == 0 {
+				size = 128
+			}
+			size = max(size, min)
+		}
+		if size < min {
+			return nil, nil, fmt.Errorf("%s: frame size %d below the %d bytes of its headers", name, size, min)
+		}
+		flow.Size().SetFixed(size)
+		results = append(results, Result{Stack: name, Consumed: st.Consumed, Size: size, MinSize: min, Frames: uint64(frames)})
+	}
+	return config, results, nil
+}
+
+// Run sends the frames of stacks and returns their results once all flows
+// stopped.
+func (s *Suite) Run(stacks []Stack) (Results, error) {
+	config, results, err := s.Config(stacks)
+	if err != nil {
+		return nil, err
+	}
+	timeout := s.Timeout
+	if timeout == 0 {
+		timeout = time.Minute
+	}
+	settle := s.Settle
+	if settle == 0 {
+		settle = 2 * time.Second
+	}
+	r := trial.Runner{Api: s.Api, PollInterval: 100 * time.Millisecond, Settle: settle}
+	byName, err := r.Run(config, nil, timeout)
+	if err != nil {
+		return nil, err
+	}
+	for i := range results {
+		m, ok := byName[flowName(results[i].Stack)]
+		if !ok {
+			continue
+		}
+		results[i].FramesTx, results[i].FramesRx = m.FramesTx(), m.FramesRx()
+		results[i].BytesTx, results[i].BytesRx = m.BytesTx(), m.BytesRx()
+	}
+	return results, nil
+}
@@ -0,0 +1,64 @@
+package gosnappi_examples
+
+import (
+	"testing"
+	"time"
+
+	"github.com/SpirentOrion/stc-otg/example/gosnappi/framesize"
+	"github.com/SpirentOrion/stc-otg/example/gosnappi/linerate"
+	"github.com/SpirentOrion/stc-otg/example/gosnappi/trial"
+	"github.com/open-traffic-generator/snappi/gosnappi"
+)
+
+// PORT1=10.109.121.181/1/1 PORT2=10.109.123.254/1/1 OTGSERVER=localhost:50051 go test -v -test.run TestImix
+func TestImix(t *testing.T) {
+	// Create a new API handle to make API calls against OTG
+	api := gosnappi.NewApi()
+
+	// Set the transport protocol to gRPC
+	api.NewGrpcTransport().
+		SetLocation(OTGSERVER).
+		SetDialTimeout(3 * time.Minute).
+		SetRequestTimeout(10 * time.Minute)
+
+	config := gosnappi.NewConfig()
+	ptx := config.Ports().Add().SetName("port1").SetLocation(PORT1)
+	prx := config.Ports().Add().SetName("port2").SetLocation(PORT2)
+
+	// Send the simple IMIX at 1 Gbps line rate for 5 seconds
+	profile := framesize.SimpleImix
+	pps := uint64(linerate.NewLink(1e9, profile).MaxPps())
+	t.Logf("%s at 1 Gbps: %d pps", profile, pps)
+	flow := config.Flows().Add().SetName("imix")
+	flow.TxRx().Port().SetTxName(ptx.Name()).SetRxNames([]string{prx.Name()})
+	flow.Metrics().SetEnable(true)
+	flow.Rate().SetPps(pps)
+	profile.Apply(flow.Size())
+	flow.Duration().FixedPackets().SetPackets(uint32(pps * 5))
+	eth := flow.Packet().Add().Ethernet()
+	eth.Src().SetValue("00:11:22:33:44:66")
+	eth.Dst().SetValue("00:11:22:33:44:55")
+	ipv4 := flow.Packet().Add().Ipv4()
+	ipv4.Src().SetValue("10.1.1.1")
+	ipv4.Dst().SetValue("20.1.1.1")
+
+	// Make sure the flow fits the ports, taken as 10G without a layer1 speed
+	if err := linerate.Validate(config, 10e9); err != nil {
+		t.Fatal(err)
+	}
+
+	// Run the flow until it stops on its own and let the last frames arrive
+	runner := trial.Runner{Api: api, Settle: 2 * time.Second}
+	metrics, err := runner.Run(config, []string{flow.Name()}, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := metrics[flow.Name()]
+
+	// The octet counters follow the average frame size of the mix
+	t.Logf("frames tx/rx %d/%d, bytes tx/rx %d/%d, %.2f bytes per frame",
+		m.FramesTx(), m.FramesRx(), m.BytesTx(), m.BytesRx(), float64(m.BytesTx())/float64(m.FramesTx()))
+	if err := profile.Check(m, 0.01); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,348 @@
+// Package linerate converts flow rates between percentage of line rate,
+// frames per second, bits per second and inter-frame gap, taking the frame
+// size, the preamble and inter-frame gap sent with every frame, extra VLAN
+// tags and the layer1 speed of the port into account. Validate checks that
+// the flows of a config do not load any port beyond its line rate before the
+// config is pushed. L2Overhead and Mtu size the IP packets of frames against
+// the MTU of the ports.
+package linerate
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/SpirentOrion/stc-otg/example/gosnappi/framesize"
+	"github.com/open-traffic-generator/snappi/gosnappi"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+const (
+	// Preamble is the preamble and start frame delimiter of a frame, in
+	// bytes.
+	Preamble = 8
+	// MinGap is the minimum inter-frame gap, in bytes.
+	MinGap = 12
+	// VlanTag is the size of a VLAN tag, in bytes.
+	VlanTag = 4
+	// MplsLabel is the size of an MPLS label stack entry, in bytes.
+	MplsLabel = 4
+	// Ethernet is the ethernet header and FCS of an untagged frame, in
+	// bytes.
+	Ethernet = 18
+)
+
+// Speed returns the line rate of a layer1 speed in bits per second, 0 if the
+// speed is unknown.
+func Speed(speed gosnappi.Layer1SpeedEnum) float64 {
+	switch speed {
+	case gosnappi.Layer1Speed.SPEED_10_FD_MBPS, gosnappi.Layer1Speed.SPEED_10_HD_MBPS:
+		return 10e6
+	case gosnappi.Layer1Speed.SPEED_100_FD_MBPS, gosnappi.Layer1Speed.SPEED_100_HD_MBPS:
+		return 100e6
+	case gosnappi.Layer1Speed.SPEED_1_GBPS:
+		return 1e9
+	case gosnappi.Layer1Speed.SPEED_10_GBPS:
+		return 10e9
+	case gosnappi.Layer1Speed.SPEED_25_GBPS:
+		return 25e9
+	case gosnappi.Layer1Speed.SPEED_40_GBPS:
+		return 40e9
+	case gosnappi.Layer1Speed.SPEED_50_GBPS:
+		return 50e9
+	case gosnappi.Layer1Speed.SPEED_100_GBPS:
+		return 100e9
+	case gosnappi.Layer1Speed.SPEED_200_GBPS:
+		return 200e9
+	case gosnappi.Layer1Speed.SPEED_400_GBPS:
+		return 400e9
+	case gosnappi.Layer1Speed.SPEED_800_GBPS:
+		return 800e9
+	}
+	return 0
+}
+
+// Link is a line rate and the frames sent on it.
+type Link struct {
+	// Speed is the line rate in bits per second.
+	Speed float64
+	// FrameSize is the average frame size in bytes, e.g. the Average() of a
+	// framesize.Profile.
+	FrameSize float64
+	// VlanTags counts the VLAN tags the frames carry on the link on top of
+	// FrameSize, e.g. one for a DUT that pushes a tag on the way out.
+	VlanTags int
+	// Overhead is the preamble and minimum inter-frame gap sent with every
+	// frame, in bytes; zero means Preamble + MinGap.
+	Overhead float64
+}
+
+// NewLink returns the link of a line rate in bits per second sending the
+// frames of a frame size profile.
+func NewLink(speed float64, p framesize.Profile) Link {
+	return Link{Speed: speed, FrameSize: p.Average()}
+}
+
+// frameBytes returns the bytes of a frame on the link, VLAN tags included.
+func (l Link) frameBytes() float64 {
+	return l.FrameSize + float64(l.VlanTags*VlanTag)
+}
+
+// WireBits returns the bits a frame takes on the wire, the overhead included.
+func (l Link) WireBits() float64 {
+	overhead := l.Overhead
+	if overhead == 0 {
+		overhead = Preamble + MinGap
+	}
+	return (l.frameBytes() + overhead) * 8
+}
+
+// MaxPps returns the frame rate of the link at line rate.
+func (l Link) MaxPps() float64 {
+	return l.Speed / l.WireBits()
+}
+
+// Pps returns the frame rate of a percentage of the line rate.
+func (l Link) Pps(percent float64) float64 {
+	return percent / 100 * l.MaxPps()
+}
+
+// Percent returns the percentage of the line rate a frame rate takes.
+func (l Link) Percent(pps float64) float64 {
+	return pps / l.MaxPps() * 100
+}
+
+// Bps returns the bits per second of a frame rate on the wire, the overhead
+// included, as flow rates in bps count them.
+func (l Link) Bps(pps float64) float64 {
+	return pps * l.WireBits()
+}
+
+// PpsOfBps returns the frame rate of bits per second on the wire.
+func (l Link) PpsOfBps(bps float64) float64 {
+	return bps / l.WireBits()
+}
+
+// Gap returns the average gap between the frames of a frame rate, in bytes;
+// it is the minimum gap at line rate and negative beyond.
+func (l Link) Gap(pps float64) float64 {
+	return l.Speed/8/pps - l.frameBytes() - Preamble
+}
+
+// PpsOfGap returns the frame rate with gap bytes between frames.
+func (l Link) PpsOfGap(gap float64) float64 {
+	return l.Speed / 8 / (l.frameBytes() + Preamble + gap)
+}
+
+// FlowPps returns the frame rate of a flow on a port of a line rate in bits
+// per second.
+func FlowPps(flow gosnappi.Flow, speed float64) (float64, error) {
+	p, err := framesize.FromFlowSize(flow.Size())
+	if err != nil {
+		return 0, fmt.Errorf("flow %s: %v", flow.Name(), err)
+	}
+	l := NewLink(speed, p)
+	rate := flow.Rate()
+	switch rate.Choice() {
+	case gosnappi.FlowRateChoice.PPS:
+		return float64(rate.Pps()), nil
+	case gosnappi.FlowRateChoice.BPS:
+		return l.PpsOfBps(float64(rate.Bps())), nil
+	case gosnappi.FlowRateChoice.KBPS:
+		return l.PpsOfBps(float64(rate.Kbps()) * 1e3), nil
+	case gosnappi.FlowRateChoice.MBPS:
+		return l.PpsOfBps(float64(rate.Mbps()) * 1e6), nil
+	case gosnappi.FlowRateChoice.GBPS:
+		return l.PpsOfBps(float64(rate.Gbps()) * 1e9), nil
+	}
+	return l.Pps(float64(rate.Percentage())), nil
+}
+
+// PortSpeeds returns the line rate of the ports and LAGs of a config in bits
+// per second, from the layer1 speeds of the ports; ports without one get
+// defaultSpeed and LAGs the sum of their member ports.
+func PortSpeeds(config gosnappi.Config, defaultSpeed float64) map[string]float64 {
+	speeds := map[string]float64{}
+	for _, p := range config.Ports().Items() {
+		speeds[p.Name()] = defaultSpeed
+	}
+	for _, l1 := range config.Layer1().Items() {
+		if !l1.HasSpeed() {
+			continue
+		}
+		for _, name := range l1.PortNames() {
+			if s := Speed(l1.Speed()); s > 0 {
+				speeds[name] = s
+			}
+		}
+	}
+	for _, lag := range config.Lags().Items() {
+		total := 0.0
+		for _, m := range lag.Ports().Items() {
+			total += speeds[m.PortName()]
+		}
+		speeds[lag.Name()] = total
+	}
+	return speeds
+}
+
+// Load is the share of the line rate of a port or LAG the flows transmitting
+// on it take.
+type Load struct {
+	Port    string
+	Speed   float64
+	Percent float64
+	Flows   []string
+}
+
+// Loads returns the load of the ports and LAGs flows of a config transmit
+// on, sorted by name. Device flows transmit on the port of the ethernet of
+// their first tx name.
+func Loads(config gosnappi.Config, defaultSpeed float64) ([]Load, error) {
+	speeds := PortSpeeds(config, defaultSpeed)
+	loads := map[string]*Load{}
+	for _, f := range config.Flows().Items() {
+		port := ""
+		switch f.TxRx().Choice() {
+		case gosnappi.FlowTxRxChoice.PORT:
+			port = f.TxRx().Port().TxName()
+		case gosnappi.FlowTxRxChoice.DEVICE:
+			if names := f.TxRx().Device().TxNames(); len(names) > 0 {
+				port = portOf(config, names[0])
+			}
+		}
+		speed, ok := speeds[port]
+		if !ok {
+			return nil, fmt.Errorf("flow %s transmits on no port of the config", f.Name())
+		}
+		pps, err := FlowPps(f, speed)
+		if err != nil {
+			return nil, err
+		}
+		p, _ := framesize.FromFlowSize(f.Size())
+		l := loads[port]
+		if l == nil {
+			l = &Load{Port: port, Speed: speed}
+			loads[port] = l
+		}
+		if speed > 0 {
+			l.Percent += NewLink(speed, p).Percent(pps)
+		}
+		l.Flows = append(l.Flows, f.Name())
+	}
+
+	result := []Load{}
+	for _, l := range loads {
+		result = append(result, *l)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Port < result[j].Port })
+	return result, nil
+}
+
+// portOf returns the port or LAG of the ethernet of the device a device,
+// ethernet, IP interface or route range name belongs to.
+func portOf(config gosnappi.Config, name string) string {
+	for _, d := range config.Devices().Items() {
+		msg, err := d.Marshal().ToProto()
+		if err != nil || !hasName(msg.ProtoReflect(), name) {
+			continue
+		}
+		for _, eth := range d.Ethernets().Items() {
+			if !eth.HasConnection() {
+				continue
+			}
+			if eth.Connection().Choice() == gosnappi.EthernetConnectionChoice.LAG_NAME {
+				return eth.Connection().LagName()
+			}
+			return eth.Connection().PortName()
+		}
+	}
+	return ""
+}
+
+// hasName reports whether m or any message in it is named name.
+func hasName(m protoreflect.Message, name string) bool {
+	found := false
+	m.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		switch {
+		case fd.Name() == "name" && fd.Kind() == protoreflect.StringKind:
+			found = v.String() == name
+		case fd.IsList() && fd.Message() != nil:
+			for i := 0; i < v.List().Len() && !found; i++ {
+				found = hasName(v.List().Get(i).Message(), name)
+			}
+		case !fd.IsList() && !fd.IsMap() && fd.Message() != nil:
+			found = hasName(v.Message(), name)
+		}
+		return !found
+	})
+	return found
+}
+
+// Validate returns an error listing the ports and LAGs the flows of a config
+// load beyond their line rate, for ports without a layer1 speed taking
+// defaultSpeed. Call it before SetConfig.
+func Validate(config gosnappi.Config, defaultSpeed float64) error {
+	loads, err := Loads(config, defaultSpeed)
+	if err != nil {
+		return err
+	}
+	var errs []string
+	for _, l := range loads {
+		if l.Percent > 100 {
+			errs = append(errs, fmt.Sprintf("%s at %.2f%% of %.0f Mbps by flows %s",
+				l.Port, l.Percent, l.Speed/1e6, strings.Join(l.Flows, ", ")))
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("ports loaded beyond line rate:\n%s", strings.Join(errs, "\n"))
+	}
+	return nil
+}
+
+// L2Overhead returns the bytes of the frames with the given packet headers
+// around their IP packet: the ethernet header and FCS and the VLAN tags and
+// MPLS labels ahead of the first IPv4 or IPv6 header. ok is false when no IP
+// header follows the ethernet, VLAN and MPLS headers.
+func L2Overhead(packet gosnappi.FlowFlowHeaderIter) (overhead uint32, ok bool) {
+	overhead = Ethernet
+	for _, h := range packet.Items() {
+		switch h.Choice() {
+		case gosnappi.FlowHeaderChoice.ETHERNET:
+		case gosnappi.FlowHeaderChoice.VLAN:
+			overhead += VlanTag
+		case gosnappi.FlowHeaderChoice.MPLS:
+			overhead += MplsLabel
+		case gosnappi.FlowHeaderChoice.IPV4, gosnappi.FlowHeaderChoice.IPV6:
+			return overhead, true
+		default:
+			return 0, false
+		}
+	}
+	return 0, false
+}
+
+// Mtu returns the smallest MTU of the device ethernets connected to the named
+// ports or LAGs, 0 if none has one.
+func Mtu(config gosnappi.Config, ports ...string) uint32 {
+	mtu := uint32(0)
+	for _, d := range config.Devices().Items() {
+		for _, eth := range d.Ethernets().Items() {
+			if !eth.HasMtu() || !eth.HasConnection() {
+				continue
+			}
+			var conn string
+			if eth.Connection().Choice() == gosnappi.EthernetConnectionChoice.LAG_NAME {
+				conn = eth.Connection().LagName()
+			} else {
+				conn = eth.Connection().PortName()
+			}
+			for _, p := range ports {
+				if conn == p && (mtu == 0 || eth.Mtu() < mtu) {
+					mtu = eth.Mtu()
+				}
+			}
+		}
+	}
+	return mtu
+}
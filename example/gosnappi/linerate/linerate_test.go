@@ -0,0 +1,171 @@
+package linerate
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/SpirentOrion/stc-otg/example/gosnappi/framesize"
+	"github.com/open-traffic-generator/snappi/gosnappi"
+)
+
+func near(got, want float64) bool {
+	return math.Abs(got-want) <= 1e-6*math.Max(1, math.Abs(want))
+}
+
+func TestLink(t *testing.T) {
+	l := NewLink(10e9, framesize.Fixed(64))
+	if got := l.MaxPps(); !near(got, 10e9/672) {
+		t.Errorf("MaxPps() of 64 byte frames at 10G got %f, want %f", got, 10e9/672)
+	}
+	if got := l.Gap(l.MaxPps()); !near(got, MinGap) {
+		t.Errorf("Gap() at line rate got %f, want %d", got, MinGap)
+	}
+	// Half the line rate leaves a gap of a whole frame slot more.
+	if got := l.Gap(l.Pps(50)); !near(got, MinGap+84) {
+		t.Errorf("Gap() at 50%% got %f, want %d", got, MinGap+84)
+	}
+	if got := l.Percent(l.PpsOfGap(MinGap + 84)); !near(got, 50) {
+		t.Errorf("Percent(PpsOfGap()) got %f, want 50", got)
+	}
+	if got := l.Bps(l.MaxPps()); !near(got, 10e9) {
+		t.Errorf("Bps() at line rate got %f, want 10G", got)
+	}
+
+	// A VLAN tag adds 4 bytes to every frame on the wire.
+	l.VlanTags = 1
+	if got := l.MaxPps(); !near(got, 10e9/704) {
+		t.Errorf("MaxPps() with a VLAN tag got %f, want %f", got, 10e9/704)
+	}
+}
+
+func TestFlowPps(t *testing.T) {
+	flow := gosnappi.NewFlow().SetName("f1")
+	flow.Size().SetFixed(1004)
+	for _, tc := range []struct {
+		set  func(gosnappi.FlowRate)
+		want float64
+	}{
+		{func(r gosnappi.FlowRate) { r.SetPps(1000) }, 1000},
+		{func(r gosnappi.FlowRate) { r.SetPercentage(10) }, 1e9 / 8192 / 10},
+		{func(r gosnappi.FlowRate) { r.SetMbps(8192) }, 1e6},
+		{func(r gosnappi.FlowRate) { r.SetBps(8192) }, 1},
+	} {
+		tc.set(flow.Rate())
+		got, err := FlowPps(flow, 1e9)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !near(got, tc.want) {
+			t.Errorf("FlowPps() of rate %s got %f, want %f", flow.Rate().Choice(), got, tc.want)
+		}
+	}
+}
+
+// loadConfig returns a config with a 1G port p1, a port p2 of the default
+// speed and flows from p1 and from a device on p2.
+func loadConfig() gosnappi.Config {
+	config := gosnappi.NewConfig()
+	config.Ports().Add().SetName("p1")
+	config.Ports().Add().SetName("p2")
+	config.Layer1().Add().SetName("l1").SetPortNames([]string{"p1"}).SetSpeed(gosnappi.Layer1Speed.SPEED_1_GBPS)
+	d := config.Devices().Add().SetName("d2")
+	eth := d.Ethernets().Add().SetName("d2Eth").SetMac("00:00:00:00:00:02")
+	eth.Connection().SetPortName("p2")
+	eth.Ipv4Addresses().Add().SetName("d2Ipv4").SetAddress("2.2.2.2").SetGateway("2.2.2.1")
+
+	f := config.Flows().Add().SetName("f1")
+	f.TxRx().Port().SetTxName("p1").SetRxNames([]string{"p2"})
+	f.Rate().SetPercentage(60)
+	f = config.Flows().Add().SetName("f2")
+	f.TxRx().Port().SetTxName("p1").SetRxNames([]string{"p2"})
+	f.Size().SetFixed(105)
+	f.Rate().SetPps(500000)
+	f = config.Flows().Add().SetName("f3")
+	f.TxRx().Device().SetTxNames([]string{"d2Ipv4"}).SetRxNames([]string{"d2Ipv4"})
+	f.Rate().SetGbps(1)
+	return config
+}
+
+func TestLoads(t *testing.T) {
+	loads, err := Loads(loadConfig(), 10e9)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(loads) != 2 {
+		t.Fatalf("Loads() got %+v, want p1 and p2", loads)
+	}
+	if l := loads[0]; l.Port != "p1" || l.Speed != 1e9 || !near(l.Percent, 110) || len(l.Flows) != 2 {
+		t.Errorf("Loads() of p1 got %+v, want 110%% of 1G by f1 and f2", l)
+	}
+	if l := loads[1]; l.Port != "p2" || l.Speed != 10e9 || !near(l.Percent, 10) {
+		t.Errorf("Loads() of p2 got %+v, want 10%% of 10G by f3", l)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	config := loadConfig()
+	err := Validate(config, 10e9)
+	if err == nil || !strings.Contains(err.Error(), "p1 at 110.00% of 1000 Mbps by flows f1, f2") {
+		t.Errorf("Validate() got %v", err)
+	}
+	config.Flows().Items()[0].Rate().SetPercentage(40)
+	if err := Validate(config, 10e9); err != nil {
+		t.Errorf("Validate() at 90%% got %v", err)
+	}
+	config.Flows().Items()[2].TxRx().Device().SetTxNames([]string{"nowhere"})
+	if err := Validate(config, 10e9); err == nil || !strings.Contains(err.Error(), "flow f3 transmits on no port") {
+		t.Errorf("Validate() of a flow without port got %v", err)
+	}
+}
+
+func TestL2Overhead(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		headers func(gosnappi.FlowFlowHeaderIter)
+		want    uint32
+		wantOk  bool
+	}{
+		{"untagged", func(p gosnappi.FlowFlowHeaderIter) { p.Add().Ethernet(); p.Add().Ipv4() }, 18, true},
+		{"vlan and labels", func(p gosnappi.FlowFlowHeaderIter) {
+			p.Add().Ethernet()
+			p.Add().Vlan()
+			p.Add().Mpls()
+			p.Add().Mpls()
+			p.Add().Ipv6()
+		}, 30, true},
+		{"no ip", func(p gosnappi.FlowFlowHeaderIter) { p.Add().Ethernet(); p.Add().Arp() }, 0, false},
+		{"no headers", func(gosnappi.FlowFlowHeaderIter) {}, 0, false},
+	} {
+		flow := gosnappi.NewFlow()
+		tc.headers(flow.Packet())
+		if got, ok := L2Overhead(flow.Packet()); got != tc.want || ok != tc.wantOk {
+			t.Errorf("%s: L2Overhead() got %d, %v, want %d, %v", tc.name, got, ok, tc.want, tc.wantOk)
+		}
+	}
+}
+
+func TestMtu(t *testing.T) {
+	config := gosnappi.NewConfig()
+	config.Lags().Add().SetName("lag1")
+	d := config.Devices().Add().SetName("d1")
+	d.Ethernets().Add().SetName("e1").SetMtu(9000).Connection().SetPortName("p1")
+	d.Ethernets().Add().SetName("e2").SetMtu(1600).Connection().SetPortName("p2")
+	d.Ethernets().Add().SetName("e3").Connection().SetPortName("p3")
+	d.Ethernets().Add().SetName("e4").SetMtu(2000).Connection().SetLagName("lag1")
+	for _, tc := range []struct {
+		ports []string
+		want  uint32
+	}{
+		{[]string{"p1"}, 9000},
+		{[]string{"p1", "p2"}, 1600},
+		// Added ethernets default to an MTU of 1500.
+		{[]string{"p3"}, 1500},
+		{[]string{"lag1"}, 2000},
+		{[]string{"nowhere"}, 0},
+	} {
+		if got := Mtu(config, tc.ports...); got != tc.want {
+			t.Errorf("Mtu(%v) got %d, want %d", tc.ports, got, tc.want)
+		}
+	}
+}
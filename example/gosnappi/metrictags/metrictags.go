@@ -0,0 +1,325 @@
+// Package metrictags breaks flow metrics down by the values of a header
+// field. Add attaches a metric tag to a field a flow varies, e.g. an IPv4
+// destination incrementing over 5 addresses; Analyze groups the tagged metric
+// rows of the flow by the tag value and checks that they add up to the flow
+// totals and that every value got its share of the frames.
+package metrictags
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"net"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/open-traffic-generator/snappi/gosnappi"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// Tag is a metric tag on a header field of a flow.
+type Tag struct {
+	Flow string
+	Name string
+	// Values are the values of the field in the order the flow sends them,
+	// in hex as tagged metrics report them, e.g. 0xc8010101 for 200.1.1.1.
+	Values []string
+}
+
+// Pattern is a header field pattern of a flow that takes metric tags, e.g.
+// gosnappi.PatternFlowIpv4Dst. M is its marshaler and L its list of metric
+// tags, both inferred from the pattern.
+type Pattern[M Marshaler[P], P proto.Message, L MetricTags[L, T], T MetricTag[T]] interface {
+	Marshal() M
+	MetricTags() L
+}
+
+// Marshaler marshals a header field pattern to its proto message P.
+type Marshaler[P proto.Message] interface {
+	ToProto() (P, error)
+}
+
+// MetricTags is the list of metric tags of a header field pattern.
+type MetricTags[L, T any] interface {
+	Items() []T
+	Add() T
+	Clear() L
+	Append(items ...T) L
+}
+
+// MetricTag is a metric tag of a header field pattern, e.g.
+// gosnappi.PatternFlowIpv4DstMetricTag.
+type MetricTag[T any] interface {
+	SetName(value string) T
+	Offset() uint32
+	SetOffset(value uint32) T
+	Length() uint32
+	SetLength(value uint32) T
+}
+
+// Options selects the bits of a header field a tag covers, counted from its
+// most significant bit. The zero Options tag the whole field.
+type Options struct {
+	// Offset is the number of leading bits of the field left out.
+	Offset uint32
+	// Length is the number of bits tagged, the rest of the field after
+	// Offset when zero.
+	Length uint32
+}
+
+// Add tags a header field of flow, given as its gosnappi pattern, e.g.
+// ipv4.Dst() after ipv4.Dst().Increment().SetStart("200.1.1.1").SetCount(5),
+// and returns the tag with the values the tagged bits take. Set the values of
+// the pattern before adding the tag. opts, if given, tag only some bits of
+// the field, e.g. Options{Offset: 24} the last octet of an IPv4 address.
+func Add[M Marshaler[P], P proto.Message, L MetricTags[L, T], T MetricTag[T]](flow gosnappi.Flow, pattern Pattern[M, P, L, T], name string, opts ...Options) (Tag, error) {
+	msg, err := pattern.Marshal().ToProto()
+	if err != nil {
+		return Tag{}, err
+	}
+	var o Options
+	if len(opts) > 0 {
+		o = opts[0]
+	}
+	tags := pattern.MetricTags()
+	prev := tags.Items()
+	tag := tags.Add()
+	// A new tag covers the whole field, so its length is the field width.
+	width := tag.Length()
+	if o.Offset >= width || o.Offset+o.Length > width {
+		tags.Clear().Append(prev...)
+		return Tag{}, fmt.Errorf("tag %s: bits %d+%d out of the %d bits of the field", name, o.Offset, o.Length, width)
+	}
+	length := o.Length
+	if length == 0 {
+		length = width - o.Offset
+	}
+	tag.SetName(name).SetOffset(o.Offset).SetLength(length)
+
+	t := Tag{Flow: flow.Name(), Name: name}
+	mask := new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), uint(length)), big.NewInt(1))
+	for _, v := range values(msg.ProtoReflect()) {
+		bits := new(big.Int).Rsh(v, uint(width-o.Offset-length))
+		t.Values = append(t.Values, hex(bits.And(bits, mask)))
+	}
+	return t, nil
+}
+
+// values returns the values a header field pattern takes, in the order frames
+// carry them.
+func values(p protoreflect.Message) []*big.Int {
+	fields := p.Descriptor().Fields()
+	choice := ""
+	if fd := fields.ByName("choice"); fd != nil && p.Has(fd) {
+		choice = string(fd.Enum().Values().ByNumber(p.Get(fd).Enum()).Name())
+	}
+	switch choice {
+	case "values":
+		vs := []*big.Int{}
+		list := p.Get(fields.ByName("values")).List()
+		for i := 0; i < list.Len(); i++ {
+			vs = append(vs, toInt(list.Get(i)))
+		}
+		return vs
+	case "increment", "decrement":
+		c := p.Get(fields.ByName(protoreflect.Name(choice))).Message()
+		cf := c.Descriptor().Fields()
+		start, step := toInt(c.Get(cf.ByName("start"))), toInt(c.Get(cf.ByName("step")))
+		if choice == "decrement" {
+			step.Neg(step)
+		}
+		vs := []*big.Int{}
+		for k := uint64(0); k < max(c.Get(cf.ByName("count")).Uint(), 1); k++ {
+			v := new(big.Int).Mul(step, new(big.Int).SetUint64(k))
+			vs = append(vs, v.Add(v, start))
+		}
+		return vs
+	}
+	return []*big.Int{toInt(p.Get(fields.ByName("value")))}
+}
+
+// toInt returns a numeric, IP address or MAC address field value as an
+// integer.
+func toInt(v protoreflect.Value) *big.Int {
+	s, ok := v.Interface().(string)
+	if !ok {
+		return new(big.Int).SetUint64(v.Uint())
+	}
+	if ip := net.ParseIP(s); ip != nil {
+		if v4 := ip.To4(); v4 != nil && !strings.Contains(s, ":") {
+			ip = v4
+		}
+		return new(big.Int).SetBytes(ip)
+	}
+	if mac, err := net.ParseMAC(s); err == nil {
+		return new(big.Int).SetBytes(mac)
+	}
+	n, _ := new(big.Int).SetString(strings.TrimPrefix(strings.ToLower(s), "0x"), 16)
+	if n == nil {
+		return new(big.Int)
+	}
+	return n
+}
+
+// hex returns v as a tag value, e.g. 0x1f.
+func hex(v *big.Int) string {
+	return "0x" + v.Text(16)
+}
+
+// normalize returns a reported tag value in the form of Tag.Values.
+func normalize(value string) string {
+	n, ok := new(big.Int).SetString(strings.TrimPrefix(strings.ToLower(value), "0x"), 16)
+	if !ok {
+		return value
+	}
+	return hex(n)
+}
+
+// Request returns a metrics request for the flow metrics of the named flows
+// with their tagged metrics.
+func Request(flows ...string) gosnappi.MetricsRequest {
+	req := gosnappi.NewMetricsRequest()
+	req.Flow().SetFlowNames(flows).TaggedMetrics().SetInclude(true)
+	return req
+}
+
+// Metrics returns the flow metrics of the named flows with their tagged
+// metrics.
+func Metrics(api gosnappi.Api, flows ...string) ([]gosnappi.FlowMetric, error) {
+	res, err := api.GetMetrics(Request(flows...))
+	if err != nil {
+		return nil, err
+	}
+	return res.FlowMetrics().Items(), nil
+}
+
+// ValueStats is the traffic of the frames with one tag value.
+type ValueStats struct {
+	Value    string
+	FramesTx uint64
+	FramesRx uint64
+	// Share is the fraction of the frames of the flow sent with the value,
+	// Want the expected fraction.
+	Share float64
+	Want  float64
+}
+
+// Deviation is the absolute difference between the actual and expected share.
+func (v ValueStats) Deviation() float64 {
+	return math.Abs(v.Share - v.Want)
+}
+
+// Result is the breakdown of a flow's metrics by the values of a tag.
+type Result struct {
+	Tag
+	// FramesTx and FramesRx are the flow totals, TaggedTx and TaggedRx their
+	// sums over the tagged metric rows.
+	FramesTx uint64
+	FramesRx uint64
+	TaggedTx uint64
+	TaggedRx uint64
+	// Values holds the expected values first, in order, then any other
+	// value reported.
+	Values []ValueStats
+	// Unexpected are the reported values the flow does not send.
+	Unexpected []string
+	// Tolerance is the effective tolerance of the share deviations: the one
+	// passed to Analyze plus one frame.
+	Tolerance float64
+}
+
+// Analyze breaks the flow metrics m, fetched with tagged metrics as by
+// Metrics, down by the values of tag. Values are expected to get a share of
+// the frames in proportion to how often the flow sends them, within
+// tolerance, e.g. 0.01 for one percentage point.
+func (t Tag) Analyze(m gosnappi.FlowMetric, tolerance float64) *Result {
+	r := &Result{Tag: t, FramesTx: m.FramesTx(), FramesRx: m.FramesRx()}
+	index := map[string]int{}
+	for _, v := range t.Values {
+		if i, ok := index[v]; ok {
+			r.Values[i].Want++
+			continue
+		}
+		index[v] = len(r.Values)
+		r.Values = append(r.Values, ValueStats{Value: v, Want: 1})
+	}
+	for i := range r.Values {
+		r.Values[i].Want /= float64(len(t.Values))
+	}
+
+	for _, row := range m.TaggedMetrics().Items() {
+		r.TaggedTx += row.FramesTx()
+		r.TaggedRx += row.FramesRx()
+		for _, tag := range row.Tags().Items() {
+			if tag.Name() != t.Name {
+				continue
+			}
+			value := normalize(tag.Value().Hex())
+			if tag.Value().Choice() == gosnappi.FlowMetricTagValueChoice.STR {
+				value = tag.Value().Str()
+			}
+			i, ok := index[value]
+			if !ok {
+				i = len(r.Values)
+				index[value] = i
+				r.Values = append(r.Values, ValueStats{Value: value})
+				r.Unexpected = append(r.Unexpected, value)
+			}
+			r.Values[i].FramesTx += row.FramesTx()
+			r.Values[i].FramesRx += row.FramesRx()
+		}
+	}
+
+	r.Tolerance = tolerance
+	if r.FramesTx > 0 {
+		r.Tolerance += 1 / float64(r.FramesTx)
+		for i := range r.Values {
+			r.Values[i].Share = float64(r.Values[i].FramesTx) / float64(r.FramesTx)
+		}
+	}
+	return r
+}
+
+// Check returns an error listing the differences between the tagged rows and
+// the flow totals, the reported values the flow does not send and the values
+// whose share deviates from the expected one by more than the tolerance.
+func (r *Result) Check() error {
+	if r.FramesTx == 0 {
+		return fmt.Errorf("flow %s sent no frames", r.Flow)
+	}
+	var errs []string
+	if r.TaggedTx != r.FramesTx || r.TaggedRx != r.FramesRx {
+		errs = append(errs, fmt.Sprintf("tagged frames tx/rx sum to %d/%d, want the flow totals %d/%d",
+			r.TaggedTx, r.TaggedRx, r.FramesTx, r.FramesRx))
+	}
+	if len(r.Unexpected) > 0 {
+		errs = append(errs, fmt.Sprintf("unexpected values %s", strings.Join(r.Unexpected, ", ")))
+	}
+	for _, v := range r.Values {
+		if v.Deviation() > r.Tolerance {
+			errs = append(errs, fmt.Sprintf("%s share got %.4f, want %.4f +- %.4f", v.Value, v.Share, v.Want, r.Tolerance))
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("tag %s of flow %s:\n%s", r.Name, r.Flow, strings.Join(errs, "\n"))
+	}
+	return nil
+}
+
+// String renders the per-value table.
+func (r *Result) String() string {
+	b := &strings.Builder{}
+	w := tabwriter.NewWriter(b, 0, 0, 1, ' ', 0)
+
+	fmt.Fprintf(w, "Flow %s by %s\n\n", r.Flow, r.Name)
+	fmt.Fprint(w, "Value\tFramesTx\tFramesRx\tShare\tWant\tDeviation\n")
+	for _, v := range r.Values {
+		fmt.Fprintf(w, "%s\t%d\t%d\t%.4f\t%.4f\t%.4f\n",
+			v.Value, v.FramesTx, v.FramesRx, v.Share, v.Want, v.Deviation())
+	}
+	fmt.Fprintf(w, "Tagged\t%d\t%d\t\t\t\n", r.TaggedTx, r.TaggedRx)
+	fmt.Fprintf(w, "Flow\t%d\t%d\t\t\t\n", r.FramesTx, r.FramesRx)
+	w.Flush()
+	return b.String()
+}
@@ -0,0 +1,163 @@
+package metrictags
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/SpirentOrion/stc-otg/example/gosnappi/otgsimtest"
+	"github.com/open-traffic-generator/snappi/gosnappi"
+)
+
+// taggedFlow adds a flow of 1003 frames to config with its IPv4 destination
+// incrementing over 5 addresses and its UDP source port over 3 values, both
+// tagged.
+func taggedFlow(t *testing.T, config gosnappi.Config) (dst, src Tag) {
+	t.Helper()
+	flow := config.Flows().Add().SetName("flow1")
+	flow.TxRx().Port().SetTxName("port1").SetRxNames([]string{"port2"})
+	flow.Metrics().SetEnable(true)
+	flow.Rate().SetPps(1000)
+	flow.Duration().FixedPackets().SetPackets(1003)
+	flow.Packet().Add().Ethernet()
+	v4 := flow.Packet().Add().Ipv4()
+	v4.Dst().Increment().SetStart("200.1.1.1").SetCount(5)
+	udp := flow.Packet().Add().Udp()
+	udp.SrcPort().SetValues([]uint32{5000, 5001, 5000})
+
+	var err error
+	if dst, err = Add(flow, v4.Dst(), "dst"); err != nil {
+		t.Fatal(err)
+	}
+	if src, err = Add(flow, udp.SrcPort(), "src"); err != nil {
+		t.Fatal(err)
+	}
+	return dst, src
+}
+
+func TestAdd(t *testing.T) {
+	config := gosnappi.NewConfig()
+	dst, src := taggedFlow(t, config)
+	if got := strings.Join(dst.Values, " "); got != "0xc8010101 0xc8010102 0xc8010103 0xc8010104 0xc8010105" {
+		t.Errorf("Add() of the IPv4 destination got values %s", got)
+	}
+	if got := strings.Join(src.Values, " "); got != "0x1388 0x1389 0x1388" {
+		t.Errorf("Add() of the UDP source port got values %s", got)
+	}
+	tags := config.Flows().Items()[0].Packet().Items()[1].Ipv4().Dst().MetricTags().Items()
+	if len(tags) != 1 || tags[0].Name() != "dst" {
+		t.Errorf("Add() got metric tags %v on the IPv4 destination", tags)
+	}
+}
+
+func TestAddOptions(t *testing.T) {
+	config := gosnappi.NewConfig()
+	flow := config.Flows().Add().SetName("flow1")
+	v4 := flow.Packet().Add().Ipv4()
+	v4.Dst().Increment().SetStart("200.1.1.254").SetCount(3)
+
+	// The last octet wraps, the third octet carries.
+	octet, err := Add(flow, v4.Dst(), "octet", Options{Offset: 24})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(octet.Values, " "); got != "0xfe 0xff 0x0" {
+		t.Errorf("Add() of the last octet got values %s", got)
+	}
+	third, err := Add(flow, v4.Dst(), "third", Options{Offset: 16, Length: 8})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(third.Values, " "); got != "0x1 0x1 0x2" {
+		t.Errorf("Add() of the third octet got values %s", got)
+	}
+	tags := v4.Dst().MetricTags().Items()
+	if len(tags) != 2 || tags[0].Offset() != 24 || tags[0].Length() != 8 || tags[1].Offset() != 16 || tags[1].Length() != 8 {
+		t.Errorf("Add() got metric tags %v on the IPv4 destination", tags)
+	}
+
+	for _, o := range []Options{{Offset: 32}, {Offset: 16, Length: 17}} {
+		if _, err := Add(flow, v4.Dst(), "bad", o); err == nil {
+			t.Errorf("Add() with %+v beyond the 32 bits of the field succeeded", o)
+		}
+	}
+	if n := len(v4.Dst().MetricTags().Items()); n != 2 {
+		t.Errorf("failed Add() left %d metric tags, want the 2 added before", n)
+	}
+}
+
+func TestAnalyze(t *testing.T) {
+	_, api := otgsimtest.Start(t)
+
+	config := gosnappi.NewConfig()
+	config.Ports().Add().SetName("port1").SetLocation("//sim/1/1")
+	config.Ports().Add().SetName("port2").SetLocation("//sim/1/2")
+	dst, src := taggedFlow(t, config)
+	if _, err := api.SetConfig(config); err != nil {
+		t.Fatal(err)
+	}
+	cs := gosnappi.NewControlState()
+	cs.Traffic().FlowTransmit().SetState(gosnappi.StateTrafficFlowTransmitState.START)
+	if _, err := api.SetControlState(cs); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(20 * time.Millisecond)
+
+	metrics, err := Metrics(api, "flow1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(metrics) != 1 {
+		t.Fatalf("Metrics() got %d flows, want 1", len(metrics))
+	}
+	// The values repeat every 15 frames, in 10 distinct combinations.
+	if rows := len(metrics[0].TaggedMetrics().Items()); rows != 10 {
+		t.Errorf("Metrics() got %d tagged rows, want the 10 distinct combinations", rows)
+	}
+
+	r := dst.Analyze(metrics[0], 0.001)
+	if err := r.Check(); err != nil {
+		t.Errorf("Check() of the destination failed: %v\n%s", err, r)
+	}
+	// 1003 frames over 5 addresses: the first 3 get one more.
+	for i, want := range []uint64{201, 201, 201, 200, 200} {
+		if got := r.Values[i].FramesTx; got != want {
+			t.Errorf("%s got %d frames, want %d", r.Values[i].Value, got, want)
+		}
+	}
+
+	r = src.Analyze(metrics[0], 0.001)
+	if err := r.Check(); err != nil {
+		t.Errorf("Check() of the source port failed: %v\n%s", err, r)
+	}
+	if len(r.Values) != 2 || r.Values[0].Want != 2.0/3 || r.Values[0].FramesTx != 669 {
+		t.Errorf("Analyze() of the source port got\n%s", r)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	m := gosnappi.NewFlowMetric().SetName("flow1").SetFramesTx(100).SetFramesRx(100)
+	row := func(value string, frames uint64) {
+		tm := m.TaggedMetrics().Add().SetFramesTx(frames).SetFramesRx(frames)
+		tm.Tags().Add().SetName("dst").Value().SetHex(value)
+	}
+	row("0xC8010101", 70)
+	row("0x00c8010102", 20)
+	row("0xc8010109", 5)
+	tag := Tag{Flow: "flow1", Name: "dst", Values: []string{"0xc8010101", "0xc8010102"}}
+
+	err := tag.Analyze(m, 0.05).Check()
+	if err == nil {
+		t.Fatal("Check() succeeded")
+	}
+	for _, want := range []string{
+		"tagged frames tx/rx sum to 95/95, want the flow totals 100/100",
+		"unexpected values 0xc8010109",
+		"0xc8010101 share got 0.7000, want 0.5000",
+		"0xc8010102 share got 0.2000, want 0.5000",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Check() got %v, want %s", err, want)
+		}
+	}
+}
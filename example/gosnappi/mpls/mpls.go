@@ -0,0 +1,338 @@
+// Package mpls builds flows of MPLS labeled frames for label switched path
+// tests: ethernet, a stack of 1 to N labels and an inner IPv4 or IPv6 packet.
+// Each label takes a fixed value, an increment or a list of values, a
+// traffic class and a TTL; the builder sets the bottom of stack bit of the
+// last label and clears it on the others. The ends of a flow are ports or
+// the ISIS and BGP route ranges of the config, in which case the flow is a
+// device flow and its inner addresses walk the prefixes of the ranges.
+//
+// SupportedAPIsList.txt lists the label and TTL of MPLS headers but not
+// their traffic class and bottom of stack; check them with TestConformance
+// on a new STC release.
+package mpls
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+
+	"github.com/SpirentOrion/stc-otg/example/gosnappi/headerstack"
+	"github.com/open-traffic-generator/snappi/gosnappi"
+	"github.com/open-traffic-generator/snappi/gosnappi/otg"
+)
+
+// MaxLabel is the largest label value.
+const MaxLabel = 1<<20 - 1
+
+// ErrNoRouteRange is the error RouteRange wraps when config has no route
+// range of the name, as opposed to one it cannot use.
+var ErrNoRouteRange = errors.New("no ISIS or BGP route range")
+
+// Label is one label of a stack.
+type Label struct {
+	// Value is the label, the first one when Count is above 1: the label
+	// then increments by Step, 1 if zero, over Count values.
+	Value uint32
+	Step  uint32
+	Count uint32
+	// Values are the labels taken in turn, instead of Value.
+	Values []uint32
+	// TrafficClass is the traffic class, 0 to 7.
+	TrafficClass uint32
+	// Ttl is the time to live, 64 if zero.
+	Ttl uint32
+}
+
+// check returns an error if a field of l is out of range.
+func (l Label) check() error {
+	for _, v := range append([]uint32{l.Value}, l.Values...) {
+		if v > MaxLabel {
+			return fmt.Errorf("label %d above %d", v, MaxLabel)
+		}
+	}
+	if l.Count > 1 && uint64(l.Value)+uint64(max(l.Step, 1))*uint64(l.Count-1) > MaxLabel {
+		return fmt.Errorf("label %d incrementing %d times runs past %d", l.Value, l.Count-1, MaxLabel)
+	}
+	if l.TrafficClass > 7 {
+		return fmt.Errorf("traffic class %d above 7", l.TrafficClass)
+	}
+	if l.Ttl > 255 {
+		return fmt.Errorf("ttl %d above 255", l.Ttl)
+	}
+	return nil
+}
+
+// Push adds the MPLS headers of labels, outermost first, to a flow packet.
+func Push(packet gosnappi.FlowFlowHeaderIter, labels ...Label) error {
+	if len(labels) == 0 {
+		return fmt.Errorf("no labels")
+	}
+	for i, l := range labels {
+		if err := l.check(); err != nil {
+			return fmt.Errorf("label %d of the stack: %v", i+1, err)
+		}
+	}
+	for i, l := range labels {
+		h := packet.Add().Mpls()
+		switch {
+		case len(l.Values) > 0:
+			h.Label().SetValues(l.Values)
+		case l.Count > 1:
+			h.Label().Increment().SetStart(l.Value).SetStep(max(l.Step, 1)).SetCount(l.Count)
+		default:
+			h.Label().SetValue(l.Value)
+		}
+		if l.TrafficClass > 0 {
+			h.TrafficClass().SetValue(l.TrafficClass)
+		}
+		bottom := uint32(0)
+		if i == len(labels)-1 {
+			bottom = 1
+		}
+		h.BottomOfStack().SetValue(bottom)
+		ttl := l.Ttl
+		if ttl == 0 {
+			ttl = 64
+		}
+		h.TimeToLive().SetValue(ttl)
+	}
+	return nil
+}
+
+// Range is the first address block of a route range, the prefixes a flow to
+// or from it sends to or from.
+type Range struct {
+	Name   string
+	Device string
+	V6     bool
+	// Address is the first prefix, Count the number of prefixes and Step
+	// the number of prefixes between two of them.
+	Address string
+	Prefix  uint32
+	Count   uint32
+	Step    uint32
+	// Mac is the MAC address of the first ethernet of the device.
+	Mac string
+}
+
+// bits returns the address width of r.
+func (r Range) bits() int {
+	if r.V6 {
+		return 128
+	}
+	return 32
+}
+
+// increment returns the address increment from one prefix of r to the next
+// as an integer.
+func (r Range) increment() *big.Int {
+	return new(big.Int).Lsh(big.NewInt(int64(max(r.Step, 1))), uint(r.bits()-int(r.Prefix)))
+}
+
+// validate checks that the prefix length of r fits its address family and
+// that its step stays within the address width.
+func (r Range) validate() error {
+	if r.Prefix < 1 || int(r.Prefix) > r.bits() {
+		return fmt.Errorf("route range %s: prefix length %d not in 1 to %d", r.Name, r.Prefix, r.bits())
+	}
+	if r.increment().BitLen() > r.bits() {
+		return fmt.Errorf("route range %s: step of %d /%d prefixes exceeds the address width", r.Name, max(r.Step, 1), r.Prefix)
+	}
+	return nil
+}
+
+// Increment returns the address increment from one prefix of r to the next.
+// r must be valid, as RouteRange returns it.
+func (r Range) Increment() string {
+	return net.IP(r.increment().FillBytes(make([]byte, r.bits()/8))).String()
+}
+
+// RouteRange returns the ISIS or BGP route range of config named name. It
+// fails with ErrNoRouteRange if there is none, and with other errors if the
+// range has no addresses or its prefix length or step does not fit its
+// address family.
+func RouteRange(config gosnappi.Config, name string) (Range, error) {
+	msg, err := config.Marshal().ToProto()
+	if err != nil {
+		return Range{}, err
+	}
+	for _, d := range msg.GetDevices() {
+		for _, rr := range deviceRoutes(d) {
+			if rr.name != name {
+				continue
+			}
+			r := Range{Name: name, Device: d.GetName()}
+			if eths := d.GetEthernets(); len(eths) > 0 {
+				r.Mac = eths[0].GetMac()
+			}
+			switch {
+			case len(rr.v4) > 0:
+				a := rr.v4[0]
+				r.Address, r.Prefix, r.Count, r.Step = a.GetAddress(), a.GetPrefix(), max(a.GetCount(), 1), max(a.GetStep(), 1)
+			case len(rr.v6) > 0:
+				a := rr.v6[0]
+				r.V6 = true
+				r.Address, r.Prefix, r.Count, r.Step = a.GetAddress(), a.GetPrefix(), max(a.GetCount(), 1), max(a.GetStep(), 1)
+			default:
+				return Range{}, fmt.Errorf("route range %s has no addresses", name)
+			}
+			if err := r.validate(); err != nil {
+				return Range{}, err
+			}
+			return r, nil
+		}
+	}
+	return Range{}, fmt.Errorf("%w %s", ErrNoRouteRange, name)
+}
+
+// routes is a named route range of either family.
+type routes struct {
+	name string
+	v4   []*otg.V4RouteAddress
+	v6   []*otg.V6RouteAddress
+}
+
+// deviceRoutes returns the ISIS and BGP route ranges of a device.
+func deviceRoutes(d *otg.Device) []routes {
+	out := []routes{}
+	for _, rr := range d.GetIsis().GetV4Routes() {
+		out = append(out, routes{name: rr.GetName(), v4: rr.GetAddresses()})
+	}
+	for _, rr := range d.GetIsis().GetV6Routes() {
+		out = append(out, routes{name: rr.GetName(), v6: rr.GetAddresses()})
+	}
+	for _, ifc := range d.GetBgp().GetIpv4Interfaces() {
+		for _, p := range ifc.GetPeers() {
+			out = append(out, bgpRoutes(p.GetV4Routes(), p.GetV6Routes())...)
+		}
+	}
+	for _, ifc := range d.GetBgp().GetIpv6Interfaces() {
+		for _, p := range ifc.GetPeers() {
+			out = append(out, bgpRoutes(p.GetV4Routes(), p.GetV6Routes())...)
+		}
+	}
+	return out
+}
+
+func bgpRoutes(v4 []*otg.BgpV4RouteRange, v6 []*otg.BgpV6RouteRange) []routes {
+	out := []routes{}
+	for _, rr := range v4 {
+		out = append(out, routes{name: rr.GetName(), v4: rr.GetAddresses()})
+	}
+	for _, rr := range v6 {
+		out = append(out, routes{name: rr.GetName(), v6: rr.GetAddresses()})
+	}
+	return out
+}
+
+// Flow is a flow of labeled frames.
+type Flow struct {
+	Name string
+	// Tx and Rx are the ends of the flow: the names of two ports, or of two
+	// ISIS or BGP route ranges for a device flow between them.
+	Tx string
+	Rx string
+	// Labels is the label stack, outermost first.
+	Labels []Label
+	// Src and Dst are the inner addresses of a flow between ports, IPv6
+	// ones for an inner IPv6 packet. Flows between route ranges send from
+	// and to the prefixes of the ranges instead.
+	Src string
+	Dst string
+	// Pps is the rate of the flow, 1000 if zero.
+	Pps uint64
+	// FrameSize is the size of the frames, 128 if zero, or the smallest
+	// size holding the headers if that is more.
+	FrameSize uint32
+	// Frames is the number of frames to send, continuous if zero.
+	Frames uint32
+}
+
+// Add adds the flow to config, after the ports or route ranges of its ends.
+// It fails if the ends are not both ports or both route ranges of one
+// family, a route range is invalid, or the frame size is too small for the
+// headers.
+func (f Flow) Add(config gosnappi.Config) (gosnappi.Flow, error) {
+	pps := f.Pps
+	if pps == 0 {
+		pps = 1000
+	}
+	flow := gosnappi.NewFlow().SetName(f.Name)
+	flow.Metrics().SetEnable(true)
+	flow.Rate().SetPps(pps)
+	if f.Frames > 0 {
+		flow.Duration().FixedPackets().SetPackets(f.Frames)
+	} else {
+		flow.Duration().Continuous()
+	}
+
+	tx, txErr := RouteRange(config, f.Tx)
+	rx, rxErr := RouteRange(config, f.Rx)
+	for _, err := range []error{txErr, rxErr} {
+		if err != nil && !errors.Is(err, ErrNoRouteRange) {
+			return nil, fmt.Errorf("flow %s: %v", f.Name, err)
+		}
+	}
+	eth := flow.Packet().Add().Ethernet()
+	switch {
+	case txErr == nil && rxErr == nil:
+		if tx.V6 != rx.V6 {
+			return nil, fmt.Errorf("flow %s: route ranges %s and %s are of different families", f.Name, f.Tx, f.Rx)
+		}
+		flow.TxRx().Device().SetTxNames([]string{f.Tx}).SetRxNames([]string{f.Rx})
+		eth.Src().SetValue(tx.Mac)
+		eth.Dst().SetValue(rx.Mac)
+		if err := Push(flow.Packet(), f.Labels...); err != nil {
+			return nil, fmt.Errorf("flow %s: %v", f.Name, err)
+		}
+		if tx.V6 {
+			ip := flow.Packet().Add().Ipv6()
+			ip.Src().Increment().SetStart(tx.Address).SetStep(tx.Increment()).SetCount(tx.Count)
+			ip.Dst().Increment().SetStart(rx.Address).SetStep(rx.Increment()).SetCount(rx.Count)
+		} else {
+			ip := flow.Packet().Add().Ipv4()
+			ip.Src().Increment().SetStart(tx.Address).SetStep(tx.Increment()).SetCount(tx.Count)
+			ip.Dst().Increment().SetStart(rx.Address).SetStep(rx.Increment()).SetCount(rx.Count)
+		}
+	case txErr != nil && rxErr != nil:
+		flow.TxRx().Port().SetTxName(f.Tx).SetRxNames([]string{f.Rx})
+		eth.Src().SetValue("00:11:22:33:44:66")
+		eth.Dst().SetValue("00:11:22:33:44:55")
+		if err := Push(flow.Packet(), f.Labels...); err != nil {
+			return nil, fmt.Errorf("flow %s: %v", f.Name, err)
+		}
+		src, dst := net.ParseIP(f.Src), net.ParseIP(f.Dst)
+		switch {
+		case src == nil || dst == nil:
+			return nil, fmt.Errorf("flow %s: inner addresses %q and %q are not IP addresses", f.Name, f.Src, f.Dst)
+		case (src.To4() == nil) != (dst.To4() == nil):
+			return nil, fmt.Errorf("flow %s: inner addresses %s and %s are of different families", f.Name, f.Src, f.Dst)
+		case dst.To4() == nil:
+			ip := flow.Packet().Add().Ipv6()
+			ip.Src().SetValue(f.Src)
+			ip.Dst().SetValue(f.Dst)
+		default:
+			ip := flow.Packet().Add().Ipv4()
+			ip.Src().SetValue(f.Src)
+			ip.Dst().SetValue(f.Dst)
+		}
+	default:
+		return nil, fmt.Errorf("flow %s: %s and %s are not both ports or both route ranges", f.Name, f.Tx, f.Rx)
+	}
+
+	min, err := headerstack.MinSize(flow)
+	if err != nil {
+		return nil, err
+	}
+	size := f.FrameSize
+	if size == 0 {
+		size = max(128, min)
+	}
+	if size < min {
+		return nil, fmt.Errorf("flow %s: frame size %d below the %d bytes of its headers", f.Name, size, min)
+	}
+	flow.Size().SetFixed(size)
+	config.Flows().Append(flow)
+	return flow, nil
+}
@@ -0,0 +1,241 @@
+package mpls
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/SpirentOrion/stc-otg/example/gosnappi/otgsim"
+	"github.com/SpirentOrion/stc-otg/example/gosnappi/otgsimtest"
+	"github.com/open-traffic-generator/snappi/gosnappi"
+)
+
+// routingConfig returns two devices on port1 and port2 that run ISIS and
+// iBGP and advertise five ISIS IPv4 routes and three BGP IPv6 routes each.
+func routingConfig() gosnappi.Config {
+	config := gosnappi.NewConfig()
+	for i := 1; i <= 2; i++ {
+		port := config.Ports().Add().SetName(fmt.Sprintf("port%d", i))
+		d := config.Devices().Add().SetName(fmt.Sprintf("dev%d", i))
+		eth := d.Ethernets().Add().SetName(fmt.Sprintf("dev%d.eth", i)).SetMac(fmt.Sprintf("02:00:00:00:00:%02d", i))
+		eth.Connection().SetPortName(port.Name())
+		ip := eth.Ipv4Addresses().Add().SetName(fmt.Sprintf("dev%d.ipv4", i)).
+			SetAddress(fmt.Sprintf("192.0.2.%d", i)).SetGateway(fmt.Sprintf("192.0.2.%d", 3-i)).SetPrefix(30)
+
+		isis := d.Isis().SetName(fmt.Sprintf("dev%d.isis", i)).SetSystemId(fmt.Sprintf("%d50000000001", i+5))
+		isis.Interfaces().Add().SetName(fmt.Sprintf("dev%d.isis.intf", i)).SetEthName(eth.Name()).
+			SetNetworkType(gosnappi.IsisInterfaceNetworkType.POINT_TO_POINT).
+			SetLevelType(gosnappi.IsisInterfaceLevelType.LEVEL_2)
+		isis.V4Routes().Add().SetName(fmt.Sprintf("dev%d.isis.routes", i)).Addresses().Add().
+			SetAddress(fmt.Sprintf("%d.1.1.1", i*100)).SetPrefix(32).SetCount(5)
+
+		peer := d.Bgp().SetRouterId(ip.Address()).Ipv4Interfaces().Add().SetIpv4Name(ip.Name()).Peers().Add().
+			SetName(fmt.Sprintf("dev%d.bgp", i)).SetPeerAddress(ip.Gateway()).
+			SetAsType(gosnappi.BgpV4PeerAsType.IBGP).SetAsNumber(65000)
+		peer.V6Routes().Add().SetName(fmt.Sprintf("dev%d.bgp.routes", i)).Addresses().Add().
+			SetAddress(fmt.Sprintf("2001:db8:%d::", i)).SetPrefix(64).SetCount(3).SetStep(2)
+	}
+	return config
+}
+
+func TestPush(t *testing.T) {
+	flow := gosnappi.NewFlow()
+	err := Push(flow.Packet(),
+		Label{Value: 16, Count: 4, Step: 10, TrafficClass: 5},
+		Label{Values: []uint32{100, 200}, Ttl: 1},
+		Label{Value: 3000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	headers := flow.Packet().Items()
+	if len(headers) != 3 {
+		t.Fatalf("Push() added %d headers, want 3", len(headers))
+	}
+	for i, want := range []uint32{0, 0, 1} {
+		if got := headers[i].Mpls().BottomOfStack().Value(); got != want {
+			t.Errorf("label %d got bottom of stack %d, want %d", i+1, got, want)
+		}
+	}
+	first := headers[0].Mpls()
+	if inc := first.Label().Increment(); inc.Start() != 16 || inc.Step() != 10 || inc.Count() != 4 {
+		t.Errorf("Push() got label increment %v", inc)
+	}
+	if first.TrafficClass().Value() != 5 || first.TimeToLive().Value() != 64 {
+		t.Errorf("Push() got traffic class %d and ttl %d, want 5 and 64", first.TrafficClass().Value(), first.TimeToLive().Value())
+	}
+	if got := headers[1].Mpls().Label().Values(); len(got) != 2 || headers[1].Mpls().TimeToLive().Value() != 1 {
+		t.Errorf("Push() got labels %v and ttl %d", got, headers[1].Mpls().TimeToLive().Value())
+	}
+
+	for _, tc := range []struct {
+		labels []Label
+		want   string
+	}{
+		{nil, "no labels"},
+		{[]Label{{Value: 16}, {Value: MaxLabel + 1}}, "label 2 of the stack: label 1048576 above 1048575"},
+		{[]Label{{Value: MaxLabel - 1, Count: 3}}, "runs past"},
+		{[]Label{{Value: 16, TrafficClass: 8}}, "traffic class 8 above 7"},
+	} {
+		if err := Push(gosnappi.NewFlow().Packet(), tc.labels...); err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("Push(%v) got %v, want %q", tc.labels, err, tc.want)
+		}
+	}
+}
+
+func TestRouteRange(t *testing.T) {
+	config := routingConfig()
+	r, err := RouteRange(config, "dev2.isis.routes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Device != "dev2" || r.Mac != "02:00:00:00:00:02" || r.Address != "200.1.1.1" || r.Count != 5 || r.Increment() != "0.0.0.1" {
+		t.Errorf("RouteRange() got %+v", r)
+	}
+	r, err = RouteRange(config, "dev1.bgp.routes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !r.V6 || r.Count != 3 || r.Increment() != "0:0:0:2::" {
+		t.Errorf("RouteRange() got %+v, increment %s", r, r.Increment())
+	}
+	if _, err := RouteRange(config, "port1"); !errors.Is(err, ErrNoRouteRange) {
+		t.Errorf("RouteRange() of a port got %v, want ErrNoRouteRange", err)
+	}
+
+	addrs := config.Devices().Items()[1].Isis().V4Routes().Items()[0].Addresses().Items()
+	for _, tc := range []struct {
+		prefix, step uint32
+		want         string
+	}{
+		{0, 1, "prefix length 0 not in 1 to 32"},
+		// gosnappi rejects the prefix itself.
+		{33, 1, "32"},
+		{8, 256, "exceeds the address width"},
+	} {
+		addrs[0].SetPrefix(tc.prefix).SetStep(tc.step)
+		if _, err := RouteRange(config, "dev2.isis.routes"); err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("RouteRange() of a /%d range by %d got %v, want %q", tc.prefix, tc.step, err, tc.want)
+		}
+	}
+}
+
+func TestAdd(t *testing.T) {
+	config := routingConfig()
+	labels := []Label{{Value: 16}, {Value: 1000, Count: 5}}
+	flow, err := Flow{Name: "lsp", Tx: "dev1.isis.routes", Rx: "dev2.isis.routes", Labels: labels}.Add(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if flow.TxRx().Choice() != gosnappi.FlowTxRxChoice.DEVICE || flow.TxRx().Device().RxNames()[0] != "dev2.isis.routes" {
+		t.Errorf("Add() between route ranges got tx rx %v", flow.TxRx())
+	}
+	headers := flow.Packet().Items()
+	if got := stackName(headers); got != "ethernet/mpls/mpls/ipv4" {
+		t.Errorf("Add() got headers %s", got)
+	}
+	if dst := headers[3].Ipv4().Dst().Increment(); dst.Start() != "200.1.1.1" || dst.Count() != 5 {
+		t.Errorf("Add() got inner destination %v", dst)
+	}
+	if headers[0].Ethernet().Dst().Value() != "02:00:00:00:00:02" || flow.Size().Fixed() != 128 {
+		t.Errorf("Add() got destination MAC %s and size %d", headers[0].Ethernet().Dst().Value(), flow.Size().Fixed())
+	}
+
+	flow, err = Flow{Name: "bgp", Tx: "dev1.bgp.routes", Rx: "dev2.bgp.routes", Labels: labels}.Add(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dst := flow.Packet().Items()[3].Ipv6().Dst().Increment(); dst.Start() != "2001:db8:2::" || dst.Step() != "0:0:0:2::" {
+		t.Errorf("Add() got inner IPv6 destination %v", dst)
+	}
+
+	// 14 + 4 * 20 + 40 bytes of headers and the FCS.
+	flow, err = Flow{Name: "ports", Tx: "port1", Rx: "port2", Labels: make([]Label, 20), Src: "2001:db8::1", Dst: "2001:db8::2"}.Add(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if flow.Size().Fixed() != 138 {
+		t.Errorf("Add() of 20 labels got size %d, want 138", flow.Size().Fixed())
+	}
+
+	for _, tc := range []struct {
+		flow Flow
+		want string
+	}{
+		{Flow{Name: "f", Tx: "port1", Rx: "dev2.isis.routes", Labels: labels}, "not both ports or both route ranges"},
+		{Flow{Name: "f", Tx: "dev1.isis.routes", Rx: "dev2.bgp.routes", Labels: labels}, "different families"},
+		{Flow{Name: "f", Tx: "port1", Rx: "port2", Labels: labels, Src: "10.0.0.1", Dst: "2001:db8::2"}, "different families"},
+		{Flow{Name: "f", Tx: "port1", Rx: "port2", Labels: labels, Src: "2001:db8::1", Dst: "2001:db8::2", FrameSize: 64}, "frame size 64 below the 66 bytes"},
+	} {
+		if _, err := tc.flow.Add(config); err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("Add() of %+v got %v, want %q", tc.flow, err, tc.want)
+		}
+	}
+	if n := len(config.Flows().Items()); n != 3 {
+		t.Errorf("config got %d flows, want the 3 added", n)
+	}
+
+	// An invalid route range is reported as it is, not taken for a port.
+	bad := routingConfig()
+	bad.Devices().Items()[0].Isis().V4Routes().Items()[0].Addresses().Items()[0].SetPrefix(0)
+	for _, f := range []Flow{
+		{Name: "f", Tx: "dev1.isis.routes", Rx: "dev2.isis.routes", Labels: labels},
+		{Name: "f", Tx: "dev1.isis.routes", Rx: "port2", Labels: labels},
+	} {
+		if _, err := f.Add(bad); err == nil || !strings.Contains(err.Error(), "prefix length 0") {
+			t.Errorf("Add() from an invalid range to %s got %v, want the prefix length error", f.Rx, err)
+		}
+	}
+}
+
+// stackName returns the header types of a packet joined by slashes.
+func stackName(headers []gosnappi.FlowHeader) string {
+	names := []string{}
+	for _, h := range headers {
+		names = append(names, string(h.Choice()))
+	}
+	return strings.Join(names, "/")
+}
+
+func TestRun(t *testing.T) {
+	sim, api := otgsimtest.Start(t)
+	sim.Links = []otgsim.Link{{A: "port1", B: "port2"}}
+
+	config := routingConfig()
+	for _, f := range []Flow{
+		{Name: "isis", Tx: "dev1.isis.routes", Rx: "dev2.isis.routes", Labels: []Label{{Value: 16}}, Frames: 100},
+		{Name: "bgp", Tx: "dev1.bgp.routes", Rx: "dev2.bgp.routes", Labels: []Label{{Value: 16}, {Value: 2000}}, Frames: 100},
+	} {
+		if _, err := f.Add(config); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := api.SetConfig(config); err != nil {
+		t.Fatal(err)
+	}
+	cs := gosnappi.NewControlState()
+	cs.Protocol().All().SetState(gosnappi.StateProtocolAllState.START)
+	if _, err := api.SetControlState(cs); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(10 * time.Millisecond)
+	cs = gosnappi.NewControlState()
+	cs.Traffic().FlowTransmit().SetState(gosnappi.StateTrafficFlowTransmitState.START)
+	if _, err := api.SetControlState(cs); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(20 * time.Millisecond)
+
+	req := gosnappi.NewMetricsRequest()
+	req.Flow()
+	res, err := api.GetMetrics(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range res.FlowMetrics().Items() {
+		if m.FramesTx() != 100 || m.FramesRx() != 100 {
+			t.Errorf("flow %s got %d of %d frames, want 100", m.Name(), m.FramesRx(), m.FramesTx())
+		}
+	}
+}
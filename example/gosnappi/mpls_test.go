@@ -0,0 +1,133 @@
+package gosnappi_examples
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/SpirentOrion/stc-otg/example/gosnappi/mpls"
+	"github.com/SpirentOrion/stc-otg/example/gosnappi/trial"
+	"github.com/open-traffic-generator/snappi/gosnappi"
+)
+
+// PORT1=10.109.121.181/1/1 PORT2=10.109.123.254/1/1 OTGSERVER=localhost:50051 go test -v -test.run TestMplsLsp
+func TestMplsLsp(t *testing.T) {
+	// Create a new API handle to make API calls against OTG
+	api := gosnappi.NewApi()
+
+	// Set the transport protocol to gRPC
+	api.NewGrpcTransport().
+		SetLocation(OTGSERVER).
+		SetDialTimeout(3 * time.Minute).
+		SetRequestTimeout(10 * time.Minute)
+
+	// Two routers advertising an ISIS IPv4 route range and a BGP IPv6 one
+	// each, like the isis_basic test
+	config := gosnappi.NewConfig()
+	for i, location := range []string{PORT1, PORT2} {
+		n := i + 1
+		port := config.Ports().Add().SetName(fmt.Sprintf("port%d", n)).SetLocation(location)
+		d := config.Devices().Add().SetName(fmt.Sprintf("dev%d", n))
+		eth := d.Ethernets().Add().SetName(fmt.Sprintf("dev%d.eth", n)).SetMac(fmt.Sprintf("02:00:00:00:00:%02d", n))
+		eth.Connection().SetPortName(port.Name())
+		ip := eth.Ipv4Addresses().Add().SetName(fmt.Sprintf("dev%d.ipv4", n)).
+			SetAddress(fmt.Sprintf("192.0.2.%d", n)).SetGateway(fmt.Sprintf("192.0.2.%d", 3-n)).SetPrefix(30)
+
+		isis := d.Isis().SetName(fmt.Sprintf("dev%d.isis", n)).SetSystemId(fmt.Sprintf("64000000000%d", n))
+		isis.Basic().SetIpv4TeRouterId(ip.Address()).SetHostname(isis.Name())
+		isis.Interfaces().Add().SetName(fmt.Sprintf("dev%d.isis.intf", n)).SetEthName(eth.Name()).
+			SetNetworkType(gosnappi.IsisInterfaceNetworkType.POINT_TO_POINT).
+			SetLevelType(gosnappi.IsisInterfaceLevelType.LEVEL_2)
+		isis.V4Routes().Add().SetName(fmt.Sprintf("dev%d.isis.routes", n)).Addresses().Add().
+			SetAddress(fmt.Sprintf("%d.1.1.1", n*100)).SetPrefix(32).SetCount(5)
+
+		peer := d.Bgp().SetRouterId(ip.Address()).Ipv4Interfaces().Add().SetIpv4Name(ip.Name()).Peers().Add().
+			SetName(fmt.Sprintf("dev%d.bgp", n)).SetPeerAddress(ip.Gateway()).
+			SetAsType(gosnappi.BgpV4PeerAsType.IBGP).SetAsNumber(65000)
+		peer.V6Routes().Add().SetName(fmt.Sprintf("dev%d.bgp.routes", n)).Addresses().Add().
+			SetAddress(fmt.Sprintf("2001:db8:%d::", n)).SetPrefix(64).SetCount(3)
+	}
+
+	// Label switched paths between the route ranges: a transport label
+	// with one service label per prefix for the ISIS routes, and a
+	// three-label stack for the BGP routes
+	flows := []mpls.Flow{
+		{
+			Name: "isis_lsp", Tx: "dev1.isis.routes", Rx: "dev2.isis.routes",
+			Labels: []mpls.Label{{Value: 16001, TrafficClass: 3}, {Value: 1000, Count: 5}},
+			Pps:    1000, Frames: 10000,
+		},
+		{
+			Name: "bgp_lsp", Tx: "dev1.bgp.routes", Rx: "dev2.bgp.routes",
+			Labels: []mpls.Label{{Value: 16002}, {Value: 24000, Ttl: 2}, {Values: []uint32{2000, 2001, 2002}}},
+			Pps:    1000, Frames: 10000,
+		},
+	}
+	for _, f := range flows {
+		if _, err := f.Add(config); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := api.SetConfig(config); err != nil {
+		t.Fatal(err)
+	}
+	controlState := gosnappi.NewControlState()
+	controlState.Protocol().All().SetState(gosnappi.StateProtocolAllState.START)
+	if _, err := api.SetControlState(controlState); err != nil {
+		t.Fatal(err)
+	}
+	awaitSessions(t, api, []string{"dev1.isis", "dev2.isis"}, []string{"dev1.bgp", "dev2.bgp"}, time.Minute)
+
+	// Run the flows until they stop on their own and let the last frames
+	// arrive before reading the metrics
+	runner := trial.Runner{Api: api, Settle: 2 * time.Second}
+	metrics, err := runner.RunFlows(nil, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Every labeled frame reaches the advertised prefixes
+	for _, m := range metrics {
+		t.Logf("flow %s: tx %d rx %d", m.Name(), m.FramesTx(), m.FramesRx())
+		if m.FramesTx() == 0 || m.FramesRx() != m.FramesTx() {
+			t.Errorf("flow %s received %d of %d frames", m.Name(), m.FramesRx(), m.FramesTx())
+		}
+	}
+}
+
+// awaitSessions waits until the ISIS routers have their level 2 adjacencies
+// up and the BGP peers are established, and fails t past timeout.
+func awaitSessions(t *testing.T, api gosnappi.Api, isis, bgp []string, timeout time.Duration) {
+	t.Helper()
+	for deadline := time.Now().Add(timeout); ; {
+		req := gosnappi.NewMetricsRequest()
+		req.Isis().SetRouterNames(isis)
+		res, err := api.GetMetrics(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		up := len(res.IsisMetrics().Items()) == len(isis)
+		for _, m := range res.IsisMetrics().Items() {
+			up = up && m.L2SessionsUp() > 0
+		}
+
+		req = gosnappi.NewMetricsRequest()
+		req.Bgpv4().SetPeerNames(bgp)
+		if res, err = api.GetMetrics(req); err != nil {
+			t.Fatal(err)
+		}
+		up = up && len(res.Bgpv4Metrics().Items()) == len(bgp)
+		for _, m := range res.Bgpv4Metrics().Items() {
+			up = up && m.SessionState() == gosnappi.Bgpv4MetricSessionState.UP
+		}
+
+		if up {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("ISIS routers %v and BGP peers %v did not come up within %v", isis, bgp, timeout)
+		}
+		time.Sleep(time.Second)
+	}
+}
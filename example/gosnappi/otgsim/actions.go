@@ -0,0 +1,94 @@
+package otgsim
+
+import (
+	"context"
+	"time"
+
+	"github.com/open-traffic-generator/snappi/gosnappi"
+	"github.com/open-traffic-generator/snappi/gosnappi/otg"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ipPort returns the port or LAG of the ethernet carrying the IPv4 or IPv6
+// interface called name, empty if no such interface is configured.
+func (s *Server) ipPort(name string, ipv6 bool) string {
+	for _, d := range s.config.Devices().Items() {
+		for _, eth := range d.Ethernets().Items() {
+			if ipv6 {
+				for _, ip := range eth.Ipv6Addresses().Items() {
+					if ip.Name() == name {
+						return s.ethPort(eth)
+					}
+				}
+			} else {
+				for _, ip := range eth.Ipv4Addresses().Items() {
+					if ip.Name() == name {
+						return s.ethPort(eth)
+					}
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// pinged reports whether a ping from the interface src to dst is answered,
+// which it is whenever dst would resolve as a neighbor of src.
+func (s *Server) pinged(src, dst string, ipv6 bool, now time.Time) bool {
+	port := s.ipPort(src, ipv6)
+	return port != "" && s.resolve(port, dst, ipv6, now) != ""
+}
+
+// ping answers the IPv4 and IPv6 ping requests of action in resp.
+func (s *Server) ping(action gosnappi.ActionProtocol, resp gosnappi.ActionResponseProtocol, now time.Time) error {
+	switch action.Choice() {
+	case gosnappi.ActionProtocolChoice.IPV4:
+		if action.Ipv4().Choice() != gosnappi.ActionProtocolIpv4Choice.PING {
+			return status.Errorf(codes.Unimplemented, "ipv4 %s actions are not simulated", action.Ipv4().Choice())
+		}
+		pings := resp.Ipv4().Ping()
+		for _, req := range action.Ipv4().Ping().Requests().Items() {
+			result := gosnappi.ActionResponseProtocolIpv4PingResponseResult.FAILED
+			if s.pinged(req.SrcName(), req.DstIp(), false, now) {
+				result = gosnappi.ActionResponseProtocolIpv4PingResponseResult.SUCCEEDED
+			}
+			pings.Responses().Add().SetSrcName(req.SrcName()).SetDstIp(req.DstIp()).SetResult(result)
+		}
+	case gosnappi.ActionProtocolChoice.IPV6:
+		if action.Ipv6().Choice() != gosnappi.ActionProtocolIpv6Choice.PING {
+			return status.Errorf(codes.Unimplemented, "ipv6 %s actions are not simulated", action.Ipv6().Choice())
+		}
+		pings := resp.Ipv6().Ping()
+		for _, req := range action.Ipv6().Ping().Requests().Items() {
+			result := gosnappi.ActionResponseProtocolIpv6PingResponseResult.FAILED
+			if s.pinged(req.SrcName(), req.DstIp(), true, now) {
+				result = gosnappi.ActionResponseProtocolIpv6PingResponseResult.SUCCEEDED
+			}
+			pings.Responses().Add().SetSrcName(req.SrcName()).SetDstIp(req.DstIp()).SetResult(result)
+		}
+	default:
+		return status.Errorf(codes.Unimplemented, "%s actions are not simulated", action.Choice())
+	}
+	return nil
+}
+
+func (s *Server) SetControlAction(ctx context.Context, req *otg.SetControlActionRequest) (*otg.SetControlActionResponse, error) {
+	ca, err := gosnappi.NewControlAction().Unmarshal().FromProto(req.ControlAction)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	resp := gosnappi.NewControlActionResponse()
+	if err := s.ping(ca.Protocol(), resp.Response().Protocol(), s.now()); err != nil {
+		return nil, err
+	}
+
+	msg, err := resp.Marshal().ToProto()
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return &otg.SetControlActionResponse{ControlActionResponse: msg}, nil
+}
@@ -0,0 +1,453 @@
+package otgsim
+
+import (
+	"encoding/binary"
+	"hash/fnv"
+	"math"
+	"net"
+	"time"
+
+	"github.com/open-traffic-generator/snappi/gosnappi"
+	"github.com/open-traffic-generator/snappi/gosnappi/otg"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// lacpNegotiation is how long after its link comes up an LACP member
+	// takes to synchronize with its partner.
+	lacpNegotiation = time.Second
+	// dutSystemId is the LACP system ID of the DUT, the partner of members
+	// without a back-to-back peer.
+	dutSystemId = "00:00:5e:00:53:00"
+)
+
+// lag is the simulated state of a LAG.
+type lag struct {
+	name     string
+	lacp     bool
+	systemId string
+	key      uint32
+	minLinks int
+	members  []*member
+}
+
+// member is a LAG member port.
+type member struct {
+	port    string
+	lag     *lag
+	portNum uint32
+	active  bool
+	short   bool
+	// interval between the LACPDUs the member sends.
+	interval time.Duration
+	// LACPDUs sent and received, advanced by updateLacp.
+	txPdus float64
+	rxPdus float64
+	last   time.Time
+	// up is the link state at the last update.
+	up bool
+}
+
+func newLag(cfg *otg.Lag, now time.Time) *lag {
+	l := &lag{name: cfg.GetName(), minLinks: int(cfg.GetMinLinks())}
+	if cfg.GetProtocol().GetChoice() == otg.LagProtocol_Choice_lacp {
+		l.lacp = true
+		l.systemId = cfg.GetProtocol().GetLacp().GetActorSystemId()
+		l.key = cfg.GetProtocol().GetLacp().GetActorKey()
+	}
+	for _, p := range cfg.GetPorts() {
+		lacp := p.GetLacp()
+		m := &member{
+			port:    p.GetPortName(),
+			lag:     l,
+			portNum: lacp.GetActorPortNumber(),
+			active:  lacp.GetActorActivity() != otg.LagPortLacp_ActorActivity_passive,
+			// A timeout of 0 is auto, which LACP starts as long.
+			short: lacp.GetLacpduTimeout() == 3,
+			last:  now,
+		}
+		m.interval = 30 * time.Second
+		if m.short {
+			m.interval = time.Second
+		}
+		if i := lacp.GetLacpduPeriodicTimeInterval(); i > 0 {
+			m.interval = time.Duration(i) * time.Second
+		}
+		l.members = append(l.members, m)
+	}
+	return l
+}
+
+// lacpState is the outcome of the LACP negotiation of a member.
+type lacpState struct {
+	partnerId   string
+	partnerKey  uint32
+	partnerPort uint32
+	// aggregatable is set if the member found an LACP partner it can
+	// aggregate with, sync once it has and is collecting and distributing.
+	aggregatable bool
+	sync         bool
+}
+
+// partner returns the LACP partner of m: the member on the other end of its
+// back-to-back link, or the DUT for ports cabled to it. ok is false if the
+// link is down or the peer port does not run LACP.
+func (s *Server) partner(m *member) (p *member, ok bool) {
+	if !s.linkUp(m.port) {
+		return nil, false
+	}
+	peer := s.peer(m.port)
+	if peer == "" {
+		// The DUT runs LACP, actively, on every port.
+		return &member{lag: &lag{lacp: true, systemId: dutSystemId, key: m.lag.key}, portNum: m.portNum, active: true, interval: m.interval}, true
+	}
+	pm, found := s.memberOf[peer]
+	if !found || !pm.lag.lacp {
+		return nil, false
+	}
+	return pm, true
+}
+
+// negotiate returns the LACP state of every member of l by port. A member
+// synchronizes lacpNegotiation after its link came up, if it or its partner
+// is active. The LAG aggregates only members whose partner has the system ID
+// and key of the partner of the first member that synchronized.
+func (s *Server) negotiate(l *lag, now time.Time) map[string]lacpState {
+	states := map[string]lacpState{}
+	var selected *lacpState
+	for _, m := range l.members {
+		st := lacpState{}
+		if pm, ok := s.partner(m); ok {
+			st.partnerId, st.partnerKey, st.partnerPort = pm.lag.systemId, pm.lag.key, pm.portNum
+			st.aggregatable = m.active || pm.active
+		}
+		if st.aggregatable && now.Sub(s.ports[m.port].since) >= lacpNegotiation {
+			switch {
+			case selected == nil:
+				st.sync = true
+				selected = &st
+			case st.partnerId == selected.partnerId && st.partnerKey == selected.partnerKey:
+				st.sync = true
+			default:
+				st.aggregatable = false
+			}
+		}
+		states[m.port] = st
+	}
+	return states
+}
+
+// memberUp reports whether the named port forwards traffic: a LAG member
+// that is distributing, or any other port whose link is up.
+func (s *Server) memberUp(name string, now time.Time) bool {
+	m, ok := s.memberOf[name]
+	if !ok || !m.lag.lacp {
+		return s.linkUp(name)
+	}
+	return s.negotiate(m.lag, now)[name].sync
+}
+
+// upMembers returns the members of l that forward traffic, in config order.
+func (s *Server) upMembers(l *lag, now time.Time) []string {
+	up := []string{}
+	for _, m := range l.members {
+		if s.memberUp(m.port, now) {
+			up = append(up, m.port)
+		}
+	}
+	return up
+}
+
+// lagUp reports whether at least the minimum number of members of l forward
+// traffic.
+func (s *Server) lagUp(l *lag, now time.Time) bool {
+	return len(s.upMembers(l, now)) >= max(l.minLinks, 1)
+}
+
+// endpointUp reports whether the named port or LAG forwards traffic.
+func (s *Server) endpointUp(name string, now time.Time) bool {
+	if l, ok := s.lags[name]; ok {
+		return s.lagUp(l, now)
+	}
+	return s.linkUp(name)
+}
+
+// speed returns the line rate of the named port, or the sum of the line rates
+// of the members of the named LAG, in bits per second.
+func (s *Server) speed(name string) float64 {
+	if p, ok := s.ports[name]; ok {
+		return p.speed
+	}
+	if l, ok := s.lags[name]; ok {
+		total := 0.0
+		for _, m := range l.members {
+			if p, ok := s.ports[m.port]; ok {
+				total += p.speed
+			}
+		}
+		if total > 0 {
+			return total
+		}
+	}
+	return defaultSpeed
+}
+
+// updateLacp advances the LACPDU counters of all members to now. Members
+// send an LACPDU when their link comes up and then one every interval, and
+// receive those of their LACP partner.
+func (s *Server) updateLacp(now time.Time) {
+	for _, l := range s.lags {
+		if !l.lacp {
+			continue
+		}
+		for _, m := range l.members {
+			elapsed := now.Sub(m.last).Seconds()
+			m.last = now
+			wasUp := m.up
+			m.up = s.linkUp(m.port)
+			if !m.up {
+				continue
+			}
+			first := 0.0
+			if !wasUp {
+				first = 1
+			}
+			m.txPdus += first + elapsed/m.interval.Seconds()
+			if pm, ok := s.partner(m); ok {
+				m.rxPdus += first + elapsed/pm.interval.Seconds()
+			}
+		}
+	}
+}
+
+// lagMetrics adds the metrics of the named LAGs, or of all LAGs, to resp.
+func (s *Server) lagMetrics(names []string, resp gosnappi.MetricsResponse, now time.Time) error {
+	if len(names) == 0 {
+		for _, l := range s.config.Lags().Items() {
+			names = append(names, l.Name())
+		}
+	}
+	for _, name := range names {
+		l, ok := s.lags[name]
+		if !ok {
+			return status.Errorf(codes.InvalidArgument, "lag %s is not configured", name)
+		}
+		var c counters
+		for _, m := range l.members {
+			c.add(s.portCounters(m.port))
+		}
+		oper := gosnappi.LagMetricOperStatus.DOWN
+		if s.lagUp(l, now) {
+			oper = gosnappi.LagMetricOperStatus.UP
+		}
+		resp.LagMetrics().Add().
+			SetName(l.name).
+			SetOperStatus(oper).
+			SetMemberPortsUp(uint32(len(s.upMembers(l, now)))).
+			SetFramesTx(uint64(math.Round(c.txFrames))).
+			SetFramesRx(uint64(math.Round(c.rxFrames))).
+			SetBytesTx(uint64(math.Round(c.txBytes))).
+			SetBytesRx(uint64(math.Round(c.rxBytes)))
+	}
+	return nil
+}
+
+// lacpMetrics adds the metrics of the members of the named LACP LAGs, or of
+// all of them, to resp, limited to the named member ports if any.
+func (s *Server) lacpMetrics(lagNames, memberNames []string, resp gosnappi.MetricsResponse, now time.Time) error {
+	if len(lagNames) == 0 {
+		for _, l := range s.config.Lags().Items() {
+			lagNames = append(lagNames, l.Name())
+		}
+	}
+	wanted := map[string]bool{}
+	for _, name := range memberNames {
+		wanted[name] = true
+	}
+	for _, name := range lagNames {
+		l, ok := s.lags[name]
+		if !ok {
+			return status.Errorf(codes.InvalidArgument, "lag %s is not configured", name)
+		}
+		if !l.lacp {
+			continue
+		}
+		states := s.negotiate(l, now)
+		for _, m := range l.members {
+			if len(wanted) > 0 && !wanted[m.port] {
+				continue
+			}
+			st := states[m.port]
+			activity := gosnappi.LacpMetricActivity.PASSIVE
+			if m.active {
+				activity = gosnappi.LacpMetricActivity.ACTIVE
+			}
+			timeout := gosnappi.LacpMetricTimeout.LONG
+			if m.short {
+				timeout = gosnappi.LacpMetricTimeout.SHORT
+			}
+			syncState := gosnappi.LacpMetricSynchronization.OUT_SYNC
+			if st.sync {
+				syncState = gosnappi.LacpMetricSynchronization.IN_SYNC
+			}
+			resp.LacpMetrics().Add().
+				SetLagName(l.name).
+				SetLagMemberPortName(m.port).
+				SetLacpPacketsTx(uint64(m.txPdus)).
+				SetLacpPacketsRx(uint64(m.rxPdus)).
+				SetLacpRxErrors(0).
+				SetActivity(activity).
+				SetTimeout(timeout).
+				SetSynchronization(syncState).
+				SetAggregatable(st.aggregatable).
+				SetCollecting(st.sync).
+				SetDistributing(st.sync).
+				SetSystemId(l.systemId).
+				SetOperKey(l.key).
+				SetPartnerId(st.partnerId).
+				SetPartnerKey(st.partnerKey).
+				SetPortNum(m.portNum).
+				SetPartnerPortNum(st.partnerPort)
+		}
+	}
+	return nil
+}
+
+// shares returns the fractions of the frames of f sent and received on each
+// port at now. On a LAG, frames go to the up member picked by the flow hash,
+// or spread over all up members if the flow varies the hashed fields. Frames
+// sent on a port with a back-to-back peer among the rx ports are received
+// there; otherwise they cross the DUT, which hashes them onto an rx LAG the
+// same way and splits them evenly over plain rx ports. Rx route ranges the tx
+// device has not learned, from learned, receive nothing.
+func (s *Server) shares(f *flow, learned map[string]bool, now time.Time) (tx, rx map[string]float64) {
+	tx, rx = map[string]float64{}, map[string]float64{}
+	if l, ok := s.lags[f.txPort]; ok {
+		s.distribute(f, s.upMembers(l, now), 1, tx)
+	} else if f.txPort != "" {
+		tx[f.txPort] = 1
+	}
+
+	candidates := []string{}
+	isRx := map[string]bool{}
+	hashed := false
+	for i, name := range f.rxPorts {
+		if i < len(f.rxRoutes) && f.rxRoutes[i] != "" && !learned[f.rxRoutes[i]] {
+			continue
+		}
+		if l, ok := s.lags[name]; ok {
+			hashed = true
+			for _, m := range s.upMembers(l, now) {
+				candidates = append(candidates, m)
+				isRx[m] = true
+			}
+		} else {
+			candidates = append(candidates, name)
+			isRx[name] = true
+		}
+	}
+	for name, share := range tx {
+		switch peer := s.peer(name); {
+		case isRx[peer]:
+			rx[peer] += share
+		case hashed:
+			s.distribute(f, candidates, share, rx)
+		default:
+			for _, c := range candidates {
+				rx[c] += share / float64(len(candidates))
+			}
+		}
+	}
+	return tx, rx
+}
+
+// distribute adds share to the member of ports picked by the flow hash, or
+// spreads it over all of them.
+func (s *Server) distribute(f *flow, ports []string, share float64, to map[string]float64) {
+	if len(ports) == 0 {
+		return
+	}
+	if f.spread {
+		for _, p := range ports {
+			to[p] += share / float64(len(ports))
+		}
+		return
+	}
+	to[ports[f.hash%uint32(len(ports))]] += share
+}
+
+// flowHash returns the LAG hash of the frames of a flow and whether they vary
+// in the hashed fields. Like the layer2+3 policy of Linux bonding, it XORs the
+// source and destination addresses of the first IP header, or of the
+// ethernet header of frames without one, and folds the result.
+func flowHash(cfg *otg.Flow) (hash uint32, spread bool) {
+	var macs, ips [][]byte
+	for _, h := range cfg.GetPacket() {
+		switch h.GetChoice() {
+		case otg.FlowHeader_Choice_ethernet:
+			if macs == nil {
+				src, dst := h.GetEthernet().GetSrc(), h.GetEthernet().GetDst()
+				macs = [][]byte{parseMAC(src.GetValue()), parseMAC(dst.GetValue())}
+				spread = spread || varies(len(src.GetValues()), src.GetIncrement().GetCount(), src.GetDecrement().GetCount())
+			}
+		case otg.FlowHeader_Choice_ipv4:
+			if ips == nil {
+				src, dst := h.GetIpv4().GetSrc(), h.GetIpv4().GetDst()
+				ips = [][]byte{net.ParseIP(src.GetValue()).To4(), net.ParseIP(dst.GetValue()).To4()}
+				spread = spread ||
+					varies(len(src.GetValues()), src.GetIncrement().GetCount(), src.GetDecrement().GetCount()) ||
+					varies(len(dst.GetValues()), dst.GetIncrement().GetCount(), dst.GetDecrement().GetCount())
+			}
+		case otg.FlowHeader_Choice_ipv6:
+			if ips == nil {
+				src, dst := h.GetIpv6().GetSrc(), h.GetIpv6().GetDst()
+				ips = [][]byte{net.ParseIP(src.GetValue()).To16(), net.ParseIP(dst.GetValue()).To16()}
+				spread = spread ||
+					varies(len(src.GetValues()), src.GetIncrement().GetCount(), src.GetDecrement().GetCount()) ||
+					varies(len(dst.GetValues()), dst.GetIncrement().GetCount(), dst.GetDecrement().GetCount())
+			}
+		}
+	}
+
+	fields := ips
+	if fields == nil {
+		fields = macs
+	}
+	if fields == nil {
+		h := fnv.New32a()
+		h.Write([]byte(cfg.GetName()))
+		return h.Sum32(), false
+	}
+	for _, b := range fields {
+		// XOR the address in 32-bit words, MACs in their last four bytes.
+		for i := len(b); i >= 4; i -= 4 {
+			hash ^= binary.BigEndian.Uint32(b[i-4 : i])
+		}
+	}
+	hash ^= hash >> 16
+	hash ^= hash >> 8
+	return hash, spread
+}
+
+// varies reports whether a pattern with the given number of values or counter
+// count takes more than one value.
+func varies(values int, counts ...uint32) bool {
+	if values > 1 {
+		return true
+	}
+	for _, c := range counts {
+		if c > 1 {
+			return true
+		}
+	}
+	return false
+}
+
+func parseMAC(s string) []byte {
+	mac, err := net.ParseMAC(s)
+	if err != nil {
+		return nil
+	}
+	return mac
+}
@@ -0,0 +1,258 @@
+package otgsim
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/open-traffic-generator/snappi/gosnappi"
+)
+
+// testbed cables port1-4 to port5-8, like b2b_1ate_4links.testbed.
+const testbed = `
+ates {
+  id: "ate"
+  ports {
+    id: "port1"
+  }
+}
+
+links {
+  a: "ate:port1"
+  b: "ate:port5"
+}
+links {
+  a: "ate:port2"
+  b: "ate:port6"
+}
+links {
+  a: "ate:port3"
+  b: "ate:port7"
+}
+links {
+  a: "ate:port4"
+  b: "ate:port8"
+}
+`
+
+func TestReadTestbedLinks(t *testing.T) {
+	links, err := ReadTestbedLinks(strings.NewReader(testbed))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(links) != 4 || links[0] != (Link{A: "port1", B: "port5"}) {
+		t.Errorf("ReadTestbedLinks() got %v, want port1-4 linked to port5-8", links)
+	}
+	if _, err := ReadTestbedLinks(strings.NewReader("links {\n  a: \"ate:port1\"\n}\n")); err == nil {
+		t.Error("ReadTestbedLinks() of a link without a b end got no error")
+	}
+}
+
+// lagConfig returns two LAGs, "rx" on port1-4 and "tx" on port5-8, and a flow
+// from each of four devices on tx to the device on rx, like the aggregate
+// tests.
+func lagConfig(lacp bool) gosnappi.Config {
+	config := gosnappi.NewConfig()
+	for i, name := range []string{"rx", "tx"} {
+		lag := config.Lags().Add().SetName(name)
+		if lacp {
+			lag.Protocol().Lacp().SetActorKey(1).SetActorSystemId(fmt.Sprintf("02:00:00:00:00:%02d", i+1))
+		} else {
+			lag.Protocol().Static().SetLagId(uint32(i + 1))
+		}
+		for j := 1; j <= 4; j++ {
+			port := config.Ports().Add().SetName(fmt.Sprintf("port%d", i*4+j))
+			lp := lag.Ports().Add().SetPortName(port.Name())
+			lp.Ethernet().SetName(fmt.Sprintf("%s.member%d", name, j)).SetMac(fmt.Sprintf("02:00:00:00:%02d:%02d", i+1, j))
+			if lacp {
+				lp.Lacp().SetActorActivity("active").SetActorPortNumber(uint32(j))
+			}
+		}
+	}
+
+	dst := config.Devices().Add().SetName("dst").Ethernets().Add().SetName("dst.eth").SetMac("02:00:00:00:01:00")
+	dst.Connection().SetLagName("rx")
+	dst.Ipv4Addresses().Add().SetName("dst.ipv4").SetAddress("192.0.2.6").SetGateway("192.0.2.2").SetPrefix(24)
+	for i := 0; i < 4; i++ {
+		src := config.Devices().Add().SetName(fmt.Sprintf("src%d", i)).Ethernets().Add().
+			SetName(fmt.Sprintf("src%d.eth", i)).SetMac(fmt.Sprintf("02:00:00:00:02:%02d", i))
+		src.Connection().SetLagName("tx")
+		ip := fmt.Sprintf("192.0.2.%d", i+2)
+		src.Ipv4Addresses().Add().SetName(fmt.Sprintf("src%d.ipv4", i)).SetAddress(ip).SetGateway("192.0.2.6").SetPrefix(24)
+
+		flow := config.Flows().Add().SetName(fmt.Sprintf("flow%d", i))
+		flow.TxRx().Device().SetTxNames([]string{fmt.Sprintf("src%d.ipv4", i)}).SetRxNames([]string{"dst.ipv4"})
+		flow.Metrics().SetEnable(true)
+		flow.Rate().SetPps(1000)
+		flow.Duration().FixedPackets().SetPackets(1000)
+		flow.Packet().Add().Ethernet()
+		v4 := flow.Packet().Add().Ipv4()
+		v4.Src().SetValue(ip)
+		v4.Dst().SetValue("192.0.2.6")
+	}
+	return config
+}
+
+func lagMetrics(t *testing.T, api gosnappi.Api) map[string]gosnappi.LagMetric {
+	t.Helper()
+	req := gosnappi.NewMetricsRequest()
+	req.Lag()
+	resp, err := api.GetMetrics(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	metrics := map[string]gosnappi.LagMetric{}
+	for _, m := range resp.LagMetrics().Items() {
+		metrics[m.Name()] = m
+	}
+	return metrics
+}
+
+func portMetrics(t *testing.T, api gosnappi.Api) map[string]gosnappi.PortMetric {
+	t.Helper()
+	req := gosnappi.NewMetricsRequest()
+	req.Port()
+	resp, err := api.GetMetrics(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	metrics := map[string]gosnappi.PortMetric{}
+	for _, m := range resp.PortMetrics().Items() {
+		metrics[m.Name()] = m
+	}
+	return metrics
+}
+
+func setLinks(t *testing.T, api gosnappi.Api, state gosnappi.StatePortLinkStateEnum, names ...string) {
+	t.Helper()
+	cs := gosnappi.NewControlState()
+	cs.Port().Link().SetPortNames(names).SetState(state)
+	if _, err := api.SetControlState(cs); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLagNegotiation(t *testing.T) {
+	for _, lacp := range []bool{false, true} {
+		t.Run(fmt.Sprintf("lacp=%v", lacp), func(t *testing.T) {
+			sim, api := startSim(t)
+			sim.Links, _ = ReadTestbedLinks(strings.NewReader(testbed))
+			if _, err := api.SetConfig(lagConfig(lacp)); err != nil {
+				t.Fatal(err)
+			}
+			// LACP members synchronize 1s of simulated time after link up.
+			wait := func() { time.Sleep(10 * time.Millisecond) }
+			check := func(desc string, wantUp uint32) {
+				t.Helper()
+				wantOper := gosnappi.LagMetricOperStatus.DOWN
+				if wantUp > 0 {
+					wantOper = gosnappi.LagMetricOperStatus.UP
+				}
+				for name, m := range lagMetrics(t, api) {
+					if m.OperStatus() != wantOper || m.MemberPortsUp() != wantUp {
+						t.Errorf("%s: lag %s got %s with %d members up, want %s with %d",
+							desc, name, m.OperStatus(), m.MemberPortsUp(), wantOper, wantUp)
+					}
+				}
+			}
+
+			wait()
+			check("all up", 4)
+
+			// Taking port1 down takes its link to port5 down on both ends.
+			setLinks(t, api, gosnappi.StatePortLinkState.DOWN, "port1")
+			ports := portMetrics(t, api)
+			for _, name := range []string{"port1", "port5"} {
+				if ports[name].Link() != gosnappi.PortMetricLink.DOWN {
+					t.Errorf("%s link got %s after port1 went down, want down", name, ports[name].Link())
+				}
+			}
+			if ports["port2"].Link() != gosnappi.PortMetricLink.UP {
+				t.Errorf("port2 link got %s, want up", ports["port2"].Link())
+			}
+			check("1 link broken", 3)
+
+			all := []string{"port1", "port2", "port3", "port4", "port5", "port6", "port7", "port8"}
+			setLinks(t, api, gosnappi.StatePortLinkState.DOWN, all...)
+			check("all links broken", 0)
+
+			setLinks(t, api, gosnappi.StatePortLinkState.UP, all...)
+			wait()
+			check("all links restored", 4)
+		})
+	}
+}
+
+func TestLacpPartner(t *testing.T) {
+	sim, api := startSim(t)
+	sim.Links, _ = ReadTestbedLinks(strings.NewReader(testbed))
+	config := lagConfig(true)
+	// port3 and port4 are passive, port8 on the other end of port4 too.
+	config.Lags().Items()[0].Ports().Items()[2].Lacp().SetActorActivity("passive")
+	config.Lags().Items()[0].Ports().Items()[3].Lacp().SetActorActivity("passive")
+	config.Lags().Items()[1].Ports().Items()[3].Lacp().SetActorActivity("passive")
+	if _, err := api.SetConfig(config); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(10 * time.Millisecond)
+
+	req := gosnappi.NewMetricsRequest()
+	req.Lacp().SetLagNames([]string{"rx"})
+	resp, err := api.GetMetrics(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	members := resp.LacpMetrics().Items()
+	if len(members) != 4 {
+		t.Fatalf("got %d LACP members of rx, want 4", len(members))
+	}
+	m := members[1]
+	if m.LagMemberPortName() != "port2" || m.Synchronization() != gosnappi.LacpMetricSynchronization.IN_SYNC ||
+		m.PartnerId() != "02:00:00:00:00:02" || m.PartnerPortNum() != 2 || !m.Distributing() {
+		t.Errorf("port2 got %s", m)
+	}
+	if m.LacpPacketsTx() == 0 || m.LacpPacketsRx() == 0 {
+		t.Errorf("port2 LACPDUs tx/rx got %d/%d, want some", m.LacpPacketsTx(), m.LacpPacketsRx())
+	}
+	// One active end is enough, two passive ends never talk.
+	if m := members[2]; m.Synchronization() != gosnappi.LacpMetricSynchronization.IN_SYNC {
+		t.Errorf("port3 with an active partner got %s, want in_sync", m.Synchronization())
+	}
+	if m := members[3]; m.Synchronization() != gosnappi.LacpMetricSynchronization.OUT_SYNC || m.Aggregatable() {
+		t.Errorf("port4 with a passive partner got %s, aggregatable %v, want out_sync", m.Synchronization(), m.Aggregatable())
+	}
+	if up := lagMetrics(t, api)["rx"].MemberPortsUp(); up != 3 {
+		t.Errorf("rx got %d members up, want 3", up)
+	}
+}
+
+func TestLagHashDistribution(t *testing.T) {
+	sim, api := startSim(t)
+	sim.Links, _ = ReadTestbedLinks(strings.NewReader(testbed))
+	if _, err := api.SetConfig(lagConfig(true)); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(10 * time.Millisecond)
+	cs := gosnappi.NewControlState()
+	cs.Traffic().FlowTransmit().SetState(gosnappi.StateTrafficFlowTransmitState.START)
+	if _, err := api.SetControlState(cs); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	// The XOR of source and destination address differs in its low two bits
+	// for the four flows, so each gets a member of its own, and is received
+	// on the peer of that member.
+	ports := portMetrics(t, api)
+	for i := 1; i <= 4; i++ {
+		tx, rx := ports[fmt.Sprintf("port%d", i+4)], ports[fmt.Sprintf("port%d", i)]
+		if tx.FramesTx() != 1000 || rx.FramesRx() != 1000 {
+			t.Errorf("port%d tx %d frames, port%d rx %d frames, want 1000 each", i+4, tx.FramesTx(), i, rx.FramesRx())
+		}
+	}
+	lags := lagMetrics(t, api)
+	if lags["tx"].FramesTx() != 4000 || lags["rx"].FramesRx() != 4000 {
+		t.Errorf("lag tx sent %d, lag rx received %d frames, want 4000", lags["tx"].FramesTx(), lags["rx"].FramesRx())
+	}
+}
@@ -0,0 +1,119 @@
+package otgsim
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Link is a back-to-back cable between two test ports, named by port name or
+// location. Taking either end down takes the link down on both.
+type Link struct {
+	A, B string
+}
+
+var (
+	linkEnd    = regexp.MustCompile(`^\s*(a|b)\s*:\s*"([^"]+)"`)
+	linksStart = regexp.MustCompile(`^\s*links\s*\{`)
+)
+
+// ReadTestbedLinks returns the links of an Ondatra testbed file, with the
+// device part of the ends ("ate:port1") removed, so they match OTG port names
+// that are the testbed port IDs, as in featureprofiles.
+func ReadTestbedLinks(rd io.Reader) ([]Link, error) {
+	links := []Link{}
+	var cur *Link
+	sc := bufio.NewScanner(rd)
+	for line := 1; sc.Scan(); line++ {
+		text := sc.Text()
+		switch {
+		case linksStart.MatchString(text):
+			cur = &Link{}
+		case cur != nil && strings.TrimSpace(text) == "}":
+			if cur.A == "" || cur.B == "" {
+				return nil, fmt.Errorf("line %d: link needs both an a and a b end", line)
+			}
+			links = append(links, *cur)
+			cur = nil
+		case cur != nil:
+			m := linkEnd.FindStringSubmatch(text)
+			if m == nil {
+				continue
+			}
+			end := m[2]
+			if i := strings.Index(end, ":"); i >= 0 {
+				end = end[i+1:]
+			}
+			if m[1] == "a" {
+				cur.A = end
+			} else {
+				cur.B = end
+			}
+		}
+	}
+	return links, sc.Err()
+}
+
+// portNamed returns the configured port with the given name or location.
+func (s *Server) portNamed(name string) *port {
+	if p, ok := s.ports[name]; ok {
+		return p
+	}
+	for _, p := range s.ports {
+		if p.location != "" && p.location == name {
+			return p
+		}
+	}
+	return nil
+}
+
+// peer returns the name of the port linked back-to-back with the named port,
+// empty if it has no configured peer and is cabled to the DUT.
+func (s *Server) peer(name string) string {
+	for _, l := range s.Links {
+		a, b := s.portNamed(l.A), s.portNamed(l.B)
+		if a == nil || b == nil {
+			continue
+		}
+		if a.name == name {
+			return b.name
+		}
+		if b.name == name {
+			return a.name
+		}
+	}
+	return ""
+}
+
+// linkUp reports whether the named port exists and its link is up: the port
+// is enabled and so is its back-to-back peer, if any.
+func (s *Server) linkUp(name string) bool {
+	p, ok := s.ports[name]
+	if !ok || !p.enabled {
+		return false
+	}
+	if peer := s.peer(name); peer != "" {
+		return s.ports[peer].enabled
+	}
+	return true
+}
+
+// setLinks enables or disables the named ports and records when the link of
+// every port that went up or down changed.
+func (s *Server) setLinks(names []string, up bool, now time.Time) {
+	before := map[string]bool{}
+	for name := range s.ports {
+		before[name] = s.linkUp(name)
+	}
+	for _, name := range names {
+		s.ports[name].enabled = up
+	}
+	for name, p := range s.ports {
+		if s.linkUp(name) != before[name] {
+			p.since = now
+		}
+	}
+}
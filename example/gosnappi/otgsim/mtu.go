@@ -0,0 +1,24 @@
+package otgsim
+
+import (
+	"github.com/SpirentOrion/stc-otg/example/gosnappi/linerate"
+	"github.com/open-traffic-generator/snappi/gosnappi"
+)
+
+// oversize reports whether the IP packets of the fixed-size frames of f,
+// with the given packet headers, exceed the MTU set on an ethernet of its tx
+// or rx ports. The DUT drops them rather than fragment them, like the DUT of
+// the large_ip_packet_transmission test. Frames without an IP packet are
+// never oversize.
+func (s *Server) oversize(f *flow, packet gosnappi.FlowFlowHeaderIter) bool {
+	l2, ok := linerate.L2Overhead(packet)
+	if !ok {
+		return false
+	}
+	for _, p := range append([]string{f.txPort}, f.rxPorts...) {
+		if mtu := linerate.Mtu(s.config, p); mtu > 0 && f.size-float64(l2) > float64(mtu) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,85 @@
+package otgsim
+
+import (
+	"math/big"
+
+	"github.com/open-traffic-generator/snappi/gosnappi/otg"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// quantum is the pause time unit of PFC and Ethernet PAUSE frames, in bit
+// times.
+const quantum = 512
+
+// pauseTimes are the pause times a flow of pause frames sends per priority,
+// in quanta, averaged over the frames. Ethernet PAUSE frames pause all
+// priorities.
+type pauseTimes [8]float64
+
+// newPause returns the pause times of a flow of PFC or Ethernet PAUSE frames,
+// nil for other flows.
+func newPause(cfg *otg.Flow) *pauseTimes {
+	for _, h := range cfg.GetPacket() {
+		switch h.GetChoice() {
+		case otg.FlowHeader_Choice_ethernetpause:
+			t := mean(h.GetEthernetpause().GetTime().ProtoReflect())
+			return &pauseTimes{t, t, t, t, t, t, t, t}
+		case otg.FlowHeader_Choice_pfcpause:
+			pfc := h.GetPfcpause()
+			cev := patternValues(pfc.GetClassEnableVector().ProtoReflect())[0].Uint64()
+			classes := []protoreflect.ProtoMessage{
+				pfc.GetPauseClass_0(), pfc.GetPauseClass_1(), pfc.GetPauseClass_2(), pfc.GetPauseClass_3(),
+				pfc.GetPauseClass_4(), pfc.GetPauseClass_5(), pfc.GetPauseClass_6(), pfc.GetPauseClass_7(),
+			}
+			t := &pauseTimes{}
+			for i, c := range classes {
+				if cev&(1<<i) != 0 {
+					t[i] = mean(c.ProtoReflect())
+				}
+			}
+			return t
+		}
+	}
+	return nil
+}
+
+// mean returns the average of the values a header field pattern takes.
+func mean(p protoreflect.Message) float64 {
+	values := patternValues(p)
+	sum := new(big.Int)
+	for _, v := range values {
+		sum.Add(sum, v)
+	}
+	f, _ := new(big.Float).Quo(new(big.Float).SetInt(sum), big.NewFloat(float64(len(values)))).Float64()
+	return f
+}
+
+// flowPriority returns the priority of the outer VLAN tag of the frames of a
+// flow, 0 for untagged ones.
+func flowPriority(cfg *otg.Flow) int {
+	for _, h := range cfg.GetPacket() {
+		if h.GetChoice() == otg.FlowHeader_Choice_vlan {
+			return int(patternValues(h.GetVlan().GetPriority().ProtoReflect())[0].Int64())
+		}
+	}
+	return 0
+}
+
+// paused returns the share of the time the DUT holds back the frames of f
+// because the port they leave it on sent pause frames for their priority:
+// every frame pauses the priority for its pause time, so the share is the
+// pause frame rate times the pause time, up to all of it.
+func (s *Server) paused(f *flow) float64 {
+	share := 0.0
+	for _, p := range s.flows {
+		if p.pause == nil || !p.running || p.pause[f.priority] == 0 {
+			continue
+		}
+		for _, rx := range f.rxPorts {
+			if rx == p.txPort && s.linkUp(rx) {
+				share = max(share, p.pps*p.pause[f.priority]*quantum/s.speed(rx))
+			}
+		}
+	}
+	return min(share, 1)
+}
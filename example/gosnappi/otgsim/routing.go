@@ -0,0 +1,959 @@
+package otgsim
+
+import (
+	"math/big"
+	"net"
+	"time"
+
+	"github.com/open-traffic-generator/snappi/gosnappi"
+	"github.com/open-traffic-generator/snappi/gosnappi/otg"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// adjacencySetup is how long an ISIS adjacency or a BGP session takes to
+	// come up once both ends run and can reach each other.
+	adjacencySetup = time.Second
+
+	defaultIsisHello = 10
+	defaultIsisDead  = 30
+	defaultIsisArea  = "490001"
+	defaultHoldTime  = 90
+	defaultKeepalive = 30
+)
+
+// routeBlock is one address of a route range: count prefixes of the given
+// length, starting at address, step prefixes apart.
+type routeBlock struct {
+	address string
+	prefix  uint32
+	count   uint32
+	step    uint32
+}
+
+// routeRange is a named range of routes a device advertises.
+type routeRange struct {
+	name   string
+	device string
+	blocks []routeBlock
+}
+
+func v4Blocks(addrs []*otg.V4RouteAddress) []routeBlock {
+	blocks := []routeBlock{}
+	for _, a := range addrs {
+		blocks = append(blocks, routeBlock{a.GetAddress(), a.GetPrefix(), max(a.GetCount(), 1), max(a.GetStep(), 1)})
+	}
+	return blocks
+}
+
+func v6Blocks(addrs []*otg.V6RouteAddress) []routeBlock {
+	blocks := []routeBlock{}
+	for _, a := range addrs {
+		blocks = append(blocks, routeBlock{a.GetAddress(), a.GetPrefix(), max(a.GetCount(), 1), max(a.GetStep(), 1)})
+	}
+	return blocks
+}
+
+// size returns the number of prefixes in r.
+func (r *routeRange) size() uint64 {
+	n := uint64(0)
+	for _, b := range r.blocks {
+		n += uint64(b.count)
+	}
+	return n
+}
+
+// prefixes returns the prefixes of r, IPv4 and IPv6 ones apart.
+func (r *routeRange) prefixes() (v4, v6 []*net.IPNet) {
+	for _, b := range r.blocks {
+		ip := net.ParseIP(b.address)
+		if ip == nil {
+			continue
+		}
+		bits := 128
+		if ip.To4() != nil {
+			ip, bits = ip.To4(), 32
+		}
+		mask := net.CIDRMask(int(b.prefix), bits)
+		if mask == nil {
+			continue
+		}
+		base := new(big.Int).SetBytes(ip.Mask(mask))
+		step := new(big.Int).Lsh(big.NewInt(int64(b.step)), uint(bits)-uint(b.prefix))
+		for i := uint32(0); i < b.count; i++ {
+			addr := make(net.IP, bits/8)
+			base.FillBytes(addr)
+			if bits == 32 {
+				v4 = append(v4, &net.IPNet{IP: addr, Mask: mask})
+			} else {
+				v6 = append(v6, &net.IPNet{IP: addr, Mask: mask})
+			}
+			base.Add(base, step)
+		}
+	}
+	return v4, v6
+}
+
+// isisIface is an ISIS interface of a router.
+type isisIface struct {
+	name     string
+	endpoint string
+	p2p      bool
+	// levels, hello and dead are indexed by level, 0 for L1 and 1 for L2.
+	levels [2]bool
+	hello  [2]float64
+	dead   [2]float64
+	auth   string
+	// hellos sent and received, advanced by updateRouting.
+	hellosTx [2]float64
+	hellosRx [2]float64
+}
+
+// isisRouter is the simulated state of the ISIS router of a device.
+type isisRouter struct {
+	name     string
+	device   string
+	systemId string
+	areas    []string
+	// lspAuth is the area (L1) and domain (L2) authentication of LSPs.
+	lspAuth [2]string
+	ifaces  []*isisIface
+	routes  []*routeRange
+	stopped bool
+	// since is when the router was last started through the control state.
+	since time.Time
+	// sessions up at the last update, and how often one went down.
+	sessions [2]int
+	flaps    [2]float64
+}
+
+// bgpPeer is the simulated state of a BGP peer.
+type bgpPeer struct {
+	name     string
+	device   string
+	v6       bool
+	local    string
+	remote   string
+	endpoint string
+	ibgp     bool
+	as       uint32
+	// hold and keepalive are in seconds; a hold time of 0 disables both.
+	hold      float64
+	keepalive float64
+	md5       string
+	passive   bool
+	routes    []*routeRange
+	stopped   bool
+	since     time.Time
+
+	// up is the session state at the last update. The counters are
+	// advanced by updateRouting.
+	up           bool
+	flaps        float64
+	opens        float64
+	updatesTx    float64
+	updatesRx    float64
+	keepalivesTx float64
+	keepalivesRx float64
+	withdrawsTx  float64
+	withdrawsRx  float64
+}
+
+// buildRouting derives the ISIS routers, BGP peers and route ranges of the
+// devices in config.
+func (s *Server) buildRouting(config gosnappi.Config) {
+	s.isis = map[string]*isisRouter{}
+	s.bgp = map[string]*bgpPeer{}
+	s.routes = map[string]*routeRange{}
+	s.withdrawn = map[string]bool{}
+	for _, d := range config.Devices().Items() {
+		msg, err := d.Marshal().ToProto()
+		if err != nil {
+			continue
+		}
+		endpoints, addrs := map[string]string{}, map[string]string{}
+		for _, eth := range msg.GetEthernets() {
+			conn := eth.GetConnection()
+			endpoint := conn.GetPortName()
+			if conn.GetChoice() == otg.EthernetConnection_Choice_lag_name {
+				endpoint = conn.GetLagName()
+			}
+			endpoints[eth.GetName()] = endpoint
+			for _, ip := range eth.GetIpv4Addresses() {
+				endpoints[ip.GetName()], addrs[ip.GetName()] = endpoint, ip.GetAddress()
+			}
+			for _, ip := range eth.GetIpv6Addresses() {
+				endpoints[ip.GetName()], addrs[ip.GetName()] = endpoint, ip.GetAddress()
+			}
+		}
+		// Loopbacks are taken to be reachable over their ethernet.
+		for _, lo := range msg.GetIpv4Loopbacks() {
+			endpoints[lo.GetName()], addrs[lo.GetName()] = endpoints[lo.GetEthName()], lo.GetAddress()
+		}
+		for _, lo := range msg.GetIpv6Loopbacks() {
+			endpoints[lo.GetName()], addrs[lo.GetName()] = endpoints[lo.GetEthName()], lo.GetAddress()
+		}
+
+		if r := msg.GetIsis(); r != nil {
+			s.isis[r.GetName()] = s.newIsisRouter(msg.GetName(), r, endpoints)
+		}
+		for _, intf := range msg.GetBgp().GetIpv4Interfaces() {
+			for _, p := range intf.GetPeers() {
+				peer := &bgpPeer{
+					name:     p.GetName(),
+					remote:   p.GetPeerAddress(),
+					ibgp:     p.GetAsType() == otg.BgpV4Peer_AsType_ibgp,
+					as:       p.GetAsNumber(),
+					routes:   s.bgpRoutes(msg.GetName(), p.GetV4Routes(), p.GetV6Routes()),
+					local:    addrs[intf.GetIpv4Name()],
+					endpoint: endpoints[intf.GetIpv4Name()],
+				}
+				s.bgp[peer.name] = newBgpPeer(peer, msg.GetName(), false, p.GetAdvanced())
+			}
+		}
+		for _, intf := range msg.GetBgp().GetIpv6Interfaces() {
+			for _, p := range intf.GetPeers() {
+				peer := &bgpPeer{
+					name:     p.GetName(),
+					remote:   p.GetPeerAddress(),
+					ibgp:     p.GetAsType() == otg.BgpV6Peer_AsType_ibgp,
+					as:       p.GetAsNumber(),
+					routes:   s.bgpRoutes(msg.GetName(), p.GetV4Routes(), p.GetV6Routes()),
+					local:    addrs[intf.GetIpv6Name()],
+					endpoint: endpoints[intf.GetIpv6Name()],
+				}
+				s.bgp[peer.name] = newBgpPeer(peer, msg.GetName(), true, p.GetAdvanced())
+			}
+		}
+	}
+}
+
+func (s *Server) newIsisRouter(device string, cfg *otg.DeviceIsisRouter, endpoints map[string]string) *isisRouter {
+	r := &isisRouter{
+		name:     cfg.GetName(),
+		device:   device,
+		systemId: cfg.GetSystemId(),
+		areas:    cfg.GetAdvanced().GetAreaAddresses(),
+		lspAuth:  [2]string{lspAuth(cfg.GetRouterAuth().GetAreaAuth()), lspAuth(cfg.GetRouterAuth().GetDomainAuth())},
+	}
+	if len(r.areas) == 0 {
+		r.areas = []string{defaultIsisArea}
+	}
+	for _, i := range cfg.GetInterfaces() {
+		intf := &isisIface{
+			name:     i.GetName(),
+			endpoint: endpoints[i.GetEthName()],
+			p2p:      i.GetNetworkType() == otg.IsisInterface_NetworkType_point_to_point,
+		}
+		switch i.GetLevelType() {
+		case otg.IsisInterface_LevelType_level_1:
+			intf.levels = [2]bool{true, false}
+		case otg.IsisInterface_LevelType_level_1_2:
+			intf.levels = [2]bool{true, true}
+		default:
+			intf.levels = [2]bool{false, true}
+		}
+		for level, settings := range []*otg.IsisInterfaceLevel{i.GetL1Settings(), i.GetL2Settings()} {
+			intf.hello[level], intf.dead[level] = defaultIsisHello, defaultIsisDead
+			if settings != nil && settings.HelloInterval != nil {
+				intf.hello[level] = float64(settings.GetHelloInterval())
+			}
+			if settings != nil && settings.DeadInterval != nil {
+				intf.dead[level] = float64(settings.GetDeadInterval())
+			}
+		}
+		switch a := i.GetAuthentication(); a.GetAuthType() {
+		case otg.IsisInterfaceAuthentication_AuthType_md5:
+			intf.auth = "md5:" + a.GetMd5()
+		case otg.IsisInterfaceAuthentication_AuthType_password:
+			intf.auth = "password:" + a.GetPassword()
+		}
+		r.ifaces = append(r.ifaces, intf)
+	}
+	for _, rr := range cfg.GetV4Routes() {
+		r.routes = append(r.routes, s.addRoute(rr.GetName(), device, v4Blocks(rr.GetAddresses())))
+	}
+	for _, rr := range cfg.GetV6Routes() {
+		r.routes = append(r.routes, s.addRoute(rr.GetName(), device, v6Blocks(rr.GetAddresses())))
+	}
+	return r
+}
+
+// lspAuth returns the key of an ISIS area or domain authentication, empty
+// without one.
+func lspAuth(a *otg.IsisAuthenticationBase) string {
+	switch a.GetAuthType() {
+	case otg.IsisAuthenticationBase_AuthType_md5:
+		return "md5:" + a.GetMd5()
+	case otg.IsisAuthenticationBase_AuthType_password:
+		return "password:" + a.GetPassword()
+	}
+	return ""
+}
+
+func newBgpPeer(p *bgpPeer, device string, v6 bool, adv *otg.BgpAdvanced) *bgpPeer {
+	p.device, p.v6 = device, v6
+	p.hold, p.keepalive = defaultHoldTime, defaultKeepalive
+	if adv != nil && adv.HoldTimeInterval != nil {
+		p.hold = float64(adv.GetHoldTimeInterval())
+	}
+	if adv != nil && adv.KeepAliveInterval != nil {
+		p.keepalive = float64(adv.GetKeepAliveInterval())
+	}
+	p.md5 = adv.GetMd5Key()
+	p.passive = adv.GetPassiveMode()
+	return p
+}
+
+func (s *Server) bgpRoutes(device string, v4 []*otg.BgpV4RouteRange, v6 []*otg.BgpV6RouteRange) []*routeRange {
+	routes := []*routeRange{}
+	for _, rr := range v4 {
+		routes = append(routes, s.addRoute(rr.GetName(), device, v4Blocks(rr.GetAddresses())))
+	}
+	for _, rr := range v6 {
+		routes = append(routes, s.addRoute(rr.GetName(), device, v6Blocks(rr.GetAddresses())))
+	}
+	return routes
+}
+
+func (s *Server) addRoute(name, device string, blocks []routeBlock) *routeRange {
+	r := &routeRange{name: name, device: device, blocks: blocks}
+	s.routes[name] = r
+	return r
+}
+
+// advertised returns the routes of routes that are not withdrawn.
+func (s *Server) advertised(routes []*routeRange) []*routeRange {
+	out := []*routeRange{}
+	for _, r := range routes {
+		if !s.withdrawn[r.name] {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+// latest returns the latest of times.
+func latest(times ...time.Time) time.Time {
+	var t time.Time
+	for _, u := range times {
+		if u.After(t) {
+			t = u
+		}
+	}
+	return t
+}
+
+// endpointPorts returns the ports of the named port or LAG.
+func (s *Server) endpointPorts(name string) []string {
+	if l, ok := s.lags[name]; ok {
+		ports := []string{}
+		for _, m := range l.members {
+			ports = append(ports, m.port)
+		}
+		return ports
+	}
+	return []string{name}
+}
+
+// endpointSince returns when the link of the named port, or of the last
+// member of the named LAG, last went up or down.
+func (s *Server) endpointSince(name string) time.Time {
+	var since time.Time
+	for _, name := range s.endpointPorts(name) {
+		if p, ok := s.ports[name]; ok {
+			since = latest(since, p.since)
+		}
+	}
+	return since
+}
+
+// connected reports whether protocol packets pass between interfaces on the
+// ports or LAGs a and b: both are up and either linked back-to-back or both
+// cabled to the DUT, which bridges them, as it does for ARP.
+func (s *Server) connected(a, b string, now time.Time) bool {
+	if a == "" || b == "" || !s.endpointUp(a, now) || !s.endpointUp(b, now) {
+		return false
+	}
+	for _, x := range s.endpointPorts(a) {
+		for _, y := range s.endpointPorts(b) {
+			peer := s.peer(x)
+			if peer == y || (peer == "" && s.peer(y) == "") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isisRunning returns whether r runs and since when.
+func (s *Server) isisRunning(r *isisRouter) (bool, time.Time) {
+	return s.protocolsUp && !r.stopped, latest(s.protocolsSince, r.since)
+}
+
+// isisAdj is a pair of ISIS interfaces of different routers at one level.
+type isisAdj struct {
+	a, b   *isisRouter
+	ia, ib *isisIface
+	level  int
+	// heard is set if the interfaces receive each other's hellos, up if
+	// they formed an adjacency.
+	heard bool
+	up    bool
+}
+
+// isisAdjacencies returns the pairs of ISIS interfaces at now that hear each
+// other. They form an adjacency adjacencySetup after both came up if their
+// network types, levels and hello authentication match, they share an area
+// for L1, and each sends hellos more often than its dead interval, which it
+// advertises as the holding time the other end waits for them.
+func (s *Server) isisAdjacencies(now time.Time) []isisAdj {
+	routers := []*isisRouter{}
+	for _, r := range s.isis {
+		if ok, _ := s.isisRunning(r); ok {
+			routers = append(routers, r)
+		}
+	}
+	adjs := []isisAdj{}
+	for i, a := range routers {
+		for _, b := range routers[i+1:] {
+			for _, ia := range a.ifaces {
+				for _, ib := range b.ifaces {
+					if !s.connected(ia.endpoint, ib.endpoint, now) {
+						continue
+					}
+					for level := 0; level < 2; level++ {
+						if !ia.levels[level] || !ib.levels[level] {
+							continue
+						}
+						adj := isisAdj{a: a, b: b, ia: ia, ib: ib, level: level, heard: true}
+						adj.up = s.isisUp(adj, now)
+						adjs = append(adjs, adj)
+					}
+				}
+			}
+		}
+	}
+	return adjs
+}
+
+func (s *Server) isisUp(adj isisAdj, now time.Time) bool {
+	l := adj.level
+	switch {
+	case adj.a.systemId == adj.b.systemId,
+		adj.ia.p2p != adj.ib.p2p,
+		adj.ia.auth != adj.ib.auth,
+		adj.ia.dead[l] <= adj.ia.hello[l] || adj.ib.dead[l] <= adj.ib.hello[l],
+		l == 0 && !shareArea(adj.a.areas, adj.b.areas):
+		return false
+	}
+	_, aSince := s.isisRunning(adj.a)
+	_, bSince := s.isisRunning(adj.b)
+	since := latest(aSince, bSince, s.endpointSince(adj.ia.endpoint), s.endpointSince(adj.ib.endpoint))
+	return now.Sub(since) >= adjacencySetup
+}
+
+func shareArea(a, b []string) bool {
+	for _, x := range a {
+		for _, y := range b {
+			if x == y {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isisDatabase returns the routers whose LSPs every running router has at
+// each level, itself included. LSPs flood over up adjacencies between
+// routers with the same area (L1) or domain (L2) authentication.
+func (s *Server) isisDatabase(adjs []isisAdj) map[string][2][]*isisRouter {
+	links := [2]map[*isisRouter][]*isisRouter{{}, {}}
+	for _, adj := range adjs {
+		if adj.up && adj.a.lspAuth[adj.level] == adj.b.lspAuth[adj.level] {
+			links[adj.level][adj.a] = append(links[adj.level][adj.a], adj.b)
+			links[adj.level][adj.b] = append(links[adj.level][adj.b], adj.a)
+		}
+	}
+	db := map[string][2][]*isisRouter{}
+	for _, r := range s.isis {
+		if ok, _ := s.isisRunning(r); !ok {
+			continue
+		}
+		var lsps [2][]*isisRouter
+		for level := 0; level < 2; level++ {
+			seen := map[*isisRouter]bool{r: true}
+			queue := []*isisRouter{r}
+			for len(queue) > 0 {
+				cur := queue[0]
+				queue = queue[1:]
+				lsps[level] = append(lsps[level], cur)
+				for _, n := range links[level][cur] {
+					if !seen[n] {
+						seen[n] = true
+						queue = append(queue, n)
+					}
+				}
+			}
+		}
+		db[r.name] = lsps
+	}
+	return db
+}
+
+// bgpRemote returns the peer a BGP peer connects to: the peer on the same
+// address family whose interface address is the peer address of p and whose
+// peer address is that of p, nil if there is none or it is not reachable.
+func (s *Server) bgpRemote(p *bgpPeer, now time.Time) *bgpPeer {
+	for _, q := range s.bgp {
+		if q != p && q.v6 == p.v6 && sameIP(q.local, p.remote) && sameIP(q.remote, p.local) &&
+			s.connected(p.endpoint, q.endpoint, now) {
+			return q
+		}
+	}
+	return nil
+}
+
+func sameIP(a, b string) bool {
+	x, y := net.ParseIP(a), net.ParseIP(b)
+	return x != nil && x.Equal(y)
+}
+
+// bgpRunning returns whether p runs and since when.
+func (s *Server) bgpRunning(p *bgpPeer) (bool, time.Time) {
+	return s.protocolsUp && !p.stopped, latest(s.protocolsSince, p.since)
+}
+
+// bgpSession returns the remote peer p has a session with at now, nil if the
+// session is not established. Sessions come up adjacencySetup after both
+// ends started and reached each other, unless both are passive, their MD5
+// keys differ, their AS numbers do not match the session type, a hold time is
+// out of range, or a keepalive interval is no shorter than the negotiated
+// hold time.
+func (s *Server) bgpSession(p *bgpPeer, now time.Time) *bgpPeer {
+	q := s.bgpRemote(p, now)
+	if q == nil {
+		return nil
+	}
+	pOk, pSince := s.bgpRunning(p)
+	qOk, qSince := s.bgpRunning(q)
+	hold := min(p.hold, q.hold)
+	switch {
+	case !pOk || !qOk,
+		p.passive && q.passive,
+		p.md5 != q.md5,
+		p.ibgp != q.ibgp,
+		p.ibgp && p.as != q.as,
+		!p.ibgp && p.as == q.as,
+		// RFC 4271 rejects hold times of 1 and 2 seconds.
+		p.hold > 0 && p.hold < 3, q.hold > 0 && q.hold < 3,
+		hold > 0 && (p.keepalive >= hold || q.keepalive >= hold):
+		return nil
+	}
+	since := latest(pSince, qSince, s.endpointSince(p.endpoint), s.endpointSince(q.endpoint))
+	if now.Sub(since) < adjacencySetup {
+		return nil
+	}
+	return q
+}
+
+// learnedRoutes returns, by device, the names of the route ranges the device
+// learned from the routers of other devices over ISIS or BGP at now.
+func (s *Server) learnedRoutes(now time.Time) map[string]map[string]bool {
+	learned := map[string]map[string]bool{}
+	learn := func(device string, routes []*routeRange) {
+		if learned[device] == nil {
+			learned[device] = map[string]bool{}
+		}
+		for _, r := range s.advertised(routes) {
+			if r.device != device {
+				learned[device][r.name] = true
+			}
+		}
+	}
+	for name, lsps := range s.isisDatabase(s.isisAdjacencies(now)) {
+		r := s.isis[name]
+		for _, level := range lsps {
+			for _, other := range level {
+				learn(r.device, other.routes)
+			}
+		}
+	}
+	for _, p := range s.bgp {
+		if q := s.bgpSession(p, now); q != nil {
+			learn(p.device, q.routes)
+		}
+	}
+	return learned
+}
+
+// updateRouting advances the ISIS hello and BGP message counters to now and
+// counts the adjacencies and sessions that went down since the last update.
+func (s *Server) updateRouting(now time.Time) {
+	elapsed := now.Sub(s.routingUpdated).Seconds()
+	s.routingUpdated = now
+
+	adjs := s.isisAdjacencies(now)
+	sessions := map[*isisRouter][2]int{}
+	for _, adj := range adjs {
+		for _, end := range []struct {
+			r        *isisRouter
+			rx, from *isisIface
+		}{{adj.a, adj.ia, adj.ib}, {adj.b, adj.ib, adj.ia}} {
+			end.rx.hellosRx[adj.level] += elapsed / end.from.hello[adj.level]
+			if adj.up {
+				n := sessions[end.r]
+				n[adj.level]++
+				sessions[end.r] = n
+			}
+		}
+	}
+	for _, r := range s.isis {
+		if ok, _ := s.isisRunning(r); ok {
+			for _, i := range r.ifaces {
+				if !s.endpointUp(i.endpoint, now) {
+					continue
+				}
+				for level := 0; level < 2; level++ {
+					if i.levels[level] {
+						i.hellosTx[level] += elapsed / i.hello[level]
+					}
+				}
+			}
+		}
+		for level := 0; level < 2; level++ {
+			if down := r.sessions[level] - sessions[r][level]; down > 0 {
+				r.flaps[level] += float64(down)
+			}
+		}
+		r.sessions = sessions[r]
+	}
+
+	for _, p := range s.bgp {
+		q := s.bgpSession(p, now)
+		switch {
+		case q != nil && !p.up:
+			// OPEN, the first KEEPALIVE, the routes and the End-of-RIB
+			// marker are exchanged when the session comes up.
+			p.opens++
+			p.keepalivesTx++
+			p.keepalivesRx++
+			p.updatesTx++
+			p.updatesRx++
+			if len(s.advertised(p.routes)) > 0 {
+				p.updatesTx++
+			}
+			if len(s.advertised(q.routes)) > 0 {
+				p.updatesRx++
+			}
+		case q != nil && p.hold > 0 && q.hold > 0:
+			p.keepalivesTx += elapsed / p.keepalive
+			p.keepalivesRx += elapsed / q.keepalive
+		case q == nil && p.up:
+			p.flaps++
+		}
+		p.up = q != nil
+	}
+}
+
+// routeCount returns the number of prefixes of the advertised routes.
+func (s *Server) routeCount(routes []*routeRange) uint64 {
+	n := uint64(0)
+	for _, r := range s.advertised(routes) {
+		n += r.size()
+	}
+	return n
+}
+
+// setProtocolState applies a protocol control state at now.
+func (s *Server) setProtocolState(ps gosnappi.StateProtocol, now time.Time) error {
+	switch ps.Choice() {
+	case gosnappi.StateProtocolChoice.ALL:
+		up := ps.All().State() == gosnappi.StateProtocolAllState.START
+		if up && !s.protocolsUp {
+			s.protocolsSince = now
+		}
+		s.protocolsUp = up
+	case gosnappi.StateProtocolChoice.ISIS:
+		rs := ps.Isis().Routers()
+		names := rs.RouterNames()
+		if len(names) == 0 {
+			for name := range s.isis {
+				names = append(names, name)
+			}
+		}
+		for _, name := range names {
+			if _, ok := s.isis[name]; !ok {
+				return status.Errorf(codes.InvalidArgument, "isis router %s is not configured", name)
+			}
+		}
+		up := rs.State() == gosnappi.StateProtocolIsisRoutersState.UP
+		for _, name := range names {
+			r := s.isis[name]
+			if up && r.stopped {
+				r.since = now
+			}
+			r.stopped = !up
+		}
+	case gosnappi.StateProtocolChoice.BGP:
+		peers := ps.Bgp().Peers()
+		names := peers.PeerNames()
+		if len(names) == 0 {
+			for name := range s.bgp {
+				names = append(names, name)
+			}
+		}
+		for _, name := range names {
+			if _, ok := s.bgp[name]; !ok {
+				return status.Errorf(codes.InvalidArgument, "bgp peer %s is not configured", name)
+			}
+		}
+		up := peers.State() == gosnappi.StateProtocolBgpPeersState.UP
+		for _, name := range names {
+			p := s.bgp[name]
+			if up && p.stopped {
+				p.since = now
+			}
+			p.stopped = !up
+		}
+	case gosnappi.StateProtocolChoice.ROUTE:
+		names := ps.Route().Names()
+		if len(names) == 0 {
+			for name := range s.routes {
+				names = append(names, name)
+			}
+		}
+		for _, name := range names {
+			if _, ok := s.routes[name]; !ok {
+				return status.Errorf(codes.InvalidArgument, "route %s is not configured", name)
+			}
+		}
+		withdraw := ps.Route().State() == gosnappi.StateProtocolRouteState.WITHDRAW
+		for _, name := range names {
+			r := s.routes[name]
+			if withdraw && !s.withdrawn[name] {
+				// Established BGP sessions carry the withdrawal.
+				for _, p := range s.bgp {
+					if q := s.bgpSession(p, now); q != nil && containsRoute(p.routes, r) {
+						p.withdrawsTx += float64(r.size())
+						q.withdrawsRx += float64(r.size())
+					}
+				}
+			}
+			s.withdrawn[name] = withdraw
+		}
+	default:
+		return status.Errorf(codes.Unimplemented, "%s protocol state is not simulated", ps.Choice())
+	}
+	return nil
+}
+
+func containsRoute(routes []*routeRange, r *routeRange) bool {
+	for _, x := range routes {
+		if x == r {
+			return true
+		}
+	}
+	return false
+}
+
+// isisMetrics adds the metrics of the named ISIS routers, or of all of them,
+// to resp.
+func (s *Server) isisMetrics(names []string, resp gosnappi.MetricsResponse, now time.Time) error {
+	if len(names) == 0 {
+		for _, d := range s.config.Devices().Items() {
+			if d.HasIsis() {
+				names = append(names, d.Isis().Name())
+			}
+		}
+	}
+	db := s.isisDatabase(s.isisAdjacencies(now))
+	for _, name := range names {
+		r, ok := s.isis[name]
+		if !ok {
+			return status.Errorf(codes.InvalidArgument, "isis router %s is not configured", name)
+		}
+		var p2pTx, p2pRx, bcastTx, bcastRx [2]float64
+		for _, i := range r.ifaces {
+			for level := 0; level < 2; level++ {
+				if i.p2p {
+					p2pTx[level] += i.hellosTx[level]
+					p2pRx[level] += i.hellosRx[level]
+				} else {
+					bcastTx[level] += i.hellosTx[level]
+					bcastRx[level] += i.hellosRx[level]
+				}
+			}
+		}
+		lsps := db[name]
+		resp.IsisMetrics().Add().
+			SetName(name).
+			SetL1SessionsUp(uint32(r.sessions[0])).
+			SetL1SessionFlap(uint64(r.flaps[0])).
+			SetL1PointToPointHellosSent(uint64(p2pTx[0])).
+			SetL1PointToPointHellosReceived(uint64(p2pRx[0])).
+			SetL1BroadcastHellosSent(uint64(bcastTx[0])).
+			SetL1BroadcastHellosReceived(uint64(bcastRx[0])).
+			SetL1DatabaseSize(uint64(len(lsps[0]))).
+			SetL2SessionsUp(uint32(r.sessions[1])).
+			SetL2SessionFlap(uint64(r.flaps[1])).
+			SetL2PointToPointHellosSent(uint64(p2pTx[1])).
+			SetL2PointToPointHellosReceived(uint64(p2pRx[1])).
+			SetL2BroadcastHellosSent(uint64(bcastTx[1])).
+			SetL2BroadcastHellosReceived(uint64(bcastRx[1])).
+			SetL2DatabaseSize(uint64(len(lsps[1])))
+	}
+	return nil
+}
+
+// bgpNames returns the names of the BGPv4 or BGPv6 peers in config order.
+func (s *Server) bgpNames(v6 bool) []string {
+	names := []string{}
+	for _, d := range s.config.Devices().Items() {
+		if !d.HasBgp() {
+			continue
+		}
+		if v6 {
+			for _, intf := range d.Bgp().Ipv6Interfaces().Items() {
+				for _, p := range intf.Peers().Items() {
+					names = append(names, p.Name())
+				}
+			}
+		} else {
+			for _, intf := range d.Bgp().Ipv4Interfaces().Items() {
+				for _, p := range intf.Peers().Items() {
+					names = append(names, p.Name())
+				}
+			}
+		}
+	}
+	return names
+}
+
+// bgpMetrics adds the metrics of the named BGPv4 or BGPv6 peers, or of all of
+// them, to resp.
+func (s *Server) bgpMetrics(names []string, v6 bool, resp gosnappi.MetricsResponse, now time.Time) error {
+	if len(names) == 0 {
+		names = s.bgpNames(v6)
+	}
+	for _, name := range names {
+		p, ok := s.bgp[name]
+		if !ok || p.v6 != v6 {
+			return status.Errorf(codes.InvalidArgument, "bgp peer %s is not configured", name)
+		}
+		q := s.bgpSession(p, now)
+		var received uint64
+		if q != nil {
+			received = s.routeCount(q.routes)
+		}
+		var advertised uint64
+		if q != nil {
+			advertised = s.routeCount(p.routes)
+		}
+		running, _ := s.bgpRunning(p)
+		if v6 {
+			m := resp.Bgpv6Metrics().Add().SetName(name)
+			switch {
+			case q != nil:
+				m.SetSessionState(gosnappi.Bgpv6MetricSessionState.UP).SetFsmState(gosnappi.Bgpv6MetricFsmState.ESTABLISHED)
+			case running:
+				m.SetSessionState(gosnappi.Bgpv6MetricSessionState.DOWN).SetFsmState(gosnappi.Bgpv6MetricFsmState.ACTIVE)
+			default:
+				m.SetSessionState(gosnappi.Bgpv6MetricSessionState.DOWN).SetFsmState(gosnappi.Bgpv6MetricFsmState.IDLE)
+			}
+			m.SetSessionFlapCount(uint64(p.flaps)).
+				SetRoutesAdvertised(advertised).
+				SetRoutesReceived(received).
+				SetRouteWithdrawsSent(uint64(p.withdrawsTx)).
+				SetRouteWithdrawsReceived(uint64(p.withdrawsRx)).
+				SetUpdatesSent(uint64(p.updatesTx)).
+				SetUpdatesReceived(uint64(p.updatesRx)).
+				SetOpensSent(uint64(p.opens)).
+				SetOpensReceived(uint64(p.opens)).
+				SetKeepalivesSent(uint64(p.keepalivesTx)).
+				SetKeepalivesReceived(uint64(p.keepalivesRx)).
+				SetEndOfRibReceived(uint64(p.opens))
+			continue
+		}
+		m := resp.Bgpv4Metrics().Add().SetName(name)
+		switch {
+		case q != nil:
+			m.SetSessionState(gosnappi.Bgpv4MetricSessionState.UP).SetFsmState(gosnappi.Bgpv4MetricFsmState.ESTABLISHED)
+		case running:
+			m.SetSessionState(gosnappi.Bgpv4MetricSessionState.DOWN).SetFsmState(gosnappi.Bgpv4MetricFsmState.ACTIVE)
+		default:
+			m.SetSessionState(gosnappi.Bgpv4MetricSessionState.DOWN).SetFsmState(gosnappi.Bgpv4MetricFsmState.IDLE)
+		}
+		m.SetSessionFlapCount(uint64(p.flaps)).
+			SetRoutesAdvertised(advertised).
+			SetRoutesReceived(received).
+			SetRouteWithdrawsSent(uint64(p.withdrawsTx)).
+			SetRouteWithdrawsReceived(uint64(p.withdrawsRx)).
+			SetUpdatesSent(uint64(p.updatesTx)).
+			SetUpdatesReceived(uint64(p.updatesRx)).
+			SetOpensSent(uint64(p.opens)).
+			SetOpensReceived(uint64(p.opens)).
+			SetKeepalivesSent(uint64(p.keepalivesTx)).
+			SetKeepalivesReceived(uint64(p.keepalivesRx)).
+			SetEndOfRibReceived(uint64(p.opens))
+	}
+	return nil
+}
+
+// bgpPrefixes adds the prefixes the named BGP peers, or all of them, learned
+// to resp, limited to the given address families if any.
+func (s *Server) bgpPrefixes(req gosnappi.BgpPrefixStateRequest, resp gosnappi.StatesResponse, now time.Time) error {
+	names := req.BgpPeerNames()
+	if len(names) == 0 {
+		names = append(s.bgpNames(false), s.bgpNames(true)...)
+	}
+	families := map[gosnappi.BgpPrefixStateRequestPrefixFiltersEnum]bool{}
+	for _, f := range req.PrefixFilters() {
+		families[f] = true
+	}
+	all := len(families) == 0
+	for _, name := range names {
+		p, ok := s.bgp[name]
+		if !ok {
+			return status.Errorf(codes.InvalidArgument, "bgp peer %s is not configured", name)
+		}
+		st := resp.BgpPrefixes().Add().SetBgpPeerName(name)
+		q := s.bgpSession(p, now)
+		if q == nil {
+			continue
+		}
+		for _, r := range s.advertised(q.routes) {
+			v4, v6 := r.prefixes()
+			if all || families[gosnappi.BgpPrefixStateRequestPrefixFilters.IPV4_UNICAST] {
+				for _, n := range v4 {
+					length, _ := n.Mask.Size()
+					pfx := st.Ipv4UnicastPrefixes().Add().
+						SetIpv4Address(n.IP.String()).
+						SetPrefixLength(uint32(length)).
+						SetOrigin(gosnappi.BgpPrefixIpv4UnicastStateOrigin.IGP)
+					if q.v6 {
+						pfx.SetIpv6NextHop(q.local)
+					} else {
+						pfx.SetIpv4NextHop(q.local)
+					}
+				}
+			}
+			if all || families[gosnappi.BgpPrefixStateRequestPrefixFilters.IPV6_UNICAST] {
+				for _, n := range v6 {
+					length, _ := n.Mask.Size()
+					pfx := st.Ipv6UnicastPrefixes().Add().
+						SetIpv6Address(n.IP.String()).
+						SetPrefixLength(uint32(length)).
+						SetOrigin(gosnappi.BgpPrefixIpv6UnicastStateOrigin.IGP)
+					if q.v6 {
+						pfx.SetIpv6NextHop(q.local)
+					} else {
+						pfx.SetIpv4NextHop(q.local)
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,337 @@
+package otgsim
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/open-traffic-generator/snappi/gosnappi"
+)
+
+// routingConfig returns two devices on port1 and port2, linked back-to-back,
+// that run ISIS at L2 and iBGP over their interfaces and advertise five ISIS
+// and three BGP routes each, like the isis_basic test.
+func routingConfig() gosnappi.Config {
+	config := gosnappi.NewConfig()
+	for i := 1; i <= 2; i++ {
+		port := config.Ports().Add().SetName(fmt.Sprintf("port%d", i))
+		d := config.Devices().Add().SetName(fmt.Sprintf("dev%d", i))
+		eth := d.Ethernets().Add().SetName(fmt.Sprintf("dev%d.eth", i)).SetMac(fmt.Sprintf("02:00:00:00:00:%02d", i))
+		eth.Connection().SetPortName(port.Name())
+		ip := eth.Ipv4Addresses().Add().SetName(fmt.Sprintf("dev%d.ipv4", i)).
+			SetAddress(fmt.Sprintf("192.0.2.%d", i)).SetGateway(fmt.Sprintf("192.0.2.%d", 3-i)).SetPrefix(30)
+
+		isis := d.Isis().SetName(fmt.Sprintf("dev%d.isis", i)).SetSystemId(fmt.Sprintf("%d50000000001", i+5))
+		isis.Advanced().SetAreaAddresses([]string{"490001"})
+		isis.Interfaces().Add().SetName(fmt.Sprintf("dev%d.isis.intf", i)).SetEthName(eth.Name()).
+			SetNetworkType(gosnappi.IsisInterfaceNetworkType.POINT_TO_POINT).
+			SetLevelType(gosnappi.IsisInterfaceLevelType.LEVEL_2)
+		isis.V4Routes().Add().SetName(fmt.Sprintf("dev%d.isis.routes", i)).Addresses().Add().
+			SetAddress(fmt.Sprintf("%d.1.1.1", i*100)).SetPrefix(32).SetCount(5)
+
+		peer := d.Bgp().SetRouterId(ip.Address()).Ipv4Interfaces().Add().SetIpv4Name(ip.Name()).Peers().Add().
+			SetName(fmt.Sprintf("dev%d.bgp", i)).SetPeerAddress(ip.Gateway()).
+			SetAsType(gosnappi.BgpV4PeerAsType.IBGP).SetAsNumber(65000)
+		peer.V4Routes().Add().SetName(fmt.Sprintf("dev%d.bgp.routes", i)).Addresses().Add().
+			SetAddress(fmt.Sprintf("198.51.%d.0", i*10)).SetPrefix(24).SetCount(3).SetStep(2)
+	}
+	return config
+}
+
+func startRouting(t *testing.T, config gosnappi.Config) gosnappi.Api {
+	t.Helper()
+	sim, api := startSim(t)
+	sim.Links = []Link{{A: "port1", B: "port2"}}
+	if _, err := api.SetConfig(config); err != nil {
+		t.Fatal(err)
+	}
+	setProtocols(t, api, gosnappi.StateProtocolAllState.START)
+	// Adjacencies and sessions come up 1s of simulated time later.
+	time.Sleep(10 * time.Millisecond)
+	return api
+}
+
+func setProtocols(t *testing.T, api gosnappi.Api, state gosnappi.StateProtocolAllStateEnum) {
+	t.Helper()
+	cs := gosnappi.NewControlState()
+	cs.Protocol().All().SetState(state)
+	if _, err := api.SetControlState(cs); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func isisMetric(t *testing.T, api gosnappi.Api, name string) gosnappi.IsisMetric {
+	t.Helper()
+	req := gosnappi.NewMetricsRequest()
+	req.Isis().SetRouterNames([]string{name})
+	resp, err := api.GetMetrics(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp.IsisMetrics().Items()[0]
+}
+
+func bgpMetric(t *testing.T, api gosnappi.Api, name string) gosnappi.Bgpv4Metric {
+	t.Helper()
+	req := gosnappi.NewMetricsRequest()
+	req.Bgpv4().SetPeerNames([]string{name})
+	resp, err := api.GetMetrics(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp.Bgpv4Metrics().Items()[0]
+}
+
+func TestIsisAdjacency(t *testing.T) {
+	intf := func(c gosnappi.Config, dev int) gosnappi.IsisInterface {
+		return c.Devices().Items()[dev].Isis().Interfaces().Items()[0]
+	}
+	tests := []struct {
+		desc   string
+		change func(gosnappi.Config)
+		wantL1 uint32
+		wantL2 uint32
+	}{
+		{"matching", func(gosnappi.Config) {}, 0, 1},
+		{"L1 in a common area", func(c gosnappi.Config) {
+			intf(c, 0).SetLevelType(gosnappi.IsisInterfaceLevelType.LEVEL_1_2)
+			intf(c, 1).SetLevelType(gosnappi.IsisInterfaceLevelType.LEVEL_1_2)
+		}, 1, 1},
+		{"L1 in different areas", func(c gosnappi.Config) {
+			intf(c, 0).SetLevelType(gosnappi.IsisInterfaceLevelType.LEVEL_1_2)
+			intf(c, 1).SetLevelType(gosnappi.IsisInterfaceLevelType.LEVEL_1_2)
+			c.Devices().Items()[1].Isis().Advanced().SetAreaAddresses([]string{"490002"})
+		}, 0, 1},
+		{"level mismatch", func(c gosnappi.Config) {
+			intf(c, 0).SetLevelType(gosnappi.IsisInterfaceLevelType.LEVEL_1)
+		}, 0, 0},
+		{"network type mismatch", func(c gosnappi.Config) {
+			intf(c, 1).SetNetworkType(gosnappi.IsisInterfaceNetworkType.BROADCAST)
+		}, 0, 0},
+		{"dead interval below hello interval", func(c gosnappi.Config) {
+			intf(c, 1).L2Settings().SetHelloInterval(10).SetDeadInterval(5)
+		}, 0, 0},
+		{"hello authentication mismatch", func(c gosnappi.Config) {
+			intf(c, 0).Authentication().SetAuthType(gosnappi.IsisInterfaceAuthenticationAuthType.MD5).SetMd5("secret")
+		}, 0, 0},
+		{"matching hello authentication", func(c gosnappi.Config) {
+			intf(c, 0).Authentication().SetAuthType(gosnappi.IsisInterfaceAuthenticationAuthType.MD5).SetMd5("secret")
+			intf(c, 1).Authentication().SetAuthType(gosnappi.IsisInterfaceAuthenticationAuthType.MD5).SetMd5("secret")
+		}, 0, 1},
+	}
+	for _, tc := range tests {
+		t.Run(tc.desc, func(t *testing.T) {
+			config := routingConfig()
+			tc.change(config)
+			api := startRouting(t, config)
+			for _, name := range []string{"dev1.isis", "dev2.isis"} {
+				m := isisMetric(t, api, name)
+				if m.L1SessionsUp() != tc.wantL1 || m.L2SessionsUp() != tc.wantL2 {
+					t.Errorf("%s L1/L2 sessions up got %d/%d, want %d/%d",
+						name, m.L1SessionsUp(), m.L2SessionsUp(), tc.wantL1, tc.wantL2)
+				}
+				hellos := m.L1PointToPointHellosSent() + m.L2PointToPointHellosSent() +
+					m.L1BroadcastHellosSent() + m.L2BroadcastHellosSent()
+				if hellos == 0 {
+					t.Errorf("%s sent no hellos", name)
+				}
+			}
+		})
+	}
+}
+
+func TestIsisSessionFlap(t *testing.T) {
+	config := routingConfig()
+	api := startRouting(t, config)
+	setLinks(t, api, gosnappi.StatePortLinkState.DOWN, "port1")
+	m := isisMetric(t, api, "dev2.isis")
+	if m.L2SessionsUp() != 0 || m.L2SessionFlap() != 1 {
+		t.Errorf("after port1 went down got %d sessions up, %d flaps, want 0 and 1", m.L2SessionsUp(), m.L2SessionFlap())
+	}
+	setLinks(t, api, gosnappi.StatePortLinkState.UP, "port1")
+	time.Sleep(10 * time.Millisecond)
+	if m := isisMetric(t, api, "dev2.isis"); m.L2SessionsUp() != 1 {
+		t.Errorf("after port1 came back got %d sessions up, want 1", m.L2SessionsUp())
+	}
+}
+
+func TestBgpSession(t *testing.T) {
+	peer := func(c gosnappi.Config, dev int) gosnappi.BgpV4Peer {
+		return c.Devices().Items()[dev].Bgp().Ipv4Interfaces().Items()[0].Peers().Items()[0]
+	}
+	tests := []struct {
+		desc   string
+		change func(gosnappi.Config)
+		wantUp bool
+	}{
+		{"matching", func(gosnappi.Config) {}, true},
+		{"ebgp", func(c gosnappi.Config) {
+			peer(c, 0).SetAsType(gosnappi.BgpV4PeerAsType.EBGP).SetAsNumber(65001)
+			peer(c, 1).SetAsType(gosnappi.BgpV4PeerAsType.EBGP).SetAsNumber(65002)
+		}, true},
+		{"ibgp in different ASes", func(c gosnappi.Config) {
+			peer(c, 1).SetAsNumber(65001)
+		}, false},
+		{"ebgp in the same AS", func(c gosnappi.Config) {
+			peer(c, 0).SetAsType(gosnappi.BgpV4PeerAsType.EBGP)
+			peer(c, 1).SetAsType(gosnappi.BgpV4PeerAsType.EBGP)
+		}, false},
+		{"wrong peer address", func(c gosnappi.Config) {
+			peer(c, 0).SetPeerAddress("192.0.2.3")
+		}, false},
+		{"md5 mismatch", func(c gosnappi.Config) {
+			peer(c, 0).Advanced().SetMd5Key("secret")
+		}, false},
+		{"both passive", func(c gosnappi.Config) {
+			peer(c, 0).Advanced().SetPassiveMode(true)
+			peer(c, 1).Advanced().SetPassiveMode(true)
+		}, false},
+		{"one passive", func(c gosnappi.Config) {
+			peer(c, 0).Advanced().SetPassiveMode(true)
+		}, true},
+		{"hold time too short", func(c gosnappi.Config) {
+			peer(c, 1).Advanced().SetHoldTimeInterval(2).SetKeepAliveInterval(1)
+		}, false},
+		{"keepalive beyond hold time", func(c gosnappi.Config) {
+			peer(c, 1).Advanced().SetHoldTimeInterval(30).SetKeepAliveInterval(60)
+		}, false},
+	}
+	for _, tc := range tests {
+		t.Run(tc.desc, func(t *testing.T) {
+			config := routingConfig()
+			tc.change(config)
+			api := startRouting(t, config)
+			wantState, wantRoutes := gosnappi.Bgpv4MetricSessionState.DOWN, uint64(0)
+			if tc.wantUp {
+				wantState, wantRoutes = gosnappi.Bgpv4MetricSessionState.UP, 3
+			}
+			for _, name := range []string{"dev1.bgp", "dev2.bgp"} {
+				m := bgpMetric(t, api, name)
+				if m.SessionState() != wantState || m.RoutesReceived() != wantRoutes {
+					t.Errorf("%s got session %s with %d routes received, want %s with %d",
+						name, m.SessionState(), m.RoutesReceived(), wantState, wantRoutes)
+				}
+			}
+		})
+	}
+}
+
+func TestBgpPrefixes(t *testing.T) {
+	api := startRouting(t, routingConfig())
+	req := gosnappi.NewStatesRequest()
+	req.BgpPrefixes().SetBgpPeerNames([]string{"dev1.bgp"})
+	resp, err := api.GetStates(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := []string{}
+	for _, p := range resp.BgpPrefixes().Items()[0].Ipv4UnicastPrefixes().Items() {
+		got = append(got, fmt.Sprintf("%s/%d via %s", p.Ipv4Address(), p.PrefixLength(), p.Ipv4NextHop()))
+	}
+	want := []string{"198.51.20.0/24 via 192.0.2.2", "198.51.22.0/24 via 192.0.2.2", "198.51.24.0/24 via 192.0.2.2"}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("dev1.bgp learned %v, want %v", got, want)
+	}
+
+	// Stopping the other end tears the session down and flushes its routes.
+	cs := gosnappi.NewControlState()
+	cs.Protocol().Bgp().Peers().SetPeerNames([]string{"dev2.bgp"}).SetState(gosnappi.StateProtocolBgpPeersState.DOWN)
+	if _, err := api.SetControlState(cs); err != nil {
+		t.Fatal(err)
+	}
+	m := bgpMetric(t, api, "dev1.bgp")
+	if m.SessionState() != gosnappi.Bgpv4MetricSessionState.DOWN || m.SessionFlapCount() != 1 {
+		t.Errorf("dev1.bgp got %s with %d flaps after its peer stopped, want down with 1", m.SessionState(), m.SessionFlapCount())
+	}
+	if m := bgpMetric(t, api, "dev2.bgp"); m.FsmState() != gosnappi.Bgpv4MetricFsmState.IDLE {
+		t.Errorf("stopped dev2.bgp got fsm state %s, want idle", m.FsmState())
+	}
+	resp, err = api.GetStates(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(resp.BgpPrefixes().Items()[0].Ipv4UnicastPrefixes().Items()); n != 0 {
+		t.Errorf("dev1.bgp got %d prefixes after its peer stopped, want none", n)
+	}
+}
+
+// routeFlow adds a flow between the ISIS route ranges of dev1 and dev2.
+func routeFlow(config gosnappi.Config) {
+	flow := config.Flows().Add().SetName("flow1")
+	flow.TxRx().Device().SetTxNames([]string{"dev1.isis.routes"}).SetRxNames([]string{"dev2.isis.routes"})
+	flow.Metrics().SetEnable(true)
+	flow.Rate().SetPps(1000)
+	flow.Duration().FixedPackets().SetPackets(1000)
+	flow.Packet().Add().Ethernet()
+	flow.Packet().Add().Ipv4()
+}
+
+func startFlow(t *testing.T, api gosnappi.Api) gosnappi.FlowMetric {
+	t.Helper()
+	cs := gosnappi.NewControlState()
+	cs.Traffic().FlowTransmit().SetState(gosnappi.StateTrafficFlowTransmitState.START)
+	if _, err := api.SetControlState(cs); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(20 * time.Millisecond)
+	return flowMetric(t, api, "flow1")
+}
+
+func TestRouteLearning(t *testing.T) {
+	tests := []struct {
+		desc   string
+		change func(gosnappi.Config)
+		wantRx uint64
+	}{
+		{"routes learned", func(gosnappi.Config) {}, 1000},
+		// The adjacency comes up, but dev1 discards the LSPs of dev2.
+		{"domain authentication mismatch", func(c gosnappi.Config) {
+			c.Devices().Items()[1].Isis().RouterAuth().DomainAuth().
+				SetAuthType(gosnappi.IsisAuthenticationBaseAuthType.PASSWORD).SetPassword("secret")
+		}, 0},
+	}
+	for _, tc := range tests {
+		t.Run(tc.desc, func(t *testing.T) {
+			config := routingConfig()
+			routeFlow(config)
+			tc.change(config)
+			api := startRouting(t, config)
+			if up := isisMetric(t, api, "dev1.isis").L2SessionsUp(); up != 1 {
+				t.Errorf("dev1.isis got %d sessions up, want 1", up)
+			}
+			m := startFlow(t, api)
+			if m.FramesTx() != 1000 || m.FramesRx() != tc.wantRx {
+				t.Errorf("flow1 tx %d, rx %d frames, want 1000 and %d", m.FramesTx(), m.FramesRx(), tc.wantRx)
+			}
+		})
+	}
+}
+
+func TestRouteWithdraw(t *testing.T) {
+	sim, api := startSim(t)
+	sim.Links = []Link{{A: "port1", B: "port2"}}
+	config := routingConfig()
+	routeFlow(config)
+	if _, err := api.SetConfig(config); err != nil {
+		t.Fatal(err)
+	}
+	// Nothing is learned before the protocols start.
+	if m := startFlow(t, api); m.FramesRx() != 0 {
+		t.Errorf("flow1 rx %d frames before the protocols started, want 0", m.FramesRx())
+	}
+
+	setProtocols(t, api, gosnappi.StateProtocolAllState.START)
+	time.Sleep(10 * time.Millisecond)
+	if m := startFlow(t, api); m.FramesRx() != 1000 {
+		t.Errorf("flow1 rx %d frames with the routes learned, want 1000", m.FramesRx())
+	}
+
+	cs := gosnappi.NewControlState()
+	cs.Protocol().Route().SetNames([]string{"dev2.isis.routes"}).SetState(gosnappi.StateProtocolRouteState.WITHDRAW)
+	if _, err := api.SetControlState(cs); err != nil {
+		t.Fatal(err)
+	}
+	if m := startFlow(t, api); m.FramesRx() != 0 {
+		t.Errorf("flow1 rx %d frames after the routes were withdrawn, want 0", m.FramesRx())
+	}
+}
@@ -0,0 +1,313 @@
+// Package otgsim is an in-process OTG service that simulates back-to-back
+// test ports, traffic flows and a device under test between them. It serves
+// the OTG gRPC API, so tests point a gosnappi API handle at it exactly as they
+// would at the STC OTG service.
+package otgsim
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/open-traffic-generator/snappi/gosnappi"
+	"github.com/open-traffic-generator/snappi/gosnappi/otg"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// Dut models the device under test every flow crosses.
+type Dut struct {
+	// MaxRate is the load, in percent of the tx port line rate, the DUT
+	// forwards without loss. Above it, the excess frames are dropped. 100
+	// when zero.
+	MaxRate float64
+	// Latency is the forwarding latency of an idle DUT.
+	Latency time.Duration
+	// Jitter is the extra queueing latency at full load. The average latency
+	// grows with load by half of it, the maximum by all of it.
+	Jitter time.Duration
+	// Buffer is how many frames of a flow the DUT queues while the flow
+	// exceeds MaxRate, e.g. during a burst at line rate. Frames are only
+	// dropped once the buffer is full; it drains at the spare forwarding
+	// capacity.
+	Buffer float64
+}
+
+// Server is a simulated OTG service.
+type Server struct {
+	otg.UnimplementedOpenapiServer
+
+	// Dut is the device under test flows are forwarded through.
+	Dut Dut
+	// TimeScale makes simulated time run this many times faster than wall
+	// clock time, so that a 60s trial finishes in 60ms at 1000. 1 when zero.
+	TimeScale float64
+	// Links are the back-to-back cables between test ports, e.g. from
+	// ReadTestbedLinks. Ports without one are cabled to the DUT.
+	Links []Link
+
+	mu       sync.Mutex
+	start    time.Time
+	config   gosnappi.Config
+	ports    map[string]*port
+	flows    map[string]*flow
+	lags     map[string]*lag
+	memberOf map[string]*member
+	isis     map[string]*isisRouter
+	bgp      map[string]*bgpPeer
+	routes   map[string]*routeRange
+	// withdrawn holds the route ranges withdrawn through the control state.
+	withdrawn map[string]bool
+	// protocolsUp is set while all protocols are started, since when.
+	protocolsUp    bool
+	protocolsSince time.Time
+	routingUpdated time.Time
+
+	grpc *grpc.Server
+	lis  net.Listener
+}
+
+// New returns a server with an empty config. Set Dut and TimeScale before
+// calling Start.
+func New() *Server {
+	s := &Server{
+		start: time.Now(),
+	}
+	s.reset(gosnappi.NewConfig())
+	return s
+}
+
+// Start serves the OTG gRPC API on location, e.g. "127.0.0.1:0" for any free
+// port.
+func (s *Server) Start(location string) error {
+	lis, err := net.Listen("tcp", location)
+	if err != nil {
+		return err
+	}
+	s.lis = lis
+	s.grpc = grpc.NewServer()
+	otg.RegisterOpenapiServer(s.grpc, s)
+	go s.grpc.Serve(lis)
+	return nil
+}
+
+// Stop stops serving.
+func (s *Server) Stop() {
+	if s.grpc != nil {
+		s.grpc.Stop()
+	}
+}
+
+// Location is the address the server listens on.
+func (s *Server) Location() string {
+	return s.lis.Addr().String()
+}
+
+// Api returns a gosnappi API handle connected to the server.
+func (s *Server) Api() gosnappi.Api {
+	api := gosnappi.NewApi()
+	api.NewGrpcTransport().
+		SetLocation(s.Location()).
+		SetDialTimeout(10 * time.Second).
+		SetRequestTimeout(time.Minute)
+	return api
+}
+
+// now returns the current simulated time.
+func (s *Server) now() time.Time {
+	scale := s.TimeScale
+	if scale == 0 {
+		scale = 1
+	}
+	return s.start.Add(time.Duration(float64(time.Since(s.start)) * scale))
+}
+
+// reset replaces the config and the state derived from it.
+func (s *Server) reset(config gosnappi.Config) {
+	now := s.now()
+	s.config = config
+	s.ports = map[string]*port{}
+	for _, p := range config.Ports().Items() {
+		sp := &port{name: p.Name(), enabled: true, since: now, speed: defaultSpeed}
+		if p.HasLocation() {
+			sp.location = p.Location()
+		}
+		s.ports[p.Name()] = sp
+	}
+	for _, l1 := range config.Layer1().Items() {
+		for _, name := range l1.PortNames() {
+			if p, ok := s.ports[name]; ok && l1.HasSpeed() {
+				p.speed = speedBps(l1.Speed())
+			}
+		}
+	}
+	s.lags = map[string]*lag{}
+	s.memberOf = map[string]*member{}
+	for _, l := range config.Lags().Items() {
+		msg, err := l.Marshal().ToProto()
+		if err != nil {
+			continue
+		}
+		sl := newLag(msg, now)
+		s.lags[sl.name] = sl
+		for _, m := range sl.members {
+			s.memberOf[m.port] = m
+		}
+	}
+	s.buildRouting(config)
+	s.protocolsUp = false
+	s.routingUpdated = now
+	s.flows = map[string]*flow{}
+	for _, f := range config.Flows().Items() {
+		s.flows[f.Name()] = newFlow(s, f)
+	}
+}
+
+func (s *Server) SetConfig(ctx context.Context, req *otg.SetConfigRequest) (*otg.SetConfigResponse, error) {
+	config, err := gosnappi.NewConfig().Unmarshal().FromProto(req.Config)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.reset(config)
+	return &otg.SetConfigResponse{Warning: &otg.Warning{}}, nil
+}
+
+func (s *Server) GetConfig(ctx context.Context, _ *emptypb.Empty) (*otg.GetConfigResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	config, err := s.config.Marshal().ToProto()
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return &otg.GetConfigResponse{Config: config}, nil
+}
+
+func (s *Server) SetControlState(ctx context.Context, req *otg.SetControlStateRequest) (*otg.SetControlStateResponse, error) {
+	cs, err := gosnappi.NewControlState().Unmarshal().FromProto(req.ControlState)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	switch cs.Choice() {
+	case gosnappi.ControlStateChoice.PORT:
+		if cs.Port().Choice() == gosnappi.StatePortChoice.LINK {
+			link := cs.Port().Link()
+			for _, name := range link.PortNames() {
+				if _, ok := s.ports[name]; !ok {
+					return nil, status.Errorf(codes.InvalidArgument, "port %s is not configured", name)
+				}
+			}
+			s.advance(now)
+			s.setLinks(link.PortNames(), link.State() == gosnappi.StatePortLinkState.UP, now)
+		}
+	case gosnappi.ControlStateChoice.TRAFFIC:
+		ft := cs.Traffic().FlowTransmit()
+		names := ft.FlowNames()
+		if len(names) == 0 {
+			for name := range s.flows {
+				names = append(names, name)
+			}
+		}
+		for _, name := range names {
+			f, ok := s.flows[name]
+			if !ok {
+				return nil, status.Errorf(codes.InvalidArgument, "flow %s is not configured", name)
+			}
+			s.advance(now)
+			switch ft.State() {
+			case gosnappi.StateTrafficFlowTransmitState.START:
+				f.start(now, false)
+			case gosnappi.StateTrafficFlowTransmitState.RESUME:
+				f.start(now, true)
+			case gosnappi.StateTrafficFlowTransmitState.STOP, gosnappi.StateTrafficFlowTransmitState.PAUSE:
+				f.stop()
+			}
+		}
+	case gosnappi.ControlStateChoice.PROTOCOL:
+		s.advance(now)
+		if err := s.setProtocolState(cs.Protocol(), now); err != nil {
+			return nil, err
+		}
+	}
+	return &otg.SetControlStateResponse{Warning: &otg.Warning{}}, nil
+}
+
+func (s *Server) GetMetrics(ctx context.Context, req *otg.GetMetricsRequest) (*otg.GetMetricsResponse, error) {
+	mr, err := gosnappi.NewMetricsRequest().Unmarshal().FromProto(req.MetricsRequest)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	s.advance(now)
+	resp := gosnappi.NewMetricsResponse()
+	switch mr.Choice() {
+	case gosnappi.MetricsRequestChoice.FLOW:
+		var tagged gosnappi.FlowTaggedMetricsFilter
+		if mr.Flow().HasTaggedMetrics() {
+			tagged = mr.Flow().TaggedMetrics()
+		}
+		if err := s.flowMetrics(mr.Flow().FlowNames(), tagged, resp); err != nil {
+			return nil, err
+		}
+	case gosnappi.MetricsRequestChoice.PORT:
+		if err := s.portMetrics(mr.Port().PortNames(), resp); err != nil {
+			return nil, err
+		}
+	case gosnappi.MetricsRequestChoice.LAG:
+		if err := s.lagMetrics(mr.Lag().LagNames(), resp, now); err != nil {
+			return nil, err
+		}
+	case gosnappi.MetricsRequestChoice.LACP:
+		if err := s.lacpMetrics(mr.Lacp().LagNames(), mr.Lacp().LagMemberPortNames(), resp, now); err != nil {
+			return nil, err
+		}
+	case gosnappi.MetricsRequestChoice.ISIS:
+		if err := s.isisMetrics(mr.Isis().RouterNames(), resp, now); err != nil {
+			return nil, err
+		}
+	case gosnappi.MetricsRequestChoice.BGPV4:
+		if err := s.bgpMetrics(mr.Bgpv4().PeerNames(), false, resp, now); err != nil {
+			return nil, err
+		}
+	case gosnappi.MetricsRequestChoice.BGPV6:
+		if err := s.bgpMetrics(mr.Bgpv6().PeerNames(), true, resp, now); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, status.Errorf(codes.Unimplemented, "%s metrics are not simulated", mr.Choice())
+	}
+
+	msg, err := resp.Marshal().ToProto()
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return &otg.GetMetricsResponse{MetricsResponse: msg}, nil
+}
+
+// advance brings the simulated traffic, LACP and routing protocol counters to
+// now. It is called before anything changes the state they depend on.
+func (s *Server) advance(now time.Time) {
+	s.updateFlows(now)
+	s.updateLacp(now)
+	s.updateRouting(now)
+}
+
+func (s *Server) GetVersion(ctx context.Context, _ *emptypb.Empty) (*otg.GetVersionResponse, error) {
+	v, err := gosnappi.NewApi().GetLocalVersion().Marshal().ToProto()
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return &otg.GetVersionResponse{Version: v}, nil
+}
@@ -0,0 +1,308 @@
+package otgsim
+
+import (
+	"testing"
+	"time"
+
+	"github.com/open-traffic-generator/snappi/gosnappi"
+)
+
+func startSim(t *testing.T) (*Server, gosnappi.Api) {
+	t.Helper()
+	sim := New()
+	sim.TimeScale = 1000
+	if err := sim.Start("127.0.0.1:0"); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(sim.Stop)
+	return sim, sim.Api()
+}
+
+func flowMetric(t *testing.T, api gosnappi.Api, name string) gosnappi.FlowMetric {
+	t.Helper()
+	req := gosnappi.NewMetricsRequest()
+	req.Flow().SetFlowNames([]string{name})
+	metrics, err := api.GetMetrics(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return metrics.FlowMetrics().Items()[0]
+}
+
+func TestFixedPacketsFlow(t *testing.T) {
+	_, api := startSim(t)
+
+	config := gosnappi.NewConfig()
+	config.Ports().Add().SetName("port1")
+	config.Ports().Add().SetName("port2")
+	flow := config.Flows().Add().SetName("flow1")
+	flow.TxRx().Port().SetTxName("port1").SetRxNames([]string{"port2"})
+	flow.Metrics().SetEnable(true)
+	flow.Size().SetFixed(128)
+	flow.Rate().SetPps(1000)
+	flow.Duration().FixedPackets().SetPackets(5000)
+	if _, err := api.SetConfig(config); err != nil {
+		t.Fatal(err)
+	}
+
+	cs := gosnappi.NewControlState()
+	cs.Traffic().FlowTransmit().SetState(gosnappi.StateTrafficFlowTransmitState.START)
+	if _, err := api.SetControlState(cs); err != nil {
+		t.Fatal(err)
+	}
+
+	// 5000 frames at 1000 pps take 5s, i.e. 5ms of wall clock time.
+	time.Sleep(50 * time.Millisecond)
+	m := flowMetric(t, api, "flow1")
+	if m.Transmit() != gosnappi.FlowMetricTransmit.STOPPED {
+		t.Errorf("flow1 transmit got %s, want stopped", m.Transmit())
+	}
+	if m.FramesTx() != 5000 || m.FramesRx() != 5000 {
+		t.Errorf("flow1 frames tx/rx got %d/%d, want 5000/5000", m.FramesTx(), m.FramesRx())
+	}
+	if m.BytesRx() != 5000*128 {
+		t.Errorf("flow1 bytes rx got %d, want %d", m.BytesRx(), 5000*128)
+	}
+}
+
+func TestLinkDownDropsTraffic(t *testing.T) {
+	_, api := startSim(t)
+
+	config := gosnappi.NewConfig()
+	config.Ports().Add().SetName("port1")
+	config.Ports().Add().SetName("port2")
+	flow := config.Flows().Add().SetName("flow1")
+	flow.TxRx().Port().SetTxName("port1").SetRxNames([]string{"port2"})
+	flow.Rate().SetPps(1000)
+	flow.Duration().Continuous()
+	if _, err := api.SetConfig(config); err != nil {
+		t.Fatal(err)
+	}
+
+	cs := gosnappi.NewControlState()
+	cs.Port().Link().SetPortNames([]string{"port2"}).SetState(gosnappi.StatePortLinkState.DOWN)
+	if _, err := api.SetControlState(cs); err != nil {
+		t.Fatal(err)
+	}
+	cs = gosnappi.NewControlState()
+	cs.Traffic().FlowTransmit().SetState(gosnappi.StateTrafficFlowTransmitState.START)
+	if _, err := api.SetControlState(cs); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(10 * time.Millisecond)
+
+	m := flowMetric(t, api, "flow1")
+	if m.Transmit() != gosnappi.FlowMetricTransmit.STARTED {
+		t.Errorf("flow1 transmit got %s, want started", m.Transmit())
+	}
+	if m.FramesTx() == 0 || m.FramesRx() != 0 {
+		t.Errorf("flow1 frames tx/rx got %d/%d, want >0/0", m.FramesTx(), m.FramesRx())
+	}
+
+	req := gosnappi.NewMetricsRequest()
+	req.Port().SetPortNames([]string{"port2"})
+	metrics, err := api.GetMetrics(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if link := metrics.PortMetrics().Items()[0].Link(); link != gosnappi.PortMetricLink.DOWN {
+		t.Errorf("port2 link got %s, want down", link)
+	}
+}
+
+func TestMtuDropsOversizeFrames(t *testing.T) {
+	_, api := startSim(t)
+
+	config := gosnappi.NewConfig()
+	config.Ports().Add().SetName("port1")
+	config.Ports().Add().SetName("port2")
+	config.Devices().Add().SetName("dev2").Ethernets().Add().SetName("dev2.eth").
+		SetMac("02:00:00:00:00:02").SetMtu(1500).Connection().SetPortName("port2")
+	for _, tc := range []struct {
+		name   string
+		size   uint32
+		vlans  int
+		labels int
+		noIp   bool
+	}{
+		{name: "fits", size: 1518},
+		{name: "tagged", size: 1522, vlans: 1},
+		{name: "labeled", size: 1526, labels: 2},
+		{name: "oversize", size: 1519},
+		{name: "labeled oversize", size: 1527, labels: 2},
+		// Frames without an IP packet are not subject to the MTU.
+		{name: "no ip", size: 1600, noIp: true},
+	} {
+		flow := config.Flows().Add().SetName(tc.name)
+		flow.TxRx().Port().SetTxName("port1").SetRxNames([]string{"port2"})
+		flow.Size().SetFixed(tc.size)
+		flow.Rate().SetPps(1000)
+		flow.Duration().FixedPackets().SetPackets(100)
+		flow.Packet().Add().Ethernet()
+		for i := 0; i < tc.vlans; i++ {
+			flow.Packet().Add().Vlan()
+		}
+		for i := 0; i < tc.labels; i++ {
+			flow.Packet().Add().Mpls()
+		}
+		if tc.noIp {
+			flow.Packet().Add().Arp()
+		} else {
+			flow.Packet().Add().Ipv4()
+		}
+	}
+	if _, err := api.SetConfig(config); err != nil {
+		t.Fatal(err)
+	}
+	cs := gosnappi.NewControlState()
+	cs.Traffic().FlowTransmit().SetState(gosnappi.StateTrafficFlowTransmitState.START)
+	if _, err := api.SetControlState(cs); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(10 * time.Millisecond)
+
+	for name, want := range map[string]uint64{
+		"fits": 100, "tagged": 100, "labeled": 100, "oversize": 0, "labeled oversize": 0, "no ip": 100,
+	} {
+		if m := flowMetric(t, api, name); m.FramesTx() != 100 || m.FramesRx() != want {
+			t.Errorf("%s frames tx/rx got %d/%d, want 100/%d", name, m.FramesTx(), m.FramesRx(), want)
+		}
+	}
+}
+
+func TestBurstBuffer(t *testing.T) {
+	sim, api := startSim(t)
+	sim.Dut = Dut{MaxRate: 50, Buffer: 1000}
+
+	for _, tc := range []struct {
+		frames uint32
+		wantRx uint64
+	}{
+		// Half of the frames of a line rate burst queue up.
+		{frames: 2000, wantRx: 2000},
+		{frames: 3000, wantRx: 2500},
+	} {
+		config := gosnappi.NewConfig()
+		config.Ports().Add().SetName("port1")
+		config.Ports().Add().SetName("port2")
+		flow := config.Flows().Add().SetName("flow1")
+		flow.TxRx().Port().SetTxName("port1").SetRxNames([]string{"port2"})
+		flow.Size().SetFixed(64)
+		flow.Rate().SetPercentage(100)
+		flow.Duration().Burst().SetBursts(1).SetPackets(tc.frames)
+		if _, err := api.SetConfig(config); err != nil {
+			t.Fatal(err)
+		}
+		cs := gosnappi.NewControlState()
+		cs.Traffic().FlowTransmit().SetState(gosnappi.StateTrafficFlowTransmitState.START)
+		if _, err := api.SetControlState(cs); err != nil {
+			t.Fatal(err)
+		}
+
+		time.Sleep(10 * time.Millisecond)
+		m := flowMetric(t, api, "flow1")
+		if m.FramesTx() != uint64(tc.frames) || m.FramesRx() != tc.wantRx {
+			t.Errorf("burst of %d frames tx/rx got %d/%d, want %d/%d", tc.frames, m.FramesTx(), m.FramesRx(), tc.frames, tc.wantRx)
+		}
+	}
+}
+
+func TestNeighbors(t *testing.T) {
+	_, api := startSim(t)
+
+	config := gosnappi.NewConfig()
+	config.Ports().Add().SetName("port1")
+	config.Ports().Add().SetName("port2")
+	d1 := config.Devices().Add().SetName("dev1")
+	eth1 := d1.Ethernets().Add().SetName("eth1").SetMac("00:00:01:01:01:01")
+	eth1.Connection().SetPortName("port1")
+	eth1.Ipv4Addresses().Add().SetName("ip1").SetAddress("10.1.1.1").SetGateway("10.1.1.2").SetPrefix(24)
+	d2 := config.Devices().Add().SetName("dev2")
+	eth2 := d2.Ethernets().Add().SetName("eth2").SetMac("00:00:02:02:02:02")
+	eth2.Connection().SetPortName("port2")
+	eth2.Ipv4Addresses().Add().SetName("ip2").SetAddress("10.1.1.2").SetGateway("10.1.1.3").SetPrefix(24)
+	if _, err := api.SetConfig(config); err != nil {
+		t.Fatal(err)
+	}
+
+	neighbors := func() map[string]string {
+		req := gosnappi.NewStatesRequest()
+		req.Ipv4Neighbors()
+		resp, err := api.GetStates(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := map[string]string{}
+		for _, n := range resp.Ipv4Neighbors().Items() {
+			mac := ""
+			if n.HasLinkLayerAddress() {
+				mac = n.LinkLayerAddress()
+			}
+			got[n.EthernetName()+" "+n.Ipv4Address()] = mac
+		}
+		return got
+	}
+
+	// eth2's gateway 10.1.1.3 is not configured anywhere.
+	got := neighbors()
+	if got["eth1 10.1.1.2"] != "00:00:02:02:02:02" || got["eth2 10.1.1.3"] != "" || len(got) != 2 {
+		t.Errorf("neighbors got %v, want eth1 resolved to eth2's MAC and eth2 unresolved", got)
+	}
+
+	cs := gosnappi.NewControlState()
+	cs.Port().Link().SetPortNames([]string{"port2"}).SetState(gosnappi.StatePortLinkState.DOWN)
+	if _, err := api.SetControlState(cs); err != nil {
+		t.Fatal(err)
+	}
+	if got := neighbors(); got["eth1 10.1.1.2"] != "" {
+		t.Errorf("neighbors with port2 down got %v, want eth1 unresolved", got)
+	}
+}
+
+func TestPing(t *testing.T) {
+	_, api := startSim(t)
+
+	config := gosnappi.NewConfig()
+	config.Ports().Add().SetName("port1")
+	config.Ports().Add().SetName("port2")
+	eth1 := config.Devices().Add().SetName("dev1").Ethernets().Add().SetName("eth1").SetMac("00:00:01:01:01:01")
+	eth1.Connection().SetPortName("port1")
+	eth1.Ipv4Addresses().Add().SetName("ip1").SetAddress("10.1.1.1").SetGateway("10.1.1.2").SetPrefix(24)
+	eth2 := config.Devices().Add().SetName("dev2").Ethernets().Add().SetName("eth2").SetMac("00:00:02:02:02:02")
+	eth2.Connection().SetPortName("port2")
+	eth2.Ipv4Addresses().Add().SetName("ip2").SetAddress("10.1.1.2").SetGateway("10.1.1.1").SetPrefix(24)
+	if _, err := api.SetConfig(config); err != nil {
+		t.Fatal(err)
+	}
+
+	ping := func() map[string]bool {
+		action := gosnappi.NewControlAction()
+		reqs := action.Protocol().Ipv4().Ping().Requests()
+		reqs.Add().SetSrcName("ip1").SetDstIp("10.1.1.2")
+		reqs.Add().SetSrcName("ip1").SetDstIp("10.1.1.3")
+		resp, err := api.SetControlAction(action)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := map[string]bool{}
+		for _, r := range resp.Response().Protocol().Ipv4().Ping().Responses().Items() {
+			got[r.SrcName()+" "+r.DstIp()] = r.Result() == gosnappi.ActionResponseProtocolIpv4PingResponseResult.SUCCEEDED
+		}
+		return got
+	}
+
+	// 10.1.1.3 is not configured anywhere.
+	got := ping()
+	if !got["ip1 10.1.1.2"] || got["ip1 10.1.1.3"] || len(got) != 2 {
+		t.Errorf("ping got %v, want only 10.1.1.2 answered", got)
+	}
+
+	cs := gosnappi.NewControlState()
+	cs.Port().Link().SetPortNames([]string{"port2"}).SetState(gosnappi.StatePortLinkState.DOWN)
+	if _, err := api.SetControlState(cs); err != nil {
+		t.Fatal(err)
+	}
+	if got := ping(); got["ip1 10.1.1.2"] {
+		t.Errorf("ping with port2 down got %v, want 10.1.1.2 unanswered", got)
+	}
+}
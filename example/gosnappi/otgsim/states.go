@@ -0,0 +1,120 @@
+package otgsim
+
+import (
+	"context"
+	"net"
+	"time"
+
+	"github.com/open-traffic-generator/snappi/gosnappi"
+	"github.com/open-traffic-generator/snappi/gosnappi/otg"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// hostAddr is an IP address configured on an ethernet interface.
+type hostAddr struct {
+	ip  net.IP
+	mac string
+	// port or LAG the ethernet is connected to.
+	port string
+}
+
+// addresses returns the IPv4 or IPv6 addresses configured on all devices.
+func (s *Server) addresses(ipv6 bool) []hostAddr {
+	addrs := []hostAddr{}
+	for _, d := range s.config.Devices().Items() {
+		for _, eth := range d.Ethernets().Items() {
+			ips := []string{}
+			if ipv6 {
+				for _, ip := range eth.Ipv6Addresses().Items() {
+					ips = append(ips, ip.Address())
+				}
+			} else {
+				for _, ip := range eth.Ipv4Addresses().Items() {
+					ips = append(ips, ip.Address())
+				}
+			}
+			for _, ip := range ips {
+				addrs = append(addrs, hostAddr{ip: net.ParseIP(ip), mac: eth.Mac(), port: s.ethPort(eth)})
+			}
+		}
+	}
+	return addrs
+}
+
+// resolve returns the MAC address of gateway as seen from port, empty if no
+// interface owns the gateway address or either port or LAG is down. All test
+// ports are taken to be on the same segment through the DUT.
+func (s *Server) resolve(port, gateway string, ipv6 bool, now time.Time) string {
+	gw := net.ParseIP(gateway)
+	if gw == nil || !s.endpointUp(port, now) {
+		return ""
+	}
+	for _, addr := range s.addresses(ipv6) {
+		if addr.ip.Equal(gw) && s.endpointUp(addr.port, now) {
+			return addr.mac
+		}
+	}
+	return ""
+}
+
+// neighbors adds the gateway of every IP interface on the named ethernets, or
+// on all ethernets, to resp.
+func (s *Server) neighbors(names []string, ipv6 bool, resp gosnappi.StatesResponse, now time.Time) {
+	wanted := map[string]bool{}
+	for _, name := range names {
+		wanted[name] = true
+	}
+	for _, d := range s.config.Devices().Items() {
+		for _, eth := range d.Ethernets().Items() {
+			if len(wanted) > 0 && !wanted[eth.Name()] {
+				continue
+			}
+			port := s.ethPort(eth)
+			if ipv6 {
+				for _, ip := range eth.Ipv6Addresses().Items() {
+					n := resp.Ipv6Neighbors().Add().SetEthernetName(eth.Name()).SetIpv6Address(ip.Gateway())
+					if mac := s.resolve(port, ip.Gateway(), true, now); mac != "" {
+						n.SetLinkLayerAddress(mac)
+					}
+				}
+			} else {
+				for _, ip := range eth.Ipv4Addresses().Items() {
+					n := resp.Ipv4Neighbors().Add().SetEthernetName(eth.Name()).SetIpv4Address(ip.Gateway())
+					if mac := s.resolve(port, ip.Gateway(), false, now); mac != "" {
+						n.SetLinkLayerAddress(mac)
+					}
+				}
+			}
+		}
+	}
+}
+
+func (s *Server) GetStates(ctx context.Context, req *otg.GetStatesRequest) (*otg.GetStatesResponse, error) {
+	sr, err := gosnappi.NewStatesRequest().Unmarshal().FromProto(req.StatesRequest)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	resp := gosnappi.NewStatesResponse()
+	switch sr.Choice() {
+	case gosnappi.StatesRequestChoice.IPV4_NEIGHBORS:
+		s.neighbors(sr.Ipv4Neighbors().EthernetNames(), false, resp, s.now())
+	case gosnappi.StatesRequestChoice.IPV6_NEIGHBORS:
+		s.neighbors(sr.Ipv6Neighbors().EthernetNames(), true, resp, s.now())
+	case gosnappi.StatesRequestChoice.BGP_PREFIXES:
+		if err := s.bgpPrefixes(sr.BgpPrefixes(), resp, s.now()); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, status.Errorf(codes.Unimplemented, "%s states are not simulated", sr.Choice())
+	}
+
+	msg, err := resp.Marshal().ToProto()
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return &otg.GetStatesResponse{StatesResponse: msg}, nil
+}
@@ -0,0 +1,275 @@
+package otgsim
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"net"
+	"strings"
+
+	"github.com/open-traffic-generator/snappi/gosnappi"
+	"github.com/open-traffic-generator/snappi/gosnappi/otg"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// maxTagPeriod bounds the frames enumerated to count the frames of each
+// combination of tag values. Flows whose tag values repeat after more frames
+// are counted as if they repeated after this many.
+const maxTagPeriod = 1 << 16
+
+// metricTag is a metric tag of a flow header field, with the tag value of
+// each frame in the order the field takes them.
+type metricTag struct {
+	name   string
+	values []*big.Int
+}
+
+// tagRow is a combination of tag values and the share of the frames of a
+// flow that carry it.
+type tagRow struct {
+	values []*big.Int
+	// frames counts the frames with the values among the first period frames.
+	frames uint64
+}
+
+// tagged holds the metric tags of a flow and the combinations of their
+// values, in the order the flow first sends them.
+type tagged struct {
+	tags   []metricTag
+	rows   []tagRow
+	period uint64
+}
+
+// newTagged returns the metric tags of the header fields of a flow, nil if it
+// has none.
+func newTagged(cfg *otg.Flow) *tagged {
+	// The width of a field, needed to take the tagged bits, is the length
+	// gosnappi fills in for a tag without offset and length.
+	bare := proto.Clone(cfg).(*otg.Flow)
+	for _, p := range patterns(bare) {
+		forTags(p, func(tag protoreflect.Message) {
+			tag.Clear(tag.Descriptor().Fields().ByName("offset"))
+			tag.Clear(tag.Descriptor().Fields().ByName("length"))
+		})
+	}
+	var widths []protoreflect.Message
+	if f, err := gosnappi.NewFlow().Unmarshal().FromProto(bare); err == nil {
+		if full, err := f.Marshal().ToProto(); err == nil {
+			widths = patterns(full)
+		}
+	}
+
+	t := &tagged{period: 1}
+	for i, p := range patterns(cfg) {
+		values := patternValues(p)
+		j := 0
+		forTags(p, func(tag protoreflect.Message) {
+			fields := tag.Descriptor().Fields()
+			width := uint64(32)
+			if i < len(widths) {
+				def := widths[i].Get(p.Descriptor().Fields().ByName("metric_tags")).List().Get(j).Message()
+				width = def.Get(fields.ByName("length")).Uint()
+			}
+			j++
+			offset := min(tag.Get(fields.ByName("offset")).Uint(), width)
+			length := width - offset
+			if tag.Has(fields.ByName("length")) {
+				length = min(tag.Get(fields.ByName("length")).Uint(), width-offset)
+			}
+			mt := metricTag{name: tag.Get(fields.ByName("name")).String()}
+			for _, v := range values {
+				// Decrements wrap around at the field width.
+				bits := new(big.Int).And(v, ones(width))
+				bits.Rsh(bits, uint(width-offset-length))
+				mt.values = append(mt.values, bits.And(bits, ones(length)))
+			}
+			t.tags = append(t.tags, mt)
+			t.period = lcm(t.period, uint64(len(mt.values)))
+		})
+	}
+	if len(t.tags) == 0 {
+		return nil
+	}
+	t.period = min(t.period, maxTagPeriod)
+
+	index := map[string]int{}
+	for k := uint64(0); k < t.period; k++ {
+		values := []*big.Int{}
+		for _, tag := range t.tags {
+			values = append(values, tag.values[k%uint64(len(tag.values))])
+		}
+		key := fmt.Sprint(values)
+		i, ok := index[key]
+		if !ok {
+			i = len(t.rows)
+			index[key] = i
+			t.rows = append(t.rows, tagRow{values: values})
+		}
+		t.rows[i].frames++
+	}
+	return t
+}
+
+// frames returns how many of the first n frames of the flow carry the values
+// of each row.
+func (t *tagged) frames(n float64) []uint64 {
+	total := uint64(math.Round(n))
+	full, rest := total/t.period, total%t.period
+	counts := make([]uint64, len(t.rows))
+	for i, r := range t.rows {
+		counts[i] = full * r.frames
+	}
+	// The first rest frames of a period, by row.
+	index := map[string]int{}
+	for i, r := range t.rows {
+		index[fmt.Sprint(r.values)] = i
+	}
+	for k := uint64(0); k < rest; k++ {
+		values := []*big.Int{}
+		for _, tag := range t.tags {
+			values = append(values, tag.values[k%uint64(len(tag.values))])
+		}
+		counts[index[fmt.Sprint(values)]]++
+	}
+	return counts
+}
+
+// patterns returns the header field patterns of a flow with metric tags, in
+// packet and field order.
+func patterns(cfg *otg.Flow) []protoreflect.Message {
+	var found []protoreflect.Message
+	var walk func(m protoreflect.Message)
+	walk = func(m protoreflect.Message) {
+		fields := m.Descriptor().Fields()
+		if fd := fields.ByName("metric_tags"); fd != nil && m.Get(fd).List().Len() > 0 {
+			found = append(found, m)
+			return
+		}
+		for i := 0; i < fields.Len(); i++ {
+			fd := fields.Get(i)
+			if fd.Message() == nil || fd.IsMap() || fd.IsList() || !m.Has(fd) {
+				continue
+			}
+			walk(m.Get(fd).Message())
+		}
+	}
+	for _, h := range cfg.GetPacket() {
+		walk(h.ProtoReflect())
+	}
+	return found
+}
+
+// forTags calls fn with each metric tag of pattern p.
+func forTags(p protoreflect.Message, fn func(tag protoreflect.Message)) {
+	tags := p.Get(p.Descriptor().Fields().ByName("metric_tags")).List()
+	for i := 0; i < tags.Len(); i++ {
+		fn(tags.Get(i).Message())
+	}
+}
+
+// patternValues returns the values a header field pattern takes, in the order
+// frames carry them. Fields the service fills in, like auto, count as a single
+// value of 0.
+func patternValues(p protoreflect.Message) []*big.Int {
+	fields := p.Descriptor().Fields()
+	choice := ""
+	if fd := fields.ByName("choice"); fd != nil && p.Has(fd) {
+		choice = string(fd.Enum().Values().ByNumber(p.Get(fd).Enum()).Name())
+	}
+	switch choice {
+	case "value":
+		return []*big.Int{toInt(p.Get(fields.ByName("value")))}
+	case "values":
+		values := []*big.Int{}
+		list := p.Get(fields.ByName("values")).List()
+		for i := 0; i < list.Len(); i++ {
+			values = append(values, toInt(list.Get(i)))
+		}
+		if len(values) > 0 {
+			return values
+		}
+	case "increment", "decrement":
+		c := p.Get(fields.ByName(protoreflect.Name(choice))).Message()
+		cf := c.Descriptor().Fields()
+		start, step := toInt(c.Get(cf.ByName("start"))), toInt(c.Get(cf.ByName("step")))
+		if choice == "decrement" {
+			step.Neg(step)
+		}
+		count := c.Get(cf.ByName("count")).Uint()
+		values := []*big.Int{}
+		for k := uint64(0); k < max(count, 1); k++ {
+			v := new(big.Int).Mul(step, new(big.Int).SetUint64(k))
+			values = append(values, v.Add(v, start))
+		}
+		return values
+	}
+	return []*big.Int{new(big.Int)}
+}
+
+// toInt returns a numeric, IP address, MAC address or hex field value as an
+// integer, 0 for other strings.
+func toInt(v protoreflect.Value) *big.Int {
+	s, ok := v.Interface().(string)
+	if !ok {
+		return new(big.Int).SetUint64(v.Uint())
+	}
+	if ip := net.ParseIP(s); ip != nil {
+		if v4 := ip.To4(); v4 != nil && !strings.Contains(s, ":") {
+			ip = v4
+		}
+		return new(big.Int).SetBytes(ip)
+	}
+	if mac, err := net.ParseMAC(s); err == nil {
+		return new(big.Int).SetBytes(mac)
+	}
+	n, ok := new(big.Int).SetString(strings.TrimPrefix(strings.ToLower(s), "0x"), 16)
+	if !ok || n.Sign() < 0 {
+		return new(big.Int)
+	}
+	return n
+}
+
+// ones returns the integer of n one bits.
+func ones(n uint64) *big.Int {
+	return new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), uint(n)), big.NewInt(1))
+}
+
+func lcm(a, b uint64) uint64 {
+	if a == 0 || b == 0 {
+		return max(a, b)
+	}
+	x, y := a, b
+	for y != 0 {
+		x, y = y, x%y
+	}
+	if a/x > maxTagPeriod/b+1 {
+		return maxTagPeriod + 1
+	}
+	return a / x * b
+}
+
+// taggedMetrics adds the tagged metrics of flow f to m, leaving out the
+// combinations of tag values no frame was sent with unless includeEmpty is
+// set.
+func (f *flow) taggedMetrics(m gosnappi.FlowMetric, includeEmpty bool, txRate, rxRate float64) {
+	t := f.tags
+	tx, rx := t.frames(f.txFrames), t.frames(f.rxFrames)
+	for i, r := range t.rows {
+		if tx[i] == 0 && !includeEmpty {
+			continue
+		}
+		tm := m.TaggedMetrics().Add()
+		for j, tag := range t.tags {
+			tm.Tags().Add().SetName(tag.name).Value().SetHex("0x" + r.values[j].Text(16))
+		}
+		share := float64(r.frames) / float64(t.period)
+		tm.SetFramesTx(tx[i]).SetFramesRx(rx[i]).
+			SetBytesTx(uint64(math.Round(float64(tx[i]) * f.size))).
+			SetBytesRx(uint64(math.Round(float64(rx[i]) * f.size))).
+			SetFramesTxRate(float32(txRate * share)).SetFramesRxRate(float32(rxRate * share))
+		if tx[i] > 0 {
+			tm.SetLoss(float32(float64(tx[i]-min(tx[i], rx[i])) * 100 / float64(tx[i])))
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package otgsim
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/open-traffic-generator/snappi/gosnappi"
+)
+
+func TestTaggedBits(t *testing.T) {
+	flow := gosnappi.NewFlow().SetName("flow1")
+	flow.TxRx().Port().SetTxName("port1")
+	flow.Packet().Add().Ethernet()
+	v4 := flow.Packet().Add().Ipv4()
+	// The last 3 bits of 200.1.1.6 to 200.1.1.10.
+	v4.Dst().Increment().SetStart("200.1.1.6").SetCount(5)
+	v4.Dst().MetricTags().Add().SetName("dst").SetOffset(29).SetLength(3)
+	// A 16-bit port decrementing from 0 wraps to 65535.
+	udp := flow.Packet().Add().Udp()
+	udp.DstPort().Decrement().SetStart(0).SetCount(2)
+	udp.DstPort().MetricTags().Add().SetName("port")
+	msg, err := flow.Marshal().ToProto()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tags := newTagged(msg)
+	if tags == nil || len(tags.tags) != 2 {
+		t.Fatalf("newTagged() got %+v, want 2 tags", tags)
+	}
+	if got := fmt.Sprint(tags.tags[0].values); got != "[6 7 0 1 2]" {
+		t.Errorf("newTagged() got dst values %s", got)
+	}
+	if got := fmt.Sprint(tags.tags[1].values); got != "[0 65535]" {
+		t.Errorf("newTagged() got port values %s", got)
+	}
+	// The combinations repeat every 10 frames; 13 frames are a period and
+	// the first 3 again.
+	if got := fmt.Sprint(tags.frames(13)); tags.period != 10 || got != "[2 2 2 1 1 1 1 1 1 1]" {
+		t.Errorf("frames(13) got %s with period %d", got, tags.period)
+	}
+}
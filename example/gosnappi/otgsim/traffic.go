@@ -0,0 +1,516 @@
+package otgsim
+
+import (
+	"math"
+	"time"
+
+	"github.com/SpirentOrion/stc-otg/example/gosnappi/framesize"
+	"github.com/SpirentOrion/stc-otg/example/gosnappi/linerate"
+	"github.com/open-traffic-generator/snappi/gosnappi"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// defaultSpeed is the line rate of ports without a layer1 speed.
+const defaultSpeed = 10e9
+
+// speedBps returns the line rate of a layer1 speed in bits per second.
+func speedBps(speed gosnappi.Layer1SpeedEnum) float64 {
+	if s := linerate.Speed(speed); s > 0 {
+		return s
+	}
+	return defaultSpeed
+}
+
+// port is the simulated state of a test port.
+type port struct {
+	name     string
+	location string
+	// enabled is the link state set through the control state. The link is
+	// up if the port and its back-to-back peer are enabled.
+	enabled bool
+	// since is when the link last went up or down.
+	since time.Time
+	// speed is the line rate in bits per second.
+	speed float64
+}
+
+// flow is the simulated state of a traffic flow. Counters are kept as floats
+// and advanced by updateFlows.
+type flow struct {
+	name string
+	// txPort and rxPorts are port or LAG names.
+	txPort  string
+	rxPorts []string
+	// rxRoutes holds the route range names among the rx names of a device
+	// flow, by rx port, and txDevice the device that has to learn them for
+	// the frames to be forwarded.
+	rxRoutes []string
+	txDevice string
+	// hash picks the LAG member the frames of the flow are sent on, unless
+	// spread is set because they vary in the hashed fields.
+	hash   uint32
+	spread bool
+	// size is the average frame size in bytes, pps the frame rate.
+	size float64
+	pps  float64
+	// limit is the number of frames the flow sends before it stops on its
+	// own, 0 for continuous flows.
+	limit      float64
+	latency    bool
+	timestamps bool
+	// tags are the metric tags of the header fields, nil without any.
+	tags *tagged
+	// priority is the VLAN priority of the frames, pause the pause times
+	// of flows of PFC or Ethernet PAUSE frames, nil for other flows.
+	priority int
+	pause    *pauseTimes
+	// oversize marks flows of frames too large for the MTU of their ports.
+	oversize bool
+
+	running  bool
+	last     time.Time
+	first    time.Time
+	lastRx   time.Time
+	txFrames float64
+	rxFrames float64
+	// txShare and rxShare are the fractions of the frames sent and received
+	// on each port, portTx and portRx the frames so far.
+	txShare map[string]float64
+	rxShare map[string]float64
+	portTx  map[string]float64
+	portRx  map[string]float64
+	// queued is the number of frames held in the DUT buffer.
+	queued float64
+	// latency statistics in nanoseconds, the sum weighted by rx frames.
+	minLatency float64
+	maxLatency float64
+	sumLatency float64
+}
+
+func newFlow(s *Server, cfg gosnappi.Flow) *flow {
+	f := &flow{name: cfg.Name(), portTx: map[string]float64{}, portRx: map[string]float64{}}
+	if msg, err := cfg.Marshal().ToProto(); err == nil {
+		f.hash, f.spread = flowHash(msg)
+		f.tags = newTagged(msg)
+		f.priority, f.pause = flowPriority(msg), newPause(msg)
+	}
+	switch cfg.TxRx().Choice() {
+	case gosnappi.FlowTxRxChoice.PORT:
+		f.txPort = cfg.TxRx().Port().TxName()
+		f.rxPorts = cfg.TxRx().Port().RxNames()
+		if len(f.rxPorts) == 0 && cfg.TxRx().Port().HasRxName() {
+			f.rxPorts = []string{cfg.TxRx().Port().RxName()}
+		}
+	case gosnappi.FlowTxRxChoice.DEVICE:
+		if names := cfg.TxRx().Device().TxNames(); len(names) > 0 {
+			f.txPort = s.portOf(names[0])
+			f.txDevice = s.deviceOf(names[0])
+		}
+		for _, name := range cfg.TxRx().Device().RxNames() {
+			if p := s.portOf(name); p != "" {
+				f.rxPorts = append(f.rxPorts, p)
+				route := ""
+				if _, ok := s.routes[name]; ok {
+					route = name
+				}
+				f.rxRoutes = append(f.rxRoutes, route)
+			}
+		}
+	}
+
+	f.size = frameSize(cfg.Size())
+	if cfg.Size().Choice() == gosnappi.FlowSizeChoice.FIXED {
+		f.oversize = s.oversize(f, cfg.Packet())
+	}
+	// FlowPps knows the frame sizes of every flow a valid config holds.
+	f.pps, _ = linerate.FlowPps(cfg, s.speed(f.txPort))
+
+	d := cfg.Duration()
+	switch d.Choice() {
+	case gosnappi.FlowDurationChoice.FIXED_PACKETS:
+		f.limit = float64(d.FixedPackets().Packets())
+	case gosnappi.FlowDurationChoice.FIXED_SECONDS:
+		f.limit = math.Round(f.pps * float64(d.FixedSeconds().Seconds()))
+	case gosnappi.FlowDurationChoice.BURST:
+		f.limit = float64(d.Burst().Bursts()) * float64(d.Burst().Packets())
+	}
+
+	if cfg.HasMetrics() {
+		f.latency = cfg.Metrics().HasLatency() && cfg.Metrics().Latency().Enable()
+		f.timestamps = cfg.Metrics().Timestamps()
+	}
+	return f
+}
+
+// frameSize returns the average frame size of a flow size choice.
+func frameSize(size gosnappi.FlowSize) float64 {
+	p, err := framesize.FromFlowSize(size)
+	if err != nil {
+		return float64(size.Fixed())
+	}
+	return p.Average()
+}
+
+// portOf returns the port or LAG a device, ethernet, IP interface or route
+// range name transmits and receives on.
+func (s *Server) portOf(name string) string {
+	for _, d := range s.config.Devices().Items() {
+		names := map[string]bool{d.Name(): true}
+		if d.HasIsis() {
+			for _, r := range d.Isis().V4Routes().Items() {
+				names[r.Name()] = true
+			}
+			for _, r := range d.Isis().V6Routes().Items() {
+				names[r.Name()] = true
+			}
+		}
+		if d.HasBgp() {
+			for _, intf := range d.Bgp().Ipv4Interfaces().Items() {
+				for _, peer := range intf.Peers().Items() {
+					for _, r := range peer.V4Routes().Items() {
+						names[r.Name()] = true
+					}
+					for _, r := range peer.V6Routes().Items() {
+						names[r.Name()] = true
+					}
+				}
+			}
+		}
+		for _, eth := range d.Ethernets().Items() {
+			match := names[name] || eth.Name() == name
+			for _, ip := range eth.Ipv4Addresses().Items() {
+				match = match || ip.Name() == name
+			}
+			for _, ip := range eth.Ipv6Addresses().Items() {
+				match = match || ip.Name() == name
+			}
+			if match {
+				return s.ethPort(eth)
+			}
+		}
+	}
+	return ""
+}
+
+// deviceOf returns the device a device, ethernet, IP interface or route range
+// name belongs to.
+func (s *Server) deviceOf(name string) string {
+	if r, ok := s.routes[name]; ok {
+		return r.device
+	}
+	for _, d := range s.config.Devices().Items() {
+		match := d.Name() == name
+		for _, eth := range d.Ethernets().Items() {
+			match = match || eth.Name() == name
+			for _, ip := range eth.Ipv4Addresses().Items() {
+				match = match || ip.Name() == name
+			}
+			for _, ip := range eth.Ipv6Addresses().Items() {
+				match = match || ip.Name() == name
+			}
+		}
+		if match {
+			return d.Name()
+		}
+	}
+	return ""
+}
+
+// ethPort returns the port or LAG an ethernet interface is connected to.
+func (s *Server) ethPort(eth gosnappi.DeviceEthernet) string {
+	conn := eth.Connection()
+	if conn.Choice() == gosnappi.EthernetConnectionChoice.LAG_NAME {
+		return conn.LagName()
+	}
+	return conn.PortName()
+}
+
+// start starts transmitting. Unless resume is set, the counters are cleared
+// first, so a fixed-size flow is sent again from scratch.
+func (f *flow) start(now time.Time, resume bool) {
+	if f.running {
+		return
+	}
+	if !resume || f.first.IsZero() {
+		f.first = now
+		f.txFrames, f.rxFrames, f.queued = 0, 0, 0
+		f.portTx, f.portRx = map[string]float64{}, map[string]float64{}
+		f.minLatency, f.maxLatency, f.sumLatency = 0, 0, 0
+	}
+	f.running = true
+	f.last = now
+}
+
+func (f *flow) stop() {
+	f.running = false
+}
+
+// link returns the tx port of f as a link sending its frames.
+func (s *Server) link(f *flow) linerate.Link {
+	return linerate.Link{Speed: s.speed(f.txPort), FrameSize: f.size}
+}
+
+// portLoad returns the offered load on every tx port, in percent of its line
+// rate.
+func (s *Server) portLoad() map[string]float64 {
+	load := map[string]float64{}
+	for _, f := range s.flows {
+		if f.running {
+			load[f.txPort] += s.link(f).Percent(f.pps)
+		}
+	}
+	return load
+}
+
+// updateFlows advances the counters of all running flows to now, using the
+// link states and load in effect since the last update.
+func (s *Server) updateFlows(now time.Time) {
+	load := s.portLoad()
+	learned := s.learnedRoutes(now)
+	for _, f := range s.flows {
+		f.txShare, f.rxShare = s.shares(f, learned[f.txDevice], now)
+		if !f.running {
+			if f.queued > 0 {
+				elapsed := now.Sub(f.last).Seconds()
+				f.last = now
+				f.receive(s.drain(f, elapsed, load[f.txPort]), now)
+			}
+			continue
+		}
+		elapsed := now.Sub(f.last).Seconds()
+		f.last = now
+		tx := f.pps * elapsed
+		// idle is the part of elapsed after a fixed-size flow finished, when
+		// only the DUT buffer drains.
+		idle := 0.0
+		if f.limit > 0 && f.txFrames+tx >= f.limit {
+			tx = f.limit - f.txFrames
+			idle = elapsed - tx/f.pps
+			f.running = false
+		}
+		if tx <= 0 {
+			continue
+		}
+		f.send(tx)
+		fwd := s.delivered(f, load[f.txPort])
+		rx := tx * fwd
+		switch {
+		case fwd > 0 && fwd < 1:
+			// Overloaded: the excess goes to the buffer as long as it fits.
+			f.queued += math.Min(tx-rx, math.Max(s.Dut.Buffer-f.queued, 0))
+		case fwd == 1 && f.queued > 0:
+			rx += s.drain(f, elapsed, load[f.txPort])
+		}
+		if rx > 0 {
+			f.receive(rx, now)
+			if f.latency {
+				lo, avg, hi := s.latency(f, load[f.txPort])
+				f.account(rx, lo, avg, hi)
+			}
+		}
+		if idle > 0 && f.queued > 0 {
+			own := s.link(f).Percent(f.pps)
+			f.receive(s.drain(f, idle, load[f.txPort]-own), now)
+		}
+	}
+}
+
+// send counts n transmitted frames of f.
+func (f *flow) send(n float64) {
+	f.txFrames += n
+	for p, share := range f.txShare {
+		f.portTx[p] += n * share
+	}
+}
+
+// receive counts n frames of f received at now.
+func (f *flow) receive(n float64, now time.Time) {
+	if n <= 0 {
+		return
+	}
+	f.rxFrames += n
+	f.lastRx = now
+	for p, share := range f.rxShare {
+		f.portRx[p] += n * share
+	}
+}
+
+// delivered returns the fraction of the frames of f the DUT forwards at the
+// given load of its tx port or LAG. It consumes pause frames, drops frames
+// above the MTU of their ports and holds back the frames of priorities paused
+// on their rx port.
+func (s *Server) delivered(f *flow, load float64) float64 {
+	txUp, rxUp := false, false
+	for name := range f.txShare {
+		txUp = txUp || s.linkUp(name)
+	}
+	for name := range f.rxShare {
+		rxUp = rxUp || s.linkUp(name)
+	}
+	if !txUp || !rxUp || f.pause != nil || f.oversize {
+		return 0
+	}
+	maxRate := s.Dut.MaxRate
+	if maxRate == 0 {
+		maxRate = 100
+	}
+	fwd := 1 - s.paused(f)
+	if load > maxRate {
+		return fwd * maxRate / load
+	}
+	return fwd
+}
+
+// drain forwards frames of f queued in the DUT buffer for elapsed seconds at
+// the capacity the given load of its tx port leaves spare, and returns how
+// many.
+func (s *Server) drain(f *flow, elapsed, load float64) float64 {
+	maxRate := s.Dut.MaxRate
+	if maxRate == 0 {
+		maxRate = 100
+	}
+	spare := s.link(f).Pps(maxRate - load)
+	n := math.Min(f.queued, math.Max(spare*elapsed, 0))
+	f.queued -= n
+	return n
+}
+
+// latency returns the minimum, average and maximum latency in nanoseconds of
+// the frames of f at the given load of its tx port: the DUT latency plus the
+// store-and-forward serialization delay, plus load-dependent queueing.
+func (s *Server) latency(f *flow, load float64) (lo, avg, hi float64) {
+	speed := s.speed(f.txPort)
+	if l, ok := s.lags[f.txPort]; ok && len(l.members) > 0 {
+		// Frames are serialized at the speed of a single member.
+		speed = s.speed(l.members[0].port)
+	}
+	lo = float64(s.Dut.Latency.Nanoseconds()) + f.size*8/speed*1e9
+	queue := float64(s.Dut.Jitter.Nanoseconds()) * math.Min(load, 100) / 100
+	return lo, lo + queue/2, lo + queue
+}
+
+// account adds rx frames with the given latencies to the statistics.
+func (f *flow) account(rx float64, lo, avg, hi float64) {
+	if f.sumLatency == 0 || lo < f.minLatency {
+		f.minLatency = lo
+	}
+	if hi > f.maxLatency {
+		f.maxLatency = hi
+	}
+	f.sumLatency += avg * rx
+}
+
+// flowMetrics adds the metrics of the named flows, or of all flows, to resp,
+// with the tagged metrics unless the tagged filter excludes them.
+func (s *Server) flowMetrics(names []string, tagged gosnappi.FlowTaggedMetricsFilter, resp gosnappi.MetricsResponse) error {
+	if len(names) == 0 {
+		for _, f := range s.config.Flows().Items() {
+			names = append(names, f.Name())
+		}
+	}
+	load := s.portLoad()
+	for _, name := range names {
+		f, ok := s.flows[name]
+		if !ok {
+			return status.Errorf(codes.InvalidArgument, "flow %s is not configured", name)
+		}
+		m := resp.FlowMetrics().Add().SetName(f.name).SetPortTx(f.txPort)
+		if len(f.rxPorts) > 0 {
+			m.SetPortRx(f.rxPorts[0])
+		}
+		txRate, rxRate := 0.0, 0.0
+		if f.running {
+			m.SetTransmit(gosnappi.FlowMetricTransmit.STARTED)
+			txRate, rxRate = f.pps, f.pps*s.delivered(f, load[f.txPort])
+			m.SetFramesTxRate(float32(txRate)).SetFramesRxRate(float32(rxRate))
+		} else {
+			m.SetTransmit(gosnappi.FlowMetricTransmit.STOPPED)
+		}
+		tx, rx := uint64(math.Round(f.txFrames)), uint64(math.Round(f.rxFrames))
+		m.SetFramesTx(tx).SetFramesRx(rx)
+		m.SetBytesTx(uint64(math.Round(f.txFrames * f.size))).SetBytesRx(uint64(math.Round(f.rxFrames * f.size)))
+		if tx > 0 {
+			m.SetLoss(float32(float64(tx-min(tx, rx)) * 100 / float64(tx)))
+		}
+		if f.latency && f.rxFrames > 0 {
+			m.Latency().
+				SetMinimumNs(f.minLatency).
+				SetAverageNs(f.sumLatency / f.rxFrames).
+				SetMaximumNs(f.maxLatency)
+		}
+		if f.timestamps && f.rxFrames > 0 {
+			m.Timestamps().
+				SetFirstTimestampNs(float64(f.first.Sub(s.start).Nanoseconds())).
+				SetLastTimestampNs(float64(f.lastRx.Sub(s.start).Nanoseconds()))
+		}
+		if f.tags != nil && (tagged == nil || tagged.Include()) {
+			f.taggedMetrics(m, tagged != nil && tagged.IncludeEmptyMetrics(), txRate, rxRate)
+		}
+	}
+	return nil
+}
+
+// counters are the traffic counters of a port.
+type counters struct {
+	txFrames, rxFrames, txBytes, rxBytes float64
+	// transmitting is set while a flow sends on the port.
+	transmitting bool
+}
+
+func (c *counters) add(o counters) {
+	c.txFrames += o.txFrames
+	c.rxFrames += o.rxFrames
+	c.txBytes += o.txBytes
+	c.rxBytes += o.rxBytes
+	c.transmitting = c.transmitting || o.transmitting
+}
+
+// portCounters sums the frames the flows sent and received on the named port.
+func (s *Server) portCounters(name string) counters {
+	var c counters
+	for _, f := range s.flows {
+		c.txFrames += f.portTx[name]
+		c.txBytes += f.portTx[name] * f.size
+		c.rxFrames += f.portRx[name]
+		c.rxBytes += f.portRx[name] * f.size
+		if f.running && f.txShare[name] > 0 {
+			c.transmitting = true
+		}
+	}
+	return c
+}
+
+// portMetrics adds the metrics of the named ports, or of all ports, to resp.
+func (s *Server) portMetrics(names []string, resp gosnappi.MetricsResponse) error {
+	if len(names) == 0 {
+		for _, p := range s.config.Ports().Items() {
+			names = append(names, p.Name())
+		}
+	}
+	for _, name := range names {
+		p, ok := s.ports[name]
+		if !ok {
+			return status.Errorf(codes.InvalidArgument, "port %s is not configured", name)
+		}
+		c := s.portCounters(name)
+		transmit := gosnappi.PortMetricTransmit.STOPPED
+		if c.transmitting {
+			transmit = gosnappi.PortMetricTransmit.STARTED
+		}
+		link := gosnappi.PortMetricLink.DOWN
+		if s.linkUp(name) {
+			link = gosnappi.PortMetricLink.UP
+		}
+		resp.PortMetrics().Add().
+			SetName(p.name).
+			SetLocation(p.location).
+			SetLink(link).
+			SetTransmit(transmit).
+			SetFramesTx(uint64(math.Round(c.txFrames))).
+			SetFramesRx(uint64(math.Round(c.rxFrames))).
+			SetBytesTx(uint64(math.Round(c.txBytes))).
+			SetBytesRx(uint64(math.Round(c.rxBytes)))
+	}
+	return nil
+}
@@ -0,0 +1,34 @@
+// Package otgsimtest starts the simulated OTG service of package otgsim for
+// the tests of the packages that run against it.
+package otgsimtest
+
+import (
+	"testing"
+
+	"github.com/SpirentOrion/stc-otg/example/gosnappi/otgsim"
+	"github.com/open-traffic-generator/snappi/gosnappi"
+)
+
+// TimeScale is how many times faster than wall clock time simulated time
+// runs in tests: a flow of 1000 frames at 1000 pps takes 1ms.
+const TimeScale = 1000
+
+// Start starts a simulated OTG service on a free local port, stopped when
+// the test ends, and returns it with an API handle connected to it.
+func Start(t testing.TB) (*otgsim.Server, gosnappi.Api) {
+	t.Helper()
+	return StartDut(t, otgsim.Dut{})
+}
+
+// StartDut is Start with flows crossing dut.
+func StartDut(t testing.TB, dut otgsim.Dut) (*otgsim.Server, gosnappi.Api) {
+	t.Helper()
+	sim := otgsim.New()
+	sim.Dut = dut
+	sim.TimeScale = TimeScale
+	if err := sim.Start("127.0.0.1:0"); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(sim.Stop)
+	return sim, sim.Api()
+}
@@ -0,0 +1,390 @@
+// Package pfc tests how a DUT responds to priority flow control (PFC) and
+// Ethernet PAUSE frames. A Test sends a data flow per VLAN priority through
+// the DUT, then pause frames from the rx port for the classes of a class
+// enable vector, and measures how much each priority's rx rate drops while
+// paused. Paused priorities are expected to drop by the share of the time
+// their pause frames cover, the others not at all.
+package pfc
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/SpirentOrion/stc-otg/example/gosnappi/linerate"
+	"github.com/open-traffic-generator/snappi/gosnappi"
+)
+
+// Quantum is the pause time unit of pause frames, in bit times.
+const Quantum = 512
+
+// MaxQuanta is the longest pause time a pause frame can ask for.
+const MaxQuanta = 0xffff
+
+// ClassEnableVector returns the class enable vector of PFC frames pausing
+// the given priorities, 0 to 7; others are left out.
+func ClassEnableVector(priorities ...int) uint32 {
+	cev := uint32(0)
+	for _, p := range priorities {
+		if validPriority(p) == nil {
+			cev |= 1 << p
+		}
+	}
+	return cev
+}
+
+// PauseShare returns the share of the time pause frames sent at pps, each
+// asking for quanta, keep a priority paused on a link of speed bits per
+// second; 1 or more pauses it for good.
+func PauseShare(pps, quanta, speed float64) float64 {
+	return pps * quanta * Quantum / speed
+}
+
+// RefreshPps returns the rate of pause frames of quanta that keeps a priority
+// paused for good on a link of speed bits per second.
+func RefreshPps(quanta, speed float64) float64 {
+	return speed / (quanta * Quantum)
+}
+
+// Pause is a flow of pause frames.
+type Pause struct {
+	// Priorities are the priorities paused by PFC frames. Ethernet PAUSE
+	// frames, which pause the link as a whole, are sent when empty.
+	Priorities []int
+	// Quanta are the pause times of the frames, taken in turn, MaxQuanta
+	// when empty.
+	Quanta []uint32
+	// Pps is the rate of the pause frames.
+	Pps uint64
+}
+
+// quanta returns the pause times of p.
+func (p Pause) quanta() []uint32 {
+	if len(p.Quanta) == 0 {
+		return []uint32{MaxQuanta}
+	}
+	return p.Quanta
+}
+
+// Mean returns the average pause time of the frames, in quanta.
+func (p Pause) Mean() float64 {
+	sum := 0.0
+	for _, q := range p.quanta() {
+		sum += float64(q)
+	}
+	return sum / float64(len(p.quanta()))
+}
+
+// Pauses reports whether the frames pause priority.
+func (p Pause) Pauses(priority int) bool {
+	if len(p.Priorities) == 0 {
+		return true
+	}
+	for _, q := range p.Priorities {
+		if q == priority {
+			return true
+		}
+	}
+	return false
+}
+
+// validPriority returns an error if priority is not a VLAN priority, 0 to 7.
+func validPriority(priority int) error {
+	if priority < 0 || priority > 7 {
+		return fmt.Errorf("priority %d is not in 0 to 7", priority)
+	}
+	return nil
+}
+
+// validate checks the priorities and pause times of p.
+func (p Pause) validate() error {
+	for _, priority := range p.Priorities {
+		if err := validPriority(priority); err != nil {
+			return fmt.Errorf("pause: %v", err)
+		}
+	}
+	for _, q := range p.Quanta {
+		if q > MaxQuanta {
+			return fmt.Errorf("pause: %d quanta exceed the maximum of %d", q, MaxQuanta)
+		}
+	}
+	return nil
+}
+
+// Add adds a continuous flow of the pause frames out of port to config.
+func (p Pause) Add(config gosnappi.Config, name, port string) (gosnappi.Flow, error) {
+	if err := p.validate(); err != nil {
+		return nil, err
+	}
+	flow := config.Flows().Add().SetName(name)
+	flow.TxRx().Port().SetTxName(port)
+	flow.Metrics().SetEnable(true)
+	flow.Rate().SetPps(p.Pps)
+	flow.Size().SetFixed(64)
+	flow.Duration().Continuous()
+	quanta := p.quanta()
+	if len(p.Priorities) == 0 {
+		setQuanta(flow.Packet().Add().Ethernetpause().Time(), quanta)
+		return flow, nil
+	}
+	pfc := flow.Packet().Add().Pfcpause()
+	pfc.ClassEnableVector().SetValue(ClassEnableVector(p.Priorities...))
+	for _, priority := range p.Priorities {
+		switch priority {
+		case 0:
+			setQuanta(pfc.PauseClass0(), quanta)
+		case 1:
+			setQuanta(pfc.PauseClass1(), quanta)
+		case 2:
+			setQuanta(pfc.PauseClass2(), quanta)
+		case 3:
+			setQuanta(pfc.PauseClass3(), quanta)
+		case 4:
+			setQuanta(pfc.PauseClass4(), quanta)
+		case 5:
+			setQuanta(pfc.PauseClass5(), quanta)
+		case 6:
+			setQuanta(pfc.PauseClass6(), quanta)
+		case 7:
+			setQuanta(pfc.PauseClass7(), quanta)
+		}
+	}
+	return flow, nil
+}
+
+// quantaPattern is a pause time field of a pause frame, e.g.
+// gosnappi.PatternFlowEthernetPauseTime or
+// gosnappi.PatternFlowPfcPausePauseClass0.
+type quantaPattern[P any] interface {
+	SetValue(value uint32) P
+	SetValues(value []uint32) P
+}
+
+// setQuanta sets a pause time field to quanta.
+func setQuanta[P quantaPattern[P]](pattern P, quanta []uint32) {
+	if len(quanta) == 1 {
+		pattern.SetValue(quanta[0])
+		return
+	}
+	pattern.SetValues(quanta)
+}
+
+// Test runs data flows at VLAN priorities from Tx to Rx, and pause frames
+// out of Rx.
+type Test struct {
+	Api gosnappi.Api
+	// Config holds the ports of the test; every run pushes a copy with the
+	// flows added.
+	Config gosnappi.Config
+	// Tx and Rx are the names of the ports the data flows are sent from and
+	// to; the pause frames are sent out of Rx into the DUT.
+	Tx string
+	Rx string
+	// Priorities are the VLAN priorities of the data flows, 0 to 7 when
+	// empty.
+	Priorities []int
+	// Percent is the rate of every data flow, in percent of the line rate
+	// of Tx; 10 when zero.
+	Percent float64
+	// FrameSize is the size of the data frames, 512 when zero.
+	FrameSize uint32
+	Pause     Pause
+	// Settle is how long the rates settle before they are measured, Measure
+	// how long they are averaged over.
+	Settle  time.Duration
+	Measure time.Duration
+}
+
+// PriorityResult is the pause response of the data flow of a priority.
+type PriorityResult struct {
+	Priority int
+	Paused   bool
+	// Baseline and Throttled are the rx rates before and while the pause
+	// frames are sent, in frames per second.
+	Baseline  float64
+	Throttled float64
+	// Want is the expected rx rate reduction, the share of the time the
+	// pause frames cover, 0 for priorities not paused.
+	Want float64
+}
+
+// Reduction is the share the rx rate dropped by while paused.
+func (r PriorityResult) Reduction() float64 {
+	if r.Baseline == 0 {
+		return 0
+	}
+	return 1 - r.Throttled/r.Baseline
+}
+
+// Result is the pause response of all priorities.
+type Result struct {
+	Pause      Pause
+	Priorities []PriorityResult
+}
+
+// flowName returns the name of the data flow of a priority.
+func flowName(priority int) string {
+	return fmt.Sprintf("pfc_data_p%d", priority)
+}
+
+// Run measures the rx rates of the data flows first alone, then with the
+// pause frames, and stops all traffic.
+func (t *Test) Run() (*Result, error) {
+	priorities := t.Priorities
+	if len(priorities) == 0 {
+		priorities = []int{0, 1, 2, 3, 4, 5, 6, 7}
+	}
+	percent, size := t.Percent, t.FrameSize
+	if percent == 0 {
+		percent = 10
+	}
+	if size == 0 {
+		size = 512
+	}
+	for _, p := range priorities {
+		if err := validPriority(p); err != nil {
+			return nil, fmt.Errorf("data flow: %v", err)
+		}
+	}
+
+	config, err := t.Config.Clone()
+	if err != nil {
+		return nil, err
+	}
+	names := []string{}
+	for _, p := range priorities {
+		flow := config.Flows().Add().SetName(flowName(p))
+		flow.TxRx().Port().SetTxName(t.Tx).SetRxNames([]string{t.Rx})
+		flow.Metrics().SetEnable(true)
+		flow.Rate().SetPercentage(float32(percent))
+		flow.Size().SetFixed(size)
+		flow.Duration().Continuous()
+		eth := flow.Packet().Add().Ethernet()
+		eth.Src().SetValue("00:11:22:33:44:66")
+		eth.Dst().SetValue("00:11:22:33:44:55")
+		flow.Packet().Add().Vlan().Priority().SetValue(uint32(p))
+		flow.Packet().Add().Ipv4()
+		names = append(names, flow.Name())
+	}
+	pause, err := t.Pause.Add(config, "pfc_pause", t.Rx)
+	if err != nil {
+		return nil, err
+	}
+	if err := linerate.Validate(config, 10e9); err != nil {
+		return nil, err
+	}
+	speed := linerate.PortSpeeds(config, 10e9)[t.Rx]
+
+	if _, err := t.Api.SetConfig(config); err != nil {
+		return nil, err
+	}
+	defer t.transmit(gosnappi.StateTrafficFlowTransmitState.STOP, nil)
+	if err := t.transmit(gosnappi.StateTrafficFlowTransmitState.START, names); err != nil {
+		return nil, err
+	}
+	baseline, err := t.rates(names)
+	if err != nil {
+		return nil, err
+	}
+	if err := t.transmit(gosnappi.StateTrafficFlowTransmitState.START, []string{pause.Name()}); err != nil {
+		return nil, err
+	}
+	throttled, err := t.rates(names)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &Result{Pause: t.Pause}
+	share := min(PauseShare(float64(t.Pause.Pps), t.Pause.Mean(), speed), 1)
+	for i, p := range priorities {
+		r := PriorityResult{Priority: p, Paused: t.Pause.Pauses(p), Baseline: baseline[i], Throttled: throttled[i]}
+		if r.Paused {
+			r.Want = share
+		}
+		res.Priorities = append(res.Priorities, r)
+	}
+	return res, nil
+}
+
+// transmit sets the transmit state of the named flows, all when nil.
+func (t *Test) transmit(state gosnappi.StateTrafficFlowTransmitStateEnum, flows []string) error {
+	cs := gosnappi.NewControlState()
+	cs.Traffic().FlowTransmit().SetState(state).SetFlowNames(flows)
+	_, err := t.Api.SetControlState(cs)
+	return err
+}
+
+// rates waits for Settle and returns the rx rates of the named flows
+// averaged over Measure, from their rx frame counters.
+func (t *Test) rates(flows []string) ([]float64, error) {
+	time.Sleep(t.Settle)
+	req := gosnappi.NewMetricsRequest()
+	req.Flow().SetFlowNames(flows)
+	before, err := t.Api.GetMetrics(req)
+	if err != nil {
+		return nil, err
+	}
+	start := time.Now()
+	time.Sleep(t.Measure)
+	after, err := t.Api.GetMetrics(req)
+	if err != nil {
+		return nil, err
+	}
+	elapsed := time.Since(start).Seconds()
+
+	rx := map[string]uint64{}
+	for _, m := range before.FlowMetrics().Items() {
+		rx[m.Name()] = m.FramesRx()
+	}
+	rates := make([]float64, len(flows))
+	for _, m := range after.FlowMetrics().Items() {
+		for i, name := range flows {
+			if name == m.Name() && elapsed > 0 {
+				rates[i] = float64(m.FramesRx()-rx[name]) / elapsed
+			}
+		}
+	}
+	return rates, nil
+}
+
+// Check returns an error listing the priorities whose rx rate reduction
+// deviates from the expected one by more than tolerance, e.g. 0.05 for five
+// percentage points.
+func (r *Result) Check(tolerance float64) error {
+	var errs []string
+	for _, p := range r.Priorities {
+		if p.Baseline == 0 {
+			errs = append(errs, fmt.Sprintf("priority %d received no frames before the pause", p.Priority))
+			continue
+		}
+		if math.Abs(p.Reduction()-p.Want) > tolerance {
+			errs = append(errs, fmt.Sprintf("priority %d rx rate dropped by %.1f%%, want %.1f%%",
+				p.Priority, p.Reduction()*100, p.Want*100))
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("pause response:\n%s", strings.Join(errs, "\n"))
+	}
+	return nil
+}
+
+// String renders the per-priority table.
+func (r *Result) String() string {
+	b := &strings.Builder{}
+	w := tabwriter.NewWriter(b, 0, 0, 1, ' ', 0)
+
+	kind := "Ethernet PAUSE"
+	if len(r.Pause.Priorities) > 0 {
+		kind = fmt.Sprintf("PFC class enable vector 0x%02x", ClassEnableVector(r.Pause.Priorities...))
+	}
+	fmt.Fprintf(w, "%s, %d pps of %.0f quanta\n\n", kind, r.Pause.Pps, r.Pause.Mean())
+	fmt.Fprint(w, "Priority\tPaused\tBaseline fps\tThrottled fps\tReduction\tWant\n")
+	for _, p := range r.Priorities {
+		fmt.Fprintf(w, "%d\t%t\t%.0f\t%.0f\t%.1f%%\t%.1f%%\n",
+			p.Priority, p.Paused, p.Baseline, p.Throttled, p.Reduction()*100, p.Want*100)
+	}
+	w.Flush()
+	return b.String()
+}
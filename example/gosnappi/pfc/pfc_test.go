@@ -0,0 +1,122 @@
+package pfc
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/SpirentOrion/stc-otg/example/gosnappi/otgsimtest"
+	"github.com/open-traffic-generator/snappi/gosnappi"
+)
+
+func TestPauseShare(t *testing.T) {
+	if got := ClassEnableVector(3, 4); got != 0x18 {
+		t.Errorf("ClassEnableVector(3, 4) got 0x%x, want 0x18", got)
+	}
+	if got := ClassEnableVector(3, 8); got != 0x08 {
+		t.Errorf("ClassEnableVector(3, 8) got 0x%x, want 0x08 without the invalid priority", got)
+	}
+	// A pause of 0xffff quanta lasts 3.355ms at 10G.
+	pps := RefreshPps(MaxQuanta, 10e9)
+	if math.Abs(1/pps-0.0033553920) > 1e-9 {
+		t.Errorf("RefreshPps() got %f, want one frame per 3.355ms", pps)
+	}
+	if got := PauseShare(pps/2, MaxQuanta, 10e9); math.Abs(got-0.5) > 1e-9 {
+		t.Errorf("PauseShare() at half the refresh rate got %f, want 0.5", got)
+	}
+}
+
+func TestAdd(t *testing.T) {
+	config := gosnappi.NewConfig()
+	flow, err := Pause{Priorities: []int{3, 4}, Quanta: []uint32{100, 200}, Pps: 1000}.Add(config, "pause", "p2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pfc := flow.Packet().Items()[0].Pfcpause()
+	if pfc.ClassEnableVector().Value() != 0x18 {
+		t.Errorf("Add() got class enable vector 0x%x, want 0x18", pfc.ClassEnableVector().Value())
+	}
+	if got := pfc.PauseClass4().Values(); len(got) != 2 || got[1] != 200 {
+		t.Errorf("Add() got class 4 quanta %v, want [100 200]", got)
+	}
+	if got := pfc.PauseClass0().Value(); got != 0 {
+		t.Errorf("Add() got class 0 quanta %d, want 0 as it is not paused", got)
+	}
+
+	if flow, err = (Pause{Pps: 10}).Add(config, "link", "p2"); err != nil {
+		t.Fatal(err)
+	}
+	if got := flow.Packet().Items()[0].Ethernetpause().Time().Value(); got != MaxQuanta {
+		t.Errorf("Add() of Ethernet PAUSE got %d quanta, want %d", got, MaxQuanta)
+	}
+
+	for _, p := range []Pause{
+		{Priorities: []int{3, 8}, Pps: 10},
+		{Priorities: []int{-1}, Pps: 10},
+		{Quanta: []uint32{MaxQuanta + 1}, Pps: 10},
+	} {
+		if _, err := p.Add(config, "bad", "p2"); err == nil {
+			t.Errorf("Add() of %+v succeeded", p)
+		}
+	}
+	if n := len(config.Flows().Items()); n != 2 {
+		t.Errorf("failed Add() left %d flows in the config, want 2", n)
+	}
+}
+
+func TestRun(t *testing.T) {
+	_, api := otgsimtest.Start(t)
+	config := gosnappi.NewConfig()
+	config.Ports().Add().SetName("p1")
+	config.Ports().Add().SetName("p2")
+
+	test := &Test{
+		Api: api, Config: config, Tx: "p1", Rx: "p2",
+		Priorities: []int{0, 3, 4},
+		Settle:     10 * time.Millisecond,
+		Measure:    50 * time.Millisecond,
+	}
+	for _, tc := range []struct {
+		desc  string
+		pause Pause
+		want  []float64
+	}{
+		{"pfc", Pause{Priorities: []int{3, 4}, Pps: uint64(math.Ceil(RefreshPps(MaxQuanta, 10e9)))}, []float64{0, 1, 1}},
+		// Every other refresh leaves the link paused half the time.
+		{"ethernet pause", Pause{Quanta: []uint32{MaxQuanta}, Pps: 149}, []float64{0.5, 0.5, 0.5}},
+	} {
+		test.Pause = tc.pause
+		res, err := test.Run()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i, p := range res.Priorities {
+			if math.Abs(p.Want-tc.want[i]) > 0.01 {
+				t.Errorf("%s: priority %d want got %f, expected %f", tc.desc, p.Priority, p.Want, tc.want[i])
+			}
+		}
+		if err := res.Check(0.05); err != nil {
+			t.Errorf("%s: Check() failed: %v\n%s", tc.desc, err, res)
+		}
+	}
+
+	test.Priorities = []int{0, 8}
+	if _, err := test.Run(); err == nil {
+		t.Error("Run() with data flow priority 8 succeeded")
+	}
+}
+
+func TestCheck(t *testing.T) {
+	res := &Result{Pause: Pause{Priorities: []int{3}, Pps: 300}, Priorities: []PriorityResult{
+		{Priority: 0, Baseline: 1000, Throttled: 1000},
+		{Priority: 3, Paused: true, Baseline: 1000, Throttled: 800, Want: 1},
+	}}
+	err := res.Check(0.05)
+	if err == nil || !strings.Contains(err.Error(), "priority 3 rx rate dropped by 20.0%, want 100.0%") {
+		t.Errorf("Check() got %v", err)
+	}
+	if strings.Contains(err.Error(), "priority 0") {
+		t.Errorf("Check() failed the priority not paused: %v", err)
+	}
+}
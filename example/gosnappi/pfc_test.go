@@ -0,0 +1,47 @@
+package gosnappi_examples
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/SpirentOrion/stc-otg/example/gosnappi/pfc"
+	"github.com/open-traffic-generator/snappi/gosnappi"
+)
+
+// PORT1=10.109.121.181/1/1 PORT2=10.109.123.254/1/1 OTGSERVER=localhost:50051 go test -v -test.run TestPfc
+func TestPfc(t *testing.T) {
+	// Create a new API handle to make API calls against OTG
+	api := gosnappi.NewApi()
+
+	// Set the transport protocol to gRPC
+	api.NewGrpcTransport().
+		SetLocation(OTGSERVER).
+		SetDialTimeout(3 * time.Minute).
+		SetRequestTimeout(10 * time.Minute)
+
+	config := gosnappi.NewConfig()
+	config.Ports().Add().SetName("port1").SetLocation(PORT1)
+	config.Ports().Add().SetName("port2").SetLocation(PORT2)
+
+	// Send a data flow per priority from port1 to port2 through the DUT and
+	// pause priorities 3 and 4 from port2, refreshing the pause before it
+	// runs out on a 10G link
+	test := &pfc.Test{
+		Api:     api,
+		Config:  config,
+		Tx:      "port1",
+		Rx:      "port2",
+		Pause:   pfc.Pause{Priorities: []int{3, 4}, Pps: uint64(math.Ceil(pfc.RefreshPps(pfc.MaxQuanta, 10e9)))},
+		Settle:  2 * time.Second,
+		Measure: 5 * time.Second,
+	}
+	res, err := test.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Log("\n" + res.String())
+	if err := res.Check(0.05); err != nil {
+		t.Error(err)
+	}
+}
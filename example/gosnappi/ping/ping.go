@@ -0,0 +1,200 @@
+// Package ping issues protocol.ipv4.ping and protocol.ipv6.ping control
+// actions against an OTG service and checks the per-destination results.
+package ping
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/open-traffic-generator/snappi/gosnappi"
+)
+
+// DefaultBatchSize is the number of ping requests sent in a single
+// control action when Run is called with a batch size of 0.
+const DefaultBatchSize = 16
+
+// Target describes one ping to issue.
+type Target struct {
+	// Src is the name of a device, ethernet or IPv4/IPv6 interface in the
+	// config the ping is sent from.
+	Src string
+	// DstIp is the IPv4 or IPv6 address to ping.
+	DstIp string
+}
+
+func (tg Target) String() string {
+	return fmt.Sprintf("%s -> %s", tg.Src, tg.DstIp)
+}
+
+// Result is the outcome of one ping.
+type Result struct {
+	Target
+	// SrcName is the IPv4/IPv6 interface name Src was resolved to.
+	SrcName string
+	// Ipv6 is true if the ping was issued as protocol.ipv6.ping.
+	Ipv6 bool
+	// Succeeded reports whether the OTG service got a reply.
+	Succeeded bool
+}
+
+func (r Result) String() string {
+	result := "failed"
+	if r.Succeeded {
+		result = "succeeded"
+	}
+	return fmt.Sprintf("ping from %s (%s) to %s %s", r.Src, r.SrcName, r.DstIp, result)
+}
+
+// ResolveSrc returns the name of the IPv4 (or IPv6) interface that src
+// refers to. src may be the name of a device, of one of its ethernets, or of
+// the IP interface itself; the first matching interface is returned.
+func ResolveSrc(config gosnappi.Config, src string, ipv6 bool) (string, error) {
+	for _, dev := range config.Devices().Items() {
+		for _, eth := range dev.Ethernets().Items() {
+			names := []string{}
+			if ipv6 {
+				for _, ip := range eth.Ipv6Addresses().Items() {
+					names = append(names, ip.Name())
+				}
+			} else {
+				for _, ip := range eth.Ipv4Addresses().Items() {
+					names = append(names, ip.Name())
+				}
+			}
+			for _, name := range names {
+				if src == dev.Name() || src == eth.Name() || src == name {
+					return name, nil
+				}
+			}
+		}
+	}
+
+	family := "IPv4"
+	if ipv6 {
+		family = "IPv6"
+	}
+	return "", fmt.Errorf("no %s interface found for source %q", family, src)
+}
+
+// Run pings every target and returns one result per target in the same
+// order. Targets are resolved against config and sent batchSize at a time,
+// IPv4 and IPv6 destinations in separate control actions.
+func Run(api gosnappi.Api, config gosnappi.Config, targets []Target, batchSize int) ([]Result, error) {
+	if batchSize <= 0 {
+		batchSize = DefaultBatchSize
+	}
+
+	results := make([]Result, len(targets))
+	var v4, v6 []int
+	for i, tg := range targets {
+		ip := net.ParseIP(tg.DstIp)
+		if ip == nil {
+			return nil, fmt.Errorf("ping %s: invalid destination address", tg)
+		}
+		ipv6 := ip.To4() == nil
+		srcName, err := ResolveSrc(config, tg.Src, ipv6)
+		if err != nil {
+			return nil, fmt.Errorf("ping %s: %v", tg, err)
+		}
+		results[i] = Result{Target: tg, SrcName: srcName, Ipv6: ipv6}
+		if ipv6 {
+			v6 = append(v6, i)
+		} else {
+			v4 = append(v4, i)
+		}
+	}
+
+	for _, idx := range [][]int{v4, v6} {
+		for start := 0; start < len(idx); start += batchSize {
+			end := start + batchSize
+			if end > len(idx) {
+				end = len(idx)
+			}
+			if err := runBatch(api, results, idx[start:end]); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return results, nil
+}
+
+// runBatch sends one control action for results[idx...], which must all be
+// of the same address family, and fills in Succeeded from the responses.
+func runBatch(api gosnappi.Api, results []Result, idx []int) error {
+	ipv6 := results[idx[0]].Ipv6
+	action := gosnappi.NewControlAction()
+	for _, i := range idx {
+		r := results[i]
+		if ipv6 {
+			action.Protocol().Ipv6().Ping().Requests().Add().SetSrcName(r.SrcName).SetDstIp(r.DstIp)
+		} else {
+			action.Protocol().Ipv4().Ping().Requests().Add().SetSrcName(r.SrcName).SetDstIp(r.DstIp)
+		}
+	}
+
+	resp, err := api.SetControlAction(action)
+	if err != nil {
+		return fmt.Errorf("ping %s: %v", results[idx[0]].Target, err)
+	}
+
+	succeeded := map[string]bool{}
+	if ipv6 {
+		for _, r := range resp.Response().Protocol().Ipv6().Ping().Responses().Items() {
+			succeeded[r.SrcName()+"|"+r.DstIp()] = r.Result() == gosnappi.ActionResponseProtocolIpv6PingResponseResult.SUCCEEDED
+		}
+	} else {
+		for _, r := range resp.Response().Protocol().Ipv4().Ping().Responses().Items() {
+			succeeded[r.SrcName()+"|"+r.DstIp()] = r.Result() == gosnappi.ActionResponseProtocolIpv4PingResponseResult.SUCCEEDED
+		}
+	}
+	for _, i := range idx {
+		results[i].Succeeded = succeeded[results[i].SrcName+"|"+results[i].DstIp]
+	}
+	return nil
+}
+
+// Check compares results with the set of targets expected to succeed; every
+// other target is expected to fail. It returns nil if all results match, or an
+// error naming the source and destination of each mismatch.
+func Check(results []Result, wantSucceeded []Target) error {
+	want := map[Target]bool{}
+	for _, tg := range wantSucceeded {
+		want[tg] = true
+	}
+
+	var errs []string
+	for _, r := range results {
+		if r.Succeeded == want[r.Target] {
+			continue
+		}
+		if want[r.Target] {
+			errs = append(errs, fmt.Sprintf("%s, want succeeded", r))
+		} else {
+			errs = append(errs, fmt.Sprintf("%s, want failed", r))
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("unexpected ping results:\n%s", strings.Join(errs, "\n"))
+	}
+	return nil
+}
+
+// Verify runs the targets and fails t unless exactly the targets in
+// wantSucceeded got a reply.
+func Verify(t testing.TB, api gosnappi.Api, config gosnappi.Config, targets []Target, wantSucceeded []Target) []Result {
+	t.Helper()
+	results, err := Run(api, config, targets, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range results {
+		t.Log(r)
+	}
+	if err := Check(results, wantSucceeded); err != nil {
+		t.Error(err)
+	}
+	return results
+}
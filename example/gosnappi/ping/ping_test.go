@@ -0,0 +1,122 @@
+package ping
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/SpirentOrion/stc-otg/example/gosnappi/otgsimtest"
+	"github.com/open-traffic-generator/snappi/gosnappi"
+)
+
+func testConfig() gosnappi.Config {
+	config := gosnappi.NewConfig()
+	config.Ports().Add().SetName("port1")
+	dev := config.Devices().Add().SetName("port1_DEV")
+	eth := dev.Ethernets().Add().SetName("port1_ETH").SetMac("00:11:22:33:44:66")
+	eth.Connection().SetPortName("port1")
+	eth.Ipv4Addresses().Add().SetName("port1_IPv4").SetAddress("10.1.1.1").SetGateway("10.1.1.2").SetPrefix(24)
+	eth.Ipv6Addresses().Add().SetName("port1_IPv6").SetAddress("2001:db8::1").SetGateway("2001:db8::2").SetPrefix(64)
+	return config
+}
+
+func TestResolveSrc(t *testing.T) {
+	config := testConfig()
+	tests := []struct {
+		src  string
+		ipv6 bool
+		want string
+	}{
+		{"port1_DEV", false, "port1_IPv4"},
+		{"port1_ETH", false, "port1_IPv4"},
+		{"port1_IPv4", false, "port1_IPv4"},
+		{"port1_DEV", true, "port1_IPv6"},
+		{"port1_IPv6", true, "port1_IPv6"},
+	}
+	for _, tt := range tests {
+		got, err := ResolveSrc(config, tt.src, tt.ipv6)
+		if err != nil {
+			t.Errorf("ResolveSrc(%q, %v) failed: %v", tt.src, tt.ipv6, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ResolveSrc(%q, %v) = %q, want %q", tt.src, tt.ipv6, got, tt.want)
+		}
+	}
+
+	if _, err := ResolveSrc(config, "port1_IPv4", true); err == nil {
+		t.Errorf("ResolveSrc of an IPv4 interface as IPv6 source should fail")
+	}
+	if _, err := ResolveSrc(config, "port2_DEV", false); err == nil {
+		t.Errorf("ResolveSrc of an unknown source should fail")
+	}
+}
+
+func TestCheck(t *testing.T) {
+	ok := Target{Src: "port1_DEV", DstIp: "10.1.1.2"}
+	bad := Target{Src: "port1_DEV", DstIp: "10.1.1.3"}
+	results := []Result{
+		{Target: ok, SrcName: "port1_IPv4", Succeeded: true},
+		{Target: bad, SrcName: "port1_IPv4", Succeeded: false},
+	}
+
+	if err := Check(results, []Target{ok}); err != nil {
+		t.Errorf("Check() returned unexpected error: %v", err)
+	}
+
+	err := Check(results, []Target{ok, bad})
+	if err == nil {
+		t.Fatalf("Check() should fail when an expected ping failed")
+	}
+	if !strings.Contains(err.Error(), "port1_DEV") || !strings.Contains(err.Error(), "10.1.1.3") {
+		t.Errorf("Check() error should name source and destination, got: %v", err)
+	}
+}
+
+func TestRun(t *testing.T) {
+	_, api := otgsimtest.Start(t)
+
+	config := testConfig()
+	config.Ports().Add().SetName("port2")
+	eth := config.Devices().Add().SetName("port2_DEV").Ethernets().Add().SetName("port2_ETH").SetMac("00:11:22:33:44:55")
+	eth.Connection().SetPortName("port2")
+	eth.Ipv4Addresses().Add().SetName("port2_IPv4").SetAddress("10.1.1.2").SetGateway("10.1.1.1").SetPrefix(24)
+	eth.Ipv6Addresses().Add().SetName("port2_IPv6").SetAddress("2001:db8::2").SetGateway("2001:db8::1").SetPrefix(64)
+	if _, err := api.SetConfig(config); err != nil {
+		t.Fatal(err)
+	}
+
+	targets := []Target{
+		{Src: "port1_DEV", DstIp: "10.1.1.2"},
+		{Src: "port1_DEV", DstIp: "2001:db8::2"},
+		{Src: "port1_DEV", DstIp: "10.1.1.3"},
+		{Src: "port2_DEV", DstIp: "10.1.1.1"},
+		{Src: "port2_IPv6", DstIp: "2001:db8::3"},
+	}
+	want := []Result{
+		{Target: targets[0], SrcName: "port1_IPv4", Succeeded: true},
+		{Target: targets[1], SrcName: "port1_IPv6", Ipv6: true, Succeeded: true},
+		{Target: targets[2], SrcName: "port1_IPv4"},
+		{Target: targets[3], SrcName: "port2_IPv4", Succeeded: true},
+		{Target: targets[4], SrcName: "port2_IPv6", Ipv6: true},
+	}
+	// A batch size of 1 sends every ping on its own, the default all of
+	// them in one action per address family.
+	for _, batchSize := range []int{1, 0} {
+		got, err := Run(api, config, targets, batchSize)
+		if err != nil {
+			t.Fatalf("Run(batchSize=%d) failed: %v", batchSize, err)
+		}
+		if len(got) != len(want) {
+			t.Fatalf("Run(batchSize=%d) returned %d results, want %d", batchSize, len(got), len(want))
+		}
+		for i := range want {
+			if got[i] != want[i] {
+				t.Errorf("Run(batchSize=%d) result %d = %+v, want %+v", batchSize, i, got[i], want[i])
+			}
+		}
+	}
+
+	if _, err := Run(api, config, []Target{{Src: "port1_DEV", DstIp: "10.1.1"}}, 0); err == nil {
+		t.Errorf("Run with an invalid destination should fail")
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/SpirentOrion/stc-otg/example/gosnappi/ping"
 	"github.com/open-traffic-generator/snappi/gosnappi"
 )
 
@@ -30,12 +31,15 @@ func TestIpPing(t *testing.T) {
 	eth_tx := dev_tx.Ethernets().Add().SetName(fmt.Sprintf("%s_ETH", ptx.Name())).SetMac("00:11:22:33:44:66").SetMtu(1000)
 	eth_tx.Vlans().Add().SetName("vlan10").SetId(10).SetPriority(2).SetTpid("x9300")
 	eth_tx.Connection().SetPortName(ptx.Name())
+	eth_tx.Ipv4Addresses().Add().SetName(fmt.Sprintf("%s_IPv4", ptx.Name())).SetAddress("10.1.1.1").SetGateway("10.1.1.2").SetPrefix(24)
 	// eth_tx.SetPortName(ptx.Name())
 
 	dev_rx := config.Devices().Add().SetName(fmt.Sprintf("%s_DEV", prx.Name()))
 	eth_rx := dev_rx.Ethernets().Add().SetName(fmt.Sprintf("%s_ETH", prx.Name())).SetMac("00:11:22:33:44:55").SetMtu(1000)
-	eth_rx.Vlans().Add().SetName("vlan20").SetId(20).SetPriority(2).SetTpid("x9300")
+	// Both ends share VLAN 10 so they can reach each other
+	eth_rx.Vlans().Add().SetName("vlan10_rx").SetId(10).SetPriority(2).SetTpid("x9300")
 	eth_rx.Connection().SetPortName(prx.Name())
+	eth_rx.Ipv4Addresses().Add().SetName(fmt.Sprintf("%s_IPv4", prx.Name())).SetAddress("10.1.1.2").SetGateway("10.1.1.1").SetPrefix(24)
 	// eth_rx.SetPortName(prx.Name())
 
 	// Configure a flow and set previously created test port as one of endpoints
@@ -85,25 +89,12 @@ func TestIpPing(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	controlaction := gosnappi.NewControlAction()
-	controlaction.Protocol().Ipv4().Ping().Requests().Add().SetDstIp("127.0.0.1")
-	controlaction1, err := api.SetControlAction(controlaction)
-	if err != nil {
-		t.Fatal(err)
-	}
-	// t.Log("-----", controlaction1)
-	responses := controlaction1.Response().Protocol().Ipv4().Ping().Responses()
-	t.Log("----", responses)
-
-	items := responses.Items()
-
-	for i, response := range items {
-		if response != nil {
-			t.Logf("Response #%d: SrcName=%s, DstIp=%s, Result=%s", i, response.SrcName(), response.DstIp(), response.Result())
-			PingResult := response.Result()
-			if PingResult == "succeeded" {
-				t.Log("Ping Test successful")
-			}
-		}
+	// Ping the rx device from the tx device; both directions are expected to
+	// get a reply, while an address nobody owns is expected to fail.
+	reachable := []ping.Target{
+		{Src: dev_tx.Name(), DstIp: "10.1.1.2"},
+		{Src: dev_rx.Name(), DstIp: "10.1.1.1"},
 	}
+	targets := append(reachable, ping.Target{Src: dev_tx.Name(), DstIp: "10.1.1.3"})
+	ping.Verify(t, api, config, targets, reachable)
 }
@@ -0,0 +1,190 @@
+package rfc2544
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/SpirentOrion/stc-otg/example/gosnappi/framesize"
+	"github.com/SpirentOrion/stc-otg/example/gosnappi/linerate"
+	"github.com/open-traffic-generator/snappi/gosnappi"
+)
+
+// BackToBackOptions controls the back-to-back frames test.
+type BackToBackOptions struct {
+	// FrameSizes to measure, DefaultFrameSizes when empty.
+	FrameSizes []uint32
+	// Rate the bursts are sent at, in percent of line rate, 100 when zero.
+	Rate float64
+	// MaxFrames is the longest burst tried. When zero it is the number of
+	// frames the tx port sends in 2s, which needs a layer1 speed for it in the
+	// config.
+	MaxFrames uint32
+	// Resolution stops the search once the passing and failing burst
+	// lengths are this many frames apart, 0.1% of MaxFrames when zero.
+	Resolution uint32
+	// Repetitions is how many times the search runs per frame size; the
+	// result is their average. RFC 2544 asks for at least 50, which is the
+	// default.
+	Repetitions int
+}
+
+func (o *BackToBackOptions) setDefaults() {
+	if len(o.FrameSizes) == 0 {
+		o.FrameSizes = DefaultFrameSizes
+	}
+	if o.Rate == 0 {
+		o.Rate = 100
+	}
+	if o.Repetitions == 0 {
+		o.Repetitions = 50
+	}
+}
+
+// BurstTrial is one burst of back-to-back frames.
+type BurstTrial struct {
+	Trial
+	Frames uint32 `json:"burst_frames"`
+}
+
+// BackToBackResult is the longest burst sent without loss for one frame size.
+type BackToBackResult struct {
+	FrameSize uint32 `json:"frame_size"`
+	// Bursts holds the longest passing burst of every repetition, 0 if none
+	// passed.
+	Bursts []uint32 `json:"bursts"`
+	// AvgFrames, MinFrames and MaxFrames summarize Bursts.
+	AvgFrames float64 `json:"avg_burst_frames"`
+	MinFrames uint32  `json:"min_burst_frames"`
+	MaxFrames uint32  `json:"max_burst_frames"`
+	// AvgDuration is how long the average burst lasts at the tested rate,
+	// 0 if the tx port line rate is not known.
+	AvgDuration time.Duration `json:"avg_burst_ns"`
+	Trials      []BurstTrial  `json:"trials"`
+}
+
+// BackToBackResults is the result set of the back-to-back frames test.
+type BackToBackResults []BackToBackResult
+
+func (r BackToBackResults) Name() string {
+	return "back_to_back"
+}
+
+func (r BackToBackResults) Header() []string {
+	return []string{"frame_size", "avg_burst_frames", "min_burst_frames", "max_burst_frames",
+		"avg_burst_us", "repetitions"}
+}
+
+func (r BackToBackResults) Rows() [][]string {
+	rows := [][]string{}
+	for _, res := range r {
+		rows = append(rows, []string{
+			strconv.FormatUint(uint64(res.FrameSize), 10),
+			strconv.FormatFloat(res.AvgFrames, 'f', 1, 64),
+			strconv.FormatUint(uint64(res.MinFrames), 10),
+			strconv.FormatUint(uint64(res.MaxFrames), 10),
+			strconv.FormatFloat(float64(res.AvgDuration)/float64(time.Microsecond), 'f', 3, 64),
+			strconv.Itoa(len(res.Bursts)),
+		})
+	}
+	return rows
+}
+
+// BackToBack runs the RFC 2544 back-to-back frames test: for every frame size
+// it binary searches the longest burst sent at Rate that the DUT forwards
+// without loss, repeats the search and averages the results.
+func (b *Benchmark) BackToBack(opts BackToBackOptions) (BackToBackResults, error) {
+	if err := b.validate(); err != nil {
+		return nil, err
+	}
+	opts.setDefaults()
+
+	results := BackToBackResults{}
+	for _, size := range opts.FrameSizes {
+		// fps is the frame rate of the bursts, 0 if the line rate is unknown.
+		fps := 0.0
+		if speed := b.txSpeed(); speed > 0 {
+			fps = linerate.NewLink(speed, framesize.Fixed(size)).Pps(opts.Rate)
+		}
+		maxFrames := opts.MaxFrames
+		if maxFrames == 0 {
+			if fps == 0 {
+				return nil, fmt.Errorf("back-to-back for frame size %d: set MaxFrames or a layer1 speed for port %s", size, b.TxPort)
+			}
+			maxFrames = uint32(2 * fps)
+		}
+		resolution := opts.Resolution
+		if resolution == 0 {
+			resolution = max(maxFrames/1000, 1)
+		}
+
+		res := BackToBackResult{FrameSize: size}
+		for i := 0; i < opts.Repetitions; i++ {
+			longest, err := search(float64(maxFrames), float64(resolution), func(v float64) (bool, error) {
+				trial, err := b.burstTrial(size, opts.Rate, uint32(math.Round(v)), fps)
+				if err != nil {
+					return false, err
+				}
+				b.logf("back-to-back: size %d repetition %d burst %d tx %d rx %d pass %v",
+					size, i+1, trial.Frames, trial.TxFrames, trial.RxFrames, trial.Pass)
+				res.Trials = append(res.Trials, trial)
+				return trial.Pass, nil
+			})
+			if err != nil {
+				return nil, fmt.Errorf("back-to-back for frame size %d: %v", size, err)
+			}
+			res.Bursts = append(res.Bursts, uint32(math.Round(longest)))
+		}
+		res.summarize(fps)
+		results = append(results, res)
+	}
+	return results, nil
+}
+
+// summarize fills in the statistics of the bursts found.
+func (r *BackToBackResult) summarize(fps float64) {
+	if len(r.Bursts) == 0 {
+		return
+	}
+	sum := 0.0
+	r.MinFrames = r.Bursts[0]
+	for _, n := range r.Bursts {
+		sum += float64(n)
+		r.MinFrames = min(r.MinFrames, n)
+		r.MaxFrames = max(r.MaxFrames, n)
+	}
+	r.AvgFrames = sum / float64(len(r.Bursts))
+	if fps > 0 {
+		r.AvgDuration = time.Duration(r.AvgFrames / fps * float64(time.Second))
+	}
+}
+
+// burstTrial sends a single burst of n frames of the given size at rate
+// percent of line rate. A trial passes if every frame is received.
+func (b *Benchmark) burstTrial(size uint32, rate float64, n uint32, fps float64) (BurstTrial, error) {
+	var expect time.Duration
+	if fps > 0 {
+		expect = time.Duration(float64(n) / fps * float64(time.Second))
+	}
+	m, err := b.runTrial(expect, func(flow gosnappi.Flow) {
+		flow.Size().SetFixed(size)
+		flow.Rate().SetPercentage(float32(rate))
+		flow.Duration().Burst().SetBursts(1).SetPackets(n)
+	})
+	if err != nil {
+		return BurstTrial{}, err
+	}
+	trial := BurstTrial{
+		Trial: Trial{
+			FrameSize: size,
+			Rate:      rate,
+			TxFrames:  m.FramesTx(),
+			RxFrames:  m.FramesRx(),
+			Loss:      lossPct(m.FramesTx(), m.FramesRx()),
+		},
+		Frames: n,
+	}
+	trial.Pass = trial.TxFrames == uint64(n) && trial.RxFrames >= trial.TxFrames
+	return trial, nil
+}
@@ -0,0 +1,163 @@
+package rfc2544
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/open-traffic-generator/snappi/gosnappi"
+)
+
+// LatencyLossOptions controls the latency and frame loss rate test.
+type LatencyLossOptions struct {
+	// FrameSizes to measure, DefaultFrameSizes when empty.
+	FrameSizes []uint32
+	// Loads are the rates to step through, in percent of line rate,
+	// 10, 20, ... 100 when empty.
+	Loads []float64
+	// Duration of every step, 60s when zero.
+	Duration time.Duration
+	// Mode is the latency measurement mode, store_forward when empty.
+	Mode gosnappi.FlowLatencyMetricsModeEnum
+}
+
+func (o *LatencyLossOptions) setDefaults() {
+	if len(o.FrameSizes) == 0 {
+		o.FrameSizes = DefaultFrameSizes
+	}
+	if len(o.Loads) == 0 {
+		for load := 10.0; load <= 100; load += 10 {
+			o.Loads = append(o.Loads, load)
+		}
+	}
+	if o.Duration == 0 {
+		o.Duration = 60 * time.Second
+	}
+	if o.Mode == "" {
+		o.Mode = gosnappi.FlowLatencyMetricsMode.STORE_FORWARD
+	}
+}
+
+// LatencyLossResult is the loss and latency measured for one frame size at
+// one load step.
+type LatencyLossResult struct {
+	Trial
+	// MinLatencyNs, AvgLatencyNs and MaxLatencyNs are the latency statistics
+	// of the received frames.
+	MinLatencyNs float64 `json:"min_latency_ns"`
+	AvgLatencyNs float64 `json:"avg_latency_ns"`
+	MaxLatencyNs float64 `json:"max_latency_ns"`
+}
+
+// LatencyLossResults is the result set of the latency and frame loss rate
+// test, one result per frame size and load in the order they ran.
+type LatencyLossResults []LatencyLossResult
+
+func (r LatencyLossResults) Name() string {
+	return "latency_frame_loss"
+}
+
+func (r LatencyLossResults) Header() []string {
+	return []string{"frame_size", "load_pct", "tx_frames", "rx_frames", "loss_pct",
+		"min_latency_ns", "avg_latency_ns", "max_latency_ns"}
+}
+
+func (r LatencyLossResults) Rows() [][]string {
+	rows := [][]string{}
+	for _, res := range r {
+		rows = append(rows, []string{
+			strconv.FormatUint(uint64(res.FrameSize), 10),
+			strconv.FormatFloat(res.Rate, 'f', 3, 64),
+			strconv.FormatUint(res.TxFrames, 10),
+			strconv.FormatUint(res.RxFrames, 10),
+			strconv.FormatFloat(res.Loss, 'f', 4, 64),
+			strconv.FormatFloat(res.MinLatencyNs, 'f', 1, 64),
+			strconv.FormatFloat(res.AvgLatencyNs, 'f', 1, 64),
+			strconv.FormatFloat(res.MaxLatencyNs, 'f', 1, 64),
+		})
+	}
+	return rows
+}
+
+// WritePlotCSV writes the results as plot data: one row per load, with a
+// loss and an average latency column per frame size.
+func (r LatencyLossResults) WritePlotCSV(w io.Writer) error {
+	sizes := []uint32{}
+	loads := []float64{}
+	seen := map[float64]bool{}
+	cells := map[uint32]map[float64]LatencyLossResult{}
+	for _, res := range r {
+		if cells[res.FrameSize] == nil {
+			cells[res.FrameSize] = map[float64]LatencyLossResult{}
+			sizes = append(sizes, res.FrameSize)
+		}
+		if !seen[res.Rate] {
+			seen[res.Rate] = true
+			loads = append(loads, res.Rate)
+		}
+		cells[res.FrameSize][res.Rate] = res
+	}
+
+	cw := csv.NewWriter(w)
+	header := []string{"load_pct"}
+	for _, size := range sizes {
+		s := strconv.FormatUint(uint64(size), 10)
+		header = append(header, "loss_pct_"+s, "avg_latency_ns_"+s)
+	}
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+	for _, load := range loads {
+		row := []string{strconv.FormatFloat(load, 'f', 3, 64)}
+		for _, size := range sizes {
+			res, ok := cells[size][load]
+			if !ok {
+				row = append(row, "", "")
+				continue
+			}
+			row = append(row,
+				strconv.FormatFloat(res.Loss, 'f', 4, 64),
+				strconv.FormatFloat(res.AvgLatencyNs, 'f', 1, 64))
+		}
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// LatencyLoss runs the RFC 2544 latency and frame loss rate tests together:
+// for every frame size it steps through the loads with latency and timestamp
+// metrics enabled on the benchmark flow, and records loss and latency.
+func (b *Benchmark) LatencyLoss(opts LatencyLossOptions) (LatencyLossResults, error) {
+	if err := b.validate(); err != nil {
+		return nil, err
+	}
+	opts.setDefaults()
+
+	results := LatencyLossResults{}
+	for _, size := range opts.FrameSizes {
+		for _, load := range opts.Loads {
+			trial, m, err := b.rateTrial(size, load, opts.Duration, func(flow gosnappi.Flow) {
+				flow.Metrics().SetTimestamps(true)
+				flow.Metrics().Latency().SetEnable(true).SetMode(opts.Mode)
+			})
+			if err != nil {
+				return nil, err
+			}
+			trial.Pass = trial.TxFrames > 0 && trial.Loss == 0
+			res := LatencyLossResult{Trial: trial}
+			if m.HasLatency() {
+				res.MinLatencyNs = m.Latency().MinimumNs()
+				res.AvgLatencyNs = m.Latency().AverageNs()
+				res.MaxLatencyNs = m.Latency().MaximumNs()
+			}
+			b.logf("latency/loss: size %d load %.1f%% loss %.4f%% latency min/avg/max %.0f/%.0f/%.0f ns",
+				size, load, res.Loss, res.MinLatencyNs, res.AvgLatencyNs, res.MaxLatencyNs)
+			results = append(results, res)
+		}
+	}
+	return results, nil
+}
@@ -0,0 +1,38 @@
+package rfc2544
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+)
+
+// Results is implemented by the result set of every benchmark so it can be
+// written as a CSV table or as JSON.
+type Results interface {
+	// Name is the name of the benchmark, e.g. "throughput".
+	Name() string
+	// Header holds the CSV column names.
+	Header() []string
+	// Rows holds one CSV row per result.
+	Rows() [][]string
+}
+
+// WriteCSV writes the results as a CSV table with a header line.
+func WriteCSV(w io.Writer, r Results) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(r.Header()); err != nil {
+		return err
+	}
+	if err := cw.WriteAll(r.Rows()); err != nil {
+		return err
+	}
+	return cw.Error()
+}
+
+// WriteJSON writes the results, including every trial, as indented JSON
+// keyed by the benchmark name.
+func WriteJSON(w io.Writer, r Results) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(map[string]Results{r.Name(): r})
+}
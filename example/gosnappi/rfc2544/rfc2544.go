@@ -0,0 +1,117 @@
+// Package rfc2544 runs RFC 2544 benchmarks between two ports of an OTG
+// config using gosnappi flows and flow metrics.
+package rfc2544
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/SpirentOrion/stc-otg/example/gosnappi/linerate"
+	"github.com/SpirentOrion/stc-otg/example/gosnappi/trial"
+	"github.com/open-traffic-generator/snappi/gosnappi"
+)
+
+// FlowName is the name of the flow every trial adds to the config.
+const FlowName = "rfc2544"
+
+// DefaultFrameSizes are the RFC 2544 ethernet frame sizes plus jumbo frames.
+var DefaultFrameSizes = []uint32{64, 128, 256, 512, 1024, 1280, 1518, 9216}
+
+// Benchmark holds what every trial needs: the OTG API, the config holding the
+// ports and the two ports traffic runs between.
+type Benchmark struct {
+	Api gosnappi.Api
+	// Config holds the ports (and devices) of the test. Every trial pushes a
+	// copy of it with the benchmark flow added.
+	Config gosnappi.Config
+	TxPort string
+	RxPort string
+	// Headers adds the packet headers of the benchmark flow. Plain
+	// ethernet/ipv4 headers are used when nil.
+	Headers func(flow gosnappi.Flow)
+	// PollInterval is how often flow metrics are fetched while a trial
+	// runs, 1s when zero.
+	PollInterval time.Duration
+	// Settle is how long to wait after a trial stopped transmitting before
+	// the final metrics are read, 2s when zero.
+	Settle time.Duration
+	// Logf, if set, receives progress messages, e.g. t.Logf.
+	Logf func(format string, args ...any)
+}
+
+func (b *Benchmark) logf(format string, args ...any) {
+	if b.Logf != nil {
+		b.Logf(format, args...)
+	}
+}
+
+func (b *Benchmark) validate() error {
+	if b.Api == nil || b.Config == nil {
+		return fmt.Errorf("benchmark needs an api and a config")
+	}
+	found := map[string]bool{}
+	for _, p := range b.Config.Ports().Items() {
+		found[p.Name()] = true
+	}
+	for _, name := range []string{b.TxPort, b.RxPort} {
+		if !found[name] {
+			return fmt.Errorf("port %q is not in the config", name)
+		}
+	}
+	return nil
+}
+
+// txSpeed returns the line rate of the tx port in bits per second from its
+// layer1 config, 0 if it has none.
+func (b *Benchmark) txSpeed() float64 {
+	for _, l1 := range b.Config.Layer1().Items() {
+		for _, name := range l1.PortNames() {
+			if name == b.TxPort && l1.HasSpeed() {
+				return linerate.Speed(l1.Speed())
+			}
+		}
+	}
+	return 0
+}
+
+// runTrial pushes a copy of the config with the benchmark flow, lets setup
+// configure its size, rate and duration, and transmits until the flow stops on
+// its own. It returns the final flow metric. expect is how long the flow is
+// supposed to transmit; the trial fails if it is still running well after.
+func (b *Benchmark) runTrial(expect time.Duration, setup func(flow gosnappi.Flow)) (gosnappi.FlowMetric, error) {
+	config, err := b.Config.Clone()
+	if err != nil {
+		return nil, err
+	}
+
+	flow := config.Flows().Add().SetName(FlowName)
+	flow.TxRx().Port().SetTxName(b.TxPort).SetRxNames([]string{b.RxPort})
+	flow.Metrics().SetEnable(true)
+	if b.Headers != nil {
+		b.Headers(flow)
+	} else {
+		flow.Packet().Add().Ethernet()
+		flow.Packet().Add().Ipv4()
+	}
+	setup(flow)
+
+	settle := b.Settle
+	if settle == 0 {
+		settle = 2 * time.Second
+	}
+	r := trial.Runner{Api: b.Api, PollInterval: b.PollInterval, Settle: settle}
+	metrics, err := r.Run(config, []string{FlowName}, expect+30*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	return metrics[FlowName], nil
+}
+
+// lossPct returns the percentage of the transmitted frames that were not
+// received.
+func lossPct(tx, rx uint64) float64 {
+	if tx == 0 || rx >= tx {
+		return 0
+	}
+	return float64(tx-rx) * 100 / float64(tx)
+}
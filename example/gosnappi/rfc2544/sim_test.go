@@ -0,0 +1,133 @@
+package rfc2544
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/SpirentOrion/stc-otg/example/gosnappi/otgsim"
+	"github.com/SpirentOrion/stc-otg/example/gosnappi/otgsimtest"
+	"github.com/open-traffic-generator/snappi/gosnappi"
+)
+
+// simDut forwards up to 80% of line rate.
+var simDut = otgsim.Dut{MaxRate: 80, Latency: 10 * time.Microsecond, Jitter: 4 * time.Microsecond}
+
+// newSimBenchmark returns a benchmark between two ports of a simulated OTG
+// service with the given DUT between them.
+func newSimBenchmark(t *testing.T, dut otgsim.Dut) *Benchmark {
+	t.Helper()
+	_, api := otgsimtest.StartDut(t, dut)
+
+	config := gosnappi.NewConfig()
+	config.Ports().Add().SetName("port1").SetLocation("//sim/1/1")
+	config.Ports().Add().SetName("port2").SetLocation("//sim/1/2")
+	return &Benchmark{
+		Api:          api,
+		Config:       config,
+		TxPort:       "port1",
+		RxPort:       "port2",
+		PollInterval: time.Millisecond,
+		Settle:       time.Millisecond,
+		Logf:         t.Logf,
+	}
+}
+
+func TestSimThroughput(t *testing.T) {
+	bm := newSimBenchmark(t, simDut)
+	results, err := bm.Throughput(ThroughputOptions{
+		FrameSizes: []uint32{64, 1518},
+		Duration:   10 * time.Second,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, res := range results {
+		if res.Rate > 80 || res.Rate < 79.5 {
+			t.Errorf("Throughput for frame size %d got %.3f%%, want 79.5-80%%", res.FrameSize, res.Rate)
+		}
+		if res.Fps == 0 {
+			t.Errorf("Throughput for frame size %d reported no frame rate", res.FrameSize)
+		}
+	}
+}
+
+func TestSimLatencyLoss(t *testing.T) {
+	bm := newSimBenchmark(t, simDut)
+	results, err := bm.LatencyLoss(LatencyLossOptions{
+		FrameSizes: []uint32{64, 512},
+		Loads:      []float64{40, 80, 100},
+		Duration:   10 * time.Second,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 6 {
+		t.Fatalf("LatencyLoss() got %d results, want 6", len(results))
+	}
+
+	for i, res := range results {
+		wantLoss := res.Rate > 80
+		if gotLoss := res.Loss > 0; gotLoss != wantLoss {
+			t.Errorf("size %d load %.0f%%: loss got %.4f%%, want loss %v", res.FrameSize, res.Rate, res.Loss, wantLoss)
+		}
+		if res.MinLatencyNs < 10000 || res.MinLatencyNs > res.AvgLatencyNs || res.AvgLatencyNs > res.MaxLatencyNs {
+			t.Errorf("size %d load %.0f%%: latency min/avg/max %.0f/%.0f/%.0f ns out of order",
+				res.FrameSize, res.Rate, res.MinLatencyNs, res.AvgLatencyNs, res.MaxLatencyNs)
+		}
+		if i > 0 && results[i-1].FrameSize == res.FrameSize && res.AvgLatencyNs <= results[i-1].AvgLatencyNs {
+			t.Errorf("size %d: average latency should grow with load", res.FrameSize)
+		}
+	}
+
+	b := &bytes.Buffer{}
+	if err := results.WritePlotCSV(b); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(b.String()), "\n")
+	if len(lines) != 4 || lines[0] != "load_pct,loss_pct_64,avg_latency_ns_64,loss_pct_512,avg_latency_ns_512" {
+		t.Errorf("WritePlotCSV() got:\n%s", b.String())
+	}
+}
+
+func TestSimBackToBack(t *testing.T) {
+	dut := simDut
+	dut.Buffer = 10000
+	bm := newSimBenchmark(t, dut)
+	bm.Config.Layer1().Add().SetName("l1").
+		SetPortNames([]string{"port1", "port2"}).
+		SetSpeed(gosnappi.Layer1Speed.SPEED_10_GBPS)
+
+	results, err := bm.BackToBack(BackToBackOptions{
+		FrameSizes:  []uint32{64, 1518},
+		MaxFrames:   200000,
+		Resolution:  100,
+		Repetitions: 2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, res := range results {
+		// At line rate a fifth of the frames queue up, so a burst of up
+		// to 5 times the buffer passes.
+		if res.AvgFrames > 50000 || res.AvgFrames < 49900 {
+			t.Errorf("BackToBack for frame size %d got %.1f frames, want 49900-50000", res.FrameSize, res.AvgFrames)
+		}
+		if len(res.Bursts) != 2 || res.MinFrames > res.MaxFrames {
+			t.Errorf("BackToBack for frame size %d got bursts %v, min %d, max %d", res.FrameSize, res.Bursts, res.MinFrames, res.MaxFrames)
+		}
+		fps := 10e9 / (float64(res.FrameSize+20) * 8)
+		want := time.Duration(res.AvgFrames / fps * float64(time.Second))
+		if res.AvgDuration != want {
+			t.Errorf("BackToBack for frame size %d got duration %v, want %v", res.FrameSize, res.AvgDuration, want)
+		}
+	}
+}
+
+func TestBackToBackNeedsLineRate(t *testing.T) {
+	bm := newSimBenchmark(t, simDut)
+	if _, err := bm.BackToBack(BackToBackOptions{FrameSizes: []uint32{64}}); err == nil {
+		t.Error("BackToBack() without MaxFrames or a layer1 speed succeeded, want error")
+	}
+}
@@ -0,0 +1,171 @@
+package rfc2544
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/open-traffic-generator/snappi/gosnappi"
+)
+
+// ThroughputOptions controls the binary search of the throughput test.
+type ThroughputOptions struct {
+	// FrameSizes to search, DefaultFrameSizes when empty.
+	FrameSizes []uint32
+	// Duration of every trial, 60s when zero.
+	Duration time.Duration
+	// MaxRate is the highest rate tried, in percent of line rate, 100
+	// when zero. The search starts there.
+	MaxRate float64
+	// Resolution stops the search once the passing and failing rates are
+	// this close, in percent of line rate, 0.5 when zero.
+	Resolution float64
+	// AcceptableLoss is the loss in percent a trial may see and still pass.
+	AcceptableLoss float64
+}
+
+func (o *ThroughputOptions) setDefaults() {
+	if len(o.FrameSizes) == 0 {
+		o.FrameSizes = DefaultFrameSizes
+	}
+	if o.Duration == 0 {
+		o.Duration = 60 * time.Second
+	}
+	if o.MaxRate == 0 {
+		o.MaxRate = 100
+	}
+	if o.Resolution == 0 {
+		o.Resolution = 0.5
+	}
+}
+
+// Trial is one fixed-duration run of the benchmark flow at a given rate.
+type Trial struct {
+	FrameSize uint32  `json:"frame_size"`
+	Rate      float64 `json:"rate_pct"`
+	TxFrames  uint64  `json:"tx_frames"`
+	RxFrames  uint64  `json:"rx_frames"`
+	Loss      float64 `json:"loss_pct"`
+	Pass      bool    `json:"pass"`
+}
+
+// ThroughputResult is the highest passing rate found for one frame size.
+type ThroughputResult struct {
+	FrameSize uint32 `json:"frame_size"`
+	// Rate is the throughput in percent of line rate, 0 if no trial passed.
+	Rate float64 `json:"rate_pct"`
+	// Fps is the frames per second sent by the passing trial.
+	Fps    float64 `json:"fps"`
+	Trials []Trial `json:"trials"`
+}
+
+// ThroughputResults is the result set of the throughput test.
+type ThroughputResults []ThroughputResult
+
+func (r ThroughputResults) Name() string {
+	return "throughput"
+}
+
+func (r ThroughputResults) Header() []string {
+	return []string{"frame_size", "throughput_pct", "fps", "trials"}
+}
+
+func (r ThroughputResults) Rows() [][]string {
+	rows := [][]string{}
+	for _, res := range r {
+		rows = append(rows, []string{
+			strconv.FormatUint(uint64(res.FrameSize), 10),
+			strconv.FormatFloat(res.Rate, 'f', 3, 64),
+			strconv.FormatFloat(res.Fps, 'f', 1, 64),
+			strconv.Itoa(len(res.Trials)),
+		})
+	}
+	return rows
+}
+
+// Throughput runs the RFC 2544 throughput test: for every frame size it
+// binary searches the highest rate at which no more than AcceptableLoss of the
+// frames are lost.
+func (b *Benchmark) Throughput(opts ThroughputOptions) (ThroughputResults, error) {
+	if err := b.validate(); err != nil {
+		return nil, err
+	}
+	opts.setDefaults()
+
+	results := ThroughputResults{}
+	for _, size := range opts.FrameSizes {
+		res := ThroughputResult{FrameSize: size}
+		var fps float64
+		rate, err := search(opts.MaxRate, opts.Resolution, func(rate float64) (bool, error) {
+			trial, _, err := b.rateTrial(size, rate, opts.Duration, nil)
+			if err != nil {
+				return false, err
+			}
+			trial.Pass = trial.TxFrames > 0 && trial.Loss <= opts.AcceptableLoss
+			b.logf("throughput: size %d rate %.3f%% tx %d rx %d loss %.4f%% pass %v",
+				size, rate, trial.TxFrames, trial.RxFrames, trial.Loss, trial.Pass)
+			res.Trials = append(res.Trials, trial)
+			if trial.Pass {
+				fps = float64(trial.TxFrames) / opts.Duration.Seconds()
+			}
+			return trial.Pass, nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("throughput for frame size %d: %v", size, err)
+		}
+		res.Rate = rate
+		if rate > 0 {
+			res.Fps = fps
+		}
+		results = append(results, res)
+	}
+	return results, nil
+}
+
+// rateTrial transmits frames of the given size at rate percent of line rate
+// for d and returns the counts along with the final flow metric. extra, if
+// set, configures the flow further, e.g. to enable latency metrics.
+func (b *Benchmark) rateTrial(size uint32, rate float64, d time.Duration, extra func(flow gosnappi.Flow)) (Trial, gosnappi.FlowMetric, error) {
+	m, err := b.runTrial(d, func(flow gosnappi.Flow) {
+		flow.Size().SetFixed(size)
+		flow.Rate().SetPercentage(float32(rate))
+		flow.Duration().FixedSeconds().SetSeconds(float32(d.Seconds()))
+		if extra != nil {
+			extra(flow)
+		}
+	})
+	if err != nil {
+		return Trial{}, nil, err
+	}
+	return Trial{
+		FrameSize: size,
+		Rate:      rate,
+		TxFrames:  m.FramesTx(),
+		RxFrames:  m.FramesRx(),
+		Loss:      lossPct(m.FramesTx(), m.FramesRx()),
+	}, m, nil
+}
+
+// search binary searches the highest value in (0, max] for which try passes,
+// starting at max and stopping once the interval is narrower than resolution.
+// It returns 0 if no value passed.
+func search(max, resolution float64, try func(v float64) (bool, error)) (float64, error) {
+	lo, hi := 0.0, max
+	best := 0.0
+	v := max
+	for {
+		pass, err := try(v)
+		if err != nil {
+			return 0, err
+		}
+		if pass {
+			best, lo = v, v
+		} else {
+			hi = v
+		}
+		if hi-lo < resolution {
+			return best, nil
+		}
+		v = (lo + hi) / 2
+	}
+}
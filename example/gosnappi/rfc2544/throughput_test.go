@@ -0,0 +1,63 @@
+package rfc2544
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestSearch(t *testing.T) {
+	// Trials pass up to limit percent; the search must land at most 0.5
+	// percent below it.
+	tests := []struct {
+		desc  string
+		limit float64
+	}{
+		{"line rate", 100},
+		{"partial", 63.2},
+		{"none", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			tries := 0
+			got, err := search(100, 0.5, func(v float64) (bool, error) {
+				tries++
+				return v <= tt.limit, nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got > tt.limit || tt.limit-got >= 0.5 {
+				t.Errorf("search() got %v, want within 0.5 below %v", got, tt.limit)
+			}
+			if tries > 10 {
+				t.Errorf("search() took %d trials, want at most 10", tries)
+			}
+		})
+	}
+}
+
+func TestWriteCSV(t *testing.T) {
+	results := ThroughputResults{
+		{FrameSize: 64, Rate: 100, Fps: 148809.5, Trials: []Trial{{FrameSize: 64, Rate: 100, Pass: true}}},
+		{FrameSize: 1518, Rate: 87.5, Fps: 7152.1},
+	}
+	b := &bytes.Buffer{}
+	if err := WriteCSV(b, results); err != nil {
+		t.Fatal(err)
+	}
+	want := "frame_size,throughput_pct,fps,trials\n" +
+		"64,100.000,148809.5,1\n" +
+		"1518,87.500,7152.1,0\n"
+	if got := b.String(); got != want {
+		t.Errorf("WriteCSV() got:\n%s\nwant:\n%s", got, want)
+	}
+
+	b.Reset()
+	if err := WriteJSON(b, results); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(b.String(), `"throughput"`) || !strings.Contains(b.String(), `"rate_pct": 87.5`) {
+		t.Errorf("WriteJSON() got unexpected output:\n%s", b.String())
+	}
+}
@@ -0,0 +1,130 @@
+package gosnappi_examples
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/SpirentOrion/stc-otg/example/gosnappi/rfc2544"
+	"github.com/open-traffic-generator/snappi/gosnappi"
+)
+
+// PORT1=10.109.121.181/1/1 PORT2=10.109.123.254/1/1 OTGSERVER=localhost:50051 go test -v -test.run TestRfc2544Throughput
+func TestRfc2544Throughput(t *testing.T) {
+	// Create a new API handle to make API calls against OTG
+	api := gosnappi.NewApi()
+
+	// Set the transport protocol to gRPC
+	api.NewGrpcTransport().
+		SetLocation(OTGSERVER).
+		SetDialTimeout(3 * time.Minute).
+		SetRequestTimeout(10 * time.Minute)
+
+	// The benchmark adds its own flow to a copy of this config for every trial
+	config := gosnappi.NewConfig()
+	ptx := config.Ports().Add().SetName("port1").SetLocation(PORT1)
+	prx := config.Ports().Add().SetName("port2").SetLocation(PORT2)
+
+	bm := &rfc2544.Benchmark{
+		Api:    api,
+		Config: config,
+		TxPort: ptx.Name(),
+		RxPort: prx.Name(),
+		Logf:   t.Logf,
+	}
+	results, err := bm.Throughput(rfc2544.ThroughputOptions{
+		FrameSizes: []uint32{64, 512, 1518},
+		Duration:   10 * time.Second,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := rfc2544.WriteCSV(os.Stdout, results); err != nil {
+		t.Fatal(err)
+	}
+	for _, res := range results {
+		if res.Rate == 0 {
+			t.Errorf("No trial passed for frame size %d", res.FrameSize)
+		}
+	}
+}
+
+// PORT1=10.109.121.181/1/1 PORT2=10.109.123.254/1/1 OTGSERVER=localhost:50051 go test -v -test.run TestRfc2544LatencyLoss
+func TestRfc2544LatencyLoss(t *testing.T) {
+	// Create a new API handle to make API calls against OTG
+	api := gosnappi.NewApi()
+
+	// Set the transport protocol to gRPC
+	api.NewGrpcTransport().
+		SetLocation(OTGSERVER).
+		SetDialTimeout(3 * time.Minute).
+		SetRequestTimeout(10 * time.Minute)
+
+	config := gosnappi.NewConfig()
+	ptx := config.Ports().Add().SetName("port1").SetLocation(PORT1)
+	prx := config.Ports().Add().SetName("port2").SetLocation(PORT2)
+
+	bm := &rfc2544.Benchmark{
+		Api:    api,
+		Config: config,
+		TxPort: ptx.Name(),
+		RxPort: prx.Name(),
+		Logf:   t.Logf,
+	}
+	results, err := bm.LatencyLoss(rfc2544.LatencyLossOptions{
+		FrameSizes: []uint32{64, 1518},
+		Duration:   10 * time.Second,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := rfc2544.WriteCSV(os.Stdout, results); err != nil {
+		t.Fatal(err)
+	}
+	// Loss and average latency per load, ready to plot
+	if err := results.WritePlotCSV(os.Stdout); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// PORT1=10.109.121.181/1/1 PORT2=10.109.123.254/1/1 OTGSERVER=localhost:50051 go test -v -test.run TestRfc2544BackToBack
+func TestRfc2544BackToBack(t *testing.T) {
+	// Create a new API handle to make API calls against OTG
+	api := gosnappi.NewApi()
+
+	// Set the transport protocol to gRPC
+	api.NewGrpcTransport().
+		SetLocation(OTGSERVER).
+		SetDialTimeout(3 * time.Minute).
+		SetRequestTimeout(10 * time.Minute)
+
+	config := gosnappi.NewConfig()
+	ptx := config.Ports().Add().SetName("port1").SetLocation(PORT1)
+	prx := config.Ports().Add().SetName("port2").SetLocation(PORT2)
+
+	// The longest burst tried defaults to 2s at line rate, taken from the layer1 speed
+	config.Layer1().Add().SetName("l1").
+		SetPortNames([]string{ptx.Name(), prx.Name()}).
+		SetSpeed(gosnappi.Layer1Speed.SPEED_10_GBPS)
+
+	bm := &rfc2544.Benchmark{
+		Api:    api,
+		Config: config,
+		TxPort: ptx.Name(),
+		RxPort: prx.Name(),
+		Logf:   t.Logf,
+	}
+	results, err := bm.BackToBack(rfc2544.BackToBackOptions{
+		FrameSizes:  []uint32{64, 1518},
+		Repetitions: 5,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := rfc2544.WriteCSV(os.Stdout, results); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,60 @@
+package gosnappi_examples
+
+import (
+	"testing"
+	"time"
+
+	"github.com/SpirentOrion/stc-otg/example/gosnappi/metrictags"
+	"github.com/SpirentOrion/stc-otg/example/gosnappi/trial"
+	"github.com/open-traffic-generator/snappi/gosnappi"
+)
+
+// PORT1=10.109.121.181/1/1 PORT2=10.109.123.254/1/1 OTGSERVER=localhost:50051 go test -v -test.run TestTaggedMetrics
+func TestTaggedMetrics(t *testing.T) {
+	// Create a new API handle to make API calls against OTG
+	api := gosnappi.NewApi()
+
+	// Set the transport protocol to gRPC
+	api.NewGrpcTransport().
+		SetLocation(OTGSERVER).
+		SetDialTimeout(3 * time.Minute).
+		SetRequestTimeout(10 * time.Minute)
+
+	config := gosnappi.NewConfig()
+	ptx := config.Ports().Add().SetName("port1").SetLocation(PORT1)
+	prx := config.Ports().Add().SetName("port2").SetLocation(PORT2)
+
+	// Send 10000 frames to 5 destination addresses and break the flow
+	// metrics down by destination
+	flow := config.Flows().Add().SetName("flow1")
+	flow.TxRx().Port().SetTxName(ptx.Name()).SetRxNames([]string{prx.Name()})
+	flow.Metrics().SetEnable(true)
+	flow.Rate().SetPps(1000)
+	flow.Size().SetFixed(128)
+	flow.Duration().FixedPackets().SetPackets(10000)
+	eth := flow.Packet().Add().Ethernet()
+	eth.Src().SetValue("00:11:22:33:44:66")
+	eth.Dst().SetValue("00:11:22:33:44:55")
+	ipv4 := flow.Packet().Add().Ipv4()
+	ipv4.Src().SetValue("10.1.1.1")
+	ipv4.Dst().Increment().SetStart("20.1.1.1").SetCount(5)
+	tag, err := metrictags.Add(flow, ipv4.Dst(), "dst")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Run the flow until it stops on its own and let the last frames arrive,
+	// so the tagged rx counters are final when they are summed
+	runner := trial.Runner{Api: api, Settle: 2 * time.Second, TaggedMetrics: true}
+	metrics, err := runner.Run(config, []string{flow.Name()}, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Each destination gets a fifth of the frames
+	res := tag.Analyze(metrics[flow.Name()], 0.001)
+	t.Log("\n" + res.String())
+	if err := res.Check(); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,105 @@
+// Package trial runs the traffic of one measurement: it pushes a config,
+// starts its flows, waits for them to stop transmitting on their own and
+// returns their final flow metrics. The rfc2544, y1564 and headerstack tests
+// run every trial through it. RunFlows runs the flows of a config that is
+// already pushed, for tests that start protocols before their traffic.
+package trial
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/open-traffic-generator/snappi/gosnappi"
+)
+
+// Runner runs trials against an OTG service.
+type Runner struct {
+	Api gosnappi.Api
+	// PollInterval is how often flow metrics are fetched while a trial runs,
+	// 1s when zero.
+	PollInterval time.Duration
+	// Settle is how long to wait after the flows stopped transmitting before
+	// the final metrics are read, to let in-flight frames arrive. None when
+	// zero.
+	Settle time.Duration
+	// TaggedMetrics includes the tagged metrics of the flows in their
+	// metrics.
+	TaggedMetrics bool
+}
+
+// Run pushes config, starts the named flows, all flows of config when names
+// is empty, and polls their metrics until all of them stopped transmitting.
+// Flows still transmitting after limit are stopped and the trial fails. It
+// returns the final metrics of the flows by name.
+func (r Runner) Run(config gosnappi.Config, names []string, limit time.Duration) (map[string]gosnappi.FlowMetric, error) {
+	if _, err := r.Api.SetConfig(config); err != nil {
+		return nil, err
+	}
+	return r.RunFlows(names, limit)
+}
+
+// RunFlows is Run on the config already pushed, e.g. once the protocols of
+// its devices are up.
+func (r Runner) RunFlows(names []string, limit time.Duration) (map[string]gosnappi.FlowMetric, error) {
+	cs := gosnappi.NewControlState()
+	cs.Traffic().FlowTransmit().
+		SetState(gosnappi.StateTrafficFlowTransmitState.START).
+		SetFlowNames(names)
+	if _, err := r.Api.SetControlState(cs); err != nil {
+		return nil, err
+	}
+
+	interval := r.PollInterval
+	if interval == 0 {
+		interval = time.Second
+	}
+	deadline := time.Now().Add(limit)
+	for {
+		time.Sleep(interval)
+		metrics, err := r.metrics(names)
+		if err != nil {
+			return nil, err
+		}
+		stopped := true
+		for _, m := range metrics {
+			stopped = stopped && m.Transmit() == gosnappi.FlowMetricTransmit.STOPPED
+		}
+		if stopped {
+			break
+		}
+		if time.Now().After(deadline) {
+			cs.Traffic().FlowTransmit().SetState(gosnappi.StateTrafficFlowTransmitState.STOP)
+			if _, err := r.Api.SetControlState(cs); err != nil {
+				return nil, err
+			}
+			if len(names) == 0 {
+				return nil, fmt.Errorf("flows did not stop transmitting within %v", limit)
+			}
+			return nil, fmt.Errorf("flows %v did not stop transmitting within %v", names, limit)
+		}
+	}
+
+	time.Sleep(r.Settle)
+	return r.metrics(names)
+}
+
+// metrics returns the flow metrics of the named flows, of all flows when
+// names is empty, by name.
+func (r Runner) metrics(names []string) (map[string]gosnappi.FlowMetric, error) {
+	req := gosnappi.NewMetricsRequest()
+	req.Flow().SetFlowNames(names).TaggedMetrics().SetInclude(r.TaggedMetrics)
+	metrics, err := r.Api.GetMetrics(req)
+	if err != nil {
+		return nil, err
+	}
+	byName := map[string]gosnappi.FlowMetric{}
+	for _, m := range metrics.FlowMetrics().Items() {
+		byName[m.Name()] = m
+	}
+	for _, name := range names {
+		if byName[name] == nil {
+			return nil, fmt.Errorf("no metrics returned for flow %s", name)
+		}
+	}
+	return byName, nil
+}
@@ -0,0 +1,109 @@
+package trial
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/SpirentOrion/stc-otg/example/gosnappi/otgsimtest"
+	"github.com/open-traffic-generator/snappi/gosnappi"
+)
+
+func testConfig() gosnappi.Config {
+	config := gosnappi.NewConfig()
+	config.Ports().Add().SetName("p1")
+	config.Ports().Add().SetName("p2")
+	for _, name := range []string{"f1", "f2"} {
+		flow := config.Flows().Add().SetName(name)
+		flow.TxRx().Port().SetTxName("p1").SetRxNames([]string{"p2"})
+		flow.Metrics().SetEnable(true)
+		flow.Packet().Add().Ethernet()
+		flow.Packet().Add().Ipv4()
+		flow.Rate().SetPps(1000)
+		flow.Duration().FixedPackets().SetPackets(1000)
+	}
+	return config
+}
+
+func TestRun(t *testing.T) {
+	_, api := otgsimtest.Start(t)
+	r := Runner{Api: api, PollInterval: time.Millisecond}
+
+	metrics, err := r.Run(testConfig(), []string{"f1"}, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(metrics) != 1 || metrics["f1"].FramesRx() != 1000 {
+		t.Errorf("Run(f1) got %v, want f1 with 1000 frames received", metrics)
+	}
+
+	// Without names all flows run.
+	metrics, err = r.Run(testConfig(), nil, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"f1", "f2"} {
+		if m := metrics[name]; m == nil || m.FramesRx() != 1000 {
+			t.Errorf("Run() got %v for %s, want 1000 frames received", m, name)
+		}
+	}
+}
+
+func TestRunFlows(t *testing.T) {
+	_, api := otgsimtest.Start(t)
+	if _, err := api.SetConfig(testConfig()); err != nil {
+		t.Fatal(err)
+	}
+	r := Runner{Api: api, PollInterval: time.Millisecond}
+	metrics, err := r.RunFlows([]string{"f2"}, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(metrics) != 1 || metrics["f2"].FramesRx() != 1000 {
+		t.Errorf("RunFlows(f2) got %v, want f2 with 1000 frames received", metrics)
+	}
+}
+
+func TestRunTaggedMetrics(t *testing.T) {
+	_, api := otgsimtest.Start(t)
+	config := testConfig()
+	config.Flows().Items()[0].Packet().Items()[1].Ipv4().Src().MetricTags().Add().SetName("src")
+
+	for _, tagged := range []bool{false, true} {
+		r := Runner{Api: api, PollInterval: time.Millisecond, TaggedMetrics: tagged}
+		metrics, err := r.Run(config, []string{"f1"}, time.Second)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := len(metrics["f1"].TaggedMetrics().Items()) > 0; got != tagged {
+			t.Errorf("Run() with TaggedMetrics %t got tagged metrics %t", tagged, got)
+		}
+	}
+}
+
+func TestRunLimit(t *testing.T) {
+	_, api := otgsimtest.Start(t)
+	r := Runner{Api: api, PollInterval: time.Millisecond}
+
+	config := testConfig()
+	config.Flows().Items()[0].Duration().Continuous()
+	_, err := r.Run(config, []string{"f1"}, 10*time.Millisecond)
+	if err == nil || !strings.Contains(err.Error(), "did not stop") {
+		t.Fatalf("Run of a continuous flow got error %v, want one about the flow not stopping", err)
+	}
+
+	// The flow was stopped on the way out.
+	req := gosnappi.NewMetricsRequest()
+	req.Flow().SetFlowNames([]string{"f1"})
+	res, err := api.GetMetrics(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m := res.FlowMetrics().Items()[0]; m.Transmit() != gosnappi.FlowMetricTransmit.STOPPED {
+		t.Errorf("flow f1 is %s after Run timed out, want stopped", m.Transmit())
+	}
+
+	if _, err := r.Run(testConfig(), []string{"f3"}, time.Second); err == nil {
+		t.Errorf("Run of an unknown flow should fail")
+	}
+}
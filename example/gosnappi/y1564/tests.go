@@ -0,0 +1,146 @@
+package y1564
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// ConfigurationOptions controls the service configuration test.
+type ConfigurationOptions struct {
+	// Steps are the loads every service is stepped through, in percent of
+	// its CIR, 25, 50, 75 and 100 when empty.
+	Steps []float64
+	// StepDuration is how long every step runs, 60s when zero.
+	StepDuration time.Duration
+	// SkipEIR leaves out the CIR+EIR step of services with an EIR.
+	SkipEIR bool
+}
+
+func (o *ConfigurationOptions) setDefaults() {
+	if len(o.Steps) == 0 {
+		o.Steps = []float64{25, 50, 75, 100}
+	}
+	if o.StepDuration == 0 {
+		o.StepDuration = 60 * time.Second
+	}
+}
+
+// ConfigurationResults is the result set of the service configuration test,
+// one result per service and step in the order they ran.
+type ConfigurationResults []Result
+
+func (r ConfigurationResults) Name() string {
+	return "y1564_configuration"
+}
+
+func (r ConfigurationResults) Header() []string {
+	return header()
+}
+
+func (r ConfigurationResults) Rows() [][]string {
+	return rows(r)
+}
+
+// Passed reports whether every step of every service passed.
+func (r ConfigurationResults) Passed() bool {
+	return passed(r)
+}
+
+// Configuration runs the service configuration test: every service runs on
+// its own through the CIR steps, where it must meet its SLA, and then at
+// CIR+EIR, where its IR must still reach the CIR.
+func (t *Test) Configuration(opts ConfigurationOptions) (ConfigurationResults, error) {
+	if err := t.validate(); err != nil {
+		return nil, err
+	}
+	opts.setDefaults()
+
+	results := ConfigurationResults{}
+	for _, svc := range t.Services {
+		for _, step := range opts.Steps {
+			name := strconv.FormatFloat(step, 'f', -1, 64) + "% CIR"
+			res, err := t.step(name, load{svc: svc, rate: svc.CIR * step / 100}, opts.StepDuration, true)
+			if err != nil {
+				return nil, err
+			}
+			results = append(results, res)
+		}
+		if svc.EIR > 0 && !opts.SkipEIR {
+			res, err := t.step("CIR+EIR", load{svc: svc, rate: svc.CIR + svc.EIR}, opts.StepDuration, false)
+			if err != nil {
+				return nil, err
+			}
+			results = append(results, res)
+		}
+	}
+	return results, nil
+}
+
+// step runs a single service at one load.
+func (t *Test) step(name string, l load, d time.Duration, sla bool) (Result, error) {
+	metrics, err := t.run([]load{l}, d)
+	if err != nil {
+		return Result{}, fmt.Errorf("service %s at %s: %v", l.svc.Name, name, err)
+	}
+	res := evaluate(name, l, metrics[l.svc.Name], d, sla)
+	t.logf("configuration: service %s step %s rate %.3f Mbps IR %.3f Mbps FLR %.4f%% FTD %.0f ns FDV %.0f ns pass %v",
+		res.Service, res.Step, res.Rate, res.IR, res.FLR, res.FTDNs, res.FDVNs, res.Pass)
+	return res, nil
+}
+
+// PerformanceOptions controls the service performance test.
+type PerformanceOptions struct {
+	// Duration of the test, 15 minutes when zero. Y.1564 suggests 15
+	// minutes, 2 hours or 24 hours.
+	Duration time.Duration
+}
+
+// PerformanceResults is the result set of the service performance test, one
+// result per service.
+type PerformanceResults []Result
+
+func (r PerformanceResults) Name() string {
+	return "y1564_performance"
+}
+
+func (r PerformanceResults) Header() []string {
+	return header()
+}
+
+func (r PerformanceResults) Rows() [][]string {
+	return rows(r)
+}
+
+// Passed reports whether every service passed.
+func (r PerformanceResults) Passed() bool {
+	return passed(r)
+}
+
+// Performance runs the service performance test: all services run
+// concurrently at their CIR for the whole duration and must meet their SLA.
+func (t *Test) Performance(opts PerformanceOptions) (PerformanceResults, error) {
+	if err := t.validate(); err != nil {
+		return nil, err
+	}
+	if opts.Duration == 0 {
+		opts.Duration = 15 * time.Minute
+	}
+
+	loads := []load{}
+	for _, svc := range t.Services {
+		loads = append(loads, load{svc: svc, rate: svc.CIR})
+	}
+	metrics, err := t.run(loads, opts.Duration)
+	if err != nil {
+		return nil, fmt.Errorf("performance test: %v", err)
+	}
+	results := PerformanceResults{}
+	for _, l := range loads {
+		res := evaluate("performance", l, metrics[l.svc.Name], opts.Duration, true)
+		t.logf("performance: service %s IR %.3f Mbps FLR %.4f%% FTD %.0f ns FDV %.0f ns pass %v",
+			res.Service, res.IR, res.FLR, res.FTDNs, res.FDVNs, res.Pass)
+		results = append(results, res)
+	}
+	return results, nil
+}
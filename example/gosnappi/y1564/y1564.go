@@ -0,0 +1,242 @@
+// Package y1564 runs ITU-T Y.1564 Ethernet service activation tests with
+// gosnappi flows: the service configuration test, which steps every service
+// on its own up to its CIR and CIR+EIR, and the service performance test,
+// which runs all services together at CIR for a long time. Every service is
+// checked against its frame loss ratio, frame transfer delay and frame delay
+// variation thresholds using the flow metrics of the OTG service.
+package y1564
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/SpirentOrion/stc-otg/example/gosnappi/linerate"
+	"github.com/SpirentOrion/stc-otg/example/gosnappi/trial"
+	"github.com/open-traffic-generator/snappi/gosnappi"
+)
+
+// Sla holds the acceptance thresholds of a service.
+type Sla struct {
+	// FLR is the highest frame loss ratio allowed, in percent.
+	FLR float64
+	// FTD is the highest average frame transfer delay allowed, not checked
+	// when zero.
+	FTD time.Duration
+	// FDV is the highest frame delay variation allowed, measured as the
+	// difference between the maximum and minimum delay, not checked when
+	// zero.
+	FDV time.Duration
+}
+
+// Service is one Ethernet service under test, sent as one flow named after it.
+type Service struct {
+	Name   string
+	TxPort string
+	RxPort string
+	// FrameSize of the service frames, 512 when zero.
+	FrameSize uint32
+	// CIR is the committed information rate in Mbps.
+	CIR float64
+	// EIR is the excess information rate in Mbps on top of the CIR, 0 if the
+	// service has none.
+	EIR float64
+	// Headers adds the packet headers of the service flow, e.g. its VLAN.
+	// Plain ethernet/ipv4 headers are used when nil.
+	Headers func(flow gosnappi.Flow)
+	Sla     Sla
+}
+
+func (s *Service) frameSize() uint32 {
+	if s.FrameSize == 0 {
+		return 512
+	}
+	return s.FrameSize
+}
+
+// Test holds the services and what every run needs.
+type Test struct {
+	Api gosnappi.Api
+	// Config holds the ports (and devices) of the test. Every run pushes a
+	// copy of it with the service flows added.
+	Config   gosnappi.Config
+	Services []Service
+	// PollInterval is how often flow metrics are fetched while a run is in
+	// progress, 1s when zero.
+	PollInterval time.Duration
+	// Settle is how long to wait after the flows stopped transmitting
+	// before the final metrics are read, 2s when zero.
+	Settle time.Duration
+	// Logf, if set, receives progress messages, e.g. t.Logf.
+	Logf func(format string, args ...any)
+}
+
+func (t *Test) logf(format string, args ...any) {
+	if t.Logf != nil {
+		t.Logf(format, args...)
+	}
+}
+
+func (t *Test) validate() error {
+	if t.Api == nil || t.Config == nil {
+		return fmt.Errorf("test needs an api and a config")
+	}
+	if len(t.Services) == 0 {
+		return fmt.Errorf("test has no services")
+	}
+	ports := map[string]bool{}
+	for _, p := range t.Config.Ports().Items() {
+		ports[p.Name()] = true
+	}
+	names := map[string]bool{}
+	for _, s := range t.Services {
+		if s.Name == "" || names[s.Name] {
+			return fmt.Errorf("service names must be unique and not empty, got %q", s.Name)
+		}
+		names[s.Name] = true
+		if s.CIR <= 0 {
+			return fmt.Errorf("service %s: CIR must be positive", s.Name)
+		}
+		for _, name := range []string{s.TxPort, s.RxPort} {
+			if !ports[name] {
+				return fmt.Errorf("service %s: port %q is not in the config", s.Name, name)
+			}
+		}
+	}
+	return nil
+}
+
+// Result is the outcome of one service at one step.
+type Result struct {
+	Service string `json:"service"`
+	// Step is "<n>% CIR", "CIR+EIR" or "performance".
+	Step string `json:"step"`
+	// Rate is the offered rate in Mbps.
+	Rate     float64 `json:"rate_mbps"`
+	TxFrames uint64  `json:"tx_frames"`
+	RxFrames uint64  `json:"rx_frames"`
+	// IR is the measured information rate at the receiver in Mbps.
+	IR float64 `json:"ir_mbps"`
+	// FLR is the frame loss ratio in percent.
+	FLR float64 `json:"flr_pct"`
+	// FTDNs is the average frame transfer delay, FDVNs the frame delay
+	// variation.
+	FTDNs    float64  `json:"ftd_ns"`
+	FDVNs    float64  `json:"fdv_ns"`
+	Pass     bool     `json:"pass"`
+	Failures []string `json:"failures,omitempty"`
+}
+
+func header() []string {
+	return []string{"service", "step", "rate_mbps", "tx_frames", "rx_frames", "ir_mbps",
+		"flr_pct", "ftd_ns", "fdv_ns", "pass", "failures"}
+}
+
+func rows(results []Result) [][]string {
+	rows := [][]string{}
+	for _, res := range results {
+		rows = append(rows, []string{
+			res.Service,
+			res.Step,
+			strconv.FormatFloat(res.Rate, 'f', 3, 64),
+			strconv.FormatUint(res.TxFrames, 10),
+			strconv.FormatUint(res.RxFrames, 10),
+			strconv.FormatFloat(res.IR, 'f', 3, 64),
+			strconv.FormatFloat(res.FLR, 'f', 4, 64),
+			strconv.FormatFloat(res.FTDNs, 'f', 1, 64),
+			strconv.FormatFloat(res.FDVNs, 'f', 1, 64),
+			strconv.FormatBool(res.Pass),
+			strings.Join(res.Failures, "; "),
+		})
+	}
+	return rows
+}
+
+func passed(results []Result) bool {
+	for _, res := range results {
+		if !res.Pass {
+			return false
+		}
+	}
+	return true
+}
+
+// load is a service offered at a rate in Mbps.
+type load struct {
+	svc  Service
+	rate float64
+}
+
+// run pushes a copy of the config with a flow per load, transmits them all
+// for d and returns the final flow metrics by service name.
+func (t *Test) run(loads []load, d time.Duration) (map[string]gosnappi.FlowMetric, error) {
+	config, err := t.Config.Clone()
+	if err != nil {
+		return nil, err
+	}
+	names := []string{}
+	for _, l := range loads {
+		flow := config.Flows().Add().SetName(l.svc.Name)
+		flow.TxRx().Port().SetTxName(l.svc.TxPort).SetRxNames([]string{l.svc.RxPort})
+		flow.Metrics().SetEnable(true)
+		flow.Metrics().Latency().SetEnable(true).SetMode(gosnappi.FlowLatencyMetricsMode.STORE_FORWARD)
+		if l.svc.Headers != nil {
+			l.svc.Headers(flow)
+		} else {
+			flow.Packet().Add().Ethernet()
+			flow.Packet().Add().Ipv4()
+		}
+		flow.Size().SetFixed(l.svc.frameSize())
+		flow.Rate().SetBps(uint64(l.rate * 1e6))
+		flow.Duration().FixedSeconds().SetSeconds(float32(d.Seconds()))
+		names = append(names, l.svc.Name)
+	}
+
+	settle := t.Settle
+	if settle == 0 {
+		settle = 2 * time.Second
+	}
+	r := trial.Runner{Api: t.Api, PollInterval: t.PollInterval, Settle: settle}
+	return r.Run(config, names, d+30*time.Second)
+}
+
+// evaluate turns the metric of a load run for d into a result. With sla set,
+// the service thresholds are checked; otherwise, as for the CIR+EIR step, only
+// that the IR reaches the CIR.
+func evaluate(step string, l load, m gosnappi.FlowMetric, d time.Duration, sla bool) Result {
+	res := Result{
+		Service:  l.svc.Name,
+		Step:     step,
+		Rate:     l.rate,
+		TxFrames: m.FramesTx(),
+		RxFrames: m.FramesRx(),
+	}
+	res.IR = linerate.Link{FrameSize: float64(l.svc.frameSize())}.Bps(float64(res.RxFrames)/d.Seconds()) / 1e6
+	if res.TxFrames > 0 && res.RxFrames < res.TxFrames {
+		res.FLR = float64(res.TxFrames-res.RxFrames) * 100 / float64(res.TxFrames)
+	}
+	if m.HasLatency() {
+		res.FTDNs = m.Latency().AverageNs()
+		res.FDVNs = m.Latency().MaximumNs() - m.Latency().MinimumNs()
+	}
+
+	if res.TxFrames == 0 {
+		res.Failures = append(res.Failures, "no frames sent")
+	}
+	if sla {
+		if res.FLR > l.svc.Sla.FLR {
+			res.Failures = append(res.Failures, fmt.Sprintf("FLR %.4f%% above %.4f%%", res.FLR, l.svc.Sla.FLR))
+		}
+		if ftd := l.svc.Sla.FTD; ftd > 0 && res.FTDNs > float64(ftd.Nanoseconds()) {
+			res.Failures = append(res.Failures, fmt.Sprintf("FTD %v above %v", time.Duration(res.FTDNs), ftd))
+		}
+		if fdv := l.svc.Sla.FDV; fdv > 0 && res.FDVNs > float64(fdv.Nanoseconds()) {
+			res.Failures = append(res.Failures, fmt.Sprintf("FDV %v above %v", time.Duration(res.FDVNs), fdv))
+		}
+	} else if res.IR < l.svc.CIR*(1-l.svc.Sla.FLR/100) {
+		res.Failures = append(res.Failures, fmt.Sprintf("IR %.3f Mbps below CIR %.3f Mbps", res.IR, l.svc.CIR))
+	}
+	res.Pass = len(res.Failures) == 0
+	return res
+}
@@ -0,0 +1,123 @@
+package y1564
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/SpirentOrion/stc-otg/example/gosnappi/otgsim"
+	"github.com/SpirentOrion/stc-otg/example/gosnappi/otgsimtest"
+	"github.com/open-traffic-generator/snappi/gosnappi"
+)
+
+// newSimTest returns a test on two 10G ports of a simulated OTG service whose
+// DUT forwards up to 1.2 Gbps per port.
+func newSimTest(t *testing.T, services ...Service) *Test {
+	t.Helper()
+	_, api := otgsimtest.StartDut(t, otgsim.Dut{MaxRate: 12, Latency: 10 * time.Microsecond, Jitter: 4 * time.Microsecond})
+
+	config := gosnappi.NewConfig()
+	config.Ports().Add().SetName("port1")
+	config.Ports().Add().SetName("port2")
+	return &Test{
+		Api:          api,
+		Config:       config,
+		Services:     services,
+		PollInterval: time.Millisecond,
+		Settle:       time.Millisecond,
+		Logf:         t.Logf,
+	}
+}
+
+var (
+	data = Service{
+		Name:   "data",
+		TxPort: "port1",
+		RxPort: "port2",
+		CIR:    1000,
+		EIR:    500,
+		Sla:    Sla{FLR: 0.01, FTD: 20 * time.Microsecond, FDV: 5 * time.Microsecond},
+	}
+	voice = Service{
+		Name:      "voice",
+		TxPort:    "port2",
+		RxPort:    "port1",
+		FrameSize: 128,
+		CIR:       100,
+		Sla:       Sla{FLR: 0.01, FTD: 20 * time.Microsecond, FDV: 10 * time.Nanosecond},
+	}
+)
+
+func TestConfiguration(t *testing.T) {
+	test := newSimTest(t, data)
+	results, err := test.Configuration(ConfigurationOptions{StepDuration: 10 * time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	steps := []string{"25% CIR", "50% CIR", "75% CIR", "100% CIR", "CIR+EIR"}
+	if len(results) != len(steps) {
+		t.Fatalf("Configuration() got %d results, want %d", len(results), len(steps))
+	}
+	for i, res := range results {
+		if res.Step != steps[i] || !res.Pass {
+			t.Errorf("result %d got step %q pass %v (%v), want step %q to pass", i, res.Step, res.Pass, res.Failures, steps[i])
+		}
+		if math.Abs(res.IR-math.Min(res.Rate, 1200)) > 1 {
+			t.Errorf("step %s got IR %.3f Mbps, want %.3f", res.Step, res.IR, math.Min(res.Rate, 1200))
+		}
+	}
+	// The DUT drops the excess above 1.2 Gbps, which is fine for EIR frames.
+	if eir := results[len(results)-1]; eir.FLR == 0 {
+		t.Errorf("CIR+EIR step got no loss, want the excess above 1.2 Gbps dropped")
+	}
+	if !results.Passed() {
+		t.Error("Passed() got false, want true")
+	}
+}
+
+func TestPerformance(t *testing.T) {
+	test := newSimTest(t, data, voice)
+	results, err := test.Performance(PerformanceOptions{Duration: time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("Performance() got %d results, want 2", len(results))
+	}
+	if res := results[0]; !res.Pass || res.FLR != 0 {
+		t.Errorf("service data got pass %v FLR %.4f%% (%v), want pass without loss", res.Pass, res.FLR, res.Failures)
+	}
+	// The voice FDV threshold is below the DUT jitter.
+	res := results[1]
+	if res.Pass || len(res.Failures) != 1 || !strings.HasPrefix(res.Failures[0], "FDV") {
+		t.Errorf("service voice got pass %v failures %v, want a single FDV failure", res.Pass, res.Failures)
+	}
+	if results.Passed() {
+		t.Error("Passed() got true, want false")
+	}
+	if rows := results.Rows(); len(rows) != 2 || len(rows[1]) != len(results.Header()) {
+		t.Errorf("Rows() got %v, want 2 rows matching the header", rows)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	bad := data
+	bad.RxPort = "port3"
+	for _, tc := range []struct {
+		desc     string
+		services []Service
+	}{
+		{"no services", nil},
+		{"duplicate names", []Service{data, data}},
+		{"unknown port", []Service{bad}},
+		{"no CIR", []Service{{Name: "x", TxPort: "port1", RxPort: "port2"}}},
+	} {
+		test := &Test{Api: gosnappi.NewApi(), Config: gosnappi.NewConfig(), Services: tc.services}
+		test.Config.Ports().Add().SetName("port1")
+		test.Config.Ports().Add().SetName("port2")
+		if err := test.validate(); err == nil {
+			t.Errorf("%s: validate() succeeded, want error", tc.desc)
+		}
+	}
+}
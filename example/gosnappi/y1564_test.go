@@ -0,0 +1,80 @@
+package gosnappi_examples
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/SpirentOrion/stc-otg/example/gosnappi/rfc2544"
+	"github.com/SpirentOrion/stc-otg/example/gosnappi/y1564"
+	"github.com/open-traffic-generator/snappi/gosnappi"
+)
+
+// PORT1=10.109.121.181/1/1 PORT2=10.109.123.254/1/1 OTGSERVER=localhost:50051 go test -v -test.run TestY1564
+func TestY1564(t *testing.T) {
+	// Create a new API handle to make API calls against OTG
+	api := gosnappi.NewApi()
+
+	// Set the transport protocol to gRPC
+	api.NewGrpcTransport().
+		SetLocation(OTGSERVER).
+		SetDialTimeout(3 * time.Minute).
+		SetRequestTimeout(10 * time.Minute)
+
+	config := gosnappi.NewConfig()
+	p1 := config.Ports().Add().SetName("port1").SetLocation(PORT1)
+	p2 := config.Ports().Add().SetName("port2").SetLocation(PORT2)
+
+	// Each service is sent as its own flow, tagged with its VLAN
+	vlan := func(id uint32) func(flow gosnappi.Flow) {
+		return func(flow gosnappi.Flow) {
+			flow.Packet().Add().Ethernet()
+			flow.Packet().Add().Vlan().Id().SetValue(id)
+			flow.Packet().Add().Ipv4()
+		}
+	}
+	test := &y1564.Test{
+		Api:    api,
+		Config: config,
+		Services: []y1564.Service{{
+			Name:    "data",
+			TxPort:  p1.Name(),
+			RxPort:  p2.Name(),
+			CIR:     500,
+			EIR:     200,
+			Headers: vlan(100),
+			Sla:     y1564.Sla{FLR: 0.01, FTD: 10 * time.Millisecond, FDV: 5 * time.Millisecond},
+		}, {
+			Name:      "voice",
+			TxPort:    p1.Name(),
+			RxPort:    p2.Name(),
+			FrameSize: 128,
+			CIR:       50,
+			Headers:   vlan(200),
+			Sla:       y1564.Sla{FLR: 0.001, FTD: 5 * time.Millisecond, FDV: time.Millisecond},
+		}},
+		Logf: t.Logf,
+	}
+
+	configuration, err := test.Configuration(y1564.ConfigurationOptions{StepDuration: 10 * time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := rfc2544.WriteCSV(os.Stdout, configuration); err != nil {
+		t.Fatal(err)
+	}
+	if !configuration.Passed() {
+		t.Fatal("Service configuration test failed")
+	}
+
+	performance, err := test.Performance(y1564.PerformanceOptions{Duration: time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := rfc2544.WriteCSV(os.Stdout, performance); err != nil {
+		t.Fatal(err)
+	}
+	if !performance.Passed() {
+		t.Error("Service performance test failed")
+	}
+}
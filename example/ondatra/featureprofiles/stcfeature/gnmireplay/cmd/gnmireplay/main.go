@@ -0,0 +1,84 @@
+// Command gnmireplay records the telemetry of an OTG gNMI service to a file,
+// or serves a recording back as a gNMI target.
+//
+// Record while a test runs, stop with Ctrl-C:
+//
+//	gnmireplay -record -target 10.61.37.199:50052 -file session.jsonl
+//
+// Replay, with the gnmi target of the binding pointed at this host:
+//
+//	gnmireplay -file session.jsonl -listen :50052
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"net"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/openconfig/featureprofiles/stcfeature/gnmireplay"
+	gpb "github.com/openconfig/gnmi/proto/gnmi"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+func main() {
+	record := flag.Bool("record", false, "record from -target instead of replaying")
+	target := flag.String("target", "localhost:50052", "gNMI service to record from")
+	paths := flag.String("paths", strings.Join(gnmireplay.DefaultPaths, ","), "comma separated paths to record")
+	sample := flag.Duration("sample", 0, "sample interval, 0 to let the target decide")
+	file := flag.String("file", "gnmi.jsonl", "recording to write or replay")
+	listen := flag.String("listen", ":50052", "address to serve the replay on")
+	speed := flag.Float64("speed", 1, "replay speed, 0 to serve the whole recording at once")
+	flag.Parse()
+
+	if *record {
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+		conn, err := grpc.Dial(*target, grpc.WithTransportCredentials(insecure.NewCredentials()))
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer conn.Close()
+		f, err := os.Create(*file)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer f.Close()
+
+		r := &gnmireplay.Recorder{
+			Client:         gpb.NewGNMIClient(conn),
+			Paths:          strings.Split(*paths, ","),
+			SampleInterval: *sample,
+		}
+		start := time.Now()
+		log.Printf("recording %s to %s, stop with Ctrl-C", *target, *file)
+		if err := r.Record(ctx, f); err != nil {
+			log.Fatal(err)
+		}
+		log.Printf("recorded %d responses in %v", r.Responses(), time.Since(start).Round(time.Second))
+		return
+	}
+
+	f, err := os.Open(*file)
+	if err != nil {
+		log.Fatal(err)
+	}
+	notifications, err := gnmireplay.Load(f)
+	f.Close()
+	if err != nil {
+		log.Fatal(err)
+	}
+	lis, err := net.Listen("tcp", *listen)
+	if err != nil {
+		log.Fatal(err)
+	}
+	s := gnmireplay.NewServer(notifications)
+	s.Speed = *speed
+	log.Printf("replaying %d notifications of %s on %s", len(notifications), *file, lis.Addr())
+	log.Fatal(s.Serve(lis))
+}
@@ -0,0 +1,164 @@
+// Package gnmireplay records the telemetry of the OTG gNMI service to a file
+// and serves a recording back as a gNMI target, so that tests using
+// gnmi.Get/Watch can be rerun offline against a recorded session.
+//
+// A recording is a JSON lines file. Every line holds the time a
+// SubscribeResponse was received and the response in protojson.
+package gnmireplay
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	gpb "github.com/openconfig/gnmi/proto/gnmi"
+	"github.com/openconfig/ygot/ygot"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// DefaultPaths are the OTG gNMI paths the STC OTG service supports, see
+// SupportedAPIsList.txt.
+var DefaultPaths = []string{
+	"/flows/flow",
+	"/ports/port",
+	"/lags/lag",
+	"/lacp",
+	"/bgp-peers/bgp-peer",
+	"/interfaces/interface",
+}
+
+// entry is one line of a recording.
+type entry struct {
+	Time     time.Time       `json:"time"`
+	Response json.RawMessage `json:"response"`
+}
+
+// Recorder writes the notifications of a gNMI subscription to a recording.
+type Recorder struct {
+	Client gpb.GNMIClient
+	// Paths to subscribe to, DefaultPaths when empty.
+	Paths []string
+	// SampleInterval of the subscriptions, 0 to let the target decide.
+	SampleInterval time.Duration
+
+	mu sync.Mutex
+	n  int
+}
+
+// Responses returns how many responses have been recorded so far.
+func (r *Recorder) Responses() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.n
+}
+
+func (r *Recorder) request() (*gpb.SubscribeRequest, error) {
+	paths := r.Paths
+	if len(paths) == 0 {
+		paths = DefaultPaths
+	}
+	list := &gpb.SubscriptionList{
+		Mode:     gpb.SubscriptionList_STREAM,
+		Encoding: gpb.Encoding_PROTO,
+	}
+	for _, p := range paths {
+		path, err := ygot.StringToStructuredPath(p)
+		if err != nil {
+			return nil, fmt.Errorf("path %q: %v", p, err)
+		}
+		sub := &gpb.Subscription{Path: path, Mode: gpb.SubscriptionMode_TARGET_DEFINED}
+		if r.SampleInterval > 0 {
+			sub.Mode = gpb.SubscriptionMode_SAMPLE
+			sub.SampleInterval = uint64(r.SampleInterval.Nanoseconds())
+		}
+		list.Subscription = append(list.Subscription, sub)
+	}
+	return &gpb.SubscribeRequest{Request: &gpb.SubscribeRequest_Subscribe{Subscribe: list}}, nil
+}
+
+// Record subscribes and writes every response to w until ctx is done or the
+// subscription fails. It returns nil when ctx is done.
+func (r *Recorder) Record(ctx context.Context, w io.Writer) error {
+	req, err := r.request()
+	if err != nil {
+		return err
+	}
+	sub, err := r.Client.Subscribe(ctx)
+	if err != nil {
+		return err
+	}
+	if err := sub.Send(req); err != nil {
+		return err
+	}
+
+	bw := bufio.NewWriter(w)
+	defer bw.Flush()
+	enc := json.NewEncoder(bw)
+	for {
+		resp, err := sub.Recv()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		raw, err := protojson.Marshal(resp)
+		if err != nil {
+			return err
+		}
+		if err := enc.Encode(entry{Time: time.Now(), Response: raw}); err != nil {
+			return err
+		}
+		// Flush every response, so a crashed session still leaves a usable
+		// recording behind.
+		if err := bw.Flush(); err != nil {
+			return err
+		}
+		r.mu.Lock()
+		r.n++
+		r.mu.Unlock()
+	}
+}
+
+// Notification is a recorded notification and when it was received,
+// relative to the start of the recording.
+type Notification struct {
+	Offset       time.Duration
+	Notification *gpb.Notification
+}
+
+// Load reads a recording. Sync responses are dropped; the replay server
+// sends its own.
+func Load(rd io.Reader) ([]Notification, error) {
+	var start time.Time
+	notifications := []Notification{}
+	sc := bufio.NewScanner(rd)
+	sc.Buffer(make([]byte, 1024*1024), 64*1024*1024)
+	for line := 1; sc.Scan(); line++ {
+		if len(sc.Bytes()) == 0 {
+			continue
+		}
+		var e entry
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+		resp := &gpb.SubscribeResponse{}
+		if err := protojson.Unmarshal(e.Response, resp); err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+		if start.IsZero() {
+			start = e.Time
+		}
+		if n := resp.GetUpdate(); n != nil {
+			notifications = append(notifications, Notification{Offset: e.Time.Sub(start), Notification: n})
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return notifications, nil
+}
@@ -0,0 +1,296 @@
+package gnmireplay
+
+import (
+	"context"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	gpb "github.com/openconfig/gnmi/proto/gnmi"
+	"github.com/openconfig/ygot/ygot"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Server is a gNMI target that replays a recording. Its clock starts with the
+// first request, so a test rerun against it sees the telemetry change the way
+// the recorded session did.
+type Server struct {
+	gpb.UnimplementedGNMIServer
+
+	// Speed the recording is replayed at, 1 for real time and 10 for ten
+	// times faster. At 0 the whole recording is available from the start.
+	Speed float64
+
+	notifications []Notification
+
+	mu    sync.Mutex
+	start time.Time
+	grpc  *grpc.Server
+}
+
+// NewServer returns a server replaying notifications at real time.
+func NewServer(notifications []Notification) *Server {
+	return &Server{Speed: 1, notifications: notifications}
+}
+
+// Serve serves the gNMI API on lis until Stop is called.
+func (s *Server) Serve(lis net.Listener) error {
+	s.mu.Lock()
+	s.grpc = grpc.NewServer()
+	gpb.RegisterGNMIServer(s.grpc, s)
+	srv := s.grpc
+	s.mu.Unlock()
+	return srv.Serve(lis)
+}
+
+// Stop stops serving.
+func (s *Server) Stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.grpc != nil {
+		s.grpc.Stop()
+	}
+}
+
+// Rewind restarts the replay from the beginning of the recording.
+func (s *Server) Rewind() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.start = time.Now()
+}
+
+// elapsed returns the replay position, starting the clock if needed.
+func (s *Server) elapsed() time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.start.IsZero() {
+		s.start = time.Now()
+	}
+	return time.Duration(float64(time.Since(s.start)) * s.Speed)
+}
+
+// available returns how many notifications have been replayed by now.
+func (s *Server) available() int {
+	if s.Speed == 0 {
+		return len(s.notifications)
+	}
+	pos := s.elapsed()
+	return sort.Search(len(s.notifications), func(i int) bool {
+		return s.notifications[i].Offset > pos
+	})
+}
+
+// wait blocks until notification i is due or ctx is done.
+func (s *Server) wait(ctx context.Context, i int) error {
+	if s.Speed == 0 {
+		return ctx.Err()
+	}
+	d := time.Duration(float64(s.notifications[i].Offset-s.elapsed()) / s.Speed)
+	if d <= 0 {
+		return ctx.Err()
+	}
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(d):
+		return nil
+	}
+}
+
+func (s *Server) Capabilities(ctx context.Context, _ *gpb.CapabilityRequest) (*gpb.CapabilityResponse, error) {
+	return &gpb.CapabilityResponse{
+		SupportedEncodings: []gpb.Encoding{gpb.Encoding_PROTO, gpb.Encoding_JSON, gpb.Encoding_JSON_IETF},
+		GNMIVersion:        "0.10.0",
+	}, nil
+}
+
+func (s *Server) Get(ctx context.Context, req *gpb.GetRequest) (*gpb.GetResponse, error) {
+	paths := []*gpb.Path{}
+	for _, p := range req.GetPath() {
+		paths = append(paths, Join(req.GetPrefix(), p))
+	}
+	return &gpb.GetResponse{Notification: snapshot(s.notifications[:s.available()], paths)}, nil
+}
+
+func (s *Server) Subscribe(stream gpb.GNMI_SubscribeServer) error {
+	req, err := stream.Recv()
+	if err != nil {
+		return err
+	}
+	list := req.GetSubscribe()
+	if list == nil {
+		return status.Error(codes.InvalidArgument, "first request must be a subscription list")
+	}
+	paths := []*gpb.Path{}
+	for _, sub := range list.GetSubscription() {
+		paths = append(paths, Join(list.GetPrefix(), sub.GetPath()))
+	}
+
+	sendState := func(n int) error {
+		if !list.GetUpdatesOnly() {
+			for _, notification := range snapshot(s.notifications[:n], paths) {
+				if err := stream.Send(update(notification)); err != nil {
+					return err
+				}
+			}
+		}
+		return stream.Send(&gpb.SubscribeResponse{Response: &gpb.SubscribeResponse_SyncResponse{SyncResponse: true}})
+	}
+
+	next := s.available()
+	if err := sendState(next); err != nil {
+		return err
+	}
+	switch list.GetMode() {
+	case gpb.SubscriptionList_ONCE:
+		return nil
+	case gpb.SubscriptionList_POLL:
+		for {
+			req, err := stream.Recv()
+			if err != nil {
+				return err
+			}
+			if req.GetPoll() == nil {
+				return status.Error(codes.InvalidArgument, "expected a poll request")
+			}
+			if err := sendState(s.available()); err != nil {
+				return err
+			}
+		}
+	}
+
+	ctx := stream.Context()
+	for ; next < len(s.notifications); next++ {
+		if err := s.wait(ctx, next); err != nil {
+			return err
+		}
+		if n := filter(s.notifications[next].Notification, paths); n != nil {
+			if err := stream.Send(update(n)); err != nil {
+				return err
+			}
+		}
+	}
+	// The recording is over; keep the stream open like a quiet target.
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func update(n *gpb.Notification) *gpb.SubscribeResponse {
+	return &gpb.SubscribeResponse{Response: &gpb.SubscribeResponse_Update{Update: n}}
+}
+
+// Join returns p below prefix.
+func Join(prefix, p *gpb.Path) *gpb.Path {
+	joined := &gpb.Path{Origin: prefix.GetOrigin(), Target: prefix.GetTarget()}
+	if joined.Origin == "" {
+		joined.Origin = p.GetOrigin()
+	}
+	joined.Elem = append(joined.Elem, prefix.GetElem()...)
+	joined.Elem = append(joined.Elem, p.GetElem()...)
+	return joined
+}
+
+// Matches reports whether path is at or below the subscribed path sub, which
+// may hold "*" names and key values and a trailing "...".
+func Matches(sub, path *gpb.Path) bool {
+	for i, e := range sub.GetElem() {
+		if e.GetName() == "..." {
+			return true
+		}
+		if i >= len(path.GetElem()) {
+			return false
+		}
+		pe := path.GetElem()[i]
+		if e.GetName() != "*" && e.GetName() != pe.GetName() {
+			return false
+		}
+		for k, v := range e.GetKey() {
+			if v != "*" && pe.GetKey()[k] != v {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func matchesAny(paths []*gpb.Path, path *gpb.Path) bool {
+	for _, sub := range paths {
+		if Matches(sub, path) {
+			return true
+		}
+	}
+	return false
+}
+
+// filter returns the part of n that falls under paths, nil if none does.
+// Deletes are kept if they cover a subscribed path or fall under one.
+func filter(n *gpb.Notification, paths []*gpb.Path) *gpb.Notification {
+	out := &gpb.Notification{Timestamp: n.GetTimestamp(), Prefix: n.GetPrefix(), Atomic: n.GetAtomic()}
+	for _, u := range n.GetUpdate() {
+		if matchesAny(paths, Join(n.GetPrefix(), u.GetPath())) {
+			out.Update = append(out.Update, u)
+		}
+	}
+	for _, d := range n.GetDelete() {
+		full := Join(n.GetPrefix(), d)
+		for _, sub := range paths {
+			if Matches(sub, full) || Matches(full, sub) {
+				out.Delete = append(out.Delete, d)
+				break
+			}
+		}
+	}
+	if len(out.Update) == 0 && len(out.Delete) == 0 {
+		return nil
+	}
+	return out
+}
+
+// snapshot returns the latest value of every leaf under paths after the
+// notifications, one notification per leaf, ordered by path.
+func snapshot(notifications []Notification, paths []*gpb.Path) []*gpb.Notification {
+	type leaf struct {
+		path         *gpb.Path
+		notification *gpb.Notification
+	}
+	leaves := map[string]leaf{}
+	for _, rec := range notifications {
+		n := rec.Notification
+		for _, d := range n.GetDelete() {
+			full := Join(n.GetPrefix(), d)
+			for key, l := range leaves {
+				if Matches(full, l.path) {
+					delete(leaves, key)
+				}
+			}
+		}
+		for _, u := range n.GetUpdate() {
+			full := Join(n.GetPrefix(), u.GetPath())
+			key, err := ygot.PathToString(full)
+			if err != nil {
+				continue
+			}
+			leaves[key] = leaf{path: full, notification: &gpb.Notification{
+				Timestamp: n.GetTimestamp(),
+				Prefix:    n.GetPrefix(),
+				Update:    []*gpb.Update{u},
+			}}
+		}
+	}
+
+	keys := []string{}
+	for key, l := range leaves {
+		if matchesAny(paths, l.path) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	out := []*gpb.Notification{}
+	for _, key := range keys {
+		out = append(out, leaves[key].notification)
+	}
+	return out
+}
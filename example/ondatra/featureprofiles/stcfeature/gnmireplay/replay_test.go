@@ -0,0 +1,164 @@
+package gnmireplay
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net"
+	"testing"
+	"time"
+
+	gpb "github.com/openconfig/gnmi/proto/gnmi"
+	"github.com/openconfig/ygot/ygot"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+func mustPath(t *testing.T, s string) *gpb.Path {
+	t.Helper()
+	p, err := ygot.StringToStructuredPath(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func counter(t *testing.T, ts int64, flow string, outPkts uint64) *gpb.Notification {
+	return &gpb.Notification{
+		Timestamp: ts,
+		Prefix:    mustPath(t, "/flows/flow[name="+flow+"]"),
+		Update: []*gpb.Update{{
+			Path: mustPath(t, "/state/counters/out-pkts"),
+			Val:  &gpb.TypedValue{Value: &gpb.TypedValue_UintVal{UintVal: outPkts}},
+		}},
+	}
+}
+
+func TestMatches(t *testing.T) {
+	leaf := "/flows/flow[name=f1]/state/counters/out-pkts"
+	tests := []struct {
+		sub  string
+		want bool
+	}{
+		{"/flows/flow", true},
+		{"/flows/flow[name=f1]", true},
+		{"/flows/flow[name=*]/state", true},
+		{"/flows/*/state/counters", true},
+		{"/flows/...", true},
+		{"/flows/flow[name=f2]", false},
+		{"/ports/port", false},
+		{leaf + "/extra", false},
+	}
+	for _, tc := range tests {
+		if got := Matches(mustPath(t, tc.sub), mustPath(t, leaf)); got != tc.want {
+			t.Errorf("Matches(%s, %s) got %v, want %v", tc.sub, leaf, got, tc.want)
+		}
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	notifications := []Notification{
+		{Notification: counter(t, 1, "f1", 10)},
+		{Notification: counter(t, 1, "f2", 20)},
+		{Notification: counter(t, 2, "f1", 30)},
+		{Notification: &gpb.Notification{Timestamp: 3, Delete: []*gpb.Path{mustPath(t, "/flows/flow[name=f2]")}}},
+	}
+	got := snapshot(notifications, []*gpb.Path{mustPath(t, "/flows/flow")})
+	if len(got) != 1 {
+		t.Fatalf("snapshot() got %d notifications, want only the latest of f1", len(got))
+	}
+	if v := got[0].GetUpdate()[0].GetVal().GetUintVal(); v != 30 || got[0].GetTimestamp() != 2 {
+		t.Errorf("snapshot() got value %d at %d, want 30 at 2", v, got[0].GetTimestamp())
+	}
+	if got := snapshot(notifications[:2], []*gpb.Path{mustPath(t, "/flows/flow[name=f2]")}); len(got) != 1 {
+		t.Errorf("snapshot() of f2 got %d notifications, want 1", len(got))
+	}
+}
+
+func recording(t *testing.T, responses ...*gpb.SubscribeResponse) *bytes.Buffer {
+	t.Helper()
+	b := &bytes.Buffer{}
+	start := time.Now()
+	for i, resp := range responses {
+		raw, err := protojson.Marshal(resp)
+		if err != nil {
+			t.Fatal(err)
+		}
+		line, err := json.Marshal(entry{Time: start.Add(time.Duration(i) * time.Second), Response: raw})
+		if err != nil {
+			t.Fatal(err)
+		}
+		b.Write(append(line, '\n'))
+	}
+	return b
+}
+
+func TestReplay(t *testing.T) {
+	rec := recording(t,
+		update(counter(t, 1, "f1", 10)),
+		&gpb.SubscribeResponse{Response: &gpb.SubscribeResponse_SyncResponse{SyncResponse: true}},
+		update(counter(t, 2, "f1", 20)),
+		update(counter(t, 3, "f1", 30)),
+	)
+	notifications, err := Load(rec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(notifications) != 3 || notifications[2].Offset != 3*time.Second {
+		t.Fatalf("Load() got %d notifications, want 3 with the last at 3s", len(notifications))
+	}
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewServer(notifications)
+	s.Speed = 100
+	go s.Serve(lis)
+	defer s.Stop()
+	conn, err := grpc.Dial(lis.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	client := gpb.NewGNMIClient(conn)
+
+	subscribe := func(mode gpb.SubscriptionList_Mode) gpb.GNMI_SubscribeClient {
+		sub, err := client.Subscribe(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = sub.Send(&gpb.SubscribeRequest{Request: &gpb.SubscribeRequest_Subscribe{Subscribe: &gpb.SubscriptionList{
+			Mode:         mode,
+			Subscription: []*gpb.Subscription{{Path: mustPath(t, "/flows/flow[name=f1]/state/counters/out-pkts")}},
+		}}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return sub
+	}
+	values := func(sub gpb.GNMI_SubscribeClient, n int) []uint64 {
+		got := []uint64{}
+		for len(got) < n {
+			resp, err := sub.Recv()
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, u := range resp.GetUpdate().GetUpdate() {
+				got = append(got, u.GetVal().GetUintVal())
+			}
+		}
+		return got
+	}
+
+	// At 100x the replay clock reaches the last notification after 30ms.
+	stream := subscribe(gpb.SubscriptionList_STREAM)
+	if got := values(stream, 3); got[0] != 10 || got[2] != 30 {
+		t.Errorf("stream got values %v, want 10, 20, 30", got)
+	}
+	once := subscribe(gpb.SubscriptionList_ONCE)
+	if got := values(once, 1); got[0] != 30 {
+		t.Errorf("once got value %d, want the latest value 30", got[0])
+	}
+}
@@ -0,0 +1,36 @@
+// Command gnmisim serves the OTG telemetry of an OTG service over gNMI, e.g.
+// next to the simulated OTG service of the gosnappi examples:
+//
+//	otgsim -listen :50051
+//	gnmisim -otg localhost:50051 -listen :50052
+package main
+
+import (
+	"flag"
+	"log"
+	"net"
+	"time"
+
+	"github.com/open-traffic-generator/snappi/gosnappi"
+	"github.com/openconfig/featureprofiles/stcfeature/gnmisim"
+)
+
+func main() {
+	otgAddr := flag.String("otg", "localhost:50051", "OTG gRPC service to poll")
+	listen := flag.String("listen", ":50052", "address to serve gNMI on")
+	interval := flag.Duration("interval", time.Second, "time between polls of the OTG service")
+	flag.Parse()
+
+	api := gosnappi.NewApi()
+	api.NewGrpcTransport().SetLocation(*otgAddr)
+	s := gnmisim.New(api)
+	s.Interval = *interval
+	s.Logf = log.Printf
+
+	lis, err := net.Listen("tcp", *listen)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("serving gNMI on %s for %s", lis.Addr(), *otgAddr)
+	log.Fatal(s.Serve(lis))
+}
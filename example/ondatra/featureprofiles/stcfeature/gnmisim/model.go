@@ -0,0 +1,222 @@
+package gnmisim
+
+import (
+	"encoding/binary"
+	"math"
+	"strings"
+
+	"github.com/open-traffic-generator/snappi/gosnappi/otg"
+	gpb "github.com/openconfig/gnmi/proto/gnmi"
+	"github.com/openconfig/ygot/ygot"
+)
+
+// leaves holds the telemetry of one poll, keyed by path string.
+type leaves map[string]*gpb.Update
+
+// add adds the leaf at rel, a "/" separated path below base.
+func (l leaves) add(base *gpb.Path, rel string, val *gpb.TypedValue) {
+	p := &gpb.Path{Elem: append([]*gpb.PathElem{}, base.GetElem()...)}
+	for _, name := range strings.Split(rel, "/") {
+		p.Elem = append(p.Elem, &gpb.PathElem{Name: name})
+	}
+	key, err := ygot.PathToString(p)
+	if err != nil {
+		return
+	}
+	l[key] = &gpb.Update{Path: p, Val: val}
+}
+
+// entry returns the path of a list entry, e.g. /flows/flow[name=f1]. keys are
+// name, value pairs.
+func entry(base *gpb.Path, container, list string, keys ...string) *gpb.Path {
+	e := &gpb.PathElem{Name: list, Key: map[string]string{}}
+	for i := 0; i+1 < len(keys); i += 2 {
+		e.Key[keys[i]] = keys[i+1]
+	}
+	p := &gpb.Path{Elem: append([]*gpb.PathElem{}, base.GetElem()...)}
+	p.Elem = append(p.Elem, &gpb.PathElem{Name: container}, e)
+	return p
+}
+
+func strVal(v string) *gpb.TypedValue {
+	return &gpb.TypedValue{Value: &gpb.TypedValue_StringVal{StringVal: v}}
+}
+
+func uintVal(v uint64) *gpb.TypedValue {
+	return &gpb.TypedValue{Value: &gpb.TypedValue_UintVal{UintVal: v}}
+}
+
+func boolVal(v bool) *gpb.TypedValue {
+	return &gpb.TypedValue{Value: &gpb.TypedValue_BoolVal{BoolVal: v}}
+}
+
+// floatVal encodes v as an ieeefloat32, the type of OTG rates and loss.
+func floatVal(v float32) *gpb.TypedValue {
+	b := make([]byte, 4)
+	binary.BigEndian.PutUint32(b, math.Float32bits(v))
+	return &gpb.TypedValue{Value: &gpb.TypedValue_BytesVal{BytesVal: b}}
+}
+
+// upDown returns the OTG model value of a link or oper status.
+func upDown(up bool) *gpb.TypedValue {
+	if up {
+		return strVal("UP")
+	}
+	return strVal("DOWN")
+}
+
+func addPorts(l leaves, metrics []*otg.PortMetric) {
+	for _, m := range metrics {
+		p := entry(nil, "ports", "port", "name", m.GetName())
+		l.add(p, "name", strVal(m.GetName()))
+		l.add(p, "state/name", strVal(m.GetName()))
+		l.add(p, "state/link", upDown(m.GetLink() == otg.PortMetric_Link_up))
+		l.add(p, "state/counters/in-frames", uintVal(m.GetFramesRx()))
+		l.add(p, "state/counters/out-frames", uintVal(m.GetFramesTx()))
+		l.add(p, "state/counters/in-octets", uintVal(m.GetBytesRx()))
+		l.add(p, "state/counters/out-octets", uintVal(m.GetBytesTx()))
+	}
+}
+
+func addFlows(l leaves, metrics []*otg.FlowMetric) {
+	for _, m := range metrics {
+		p := entry(nil, "flows", "flow", "name", m.GetName())
+		l.add(p, "name", strVal(m.GetName()))
+		l.add(p, "state/name", strVal(m.GetName()))
+		l.add(p, "state/transmit", boolVal(m.GetTransmit() == otg.FlowMetric_Transmit_started))
+		l.add(p, "state/loss-pct", floatVal(m.GetLoss()))
+		l.add(p, "state/in-frame-rate", floatVal(m.GetFramesRxRate()))
+		l.add(p, "state/out-frame-rate", floatVal(m.GetFramesTxRate()))
+		l.add(p, "state/counters/in-pkts", uintVal(m.GetFramesRx()))
+		l.add(p, "state/counters/out-pkts", uintVal(m.GetFramesTx()))
+		l.add(p, "state/counters/in-octets", uintVal(m.GetBytesRx()))
+		l.add(p, "state/counters/out-octets", uintVal(m.GetBytesTx()))
+	}
+}
+
+func addLags(l leaves, metrics []*otg.LagMetric) {
+	for _, m := range metrics {
+		p := entry(nil, "lags", "lag", "name", m.GetName())
+		l.add(p, "name", strVal(m.GetName()))
+		l.add(p, "state/name", strVal(m.GetName()))
+		l.add(p, "state/oper-status", upDown(m.GetOperStatus() == otg.LagMetric_OperStatus_up))
+		l.add(p, "state/counters/member-ports-up", uintVal(uint64(m.GetMemberPortsUp())))
+		l.add(p, "state/counters/in-frames", uintVal(m.GetFramesRx()))
+		l.add(p, "state/counters/out-frames", uintVal(m.GetFramesTx()))
+		l.add(p, "state/counters/in-octets", uintVal(m.GetBytesRx()))
+		l.add(p, "state/counters/out-octets", uintVal(m.GetBytesTx()))
+	}
+}
+
+func addLacp(l leaves, metrics []*otg.LacpMetric) {
+	lacp := &gpb.Path{Elem: []*gpb.PathElem{{Name: "lacp"}}}
+	for _, m := range metrics {
+		p := entry(lacp, "lag-members", "lag-member", "name", m.GetLagMemberPortName())
+		l.add(p, "name", strVal(m.GetLagMemberPortName()))
+		l.add(p, "state/name", strVal(m.GetLagMemberPortName()))
+		syncState := "OUT_SYNC"
+		if m.GetSynchronization() == otg.LacpMetric_Synchronization_in_sync {
+			syncState = "IN_SYNC"
+		}
+		l.add(p, "state/synchronization", strVal(syncState))
+		activity := "PASSIVE"
+		if m.GetActivity() == otg.LacpMetric_Activity_active {
+			activity = "ACTIVE"
+		}
+		l.add(p, "state/activity", strVal(activity))
+		timeout := "LONG"
+		if m.GetTimeout() == otg.LacpMetric_Timeout_short {
+			timeout = "SHORT"
+		}
+		l.add(p, "state/timeout", strVal(timeout))
+		l.add(p, "state/aggregatable", boolVal(m.GetAggregatable()))
+		l.add(p, "state/collecting", boolVal(m.GetCollecting()))
+		l.add(p, "state/distributing", boolVal(m.GetDistributing()))
+		l.add(p, "state/system-id", strVal(m.GetSystemId()))
+		l.add(p, "state/oper-key", uintVal(uint64(m.GetOperKey())))
+		l.add(p, "state/partner-id", strVal(m.GetPartnerId()))
+		l.add(p, "state/partner-key", uintVal(uint64(m.GetPartnerKey())))
+		l.add(p, "state/port-num", uintVal(uint64(m.GetPortNum())))
+		l.add(p, "state/partner-port-num", uintVal(uint64(m.GetPartnerPortNum())))
+		l.add(p, "state/counters/lacp-in-pkts", uintVal(m.GetLacpPacketsRx()))
+		l.add(p, "state/counters/lacp-out-pkts", uintVal(m.GetLacpPacketsTx()))
+		l.add(p, "state/counters/lacp-rx-errors", uintVal(m.GetLacpRxErrors()))
+	}
+}
+
+// bgpMetric is what BGPv4 and BGPv6 peer metrics have in common.
+type bgpMetric interface {
+	GetName() string
+	GetRoutesAdvertised() uint64
+	GetRoutesReceived() uint64
+	GetRouteWithdrawsSent() uint64
+	GetRouteWithdrawsReceived() uint64
+	GetUpdatesSent() uint64
+	GetUpdatesReceived() uint64
+	GetOpensSent() uint64
+	GetOpensReceived() uint64
+	GetKeepalivesSent() uint64
+	GetKeepalivesReceived() uint64
+	GetNotificationsSent() uint64
+	GetNotificationsReceived() uint64
+}
+
+func addBgpPeer(l leaves, m bgpMetric, up bool) {
+	p := entry(nil, "bgp-peers", "bgp-peer", "name", m.GetName())
+	l.add(p, "name", strVal(m.GetName()))
+	l.add(p, "state/name", strVal(m.GetName()))
+	state := "IDLE"
+	if up {
+		state = "ESTABLISHED"
+	}
+	l.add(p, "state/session-state", strVal(state))
+	l.add(p, "state/counters/in-routes", uintVal(m.GetRoutesReceived()))
+	l.add(p, "state/counters/out-routes", uintVal(m.GetRoutesAdvertised()))
+	l.add(p, "state/counters/in-route-withdraw", uintVal(m.GetRouteWithdrawsReceived()))
+	l.add(p, "state/counters/out-route-withdraw", uintVal(m.GetRouteWithdrawsSent()))
+	l.add(p, "state/counters/in-updates", uintVal(m.GetUpdatesReceived()))
+	l.add(p, "state/counters/out-updates", uintVal(m.GetUpdatesSent()))
+	l.add(p, "state/counters/in-opens", uintVal(m.GetOpensReceived()))
+	l.add(p, "state/counters/out-opens", uintVal(m.GetOpensSent()))
+	l.add(p, "state/counters/in-keepalives", uintVal(m.GetKeepalivesReceived()))
+	l.add(p, "state/counters/out-keepalives", uintVal(m.GetKeepalivesSent()))
+	l.add(p, "state/counters/in-notifications", uintVal(m.GetNotificationsReceived()))
+	l.add(p, "state/counters/out-notifications", uintVal(m.GetNotificationsSent()))
+}
+
+func addBgpv4(l leaves, metrics []*otg.Bgpv4Metric) {
+	for _, m := range metrics {
+		addBgpPeer(l, m, m.GetSessionState() == otg.Bgpv4Metric_SessionState_up)
+	}
+}
+
+func addBgpv6(l leaves, metrics []*otg.Bgpv6Metric) {
+	for _, m := range metrics {
+		addBgpPeer(l, m, m.GetSessionState() == otg.Bgpv6Metric_SessionState_up)
+	}
+}
+
+// addNeighbor adds an IPv4 or IPv6 neighbor of an interface. Unresolved
+// neighbors have no link-layer-address, the way the OTG service reports them.
+func addNeighbor(l leaves, ethName, family, addr, mac string) {
+	intf := entry(nil, "interfaces", "interface", "name", ethName)
+	l.add(intf, "name", strVal(ethName))
+	p := entry(intf, family+"-neighbors", family+"-neighbor", family+"-address", addr)
+	l.add(p, family+"-address", strVal(addr))
+	l.add(p, "state/"+family+"-address", strVal(addr))
+	if mac != "" {
+		l.add(p, "state/link-layer-address", strVal(mac))
+	}
+}
+
+func addIpv4Neighbors(l leaves, states []*otg.Neighborsv4State) {
+	for _, n := range states {
+		addNeighbor(l, n.GetEthernetName(), "ipv4", n.GetIpv4Address(), n.GetLinkLayerAddress())
+	}
+}
+
+func addIpv6Neighbors(l leaves, states []*otg.Neighborsv6State) {
+	for _, n := range states {
+		addNeighbor(l, n.GetEthernetName(), "ipv6", n.GetIpv6Address(), n.GetLinkLayerAddress())
+	}
+}
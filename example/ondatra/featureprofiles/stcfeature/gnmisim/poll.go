@@ -0,0 +1,134 @@
+package gnmisim
+
+import (
+	"github.com/open-traffic-generator/snappi/gosnappi"
+	"github.com/open-traffic-generator/snappi/gosnappi/otg"
+)
+
+// source is one metrics or states request the server polls.
+type source struct {
+	name string
+	// used reports whether the config has anything to poll for.
+	used func(config gosnappi.Config) bool
+	poll func(api gosnappi.Api) (leaves, error)
+}
+
+var sources = []source{
+	{"flow", hasFlows, pollMetrics(func(req gosnappi.MetricsRequest) { req.Flow() }, func(l leaves, m *otg.MetricsResponse) {
+		addFlows(l, m.GetFlowMetrics())
+	})},
+	{"port", hasPorts, pollMetrics(func(req gosnappi.MetricsRequest) { req.Port() }, func(l leaves, m *otg.MetricsResponse) {
+		addPorts(l, m.GetPortMetrics())
+	})},
+	{"lag", hasLags, pollMetrics(func(req gosnappi.MetricsRequest) { req.Lag() }, func(l leaves, m *otg.MetricsResponse) {
+		addLags(l, m.GetLagMetrics())
+	})},
+	{"lacp", hasLacp, pollMetrics(func(req gosnappi.MetricsRequest) { req.Lacp() }, func(l leaves, m *otg.MetricsResponse) {
+		addLacp(l, m.GetLacpMetrics())
+	})},
+	{"bgpv4", hasBgpv4, pollMetrics(func(req gosnappi.MetricsRequest) { req.Bgpv4() }, func(l leaves, m *otg.MetricsResponse) {
+		addBgpv4(l, m.GetBgpv4Metrics())
+	})},
+	{"bgpv6", hasBgpv6, pollMetrics(func(req gosnappi.MetricsRequest) { req.Bgpv6() }, func(l leaves, m *otg.MetricsResponse) {
+		addBgpv6(l, m.GetBgpv6Metrics())
+	})},
+	{"ipv4-neighbors", hasIpv4, pollStates(func(req gosnappi.StatesRequest) { req.Ipv4Neighbors() }, func(l leaves, s *otg.StatesResponse) {
+		addIpv4Neighbors(l, s.GetIpv4Neighbors())
+	})},
+	{"ipv6-neighbors", hasIpv6, pollStates(func(req gosnappi.StatesRequest) { req.Ipv6Neighbors() }, func(l leaves, s *otg.StatesResponse) {
+		addIpv6Neighbors(l, s.GetIpv6Neighbors())
+	})},
+}
+
+// pollMetrics returns a poll func for a metrics request. Leaves are built from
+// the proto form of the response, whose getters return zero for fields the
+// service left out.
+func pollMetrics(request func(gosnappi.MetricsRequest), add func(leaves, *otg.MetricsResponse)) func(gosnappi.Api) (leaves, error) {
+	return func(api gosnappi.Api) (leaves, error) {
+		req := gosnappi.NewMetricsRequest()
+		request(req)
+		resp, err := api.GetMetrics(req)
+		if err != nil {
+			return nil, err
+		}
+		msg, err := resp.Marshal().ToProto()
+		if err != nil {
+			return nil, err
+		}
+		l := leaves{}
+		add(l, msg)
+		return l, nil
+	}
+}
+
+// pollStates returns a poll func for a states request.
+func pollStates(request func(gosnappi.StatesRequest), add func(leaves, *otg.StatesResponse)) func(gosnappi.Api) (leaves, error) {
+	return func(api gosnappi.Api) (leaves, error) {
+		req := gosnappi.NewStatesRequest()
+		request(req)
+		resp, err := api.GetStates(req)
+		if err != nil {
+			return nil, err
+		}
+		msg, err := resp.Marshal().ToProto()
+		if err != nil {
+			return nil, err
+		}
+		l := leaves{}
+		add(l, msg)
+		return l, nil
+	}
+}
+
+func hasFlows(c gosnappi.Config) bool { return len(c.Flows().Items()) > 0 }
+func hasPorts(c gosnappi.Config) bool { return len(c.Ports().Items()) > 0 }
+func hasLags(c gosnappi.Config) bool  { return len(c.Lags().Items()) > 0 }
+
+func hasLacp(c gosnappi.Config) bool {
+	for _, lag := range c.Lags().Items() {
+		if lag.Protocol().Choice() == gosnappi.LagProtocolChoice.LACP {
+			return true
+		}
+	}
+	return false
+}
+
+func hasBgpv4(c gosnappi.Config) bool {
+	for _, d := range c.Devices().Items() {
+		if d.HasBgp() && len(d.Bgp().Ipv4Interfaces().Items()) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func hasBgpv6(c gosnappi.Config) bool {
+	for _, d := range c.Devices().Items() {
+		if d.HasBgp() && len(d.Bgp().Ipv6Interfaces().Items()) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func hasIpv4(c gosnappi.Config) bool {
+	for _, d := range c.Devices().Items() {
+		for _, eth := range d.Ethernets().Items() {
+			if len(eth.Ipv4Addresses().Items()) > 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func hasIpv6(c gosnappi.Config) bool {
+	for _, d := range c.Devices().Items() {
+		for _, eth := range d.Ethernets().Items() {
+			if len(eth.Ipv6Addresses().Items()) > 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
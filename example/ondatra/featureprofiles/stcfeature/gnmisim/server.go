@@ -0,0 +1,363 @@
+// Package gnmisim is a gNMI target that serves the OTG telemetry model from
+// the metrics and states of an OTG service, e.g. the simulated one of the
+// gosnappi examples (example/gosnappi/otgsim). Pointing the gnmi target of a
+// binding at it lets tests that use gnmi.Get/Watch on ports, flows, LAGs,
+// LACP members, neighbors and BGP peers run without the STC gNMI service.
+package gnmisim
+
+import (
+	"context"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/open-traffic-generator/snappi/gosnappi"
+	"github.com/openconfig/featureprofiles/stcfeature/gnmireplay"
+	gpb "github.com/openconfig/gnmi/proto/gnmi"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// subscriberQueue is how many notifications a STREAM subscription may fall
+// behind before it is closed.
+const subscriberQueue = 4096
+
+// Server polls an OTG service and serves what it finds over gNMI.
+type Server struct {
+	gpb.UnimplementedGNMIServer
+
+	Api gosnappi.Api
+	// Interval between polls, 1s when zero. Get, ONCE and POLL requests poll
+	// first if the last poll is older than that.
+	Interval time.Duration
+	// Logf, if set, receives poll errors, e.g. log.Printf.
+	Logf func(format string, args ...any)
+
+	mu sync.Mutex
+	// polled holds the last successful poll of every source.
+	polled  map[string]leaves
+	current map[string]*gpb.Notification
+	updated time.Time
+	subs    map[*subscriber]bool
+	grpc    *grpc.Server
+	cancel  context.CancelFunc
+}
+
+// New returns a server for the OTG service behind api.
+func New(api gosnappi.Api) *Server {
+	return &Server{
+		Api:     api,
+		polled:  map[string]leaves{},
+		current: map[string]*gpb.Notification{},
+		subs:    map[*subscriber]bool{},
+	}
+}
+
+func (s *Server) logf(format string, args ...any) {
+	if s.Logf != nil {
+		s.Logf(format, args...)
+	}
+}
+
+func (s *Server) interval() time.Duration {
+	if s.Interval == 0 {
+		return time.Second
+	}
+	return s.Interval
+}
+
+// Serve polls the OTG service and serves the gNMI API on lis until Stop is
+// called.
+func (s *Server) Serve(lis net.Listener) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	s.mu.Lock()
+	s.grpc = grpc.NewServer()
+	gpb.RegisterGNMIServer(s.grpc, s)
+	s.cancel = cancel
+	srv := s.grpc
+	s.mu.Unlock()
+	go s.run(ctx)
+	return srv.Serve(lis)
+}
+
+// Stop stops polling and serving.
+func (s *Server) Stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.cancel != nil {
+		s.cancel()
+	}
+	if s.grpc != nil {
+		s.grpc.Stop()
+	}
+}
+
+func (s *Server) run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval())
+	defer ticker.Stop()
+	for {
+		if err := s.Update(); err != nil {
+			s.logf("gnmisim: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Update polls the OTG service once and sends what changed to the
+// subscribers. A source that fails keeps its previous values; only failing to
+// get the config is returned.
+func (s *Server) Update() error {
+	config, err := s.Api.GetConfig()
+	if err != nil {
+		return err
+	}
+	polled := map[string]leaves{}
+	for _, src := range sources {
+		if !src.used(config) {
+			continue
+		}
+		l, err := src.poll(s.Api)
+		if err != nil {
+			s.logf("gnmisim: poll %s: %v", src.name, err)
+			s.mu.Lock()
+			if prev, ok := s.polled[src.name]; ok {
+				polled[src.name] = prev
+			}
+			s.mu.Unlock()
+			continue
+		}
+		polled[src.name] = l
+	}
+	s.apply(polled, time.Now())
+	return nil
+}
+
+// fresh polls if the last poll is older than the interval.
+func (s *Server) fresh() {
+	s.mu.Lock()
+	stale := time.Since(s.updated) >= s.interval()
+	s.mu.Unlock()
+	if stale {
+		if err := s.Update(); err != nil {
+			s.logf("gnmisim: %v", err)
+		}
+	}
+}
+
+// apply makes polled the current state. Leaves that changed get the new
+// timestamp and are sent to the subscribers, with deletes for leaves that are
+// gone.
+func (s *Server) apply(polled map[string]leaves, now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ts := now.UnixNano()
+	next := map[string]*gpb.Notification{}
+	changed := []*gpb.Notification{}
+	for _, l := range polled {
+		for key, u := range l {
+			if old, ok := s.current[key]; ok && proto.Equal(old.GetUpdate()[0], u) {
+				next[key] = old
+				continue
+			}
+			n := &gpb.Notification{Timestamp: ts, Update: []*gpb.Update{u}}
+			next[key] = n
+			changed = append(changed, n)
+		}
+	}
+	for key, old := range s.current {
+		if _, ok := next[key]; !ok {
+			changed = append(changed, &gpb.Notification{Timestamp: ts, Delete: []*gpb.Path{old.GetUpdate()[0].GetPath()}})
+		}
+	}
+	s.polled = polled
+	s.current = next
+	s.updated = now
+	for sub := range s.subs {
+		for _, n := range changed {
+			if !sub.deliver(n) {
+				delete(s.subs, sub)
+				break
+			}
+		}
+	}
+}
+
+// snapshot returns the current leaves under paths, ordered by path.
+func (s *Server) snapshot(paths []*gpb.Path) []*gpb.Notification {
+	keys := []string{}
+	for key, n := range s.current {
+		if matchesAny(paths, n.GetUpdate()[0].GetPath()) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	out := []*gpb.Notification{}
+	for _, key := range keys {
+		out = append(out, s.current[key])
+	}
+	return out
+}
+
+func matchesAny(paths []*gpb.Path, path *gpb.Path) bool {
+	for _, sub := range paths {
+		if gnmireplay.Matches(sub, path) {
+			return true
+		}
+	}
+	return false
+}
+
+// subscriber is a STREAM subscription.
+type subscriber struct {
+	paths []*gpb.Path
+	ch    chan *gpb.Notification
+}
+
+// deliver queues n if it falls under the subscribed paths. It closes the
+// queue and returns false if the subscriber has fallen too far behind.
+func (sub *subscriber) deliver(n *gpb.Notification) bool {
+	for _, u := range n.GetUpdate() {
+		if !matchesAny(sub.paths, u.GetPath()) {
+			return true
+		}
+	}
+	for _, d := range n.GetDelete() {
+		if !matchesAny(sub.paths, d) {
+			return true
+		}
+	}
+	select {
+	case sub.ch <- n:
+		return true
+	default:
+		close(sub.ch)
+		return false
+	}
+}
+
+// withPrefix returns n with the origin and target of the request prefix.
+func withPrefix(n *gpb.Notification, prefix *gpb.Path) *gpb.Notification {
+	if prefix.GetOrigin() == "" && prefix.GetTarget() == "" {
+		return n
+	}
+	out := proto.Clone(n).(*gpb.Notification)
+	out.Prefix = &gpb.Path{Origin: prefix.GetOrigin(), Target: prefix.GetTarget()}
+	return out
+}
+
+func (s *Server) Capabilities(ctx context.Context, _ *gpb.CapabilityRequest) (*gpb.CapabilityResponse, error) {
+	return &gpb.CapabilityResponse{
+		SupportedEncodings: []gpb.Encoding{gpb.Encoding_PROTO, gpb.Encoding_JSON, gpb.Encoding_JSON_IETF},
+		GNMIVersion:        "0.10.0",
+	}, nil
+}
+
+func (s *Server) Get(ctx context.Context, req *gpb.GetRequest) (*gpb.GetResponse, error) {
+	paths := []*gpb.Path{}
+	for _, p := range req.GetPath() {
+		paths = append(paths, gnmireplay.Join(&gpb.Path{Elem: req.GetPrefix().GetElem()}, p))
+	}
+	s.fresh()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	resp := &gpb.GetResponse{}
+	for _, n := range s.snapshot(paths) {
+		resp.Notification = append(resp.Notification, withPrefix(n, req.GetPrefix()))
+	}
+	return resp, nil
+}
+
+func (s *Server) Subscribe(stream gpb.GNMI_SubscribeServer) error {
+	req, err := stream.Recv()
+	if err != nil {
+		return err
+	}
+	list := req.GetSubscribe()
+	if list == nil {
+		return status.Error(codes.InvalidArgument, "first request must be a subscription list")
+	}
+	paths := []*gpb.Path{}
+	for _, sub := range list.GetSubscription() {
+		paths = append(paths, gnmireplay.Join(&gpb.Path{Elem: list.GetPrefix().GetElem()}, sub.GetPath()))
+	}
+
+	send := func(n *gpb.Notification) error {
+		return stream.Send(&gpb.SubscribeResponse{Response: &gpb.SubscribeResponse_Update{Update: withPrefix(n, list.GetPrefix())}})
+	}
+	sendState := func(state []*gpb.Notification) error {
+		if !list.GetUpdatesOnly() {
+			for _, n := range state {
+				if err := send(n); err != nil {
+					return err
+				}
+			}
+		}
+		return stream.Send(&gpb.SubscribeResponse{Response: &gpb.SubscribeResponse_SyncResponse{SyncResponse: true}})
+	}
+	current := func() []*gpb.Notification {
+		s.fresh()
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		return s.snapshot(paths)
+	}
+
+	switch list.GetMode() {
+	case gpb.SubscriptionList_ONCE:
+		return sendState(current())
+	case gpb.SubscriptionList_POLL:
+		if err := sendState(current()); err != nil {
+			return err
+		}
+		for {
+			req, err := stream.Recv()
+			if err != nil {
+				return err
+			}
+			if req.GetPoll() == nil {
+				return status.Error(codes.InvalidArgument, "expected a poll request")
+			}
+			if err := sendState(current()); err != nil {
+				return err
+			}
+		}
+	}
+
+	// Take the snapshot and register under one lock, so no change falls
+	// between them.
+	s.fresh()
+	sub := &subscriber{paths: paths, ch: make(chan *gpb.Notification, subscriberQueue)}
+	s.mu.Lock()
+	state := s.snapshot(paths)
+	s.subs[sub] = true
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.subs, sub)
+		s.mu.Unlock()
+	}()
+
+	if err := sendState(state); err != nil {
+		return err
+	}
+	ctx := stream.Context()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case n, ok := <-sub.ch:
+			if !ok {
+				return status.Error(codes.ResourceExhausted, "subscriber fell too far behind")
+			}
+			if err := send(n); err != nil {
+				return err
+			}
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package gnmisim
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/open-traffic-generator/snappi/gosnappi/otg"
+	gpb "github.com/openconfig/gnmi/proto/gnmi"
+	"github.com/openconfig/ygot/ygot"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/protobuf/proto"
+)
+
+func mustPath(t *testing.T, s string) *gpb.Path {
+	t.Helper()
+	p, err := ygot.StringToStructuredPath(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func lagLeaves(up bool) leaves {
+	status := otg.LagMetric_OperStatus_down
+	if up {
+		status = otg.LagMetric_OperStatus_up
+	}
+	l := leaves{}
+	addLags(l, []*otg.LagMetric{{Name: ptr("lag1"), OperStatus: &status}})
+	return l
+}
+
+func TestLeaves(t *testing.T) {
+	l := leaves{}
+	link := otg.PortMetric_Link_up
+	addPorts(l, []*otg.PortMetric{{Name: ptr("port1"), Link: &link, FramesTx: ptr(uint64(100))}})
+	addIpv4Neighbors(l, []*otg.Neighborsv4State{
+		{EthernetName: ptr("eth1"), Ipv4Address: ptr("192.0.2.2"), LinkLayerAddress: ptr("02:00:00:00:00:02")},
+		{EthernetName: ptr("eth1"), Ipv4Address: ptr("192.0.2.3")},
+	})
+
+	tests := []struct {
+		path string
+		want *gpb.TypedValue
+	}{
+		{"/ports/port[name=port1]/state/link", strVal("UP")},
+		{"/ports/port[name=port1]/state/counters/out-frames", uintVal(100)},
+		{"/interfaces/interface[name=eth1]/ipv4-neighbors/ipv4-neighbor[ipv4-address=192.0.2.2]/state/link-layer-address", strVal("02:00:00:00:00:02")},
+		{"/interfaces/interface[name=eth1]/ipv4-neighbors/ipv4-neighbor[ipv4-address=192.0.2.3]/state/link-layer-address", nil},
+	}
+	for _, tc := range tests {
+		u, ok := l[tc.path]
+		switch {
+		case tc.want == nil && ok:
+			t.Errorf("%s got %v, want no leaf for an unresolved neighbor", tc.path, u.GetVal())
+		case tc.want != nil && (!ok || !proto.Equal(u.GetVal(), tc.want)):
+			t.Errorf("%s got %v, want %v", tc.path, u.GetVal(), tc.want)
+		}
+	}
+}
+
+func ptr[T any](v T) *T { return &v }
+
+func TestSubscribe(t *testing.T) {
+	s := New(nil)
+	// Nothing polls in this test; the hour keeps Get and Subscribe from
+	// polling the missing OTG service.
+	s.Interval = time.Hour
+	s.apply(map[string]leaves{"lag": lagLeaves(false)}, time.Now())
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := grpc.NewServer()
+	gpb.RegisterGNMIServer(srv, s)
+	go srv.Serve(lis)
+	defer srv.Stop()
+	conn, err := grpc.Dial(lis.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	sub, err := gpb.NewGNMIClient(conn).Subscribe(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = sub.Send(&gpb.SubscribeRequest{Request: &gpb.SubscribeRequest_Subscribe{Subscribe: &gpb.SubscriptionList{
+		Prefix:       &gpb.Path{Target: "ate"},
+		Mode:         gpb.SubscriptionList_STREAM,
+		Subscription: []*gpb.Subscription{{Path: mustPath(t, "/lags/lag[name=lag1]/state/oper-status")}},
+	}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	next := func() *gpb.SubscribeResponse {
+		resp, err := sub.Recv()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+
+	if got := next().GetUpdate(); got.GetUpdate()[0].GetVal().GetStringVal() != "DOWN" || got.GetPrefix().GetTarget() != "ate" {
+		t.Errorf("initial update got %v, want DOWN for target ate", got)
+	}
+	if !next().GetSyncResponse() {
+		t.Fatal("want a sync response after the initial state")
+	}
+	// An unchanged poll sends nothing, the link coming up sends the change.
+	s.apply(map[string]leaves{"lag": lagLeaves(false)}, time.Now())
+	s.apply(map[string]leaves{"lag": lagLeaves(true)}, time.Now())
+	if got := next().GetUpdate().GetUpdate()[0].GetVal().GetStringVal(); got != "UP" {
+		t.Errorf("update got %q, want UP", got)
+	}
+	s.apply(map[string]leaves{}, time.Now())
+	if got := next().GetUpdate().GetDelete(); len(got) != 1 {
+		t.Errorf("got deletes %v, want the oper-status of the removed LAG", got)
+	}
+}
@@ -21,17 +21,14 @@ import (
 	"net"
 	"sort"
 	"strconv"
-	"strings"
 	"testing"
-	"text/tabwriter"
 	"time"
 
-	"github.com/google/go-cmp/cmp"
-	"github.com/google/go-cmp/cmp/cmpopts"
 	"github.com/open-traffic-generator/snappi/gosnappi"
 	"github.com/openconfig/featureprofiles/internal/attrs"
 	"github.com/openconfig/featureprofiles/internal/fptest"
 	"github.com/openconfig/featureprofiles/internal/otgutils"
+	"github.com/openconfig/featureprofiles/stcfeature/utils"
 	"github.com/openconfig/ondatra"
 	"github.com/openconfig/ondatra/gnmi"
 	"github.com/openconfig/ondatra/gnmi/oc"
@@ -89,12 +86,22 @@ var (
 	}
 
 	expectedStatus = make(map[string]any)
-	breakLinkIds   = []uint32{}
 )
 
 const (
 	lagTypeLACP   = oc.IfAggregate_AggregationType_LACP
 	lagTypeSTATIC = oc.IfAggregate_AggregationType_STATIC
+
+	// maxConvergence is the longest outage a LAG member failure or recovery
+	// may cause for the flows crossing the LAG.
+	maxConvergence = time.Second
+
+	// lbTolerance is the largest absolute deviation of a LAG member's share
+	// of the traffic from its expected share.
+	lbTolerance = 0.01
+	// lossTolerance is the largest fraction of the packets sent out of the
+	// LAG that may be lost before reaching the other LAG.
+	lossTolerance = 0.001
 )
 
 type testCase struct {
@@ -234,10 +241,13 @@ func (tc *testCase) verifyATE(t *testing.T) {
 }
 
 func (tc *testCase) verifyLoadBalance(t *testing.T) {
+	flows := len(tc.top.Flows().Items())
+	txLag := &utils.LagDistribution{Members: portIDs(tc.atePorts[4:]), Flows: flows, Tolerance: lbTolerance}
+	rxLag := &utils.LagDistribution{Members: portIDs(tc.atePorts[0:4]), Flows: flows, Tolerance: lbTolerance}
+	txLag.Snapshot(t, tc.ate.OTG())
+	rxLag.Snapshot(t, tc.ate.OTG())
 
-	beforeTrafficCounters := tc.getCounters(t, "before")
-
-	tc.ate.OTG().StartTraffic(t)
+	utils.StartTrafficWhenResolved(t, tc.ate.OTG(), tc.top, 5, 60)
 	time.Sleep(15 * time.Second)
 	tc.ate.OTG().StopTraffic(t)
 
@@ -257,9 +267,25 @@ func (tc *testCase) verifyLoadBalance(t *testing.T) {
 		}
 	}
 
-	afterTrafficCounters := tc.getCounters(t, "after")
-	tc.verifyCounterDiff(t, beforeTrafficCounters, afterTrafficCounters)
-
+	tx := txLag.Analyze(t, tc.ate.OTG())
+	rx := rxLag.Analyze(t, tc.ate.OTG())
+	t.Log(tx)
+	t.Log(rx)
+	t.Run("Ratio", func(t *testing.T) {
+		if err := tx.Check(); err != nil {
+			t.Error(err)
+		}
+	})
+	t.Run("Loss", func(t *testing.T) {
+		if rx.InPkts > tx.OutPkts {
+			t.Errorf("Traffic flow received %d packets, sent only %d",
+				rx.InPkts, tx.OutPkts)
+		}
+		if lost := int64(tx.OutPkts) - int64(rx.InPkts); float64(lost) > lossTolerance*float64(tx.OutPkts) {
+			t.Errorf("Traffic flow lost %d of %d packets, want at most %.2f%%",
+				lost, tx.OutPkts, lossTolerance*100)
+		}
+	})
 }
 
 func (tc *testCase) verifyPortOperStatus(t *testing.T, ap *ondatra.Port) {
@@ -294,6 +320,15 @@ func (tc *testCase) verifyATE2(t *testing.T) {
 	tc.verifyLAGOperStatus(t, dutSrc.Name)
 }
 
+// portIDs returns the testbed port IDs of ports.
+func portIDs(ports []*ondatra.Port) []string {
+	ids := []string{}
+	for _, p := range ports {
+		ids = append(ids, p.ID())
+	}
+	return ids
+}
+
 // sortPorts sorts the ports by the testbed port ID.
 func sortPorts(ports []*ondatra.Port) []*ondatra.Port {
 	sort.SliceStable(ports, func(i, j int) bool {
@@ -346,105 +381,11 @@ func incrementIPV6Address(t *testing.T, address string, i int, part string) stri
 	return addr.String()
 }
 
-func (tc *testCase) breakLinks(t *testing.T) {
-	for i := range breakLinkIds {
-		port := tc.atePorts[i]
-		portStateAction := gosnappi.NewControlState()
-		portStateAction.Port().Link().SetPortNames([]string{port.ID()}).SetState(gosnappi.StatePortLinkState.DOWN)
-		tc.ate.OTG().SetControlState(t, portStateAction)
-	}
-}
-
-// normalize normalizes the input values so that the output values sum
-// to 1.0 but reflect the proportions of the input.  For example,
-// input [1, 2, 3, 4] is normalized to [0.1, 0.2, 0.3, 0.4].
-func normalize(xs []uint64) (ys []float64, sum uint64) {
-	for _, x := range xs {
-		sum += x
-	}
-	ys = make([]float64, len(xs))
-	for i, x := range xs {
-		ys[i] = float64(x) / float64(sum)
-	}
-	return ys, sum
-}
-
-var approxOpt = cmpopts.EquateApprox(0 /* frac */, 0.01 /* absolute */)
-
-// portWants converts the nextHop wanted weights to per-port wanted
-// weights listed in the same order as atePorts.
-func (tc *testCase) portWants() []float64 {
-	numPorts := len(tc.atePorts[4:])
-	weights := []float64{}
-	for i := 0; i < numPorts; i++ {
-		weights = append(weights, 1/float64(numPorts))
+// setLinks returns a subtest that sets the link of ports to state.
+func (tc *testCase) setLinks(ports []*ondatra.Port, state gosnappi.StatePortLinkStateEnum) func(t *testing.T) {
+	return func(t *testing.T) {
+		utils.SetLinkState(t, tc.ate.OTG(), state, portIDs(ports)...)
 	}
-	return weights
-}
-
-func (tc *testCase) verifyCounterDiff(t *testing.T, before, after map[string]*otgtelemetry.Port_Counters) {
-	b := &strings.Builder{}
-	w := tabwriter.NewWriter(b, 0, 0, 1, ' ', 0)
-
-	fmt.Fprint(w, "Port Counter Deltas\n\n")
-	fmt.Fprint(w, "Name\tInPkts\tInOctets\tOutPkts\tOutOctets\n")
-	allInPkts := []uint64{}
-	allOutPkts := []uint64{}
-
-	for port := range before {
-		inPkts := after[port].GetInFrames() - before[port].GetInFrames()
-		allInPkts = append(allInPkts, inPkts)
-		inOctets := after[port].GetInOctets() - before[port].GetInOctets()
-		outPkts := after[port].GetOutFrames() - before[port].GetOutFrames()
-		allOutPkts = append(allOutPkts, outPkts)
-		outOctets := after[port].GetOutOctets() - before[port].GetOutOctets()
-
-		fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%d\n",
-			port,
-			inPkts, inOctets,
-			outPkts, outOctets)
-	}
-	got, outSum := normalize(allOutPkts)
-	want := tc.portWants()
-	t.Logf("outPkts normalized got: %v", got)
-	t.Logf("want: %v", want)
-	t.Run("Ratio", func(t *testing.T) {
-		if diff := cmp.Diff(want, got, approxOpt); diff != "" {
-			t.Errorf("Packet distribution ratios -want,+got:\n%s", diff)
-		}
-	})
-	t.Run("Loss", func(t *testing.T) {
-		if allInPkts[0] > outSum {
-			t.Errorf("Traffic flow received %d packets, sent only %d",
-				allOutPkts[0], outSum)
-		}
-	})
-	w.Flush()
-
-	t.Log(b)
-}
-
-func (tc *testCase) getCounters(t *testing.T, when string) map[string]*otgtelemetry.Port_Counters {
-	results := make(map[string]*otgtelemetry.Port_Counters)
-	b := &strings.Builder{}
-	w := tabwriter.NewWriter(b, 0, 0, 1, ' ', 0)
-
-	fmt.Fprint(w, "Raw Port Counters\n\n")
-	fmt.Fprint(w, "Name\tInPkts\tInOctets\tOutPkts\tOutOctets\n")
-	for _, port := range tc.atePorts[4:] {
-		state := gnmi.Get(t, tc.ate.OTG(), gnmi.OTG().Port(port.ID()).State())
-		counters := state.GetCounters()
-		results[port.Name()] = counters
-		fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%d\n",
-			port.Name(),
-			counters.GetInFrames(), counters.GetInOctets(),
-			counters.GetOutFrames(), counters.GetOutOctets())
-	}
-	w.Flush()
-
-	t.Log(b)
-
-	return results
 }
 
 func TestNegotiation(t *testing.T) {
@@ -480,17 +421,14 @@ func TestNegotiation(t *testing.T) {
 			t.Run("VerifyATE all up", tc.verifyATE)
 
 			//////////////////////////////////////////////////////////////
-			indexBreakLink := uint32(0)
-			breakLinkIds = []uint32{indexBreakLink}
-			t.Run("Break 1 link", tc.breakLinks)
-			expectedStatus[tc.atePorts[indexBreakLink].Name()] = otgtelemetry.Port_Link_DOWN
-			expectedStatus[tc.atePorts[indexBreakLink+4].Name()] = otgtelemetry.Port_Link_DOWN
+			t.Run("Break 1 link", tc.setLinks(tc.atePorts[0:1], gosnappi.StatePortLinkState.DOWN))
+			expectedStatus[tc.atePorts[0].Name()] = otgtelemetry.Port_Link_DOWN
+			expectedStatus[tc.atePorts[4].Name()] = otgtelemetry.Port_Link_DOWN
 
 			t.Run("VerifyATE 1 link broken", tc.verifyATE)
 
 			//////////////////////////////////////////////////////////////
-			breakLinkIds = []uint32{0, 1, 2, 3, 4, 5, 6, 7}
-			t.Run("Break all links", tc.breakLinks)
+			t.Run("Break all links", tc.setLinks(tc.atePorts, gosnappi.StatePortLinkState.DOWN))
 			for _, p := range tc.atePorts {
 				expectedStatus[p.Name()] = otgtelemetry.Port_Link_DOWN
 			}
@@ -498,6 +436,16 @@ func TestNegotiation(t *testing.T) {
 			expectedStatus[dutSrc.Name] = otgtelemetry.Lag_OperStatus_DOWN
 
 			t.Run("VerifyATE all links broken", tc.verifyATE)
+
+			//////////////////////////////////////////////////////////////
+			t.Run("Restore all links", tc.setLinks(tc.atePorts, gosnappi.StatePortLinkState.UP))
+			for _, p := range tc.atePorts {
+				expectedStatus[p.Name()] = otgtelemetry.Port_Link_UP
+			}
+			expectedStatus[ateDst.Name] = otgtelemetry.Lag_OperStatus_UP
+			expectedStatus[dutSrc.Name] = otgtelemetry.Lag_OperStatus_UP
+
+			t.Run("VerifyATE all links restored", tc.verifyATE)
 		})
 	}
 }
@@ -539,3 +487,66 @@ func TestTraffic(t *testing.T) {
 		})
 	}
 }
+
+// verifyLinkFlap takes one member of each LAG down and back up while traffic
+// runs and checks that the flows reconverge within maxConvergence.
+func (tc *testCase) verifyLinkFlap(t *testing.T) {
+	flows := []string{}
+	for _, f := range tc.top.Flows().Items() {
+		flows = append(flows, f.Name())
+	}
+	members := []string{tc.atePorts[0].ID(), tc.atePorts[4].ID()}
+
+	utils.WaitForNeighbors(t, tc.ate.OTG(), tc.top, 5, 60)
+	script := &utils.LinkFaultScript{
+		Events: []utils.LinkEvent{
+			{At: 5 * time.Second, Ports: members, State: gosnappi.StatePortLinkState.DOWN},
+			{At: 15 * time.Second, Ports: members, State: gosnappi.StatePortLinkState.UP},
+		},
+		Flows:  flows,
+		Settle: 10 * time.Second,
+	}
+	results := script.Run(t, tc.ate.OTG())
+	utils.LogLinkEventResults(t, results)
+
+	for _, r := range results {
+		if r.TxPkts == 0 {
+			t.Errorf("%s: no packets sent after the event", r.LinkEvent)
+		} else if r.Convergence > maxConvergence {
+			t.Errorf("%s: convergence got %v (%d packets lost), want max %v", r.LinkEvent, r.Convergence, r.LostPkts, maxConvergence)
+		}
+	}
+}
+
+func TestLinkFlap(t *testing.T) {
+	ate := ondatra.ATE(t, "ate")
+
+	for _, lagType := range []oc.E_IfAggregate_AggregationType{lagTypeSTATIC, lagTypeLACP} {
+
+		top := gosnappi.NewConfig()
+		// Clean otg with an empty config
+		ate.OTG().PushConfig(t, top)
+
+		tc := &testCase{
+			ate:      ate,
+			top:      top,
+			lagType:  lagType,
+			atePorts: sortPorts(ate.Ports()),
+			aggID1:   "001",
+			aggID2:   "002",
+		}
+		t.Run(fmt.Sprintf("LagType=%s", lagType), func(t *testing.T) {
+			tc.configureATE(t)
+
+			for _, p := range tc.atePorts {
+				expectedStatus[p.Name()] = otgtelemetry.Port_Link_UP
+			}
+			expectedStatus[ateDst.Name] = otgtelemetry.Lag_OperStatus_UP
+			expectedStatus[dutSrc.Name] = otgtelemetry.Lag_OperStatus_UP
+
+			t.Run("VerifyATE all up", tc.verifyATE)
+
+			t.Run("VerifyATE Link Flap", tc.verifyLinkFlap)
+		})
+	}
+}
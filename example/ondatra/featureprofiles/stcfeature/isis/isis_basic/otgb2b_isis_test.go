@@ -339,7 +339,7 @@ func verifyTraffic(t *testing.T, ate *ondatra.ATEDevice, c gosnappi.Config, want
 
 func sendTraffic(t *testing.T, otg *otg.OTG, c gosnappi.Config) {
 	t.Logf("Starting traffic")
-	otg.StartTraffic(t)
+	utils.StartTrafficWhenResolved(t, otg, c, 5, 60)
 	time.Sleep(trafficDuration)
 	t.Logf("Stop traffic")
 	otg.StopTraffic(t)
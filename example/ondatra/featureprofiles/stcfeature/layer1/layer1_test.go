@@ -0,0 +1,46 @@
+package layer1
+
+import (
+	"testing"
+	"time"
+
+	"github.com/open-traffic-generator/snappi/gosnappi"
+	"github.com/openconfig/featureprofiles/internal/fptest"
+	"github.com/openconfig/featureprofiles/stcfeature/utils"
+	"github.com/openconfig/ondatra"
+)
+
+// The testbed consists of 8 ATE ports cabled back-to-back. Ports are
+// configured to the speed the binding gives them, e.g. "speed: S_10GB" as in
+// testbed/b2b_1ate_4links_layer1.binding, or b2b_1ate_4links_layer1_sim.binding
+// for the simulated OTG service.
+func TestMain(m *testing.M) {
+	fptest.RunTests(m)
+}
+
+func TestLayer1Speed(t *testing.T) {
+	ate := ondatra.ATE(t, "ate")
+	otg := ate.OTG()
+
+	groups, err := utils.Layer1Groups(ate.Ports()...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(groups) == 0 {
+		t.Skip("the binding gives none of the ports a speed")
+	}
+	for _, g := range groups {
+		t.Logf("layer1 %s", g)
+	}
+
+	config := gosnappi.NewConfig()
+	for _, p := range ate.Ports() {
+		config.Ports().Add().SetName(p.ID())
+	}
+	utils.AddLayer1(config, groups)
+	utils.PushLayer1(t, otg, config, groups)
+
+	utils.AwaitLinkUp(t, otg, groups, time.Minute)
+	speeds := utils.MeasureLinkSpeed(t, otg, config, groups, 5*time.Second)
+	utils.CheckLinkSpeeds(t, speeds, 0.02)
+}
@@ -0,0 +1,227 @@
+package utils
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+	"text/tabwriter"
+
+	"github.com/openconfig/ondatra/gnmi"
+	"github.com/openconfig/ondatra/otg"
+)
+
+// PortCounters holds the packet and octet counters of one port.
+type PortCounters struct {
+	InPkts    uint64
+	InOctets  uint64
+	OutPkts   uint64
+	OutOctets uint64
+}
+
+// GetPortCounters reads the /ports/port counters of the named ports.
+func GetPortCounters(t *testing.T, otg *otg.OTG, ports []string) map[string]PortCounters {
+	t.Helper()
+	results := make(map[string]PortCounters)
+	for _, p := range ports {
+		counters := gnmi.Get(t, otg, gnmi.OTG().Port(p).State()).GetCounters()
+		results[p] = PortCounters{
+			InPkts:    counters.GetInFrames(),
+			InOctets:  counters.GetInOctets(),
+			OutPkts:   counters.GetOutFrames(),
+			OutOctets: counters.GetOutOctets(),
+		}
+	}
+	return results
+}
+
+// LagDistribution checks how the traffic sent out of a LAG spreads over its
+// member ports. Take a Snapshot before starting traffic and Analyze after
+// stopping it.
+type LagDistribution struct {
+	// Members are the port names of the LAG members.
+	Members []string
+	// Weights are the expected shares of the members, in the order of
+	// Members. They are normalized, so {1, 1, 2} expects 25%, 25% and 50%.
+	// Equal shares are expected when nil.
+	Weights []float64
+	// Flows is the number of flows hashed over the LAG. Flows hash to members
+	// whole, so a member whose expected number of flows is not a whole number
+	// gets one flow's share added to its tolerance.
+	Flows int
+	// Tolerance is the largest absolute deviation of a member's share from
+	// its expected share, e.g. 0.01 for one percentage point.
+	Tolerance float64
+
+	before map[string]PortCounters
+}
+
+// MemberStats is the traffic one LAG member carried between the snapshots.
+type MemberStats struct {
+	Name string
+	PortCounters
+	// Share is the member's fraction of the OutPkts of all members, Want the
+	// expected fraction.
+	Share float64
+	Want  float64
+	// Tolerance is the largest Deviation allowed for the member.
+	Tolerance float64
+}
+
+// Deviation is the absolute difference between the actual and expected share.
+func (m MemberStats) Deviation() float64 {
+	return math.Abs(m.Share - m.Want)
+}
+
+// LagDistributionResult holds the per-member statistics of a LAG.
+type LagDistributionResult struct {
+	Members []MemberStats
+	// InPkts and OutPkts are summed over all members.
+	InPkts  uint64
+	OutPkts uint64
+	// ChiSquare is Pearson's chi-square statistic of the member OutPkts
+	// against the expected weights, with len(Members)-1 degrees of freedom.
+	ChiSquare float64
+	// MaxDeviation is the largest Deviation of any member.
+	MaxDeviation float64
+}
+
+// Snapshot records the member counters traffic is measured from.
+func (d *LagDistribution) Snapshot(t *testing.T, otg *otg.OTG) {
+	t.Helper()
+	if err := d.validate(); err != nil {
+		t.Fatalf("LagDistribution.Snapshot: %v", err)
+	}
+	d.before = GetPortCounters(t, otg, d.Members)
+}
+
+// Analyze reads the member counters again and computes the distribution of
+// the traffic sent since the last Snapshot.
+func (d *LagDistribution) Analyze(t *testing.T, otg *otg.OTG) *LagDistributionResult {
+	t.Helper()
+	if d.before == nil {
+		t.Fatalf("LagDistribution.Analyze called without a Snapshot")
+	}
+	res, err := d.compute(d.before, GetPortCounters(t, otg, d.Members))
+	if err != nil {
+		t.Fatalf("LagDistribution.Analyze: %v", err)
+	}
+	return res
+}
+
+// validate checks that Weights, when set, has one weight per member and that
+// the weights are non-negative and not all zero.
+func (d *LagDistribution) validate() error {
+	if d.Weights == nil {
+		return nil
+	}
+	if len(d.Weights) != len(d.Members) {
+		return fmt.Errorf("%d weights for %d members", len(d.Weights), len(d.Members))
+	}
+	var sum float64
+	for i, w := range d.Weights {
+		if w < 0 || math.IsInf(w, 0) || math.IsNaN(w) {
+			return fmt.Errorf("weight %v of member %s is not a non-negative number", w, d.Members[i])
+		}
+		sum += w
+	}
+	if sum == 0 {
+		return fmt.Errorf("all member weights are zero")
+	}
+	return nil
+}
+
+// compute returns the distribution of the counter deltas between before and
+// after.
+func (d *LagDistribution) compute(before, after map[string]PortCounters) (*LagDistributionResult, error) {
+	if err := d.validate(); err != nil {
+		return nil, err
+	}
+	res := &LagDistributionResult{}
+	var weightSum float64
+	for i := range d.Members {
+		weightSum += d.weight(i)
+	}
+
+	for i, name := range d.Members {
+		m := MemberStats{
+			Name: name,
+			PortCounters: PortCounters{
+				InPkts:    after[name].InPkts - before[name].InPkts,
+				InOctets:  after[name].InOctets - before[name].InOctets,
+				OutPkts:   after[name].OutPkts - before[name].OutPkts,
+				OutOctets: after[name].OutOctets - before[name].OutOctets,
+			},
+			Want:      d.weight(i) / weightSum,
+			Tolerance: d.Tolerance,
+		}
+		if flows := m.Want * float64(d.Flows); d.Flows > 0 && math.Abs(flows-math.Round(flows)) > 1e-9 {
+			m.Tolerance += 1 / float64(d.Flows)
+		}
+		res.InPkts += m.InPkts
+		res.OutPkts += m.OutPkts
+		res.Members = append(res.Members, m)
+	}
+
+	for i := range res.Members {
+		m := &res.Members[i]
+		if res.OutPkts > 0 {
+			m.Share = float64(m.OutPkts) / float64(res.OutPkts)
+		}
+		if dev := m.Deviation(); dev > res.MaxDeviation {
+			res.MaxDeviation = dev
+		}
+		if expected := m.Want * float64(res.OutPkts); expected > 0 {
+			diff := float64(m.OutPkts) - expected
+			res.ChiSquare += diff * diff / expected
+		}
+	}
+	return res, nil
+}
+
+func (d *LagDistribution) weight(i int) float64 {
+	if d.Weights == nil {
+		return 1
+	}
+	return d.Weights[i]
+}
+
+// Check returns an error listing the members whose share deviates from the
+// expected one by more than their tolerance.
+func (r *LagDistributionResult) Check() error {
+	if r.OutPkts == 0 {
+		return fmt.Errorf("no packets sent out of the LAG members")
+	}
+	var errs []string
+	for _, m := range r.Members {
+		if m.Deviation() > m.Tolerance {
+			errs = append(errs, fmt.Sprintf("%s share got %.4f, want %.4f +- %.4f", m.Name, m.Share, m.Want, m.Tolerance))
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("LAG distribution out of tolerance (chi-square %.2f):\n%s", r.ChiSquare, strings.Join(errs, "\n"))
+	}
+	return nil
+}
+
+// String renders the per-member table.
+func (r *LagDistributionResult) String() string {
+	b := &strings.Builder{}
+	w := tabwriter.NewWriter(b, 0, 0, 1, ' ', 0)
+
+	fmt.Fprint(w, "LAG Member Distribution\n\n")
+	fmt.Fprint(w, "Name\tInPkts\tInOctets\tOutPkts\tOutOctets\tShare\tWant\tDeviation\tTolerance\n")
+	for _, m := range r.Members {
+		fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%d\t%.4f\t%.4f\t%.4f\t%.4f\n",
+			m.Name,
+			m.InPkts, m.InOctets,
+			m.OutPkts, m.OutOctets,
+			m.Share, m.Want, m.Deviation(), m.Tolerance)
+	}
+	fmt.Fprintf(w, "Total\t%d\t\t%d\t\t\t\t\t\n", r.InPkts, r.OutPkts)
+	w.Flush()
+
+	fmt.Fprintf(b, "\nChi-square: %.4f (%d degrees of freedom), max deviation: %.4f\n",
+		r.ChiSquare, len(r.Members)-1, r.MaxDeviation)
+	return b.String()
+}
@@ -0,0 +1,83 @@
+package utils
+
+import (
+	"math"
+	"testing"
+)
+
+func TestLagDistribution(t *testing.T) {
+	before := map[string]PortCounters{
+		"port5": {OutPkts: 100},
+		"port6": {OutPkts: 100},
+	}
+	after := map[string]PortCounters{
+		"port5": {OutPkts: 350},
+		"port6": {OutPkts: 850},
+	}
+
+	tests := []struct {
+		desc    string
+		dist    LagDistribution
+		wantErr bool
+	}{{
+		desc: "equal weights",
+		dist: LagDistribution{Members: []string{"port5", "port6"}, Tolerance: 0.01},
+		// 250 vs 750 packets is 0.25 off the expected 0.5 share.
+		wantErr: true,
+	}, {
+		desc: "weighted",
+		dist: LagDistribution{Members: []string{"port5", "port6"}, Weights: []float64{1, 3}, Tolerance: 0.01},
+	}, {
+		desc: "uneven flows",
+		// 4 flows cannot be split 1:2, so each member may be off by a flow.
+		dist:    LagDistribution{Members: []string{"port5", "port6"}, Weights: []float64{1, 2}, Flows: 4, Tolerance: 0.01},
+		wantErr: false,
+	}, {
+		desc: "even flows",
+		// 3 flows split 1:2 exactly, so no member gets an allowance.
+		dist:    LagDistribution{Members: []string{"port5", "port6"}, Weights: []float64{1, 2}, Flows: 3, Tolerance: 0.01},
+		wantErr: true,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			res, err := tt.dist.compute(before, after)
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Log(res)
+			if res.OutPkts != 1000 {
+				t.Errorf("OutPkts got %d, want 1000", res.OutPkts)
+			}
+			if err := res.Check(); (err != nil) != tt.wantErr {
+				t.Errorf("Check() got error %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLagDistributionChiSquare(t *testing.T) {
+	d := LagDistribution{Members: []string{"a", "b"}}
+	res, err := d.compute(map[string]PortCounters{}, map[string]PortCounters{
+		"a": {OutPkts: 40},
+		"b": {OutPkts: 60},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// (40-50)^2/50 + (60-50)^2/50
+	if want := 4.0; math.Abs(res.ChiSquare-want) > 1e-9 {
+		t.Errorf("ChiSquare got %v, want %v", res.ChiSquare, want)
+	}
+	if want := 0.1; math.Abs(res.MaxDeviation-want) > 1e-9 {
+		t.Errorf("MaxDeviation got %v, want %v", res.MaxDeviation, want)
+	}
+}
+
+func TestLagDistributionWeights(t *testing.T) {
+	for _, weights := range [][]float64{{1}, {1, 2, 3}, {1, -1}, {0, 0}} {
+		d := LagDistribution{Members: []string{"a", "b"}, Weights: weights}
+		if _, err := d.compute(map[string]PortCounters{}, map[string]PortCounters{}); err == nil {
+			t.Errorf("compute with weights %v should fail", weights)
+		}
+	}
+}
@@ -0,0 +1,244 @@
+package utils
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+	"text/tabwriter"
+	"time"
+
+	"github.com/open-traffic-generator/snappi/gosnappi"
+	"github.com/openconfig/ondatra"
+	"github.com/openconfig/ondatra/gnmi"
+	otgtelemetry "github.com/openconfig/ondatra/gnmi/otg"
+	"github.com/openconfig/ondatra/otg"
+	"github.com/openconfig/ygnmi/ygnmi"
+)
+
+// layer1Speeds maps the port speeds of bindings to OTG layer1 speeds.
+var layer1Speeds = map[ondatra.PortSpeed]gosnappi.Layer1SpeedEnum{
+	ondatra.Speed1Gb:   gosnappi.Layer1Speed.SPEED_1_GBPS,
+	ondatra.Speed10Gb:  gosnappi.Layer1Speed.SPEED_10_GBPS,
+	ondatra.Speed100Gb: gosnappi.Layer1Speed.SPEED_100_GBPS,
+	ondatra.Speed400Gb: gosnappi.Layer1Speed.SPEED_400_GBPS,
+}
+
+// speedBps returns the line rate of a layer1 speed in bits per second, 0 if
+// it is unknown.
+func speedBps(speed gosnappi.Layer1SpeedEnum) float64 {
+	switch speed {
+	case gosnappi.Layer1Speed.SPEED_10_FD_MBPS, gosnappi.Layer1Speed.SPEED_10_HD_MBPS:
+		return 10e6
+	case gosnappi.Layer1Speed.SPEED_100_FD_MBPS, gosnappi.Layer1Speed.SPEED_100_HD_MBPS:
+		return 100e6
+	case gosnappi.Layer1Speed.SPEED_1_GBPS:
+		return 1e9
+	case gosnappi.Layer1Speed.SPEED_10_GBPS:
+		return 10e9
+	case gosnappi.Layer1Speed.SPEED_25_GBPS:
+		return 25e9
+	case gosnappi.Layer1Speed.SPEED_40_GBPS:
+		return 40e9
+	case gosnappi.Layer1Speed.SPEED_50_GBPS:
+		return 50e9
+	case gosnappi.Layer1Speed.SPEED_100_GBPS:
+		return 100e9
+	case gosnappi.Layer1Speed.SPEED_200_GBPS:
+		return 200e9
+	case gosnappi.Layer1Speed.SPEED_400_GBPS:
+		return 400e9
+	case gosnappi.Layer1Speed.SPEED_800_GBPS:
+		return 800e9
+	}
+	return 0
+}
+
+// Layer1Group is a group of ports configured to the same speed by one layer1
+// of the config.
+type Layer1Group struct {
+	Name  string
+	Speed gosnappi.Layer1SpeedEnum
+	// Ports are the port IDs, which name the ports in the config.
+	Ports []string
+	// CardModels are the card models of the ports the binding reports, to
+	// tell which port type lacks the speed.
+	CardModels []string
+}
+
+func (g Layer1Group) String() string {
+	s := fmt.Sprintf("%s (%s on %s", g.Name, g.Speed, strings.Join(g.Ports, ","))
+	if len(g.CardModels) > 0 {
+		s += " of card model " + strings.Join(g.CardModels, ",")
+	}
+	return s + ")"
+}
+
+// Layer1Groups groups ports by the speed their binding gives them, one group
+// per speed named after it, e.g. layer1_10_gbps. Ports without a speed are
+// left out and auto-negotiate.
+func Layer1Groups(ports ...*ondatra.Port) ([]Layer1Group, error) {
+	groups := map[gosnappi.Layer1SpeedEnum]*Layer1Group{}
+	for _, p := range ports {
+		if p.Speed() == 0 {
+			continue
+		}
+		speed, ok := layer1Speeds[p.Speed()]
+		if !ok {
+			return nil, fmt.Errorf("port %s has binding speed %v, which has no layer1 speed", p.ID(), p.Speed())
+		}
+		g := groups[speed]
+		if g == nil {
+			g = &Layer1Group{Name: "layer1_" + strings.TrimPrefix(string(speed), "speed_"), Speed: speed}
+			groups[speed] = g
+		}
+		g.Ports = append(g.Ports, p.ID())
+		if m := p.CardModel(); m != "" && !contains(g.CardModels, m) {
+			g.CardModels = append(g.CardModels, m)
+		}
+	}
+
+	result := []Layer1Group{}
+	for _, g := range groups {
+		result = append(result, *g)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result, nil
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// AddLayer1 adds a layer1 per group to config.
+func AddLayer1(config gosnappi.Config, groups []Layer1Group) {
+	for _, g := range groups {
+		config.Layer1().Add().SetName(g.Name).SetPortNames(g.Ports).SetSpeed(g.Speed)
+	}
+}
+
+// PushLayer1 pushes config, which holds the layer1 of groups, and fails the
+// test naming the groups if the service rejects it, e.g. because a speed is
+// not available on the port type.
+func PushLayer1(t *testing.T, otg *otg.OTG, config gosnappi.Config, groups []Layer1Group) {
+	t.Helper()
+	if !t.Run("PushConfig", func(t *testing.T) { otg.PushConfig(t, config) }) {
+		names := []string{}
+		for _, g := range groups {
+			names = append(names, g.String())
+		}
+		t.Fatalf("config rejected; check the ports support the speeds of layer1 %s", strings.Join(names, ", "))
+	}
+}
+
+// AwaitLinkUp waits up to timeout in all for the /ports/port link state of
+// the ports of groups to come up, and fails the test for each port that does
+// not. Ports are checked in turn against one deadline; once it has passed,
+// the remaining ports are only checked for being up already.
+func AwaitLinkUp(t *testing.T, otg *otg.OTG, groups []Layer1Group, timeout time.Duration) {
+	t.Helper()
+	up := func(v *ygnmi.Value[otgtelemetry.E_Port_Link]) bool {
+		link, present := v.Val()
+		return present && link == otgtelemetry.Port_Link_UP
+	}
+	deadline := time.Now().Add(timeout)
+	for _, g := range groups {
+		for _, p := range g.Ports {
+			path := gnmi.OTG().Port(p).Link().State()
+			var ok bool
+			if left := time.Until(deadline); left > 0 {
+				_, ok = gnmi.Watch(t, otg, path, left, up).Await(t)
+			} else {
+				ok = up(gnmi.Lookup(t, otg, path))
+			}
+			if !ok {
+				t.Errorf("port %s of layer1 %s link not up after %s", p, g, timeout)
+			}
+		}
+	}
+}
+
+// LinkSpeed is the line rate a port measured while sending at 100% of its
+// configured speed.
+type LinkSpeed struct {
+	Port string
+	// Want is the configured speed in bits per second, Got the measured one.
+	Want float64
+	Got  float64
+}
+
+// probeSize is the frame size of the speed probe flows, in bytes.
+const probeSize = 1518
+
+// MeasureLinkSpeed runs a flow at 100% line rate out of every port of groups
+// for duration and derives the speed each port came up at from its
+// /ports/port counters, with the preamble and inter-frame gap of every frame
+// added back. OTG ports report no speed of their own, so the rate they send
+// at is the negotiated speed. The probe replaces the flows of config, which
+// is pushed again afterwards.
+func MeasureLinkSpeed(t *testing.T, otg *otg.OTG, config gosnappi.Config, groups []Layer1Group, duration time.Duration) []LinkSpeed {
+	t.Helper()
+	probe, err := config.Clone()
+	if err != nil {
+		t.Fatalf("cloning config: %v", err)
+	}
+	probe.Flows().Clear()
+	ports := []string{}
+	for _, g := range groups {
+		for _, p := range g.Ports {
+			f := probe.Flows().Add().SetName("layer1_probe_" + p)
+			f.TxRx().Port().SetTxName(p)
+			f.Size().SetFixed(probeSize)
+			f.Rate().SetPercentage(100)
+			f.Duration().FixedSeconds().SetSeconds(float32(duration.Seconds()))
+			f.Packet().Add().Ethernet()
+			ports = append(ports, p)
+		}
+	}
+	otg.PushConfig(t, probe)
+
+	before := GetPortCounters(t, otg, ports)
+	otg.StartTraffic(t)
+	// The flows stop on their own; let in-flight packets arrive.
+	time.Sleep(duration + 2*time.Second)
+	otg.StopTraffic(t)
+	after := GetPortCounters(t, otg, ports)
+	otg.PushConfig(t, config)
+
+	speeds := []LinkSpeed{}
+	for _, g := range groups {
+		for _, p := range g.Ports {
+			frames := after[p].OutPkts - before[p].OutPkts
+			octets := after[p].OutOctets - before[p].OutOctets
+			bits := float64(octets+frames*20) * 8
+			speeds = append(speeds, LinkSpeed{Port: p, Want: speedBps(g.Speed), Got: bits / duration.Seconds()})
+		}
+	}
+	return speeds
+}
+
+// CheckLinkSpeeds fails the test for each port whose measured speed is off
+// the configured one by more than tolerance, e.g. 0.05 for 5%, and logs the
+// speeds.
+func CheckLinkSpeeds(t *testing.T, speeds []LinkSpeed, tolerance float64) {
+	t.Helper()
+	b := &strings.Builder{}
+	w := tabwriter.NewWriter(b, 0, 0, 1, ' ', 0)
+
+	fmt.Fprint(w, "Link Speeds\n\n")
+	fmt.Fprint(w, "Port\tWant Mbps\tGot Mbps\n")
+	for _, s := range speeds {
+		fmt.Fprintf(w, "%s\t%.0f\t%.0f\n", s.Port, s.Want/1e6, s.Got/1e6)
+		if s.Got < s.Want*(1-tolerance) || s.Got > s.Want*(1+tolerance) {
+			t.Errorf("port %s runs at %.0f Mbps, want %.0f Mbps", s.Port, s.Got/1e6, s.Want/1e6)
+		}
+	}
+	w.Flush()
+
+	t.Log(b)
+}
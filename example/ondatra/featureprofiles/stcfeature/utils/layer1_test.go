@@ -0,0 +1,38 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/open-traffic-generator/snappi/gosnappi"
+)
+
+func TestAddLayer1(t *testing.T) {
+	for ps, speed := range layer1Speeds {
+		if speedBps(speed) == 0 {
+			t.Errorf("binding speed %v maps to layer1 speed %s without a line rate", ps, speed)
+		}
+	}
+
+	config := gosnappi.NewConfig()
+	config.Ports().Add().SetName("port1")
+	config.Ports().Add().SetName("port2")
+	groups := []Layer1Group{{
+		Name:       "layer1_10_gbps",
+		Speed:      gosnappi.Layer1Speed.SPEED_10_GBPS,
+		Ports:      []string{"port1", "port2"},
+		CardModels: []string{"FX3-100GO-T2"},
+	}}
+	AddLayer1(config, groups)
+
+	l1 := config.Layer1().Items()
+	if len(l1) != 1 || l1[0].Speed() != gosnappi.Layer1Speed.SPEED_10_GBPS || len(l1[0].PortNames()) != 2 {
+		t.Errorf("AddLayer1() got %v", config.Layer1())
+	}
+	if _, err := config.Marshal().ToJson(); err != nil {
+		t.Errorf("AddLayer1() config invalid: %v", err)
+	}
+	want := "layer1_10_gbps (speed_10_gbps on port1,port2 of card model FX3-100GO-T2)"
+	if got := groups[0].String(); got != want {
+		t.Errorf("String() got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,152 @@
+package utils
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+	"text/tabwriter"
+	"time"
+
+	"github.com/open-traffic-generator/snappi/gosnappi"
+	"github.com/openconfig/ondatra/gnmi"
+	"github.com/openconfig/ondatra/otg"
+)
+
+// LinkEvent is one step of a timed port link fault script.
+type LinkEvent struct {
+	// At is when the event fires, relative to the start of the script.
+	At time.Duration
+	// Ports are the names of the ports whose link state is set.
+	Ports []string
+	// State is the link state to set.
+	State gosnappi.StatePortLinkStateEnum
+}
+
+func (e LinkEvent) String() string {
+	return fmt.Sprintf("%s link %s", strings.Join(e.Ports, ","), e.State)
+}
+
+// LinkEventResult is a fired LinkEvent and the traffic impact measured from
+// the moment it fired until the next event (or until traffic is stopped after
+// the last one).
+type LinkEventResult struct {
+	LinkEvent
+	// Fired is when the control state was applied.
+	Fired time.Time
+	// Window is how long traffic was measured after the event.
+	Window time.Duration
+	// TxPkts and LostPkts are summed over all measured flows.
+	TxPkts   uint64
+	LostPkts uint64
+	// Convergence is the outage derived from the loss, i.e. how long it takes
+	// to send LostPkts at the tx rate seen in the window.
+	Convergence time.Duration
+}
+
+// LinkFaultScript runs a list of link events, optionally while traffic runs.
+type LinkFaultScript struct {
+	Events []LinkEvent
+	// Flows are the flows measured while the script runs. When set, traffic
+	// is started before the first event and stopped after the last one.
+	Flows []string
+	// Settle is how long to keep measuring after the last event.
+	Settle time.Duration
+}
+
+// SetLinkState sets the link of the named ports to state.
+func SetLinkState(t *testing.T, otg *otg.OTG, state gosnappi.StatePortLinkStateEnum, ports ...string) {
+	t.Helper()
+	cs := gosnappi.NewControlState()
+	cs.Port().Link().SetPortNames(ports).SetState(state)
+	otg.SetControlState(t, cs)
+}
+
+// flowCounters returns the out and in packet counters summed over flows.
+func flowCounters(t *testing.T, otg *otg.OTG, flows []string) (out, in uint64) {
+	for _, f := range flows {
+		counters := gnmi.Get(t, otg, gnmi.OTG().Flow(f).State()).GetCounters()
+		out += counters.GetOutPkts()
+		in += counters.GetInPkts()
+	}
+	return out, in
+}
+
+// Run fires the events in order of their At offset and returns one result
+// per event. Loss is attributed to the window it was counted in, so packets
+// in flight across an event boundary may shift between neighbouring results.
+func (s *LinkFaultScript) Run(t *testing.T, otg *otg.OTG) []LinkEventResult {
+	t.Helper()
+	events := append([]LinkEvent{}, s.Events...)
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].At < events[j].At
+	})
+
+	if len(s.Flows) > 0 {
+		t.Logf("Starting traffic...")
+		otg.StartTraffic(t)
+	}
+
+	results := make([]LinkEventResult, len(events))
+	start := time.Now()
+	lastOut, lastIn := flowCounters(t, otg, s.Flows)
+	for i, e := range events {
+		time.Sleep(time.Until(start.Add(e.At)))
+
+		if i > 0 {
+			out, in := flowCounters(t, otg, s.Flows)
+			results[i-1].account(out-lastOut, in-lastIn, time.Since(results[i-1].Fired))
+			lastOut, lastIn = out, in
+		}
+
+		t.Logf("%s: %s", e.At, e)
+		results[i] = LinkEventResult{LinkEvent: e, Fired: time.Now()}
+		SetLinkState(t, otg, e.State, e.Ports...)
+	}
+
+	time.Sleep(s.Settle)
+	// The last window ends when traffic stops, not after the drain below,
+	// so its packets are not spread over time nothing was sent in.
+	end := time.Now()
+	if len(s.Flows) > 0 {
+		t.Logf("Stopping traffic...")
+		otg.StopTraffic(t)
+		// Let in-flight packets arrive before the last read.
+		time.Sleep(2 * time.Second)
+	}
+	if n := len(results); n > 0 {
+		out, in := flowCounters(t, otg, s.Flows)
+		results[n-1].account(out-lastOut, in-lastIn, end.Sub(results[n-1].Fired))
+	}
+
+	return results
+}
+
+func (r *LinkEventResult) account(out, in uint64, window time.Duration) {
+	r.Window = window
+	r.TxPkts = out
+	if out > in {
+		r.LostPkts = out - in
+	}
+	if out > 0 {
+		r.Convergence = time.Duration(float64(window) * float64(r.LostPkts) / float64(out))
+	}
+}
+
+// LogLinkEventResults logs a table of the fired events and their impact.
+func LogLinkEventResults(t *testing.T, results []LinkEventResult) {
+	t.Helper()
+	b := &strings.Builder{}
+	w := tabwriter.NewWriter(b, 0, 0, 1, ' ', 0)
+
+	fmt.Fprint(w, "Link Fault Events\n\n")
+	fmt.Fprint(w, "At\tEvent\tFired\tTxPkts\tLostPkts\tConvergence\n")
+	for _, r := range results {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%d\t%s\n",
+			r.At, r.LinkEvent, r.Fired.Format("15:04:05.000"),
+			r.TxPkts, r.LostPkts, r.Convergence)
+	}
+	w.Flush()
+
+	t.Log(b)
+}
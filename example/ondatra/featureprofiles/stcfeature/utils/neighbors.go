@@ -0,0 +1,99 @@
+package utils
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/open-traffic-generator/snappi/gosnappi"
+	"github.com/openconfig/ondatra/gnmi"
+	"github.com/openconfig/ondatra/otg"
+)
+
+// gateway is an IPv4/IPv6 gateway configured on an OTG ethernet interface.
+type gateway struct {
+	ethName string
+	ip      string
+	ipv6    bool
+}
+
+func (gw gateway) String() string {
+	return fmt.Sprintf("%s (gateway %s)", gw.ethName, gw.ip)
+}
+
+// configuredGateways returns every gateway configured on the devices in c.
+func configuredGateways(c gosnappi.Config) []gateway {
+	gws := []gateway{}
+	for _, d := range c.Devices().Items() {
+		for _, eth := range d.Ethernets().Items() {
+			for _, ip := range eth.Ipv4Addresses().Items() {
+				if ip.Gateway() != "" {
+					gws = append(gws, gateway{ethName: eth.Name(), ip: ip.Gateway()})
+				}
+			}
+			for _, ip := range eth.Ipv6Addresses().Items() {
+				if ip.Gateway() != "" {
+					gws = append(gws, gateway{ethName: eth.Name(), ip: ip.Gateway(), ipv6: true})
+				}
+			}
+		}
+	}
+	return gws
+}
+
+// neighborResolved reports whether the ipv4-neighbors (or ipv6-neighbors)
+// state of the gateway's interface holds a link-layer address for it.
+func neighborResolved(t *testing.T, otg *otg.OTG, gw gateway) bool {
+	want := net.ParseIP(gw.ip)
+	if gw.ipv6 {
+		for _, v := range gnmi.LookupAll(t, otg, gnmi.OTG().Interface(gw.ethName).Ipv6NeighborAny().State()) {
+			if n, ok := v.Val(); ok && net.ParseIP(n.GetIpv6Address()).Equal(want) && n.GetLinkLayerAddress() != "" {
+				return true
+			}
+		}
+		return false
+	}
+	for _, v := range gnmi.LookupAll(t, otg, gnmi.OTG().Interface(gw.ethName).Ipv4NeighborAny().State()) {
+		if n, ok := v.Val(); ok && net.ParseIP(n.GetIpv4Address()).Equal(want) && n.GetLinkLayerAddress() != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// WaitForNeighbors polls /interfaces/interface/ipv4-neighbors and
+// ipv6-neighbors every interval seconds until every gateway configured in c
+// has a resolved link-layer address. It fails t with the interfaces that are
+// still unresolved once timeout seconds have passed.
+func WaitForNeighbors(t *testing.T, otg *otg.OTG, c gosnappi.Config, interval, timeout time.Duration) {
+	t.Helper()
+	unresolved := configuredGateways(c)
+	t.Logf("Waiting for %d gateways to resolve ...", len(unresolved))
+	err := PollStatus(t, interval, timeout, func(t *testing.T, _ []any) (bool, error) {
+		pending := []gateway{}
+		for _, gw := range unresolved {
+			if !neighborResolved(t, otg, gw) {
+				pending = append(pending, gw)
+			}
+		}
+		unresolved = pending
+		return len(unresolved) == 0, nil
+	})
+	if err != nil {
+		names := []string{}
+		for _, gw := range unresolved {
+			names = append(names, gw.String())
+		}
+		t.Fatalf("%s, unresolved interfaces: %s", err.Error(), strings.Join(names, ", "))
+	}
+}
+
+// StartTrafficWhenResolved waits for all configured gateways to resolve,
+// see WaitForNeighbors, and then starts traffic.
+func StartTrafficWhenResolved(t *testing.T, otg *otg.OTG, c gosnappi.Config, interval, timeout time.Duration) {
+	t.Helper()
+	WaitForNeighbors(t, otg, c, interval, timeout)
+	otg.StartTraffic(t)
+}